@@ -3,8 +3,11 @@ package main
 import (
 	"log"
 	"lomi-backend/config"
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/database"
+	"lomi-backend/internal/geoip"
 	"lomi-backend/internal/handlers"
+	"lomi-backend/internal/payments"
 	"lomi-backend/internal/repositories"
 	"lomi-backend/internal/routes"
 	"lomi-backend/internal/services"
@@ -19,9 +22,17 @@ func main() {
 	// 1. Load Configuration
 	cfg := config.LoadConfig()
 
+	// 1a. Load JWT signing keys (kid rotation)
+	if err := auth.Init(cfg); err != nil {
+		log.Fatal("Failed to load JWT keys: ", err)
+	}
+
 	// 2. Connect to Database (GORM)
 	database.ConnectDB(cfg)
 
+	// 2a. Create the first super admin (ADMIN_BOOTSTRAP_*, empty admin_users only)
+	services.BootstrapSuperAdmin(cfg)
+
 	// 3. Connect to Database (sqlx for wallet system)
 	database.ConnectSqlxDB(cfg)
 
@@ -39,6 +50,50 @@ func main() {
 		cfg.FirebaseServerKey,
 	)
 
+	// 6a. Initialize Gift Catalog (gifts table, cached in Redis)
+	services.InitGiftCatalogService(database.DB)
+
+	// 6b. Load payment webhook keys (signature verification per provider)
+	if err := payments.Init(cfg); err != nil {
+		log.Fatal("Failed to load payment provider keys: ", err)
+	}
+
+	// 6c. Start live chat persister (Redis history streams -> messages)
+	go services.StartLiveChatPersister()
+
+	// 6d. Start entitlement sweeper (expires gift effects)
+	go services.StartEntitlementSweeper()
+
+	// 6e. Start payment reconciler (stale pending purchases + daily report)
+	go services.StartPaymentReconciler()
+
+	// 6f. Start earnings statement generator (last month's creator statements)
+	go services.StartEarningsStatementGenerator()
+
+	// 6g. Initialize SMS sender (phone login codes)
+	if err := services.InitSMS(cfg); err != nil {
+		log.Fatal("Failed to initialize SMS sender: ", err)
+	}
+
+	// 6h. Load GeoIP database (login locations for sessions and alerts)
+	if err := geoip.Init(cfg); err != nil {
+		log.Fatal("Failed to load GeoIP database: ", err)
+	}
+
+	// 6i. Telegram initData validation (max age, Ed25519 fallback)
+	if err := services.InitTelegramAuth(cfg); err != nil {
+		log.Fatal("Failed to configure Telegram login: ", err)
+	}
+
+	// 6j. Start account purger (deletions past their 14 day grace period)
+	go services.StartAccountPurger()
+
+	// 6k. Start data exporter (builds requested exports, deletes expired ones)
+	go services.StartDataExporter()
+
+	// 6l. Resume live battle timers (rounds that were running before a restart)
+	go handlers.ResumeBattleTimers()
+
 	// 7. Initialize Wallet Dependencies
 	walletRepo := repositories.NewWalletRepository(database.SqlxDB)
 	walletService := services.NewWalletService(walletRepo)
@@ -59,6 +114,13 @@ func main() {
 		AppName:      cfg.AppName,
 		ServerHeader: "Lomi-Social",
 		Prefork:      false, // Set to true for production if needed
+
+		// Behind Caddy: c.IP() is the client from ProxyHeader (rate limits,
+		// sessions, GeoIP), but only when the request comes from the proxy
+		ProxyHeader:             cfg.ProxyHeader,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          cfg.TrustedProxies,
+		EnableIPValidation:      true,
 	})
 
 	// 9. Middleware
@@ -66,7 +128,7 @@ func main() {
 	app.Use(recover.New()) // Panic recovery
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*", // Allow all for dev, restrict in prod
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders: "Origin, Content-Type, Accept, Authorization, X-Device-ID",
 		AllowMethods: "GET, POST, HEAD, PUT, DELETE, PATCH",
 	}))
 
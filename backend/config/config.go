@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -12,6 +13,14 @@ type Config struct {
 	AppPort string
 	AppName string
 
+	// Public base URL of this API (payment callbacks, sandbox checkout links)
+	APIPublicURL string
+
+	// Reverse proxy (Caddy): the client IP is read from ProxyHeader, only on
+	// requests from TrustedProxies (comma-separated IPs or CIDRs)
+	ProxyHeader    string
+	TrustedProxies []string
+
 	// Database
 	DBHost     string
 	DBPort     string
@@ -26,6 +35,10 @@ type Config struct {
 	RedisPassword string
 	RedisDB       int
 
+	// Live chat persistence
+	LiveChatBatchSize    int
+	LiveChatStreamMaxLen int
+
 	// Storage (S3/R2)
 	S3Endpoint     string
 	S3AccessKey    string
@@ -39,14 +52,59 @@ type Config struct {
 
 	// JWT
 	JWTSecret        string
+	JWTRefreshSecret string // Signs refresh tokens only
 	JWTAccessExpiry  string
 	JWTRefreshExpiry string
+	// Key rotation: "kid:secret,kid:secret". Tokens are signed with the active
+	// kid and verified with any listed key. Empty = the single secret above.
+	JWTKeys             string
+	JWTActiveKID        string
+	JWTRefreshKeys      string
+	JWTRefreshActiveKID string
+
+	// Admin: first super admin, created when admin_users is empty
+	AdminBootstrapEmail    string
+	AdminBootstrapPassword string
+	AdminBootstrapUserID   string
 
 	// Telegram
 	TelegramBotToken string
-
-	// Google OAuth
-	GoogleClientID string
+	// Mini App initData: accepted for InitDataMaxAge after auth_date. Without
+	// the bot token it is checked by Telegram's Ed25519 signature for BotID.
+	TelegramBotID          string
+	TelegramTestEnv        bool // Telegram test environment (its own signing key)
+	TelegramInitDataMaxAge string
+
+	// Google Sign-In: ID tokens must be issued to GoogleClientID (Google) or
+	// FirebaseProjectID (Firebase Auth). Without either Google login is off.
+	GoogleClientID    string
+	FirebaseProjectID string
+
+	// SMS (phone login codes): "http" gateway or "log" (local fake)
+	SMSProvider     string
+	SMSGatewayURL   string
+	SMSGatewayToken string
+	SMSSenderID     string
+	SMSFakeFile     string // Fake sender also appends messages here (JSON lines)
+
+	// GeoIP: DB-IP Lite CSV (country or city, may be .gz) for login locations
+	GeoIPDBPath string
+
+	// Payment webhooks: HMAC secret or RSA public key (PEM) per provider
+	TelebirrWebhookSecret    string
+	TelebirrWebhookPublicKey string
+	CBEBirrWebhookSecret     string
+	CBEBirrWebhookPublicKey  string
+
+	// Payment gateways
+	PaymentGatewayURL    string // Hosted checkout base URL
+	PaymentSandbox       bool   // Serve every provider from the local sandbox gateway
+	PaymentSandboxSecret string
+	TelebirrAPIKey       string // Merchant API keys, used for order status queries
+	CBEBirrAPIKey        string
+
+	// Payouts with a net amount (ETB) at or above this need two admin approvals
+	PayoutDualApprovalETB int
 
 	// Push Notifications
 	OneSignalAppID    string
@@ -65,6 +123,11 @@ func LoadConfig() *Config {
 		AppPort: getEnv("APP_PORT", "8080"),
 		AppName: getEnv("APP_NAME", "Lomi Social API"),
 
+		APIPublicURL: getEnv("API_PUBLIC_URL", "http://localhost:8080"),
+
+		ProxyHeader:    getEnv("PROXY_HEADER", "X-Real-IP"),
+		TrustedProxies: getEnvAsList("TRUSTED_PROXIES", "127.0.0.1,::1,172.16.0.0/12"),
+
 		DBHost:     getEnv("DB_HOST", "localhost"),
 		DBPort:     getEnv("DB_PORT", "5432"),
 		DBUser:     getEnv("DB_USER", "lomi"),
@@ -77,6 +140,9 @@ func LoadConfig() *Config {
 		RedisPassword: getEnv("REDIS_PASSWORD", ""),
 		RedisDB:       getEnvAsInt("REDIS_DB", 0),
 
+		LiveChatBatchSize:    getEnvAsInt("LIVE_CHAT_BATCH_SIZE", 500),
+		LiveChatStreamMaxLen: getEnvAsInt("LIVE_CHAT_STREAM_MAXLEN", 1000),
+
 		S3Endpoint:     getEnv("S3_ENDPOINT", "localhost:9000"),
 		S3AccessKey:    getEnv("S3_ACCESS_KEY", "minioadmin"),
 		S3SecretKey:    getEnv("S3_SECRET_KEY", "minioadmin"),
@@ -88,17 +154,56 @@ func LoadConfig() *Config {
 		S3BucketVerify: getEnv("S3_BUCKET_VERIFICATIONS", "lomi-verifications"),
 
 		JWTSecret:        getEnv("JWT_SECRET", "secret"),
+		JWTRefreshSecret: getEnv("JWT_REFRESH_SECRET", ""),
 		JWTAccessExpiry:  getEnv("JWT_ACCESS_EXPIRY", "24h"),
 		JWTRefreshExpiry: getEnv("JWT_REFRESH_EXPIRY", "168h"),
 
-		TelegramBotToken: getEnv("TELEGRAM_BOT_TOKEN", ""),
+		AdminBootstrapEmail:    getEnv("ADMIN_BOOTSTRAP_EMAIL", ""),
+		AdminBootstrapPassword: getEnv("ADMIN_BOOTSTRAP_PASSWORD", ""),
+		AdminBootstrapUserID:   getEnv("ADMIN_BOOTSTRAP_USER_ID", ""),
+
+		JWTKeys:             getEnv("JWT_KEYS", ""),
+		JWTActiveKID:        getEnv("JWT_ACTIVE_KID", ""),
+		JWTRefreshKeys:      getEnv("JWT_REFRESH_KEYS", ""),
+		JWTRefreshActiveKID: getEnv("JWT_REFRESH_ACTIVE_KID", ""),
+
+		TelegramBotToken:       getEnv("TELEGRAM_BOT_TOKEN", ""),
+		TelegramBotID:          getEnv("TELEGRAM_BOT_ID", ""),
+		TelegramTestEnv:        getEnvAsBool("TELEGRAM_TEST_ENV", false),
+		TelegramInitDataMaxAge: getEnv("TELEGRAM_INIT_DATA_MAX_AGE", "1h"),
 
-		GoogleClientID: getEnv("GOOGLE_CLIENT_ID", ""),
+		GoogleClientID:    getEnv("GOOGLE_CLIENT_ID", ""),
+		FirebaseProjectID: getEnv("FIREBASE_PROJECT_ID", ""),
+
+		SMSProvider:     getEnv("SMS_PROVIDER", "log"),
+		SMSGatewayURL:   getEnv("SMS_GATEWAY_URL", ""),
+		SMSGatewayToken: getEnv("SMS_GATEWAY_TOKEN", ""),
+		SMSSenderID:     getEnv("SMS_SENDER_ID", "Lomi"),
+		SMSFakeFile:     getEnv("SMS_FAKE_FILE", ""),
+
+		GeoIPDBPath: getEnv("GEOIP_DB_PATH", ""),
+
+		TelebirrWebhookSecret:    getEnv("TELEBIRR_WEBHOOK_SECRET", ""),
+		TelebirrWebhookPublicKey: getEnv("TELEBIRR_WEBHOOK_PUBLIC_KEY", ""),
+		CBEBirrWebhookSecret:     getEnv("CBE_BIRR_WEBHOOK_SECRET", ""),
+		CBEBirrWebhookPublicKey:  getEnv("CBE_BIRR_WEBHOOK_PUBLIC_KEY", ""),
+
+		PaymentGatewayURL:    getEnv("PAYMENT_GATEWAY_URL", "https://payment.lomi.app"),
+		PaymentSandbox:       getEnvAsBool("PAYMENT_SANDBOX", false),
+		PaymentSandboxSecret: getEnv("PAYMENT_SANDBOX_SECRET", ""),
+		TelebirrAPIKey:       getEnv("TELEBIRR_API_KEY", ""),
+		CBEBirrAPIKey:        getEnv("CBE_BIRR_API_KEY", ""),
+
+		PayoutDualApprovalETB: getEnvAsInt("PAYOUT_DUAL_APPROVAL_ETB", 5000),
 
 		OneSignalAppID:    getEnv("ONESIGNAL_APP_ID", ""),
 		OneSignalAPIKey:   getEnv("ONESIGNAL_API_KEY", ""),
 		FirebaseServerKey: getEnv("FIREBASE_SERVER_KEY", ""),
 	}
+	if Cfg.JWTRefreshSecret == "" {
+		// Keep refresh tokens on a different key than access tokens
+		Cfg.JWTRefreshSecret = Cfg.JWTSecret + ":refresh"
+	}
 	return Cfg
 }
 
@@ -124,3 +229,13 @@ func getEnvAsBool(key string, fallback bool) bool {
 	}
 	return fallback
 }
+
+func getEnvAsList(key, fallback string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, fallback), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,39 @@
+-- Live PK Battles Migration
+-- Two live broadcasters link streams for a timed round; gifts to each side are scored
+
+CREATE TABLE IF NOT EXISTS live_battles (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+
+    -- Side A (inviter) and side B (opponent)
+    stream_a_id UUID NOT NULL,
+    broadcaster_a_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    stream_b_id UUID NOT NULL,
+    broadcaster_b_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    duration_seconds INT NOT NULL DEFAULT 300 CHECK (duration_seconds BETWEEN 60 AND 1800),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'active', 'finished', 'cancelled')),
+
+    -- Results (coins received by each side during the round)
+    score_a INT NOT NULL DEFAULT 0,
+    score_b INT NOT NULL DEFAULT 0,
+    gift_count_a INT NOT NULL DEFAULT 0,
+    gift_count_b INT NOT NULL DEFAULT 0,
+    winner_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    is_draw BOOLEAN DEFAULT FALSE,
+    top_supporter UUID REFERENCES users(id) ON DELETE SET NULL,
+
+    started_at TIMESTAMPTZ,
+    ends_at TIMESTAMPTZ,
+    ended_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+
+    CHECK (broadcaster_a_id <> broadcaster_b_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_live_battles_stream_a ON live_battles(stream_a_id);
+CREATE INDEX IF NOT EXISTS idx_live_battles_stream_b ON live_battles(stream_b_id);
+CREATE INDEX IF NOT EXISTS idx_live_battles_broadcaster_a ON live_battles(broadcaster_a_id);
+CREATE INDEX IF NOT EXISTS idx_live_battles_broadcaster_b ON live_battles(broadcaster_b_id);
+CREATE INDEX IF NOT EXISTS idx_live_battles_status ON live_battles(status) WHERE status IN ('pending', 'active');
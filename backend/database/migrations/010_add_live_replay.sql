@@ -0,0 +1,15 @@
+-- Live Stream Replay Migration
+-- Links a recorded VOD to the stream and puts gifts on the stream timeline
+
+ALTER TABLE live_streams ADD COLUMN IF NOT EXISTS vod_url TEXT;
+ALTER TABLE live_streams ADD COLUMN IF NOT EXISTS vod_duration_seconds INT DEFAULT 0;
+
+-- Gifts sent during a live stream (NULL for chat / profile gifts)
+ALTER TABLE gift_transactions ADD COLUMN IF NOT EXISTS live_stream_id UUID;
+
+CREATE INDEX IF NOT EXISTS idx_gift_transactions_live_stream
+ON gift_transactions(live_stream_id, created_at)
+WHERE live_stream_id IS NOT NULL;
+
+COMMENT ON COLUMN live_streams.vod_url IS 'Recorded VOD of the stream, used for replay with synchronized chat';
+COMMENT ON COLUMN gift_transactions.live_stream_id IS 'Live stream the gift was sent in. Used for replay timelines.';
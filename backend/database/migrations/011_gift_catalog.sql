@@ -0,0 +1,42 @@
+-- Gift Catalog Migration
+-- Makes the gifts table the single source of truth for the gift shop:
+-- stable slugs (the old gift_type values), seasonal availability windows,
+-- and the luxury catalog that used to be hard-coded in the API
+
+ALTER TABLE gifts ADD COLUMN IF NOT EXISTS slug VARCHAR(50);
+ALTER TABLE gifts ADD COLUMN IF NOT EXISTS available_from TIMESTAMPTZ;
+ALTER TABLE gifts ADD COLUMN IF NOT EXISTS available_until TIMESTAMPTZ;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_gifts_slug ON gifts(slug) WHERE slug IS NOT NULL;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.table_constraints
+        WHERE table_name = 'gifts' AND constraint_name = 'chk_gifts_availability_window'
+    ) THEN
+        ALTER TABLE gifts ADD CONSTRAINT chk_gifts_availability_window
+        CHECK (available_from IS NULL OR available_until IS NULL OR available_from < available_until);
+    END IF;
+END $$;
+
+-- Luxury catalog (1 LC = 0.1 ETB)
+INSERT INTO gifts (slug, name_en, name_am, coin_price, birr_value, icon_url, animation_url, sound_url, is_active, is_featured, display_order) VALUES
+    ('rose',         'Rose',         'ጽጌረዳ',         290,    29.00,    '/icons/rose.png',         '/animations/rose.json',         '/sounds/rose.mp3',         TRUE, FALSE, 1),
+    ('heart',        'Heart',        'ልብ',           499,    49.90,    '/icons/heart.png',        '/animations/heart.json',        '/sounds/heart.mp3',        TRUE, FALSE, 2),
+    ('diamond_ring', 'Diamond Ring', 'የአልማዝ ቀለበት',   999,    99.90,    '/icons/diamond_ring.png', '/animations/diamond_ring.json', '/sounds/diamond_ring.mp3', TRUE, FALSE, 3),
+    ('fireworks',    'Fireworks',    'ርችት',          1999,   199.90,   '/icons/fireworks.png',    '/animations/fireworks.json',    '/sounds/fireworks.mp3',    TRUE, FALSE, 4),
+    ('yacht',        'Yacht',        'ጀልባ',          4999,   499.90,   '/icons/yacht.png',        '/animations/yacht.json',        '/sounds/yacht.mp3',        TRUE, FALSE, 5),
+    ('sports_car',   'Sports Car',   'የስፖርት መኪና',    9999,   999.90,   '/icons/sports_car.png',   '/animations/sports_car.json',   '/sounds/sports_car.mp3',   TRUE, TRUE,  6),
+    ('private_jet',  'Private Jet',  'የግል ጄት',       29999,  2999.90,  '/icons/private_jet.png',  '/animations/private_jet.json',  '/sounds/private_jet.mp3',  TRUE, TRUE,  7),
+    ('castle',       'Castle',       'ቤተ መንግስት',     79999,  7999.90,  '/icons/castle.png',       '/animations/castle.json',       '/sounds/castle.mp3',       TRUE, TRUE,  8),
+    ('universe',     'Universe',     'ዩኒቨርስ',        149999, 14999.90, '/icons/universe.png',     '/animations/universe.json',     '/sounds/universe.mp3',     TRUE, TRUE,  9),
+    ('lomi_crown',   'Lomi Crown',   'የሎሚ ዘውድ',      299999, 29999.90, '/icons/lomi_crown.png',   '/animations/lomi_crown.json',   '/sounds/lomi_crown.mp3',   TRUE, TRUE,  10)
+ON CONFLICT (slug) WHERE slug IS NOT NULL DO NOTHING;
+
+-- Link historical gift transactions (sent with gift_id = nil UUID) to the catalog rows
+UPDATE gift_transactions gt
+SET gift_id = g.id
+FROM gifts g
+WHERE gt.gift_type = g.slug
+  AND gt.gift_id = '00000000-0000-0000-0000-000000000000';
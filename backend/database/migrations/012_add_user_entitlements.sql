@@ -0,0 +1,38 @@
+-- Gift Special Effects Migration
+-- Gifts with a special effect grant the receiver a time-limited cosmetic entitlement
+
+ALTER TABLE gifts ADD COLUMN IF NOT EXISTS effect_type VARCHAR(30)
+    CHECK (effect_type IS NULL OR effect_type IN ('profile_frame', 'crown_badge', 'highlighted_card'));
+
+CREATE TABLE IF NOT EXISTS user_entitlements (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    entitlement_type VARCHAR(30) NOT NULL CHECK (entitlement_type IN ('profile_frame', 'crown_badge', 'highlighted_card')),
+
+    source_gift_id UUID REFERENCES gifts(id) ON DELETE SET NULL,
+    source_gift_transaction_id UUID REFERENCES gift_transactions(id) ON DELETE SET NULL,
+    granted_by_id UUID REFERENCES users(id) ON DELETE SET NULL,
+
+    starts_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    is_active BOOLEAN DEFAULT TRUE,
+
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+
+    CHECK (expires_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_entitlements_user ON user_entitlements(user_id) WHERE is_active = TRUE;
+CREATE INDEX IF NOT EXISTS idx_user_entitlements_expires ON user_entitlements(expires_at) WHERE is_active = TRUE;
+
+-- One active entitlement per user and type (receiving again extends it)
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_entitlements_active_type
+ON user_entitlements(user_id, entitlement_type) WHERE is_active = TRUE;
+
+-- Effects for the top of the luxury catalog
+UPDATE gifts SET has_special_effect = TRUE, effect_type = 'highlighted_card', special_effect_duration_days = 1  WHERE slug = 'private_jet';
+UPDATE gifts SET has_special_effect = TRUE, effect_type = 'profile_frame',    special_effect_duration_days = 7  WHERE slug = 'castle';
+UPDATE gifts SET has_special_effect = TRUE, effect_type = 'profile_frame',    special_effect_duration_days = 14 WHERE slug = 'universe';
+UPDATE gifts SET has_special_effect = TRUE, effect_type = 'crown_badge',      special_effect_duration_days = 30 WHERE slug = 'lomi_crown';
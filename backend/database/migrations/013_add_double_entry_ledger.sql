@@ -0,0 +1,171 @@
+-- Double-Entry Coin Ledger Migration
+-- Replaces users.coin_balance and wallets.balance as the source of truth for coins.
+-- Both columns stay as projections of the user's ledger account and are only
+-- written by the ledger package. Amounts are integer minor units (1 LC = 1).
+
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    code VARCHAR(100) NOT NULL UNIQUE, -- 'user:<uuid>:LC' or 'system:<name>'
+    account_type VARCHAR(20) NOT NULL CHECK (account_type IN ('user', 'system')),
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    currency VARCHAR(3) NOT NULL,
+
+    balance BIGINT NOT NULL DEFAULT 0,
+    allow_negative BOOLEAN NOT NULL DEFAULT FALSE,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT ledger_accounts_non_negative CHECK (allow_negative OR balance >= 0)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ledger_accounts_user_currency
+ON ledger_accounts(user_id, currency) WHERE user_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    entry_type VARCHAR(40) NOT NULL,
+    reference_type VARCHAR(50),
+    reference_id VARCHAR(100),
+    idempotency_key VARCHAR(150) UNIQUE,
+    description TEXT,
+    metadata JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_journal_entries_reference ON journal_entries(reference_type, reference_id);
+CREATE INDEX IF NOT EXISTS idx_journal_entries_type_created ON journal_entries(entry_type, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS postings (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    journal_entry_id UUID NOT NULL REFERENCES journal_entries(id),
+    account_id UUID NOT NULL REFERENCES ledger_accounts(id),
+    currency VARCHAR(3) NOT NULL,
+    amount BIGINT NOT NULL CHECK (amount <> 0), -- positive credits the account, negative debits it
+    balance_after BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_postings_entry ON postings(journal_entry_id);
+CREATE INDEX IF NOT EXISTS idx_postings_account_created ON postings(account_id, created_at DESC);
+
+-- Every journal entry must balance per currency. Checked at commit so the
+-- postings of one entry can be inserted one by one.
+CREATE OR REPLACE FUNCTION ledger_check_entry_balanced() RETURNS TRIGGER AS $$
+DECLARE
+    unbalanced TEXT;
+BEGIN
+    SELECT currency INTO unbalanced
+    FROM postings
+    WHERE journal_entry_id = NEW.journal_entry_id
+    GROUP BY currency
+    HAVING SUM(amount) <> 0
+    LIMIT 1;
+
+    IF unbalanced IS NOT NULL THEN
+        RAISE EXCEPTION 'journal entry % does not balance in %', NEW.journal_entry_id, unbalanced;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS postings_balanced ON postings;
+CREATE CONSTRAINT TRIGGER postings_balanced
+AFTER INSERT ON postings
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW EXECUTE FUNCTION ledger_check_entry_balanced();
+
+-- Ledger history is append-only: corrections are new (reversing) entries
+CREATE OR REPLACE FUNCTION ledger_forbid_change() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS postings_append_only ON postings;
+CREATE TRIGGER postings_append_only
+BEFORE UPDATE OR DELETE ON postings
+FOR EACH ROW EXECUTE FUNCTION ledger_forbid_change();
+
+DROP TRIGGER IF EXISTS journal_entries_append_only ON journal_entries;
+CREATE TRIGGER journal_entries_append_only
+BEFORE UPDATE OR DELETE ON journal_entries
+FOR EACH ROW EXECUTE FUNCTION ledger_forbid_change();
+
+-- Activity records point at the entry that moved the coins
+ALTER TABLE coin_transactions ADD COLUMN IF NOT EXISTS journal_entry_id UUID REFERENCES journal_entries(id);
+CREATE INDEX IF NOT EXISTS idx_coin_transactions_journal_entry ON coin_transactions(journal_entry_id);
+
+-- System accounts
+INSERT INTO ledger_accounts (code, account_type, currency, allow_negative) VALUES
+    ('system:coin_sales', 'system', 'LC', TRUE),
+    ('system:gift_revenue', 'system', 'LC', TRUE),
+    ('system:spend', 'system', 'LC', TRUE),
+    ('system:rewards', 'system', 'LC', TRUE),
+    ('system:referrals', 'system', 'LC', TRUE),
+    ('system:payouts_held', 'system', 'LC', TRUE),
+    ('system:platform_credits', 'system', 'LC', TRUE),
+    ('system:opening_balance', 'system', 'LC', TRUE)
+ON CONFLICT (code) DO NOTHING;
+
+-- Opening entries: carry over users.coin_balance plus any wallets.balance
+-- (the wallet system counted 1 coin = 1.00) into one LC account per user.
+-- Runs once: users that already have a ledger account are skipped.
+DO $$
+DECLARE
+    has_wallets BOOLEAN := to_regclass('public.wallets') IS NOT NULL;
+    opening_id UUID;
+    rec RECORD;
+    entry_id UUID;
+    account_id UUID;
+    running BIGINT;
+BEGIN
+    IF has_wallets THEN
+        ALTER TABLE wallet_transactions ADD COLUMN IF NOT EXISTS journal_entry_id UUID REFERENCES journal_entries(id);
+        ALTER TABLE wallets ALTER COLUMN currency SET DEFAULT 'LC';
+    END IF;
+
+    SELECT id, balance INTO opening_id, running FROM ledger_accounts WHERE code = 'system:opening_balance';
+
+    FOR rec IN EXECUTE format($q$
+        SELECT u.id AS user_id,
+               GREATEST(COALESCE(u.coin_balance, 0), 0)::BIGINT %s AS opening
+        FROM users u
+        %s
+        WHERE NOT EXISTS (SELECT 1 FROM ledger_accounts a WHERE a.user_id = u.id AND a.currency = 'LC')
+        ORDER BY u.id
+    $q$,
+        CASE WHEN has_wallets THEN '+ GREATEST(FLOOR(COALESCE(w.balance, 0)), 0)::BIGINT' ELSE '' END,
+        CASE WHEN has_wallets THEN 'LEFT JOIN wallets w ON w.user_id = u.id' ELSE '' END)
+    LOOP
+        CONTINUE WHEN rec.opening = 0;
+
+        INSERT INTO ledger_accounts (code, account_type, user_id, currency, balance)
+        VALUES ('user:' || rec.user_id || ':LC', 'user', rec.user_id, 'LC', rec.opening)
+        RETURNING id INTO account_id;
+
+        INSERT INTO journal_entries (entry_type, reference_type, reference_id, idempotency_key, description)
+        VALUES ('opening_balance', 'user', rec.user_id::TEXT, 'opening_balance:' || rec.user_id, 'Balance carried over from coin_balance and wallet')
+        RETURNING id INTO entry_id;
+
+        running := running - rec.opening;
+        INSERT INTO postings (journal_entry_id, account_id, currency, amount, balance_after) VALUES
+            (entry_id, account_id, 'LC', rec.opening, rec.opening),
+            (entry_id, opening_id, 'LC', -rec.opening, running);
+    END LOOP;
+
+    UPDATE ledger_accounts SET balance = running, updated_at = NOW() WHERE id = opening_id;
+
+    -- Projections now mirror the ledger
+    UPDATE users u SET coin_balance = COALESCE(a.balance, 0)
+    FROM users u2
+    LEFT JOIN ledger_accounts a ON a.user_id = u2.id AND a.currency = 'LC'
+    WHERE u.id = u2.id AND u.coin_balance IS DISTINCT FROM COALESCE(a.balance, 0);
+
+    IF has_wallets THEN
+        UPDATE wallets w SET balance = COALESCE(a.balance, 0), currency = 'LC', updated_at = NOW()
+        FROM wallets w2
+        LEFT JOIN ledger_accounts a ON a.user_id = w2.user_id AND a.currency = 'LC'
+        WHERE w.id = w2.id;
+    END IF;
+END $$;
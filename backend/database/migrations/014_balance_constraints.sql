@@ -0,0 +1,10 @@
+-- Balance Constraints Migration
+-- Last line of defence against lost-update races: the database refuses any
+-- write that would leave a balance negative. NOT VALID so existing rows are
+-- not re-checked; every new write is.
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_gift_balance_non_negative;
+ALTER TABLE users ADD CONSTRAINT users_gift_balance_non_negative CHECK (gift_balance >= 0) NOT VALID;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_coin_balance_non_negative;
+ALTER TABLE users ADD CONSTRAINT users_coin_balance_non_negative CHECK (coin_balance >= 0) NOT VALID;
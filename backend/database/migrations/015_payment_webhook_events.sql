@@ -0,0 +1,34 @@
+-- Payment Webhook Events Migration
+-- Raw audit log of every payment provider callback, valid or not.
+-- A provider event is applied at most once: only one row per
+-- (provider, event_id) can reach status 'processed'.
+
+CREATE TABLE IF NOT EXISTS payment_webhook_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    provider VARCHAR(30) NOT NULL,
+    event_id VARCHAR(150), -- NULL when the payload could not be parsed
+    coin_transaction_id UUID REFERENCES coin_transactions(id),
+
+    signature_valid BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(20) NOT NULL DEFAULT 'received'
+        CHECK (status IN ('received', 'processed', 'duplicate', 'rejected', 'ignored')),
+    error TEXT,
+
+    -- Normalized fields as reported by the provider
+    event_status VARCHAR(20),
+    amount BIGINT, -- santim
+    currency VARCHAR(3),
+
+    headers JSONB NOT NULL DEFAULT '{}',
+    payload TEXT NOT NULL,
+    remote_ip VARCHAR(45),
+
+    received_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    processed_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_webhook_events_processed
+ON payment_webhook_events(provider, event_id) WHERE status = 'processed';
+
+CREATE INDEX IF NOT EXISTS idx_payment_webhook_events_tx ON payment_webhook_events(coin_transaction_id);
+CREATE INDEX IF NOT EXISTS idx_payment_webhook_events_received ON payment_webhook_events(received_at DESC);
@@ -0,0 +1,32 @@
+-- Payment Reconciliation Migration
+-- Daily per-provider summary of coin purchases for finance: what was
+-- initiated (expected birr) against what actually settled.
+
+CREATE TABLE IF NOT EXISTS payment_reconciliation_reports (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    report_date DATE NOT NULL, -- Africa/Addis_Ababa calendar day the purchases were initiated
+    provider VARCHAR(30) NOT NULL,
+
+    initiated_count INTEGER NOT NULL DEFAULT 0,
+    expected_birr DECIMAL(12,2) NOT NULL DEFAULT 0,
+    settled_count INTEGER NOT NULL DEFAULT 0,
+    settled_birr DECIMAL(12,2) NOT NULL DEFAULT 0,
+    reconciled_count INTEGER NOT NULL DEFAULT 0, -- settled by the reconciler, not a webhook
+    failed_count INTEGER NOT NULL DEFAULT 0,
+    failed_birr DECIMAL(12,2) NOT NULL DEFAULT 0,
+    expired_count INTEGER NOT NULL DEFAULT 0, -- subset of failed
+    pending_count INTEGER NOT NULL DEFAULT 0,
+    pending_birr DECIMAL(12,2) NOT NULL DEFAULT 0,
+
+    -- Coins on settled purchases vs coins actually posted to the ledger
+    coins_credited BIGINT NOT NULL DEFAULT 0,
+    ledger_coins BIGINT NOT NULL DEFAULT 0,
+    has_discrepancy BOOLEAN NOT NULL DEFAULT FALSE,
+
+    generated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    UNIQUE (report_date, provider)
+);
+
+CREATE INDEX IF NOT EXISTS idx_coin_transactions_pending_purchases
+ON coin_transactions(created_at) WHERE payment_status = 'pending';
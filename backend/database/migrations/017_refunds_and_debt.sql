@@ -0,0 +1,25 @@
+-- Refunds and Chargebacks Migration
+-- Refunding a purchase whose coins were already spent leaves the user's
+-- account negative. The ledger flags such accounts in_debt; the balance
+-- projections follow the ledger, so their non-negative checks go.
+
+ALTER TABLE ledger_accounts ADD COLUMN IF NOT EXISTS in_debt BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE ledger_accounts DROP CONSTRAINT IF EXISTS ledger_accounts_non_negative;
+ALTER TABLE ledger_accounts ADD CONSTRAINT ledger_accounts_non_negative
+    CHECK (allow_negative OR in_debt OR balance >= 0);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_accounts_in_debt ON ledger_accounts(user_id) WHERE in_debt;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_coin_balance_check;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_coin_balance_non_negative;
+
+DO $$
+BEGIN
+    IF to_regclass('public.wallets') IS NOT NULL THEN
+        ALTER TABLE wallets DROP CONSTRAINT IF EXISTS positive_balance;
+    END IF;
+END $$;
+
+-- Reconciliation report: refunded purchases are neither settled nor failed
+ALTER TABLE payment_reconciliation_reports ADD COLUMN IF NOT EXISTS refunded_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE payment_reconciliation_reports ADD COLUMN IF NOT EXISTS refunded_birr DECIMAL(12,2) NOT NULL DEFAULT 0;
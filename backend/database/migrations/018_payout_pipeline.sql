@@ -0,0 +1,72 @@
+-- Payout Pipeline Migration
+-- Payouts become a state machine:
+--   requested → held → approved → sent → settled
+--   held / approved / sent → rejected → refunded
+-- Funds are held when the payout is requested, payouts above the dual
+-- approval threshold need two different admins, approved payouts are
+-- exported in disbursement batches and rejected ones are refunded.
+-- Run with psql in autocommit mode: new enum values can't be used in the
+-- transaction that adds them.
+
+ALTER TYPE payout_status ADD VALUE IF NOT EXISTS 'requested';
+ALTER TYPE payout_status ADD VALUE IF NOT EXISTS 'held';
+ALTER TYPE payout_status ADD VALUE IF NOT EXISTS 'approved';
+ALTER TYPE payout_status ADD VALUE IF NOT EXISTS 'sent';
+ALTER TYPE payout_status ADD VALUE IF NOT EXISTS 'settled';
+ALTER TYPE payout_status ADD VALUE IF NOT EXISTS 'refunded';
+
+CREATE TABLE IF NOT EXISTS payout_batches (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    payment_method payment_method NOT NULL,
+    payout_count INTEGER NOT NULL,
+    total_net_amount DECIMAL(12,2) NOT NULL,
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE payouts ADD COLUMN IF NOT EXISTS hold_journal_entry_id UUID REFERENCES journal_entries(id);
+ALTER TABLE payouts ADD COLUMN IF NOT EXISTS requires_dual_approval BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE payouts ADD COLUMN IF NOT EXISTS first_approved_by UUID REFERENCES users(id);
+ALTER TABLE payouts ADD COLUMN IF NOT EXISTS first_approved_at TIMESTAMPTZ;
+ALTER TABLE payouts ADD COLUMN IF NOT EXISTS approved_by UUID REFERENCES users(id);
+ALTER TABLE payouts ADD COLUMN IF NOT EXISTS approved_at TIMESTAMPTZ;
+ALTER TABLE payouts ADD COLUMN IF NOT EXISTS batch_id UUID REFERENCES payout_batches(id);
+ALTER TABLE payouts ADD COLUMN IF NOT EXISTS sent_at TIMESTAMPTZ;
+ALTER TABLE payouts ADD COLUMN IF NOT EXISTS settled_at TIMESTAMPTZ;
+ALTER TABLE payouts ADD COLUMN IF NOT EXISTS rejected_by UUID REFERENCES users(id);
+ALTER TABLE payouts ADD COLUMN IF NOT EXISTS rejected_at TIMESTAMPTZ;
+ALTER TABLE payouts ADD COLUMN IF NOT EXISTS refunded_at TIMESTAMPTZ;
+
+-- The same admin can't give both approvals
+ALTER TABLE payouts DROP CONSTRAINT IF EXISTS payouts_distinct_approvers;
+ALTER TABLE payouts ADD CONSTRAINT payouts_distinct_approvers
+    CHECK (NOT requires_dual_approval OR first_approved_by IS NULL OR approved_by IS NULL OR first_approved_by <> approved_by);
+
+CREATE INDEX IF NOT EXISTS idx_payouts_batch ON payouts(batch_id) WHERE batch_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_payouts_status_created ON payouts(status, created_at);
+
+CREATE TABLE IF NOT EXISTS payout_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    payout_id UUID NOT NULL REFERENCES payouts(id) ON DELETE CASCADE,
+    from_status payout_status,
+    to_status payout_status NOT NULL,
+    actor_id UUID REFERENCES users(id), -- NULL when the system made the change
+    note TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_payout_events_payout ON payout_events(payout_id, created_at);
+
+ALTER TABLE payouts ALTER COLUMN status SET DEFAULT 'requested';
+
+-- Legacy statuses: pending payouts already had their funds taken, processing
+-- ones were handed to finance, and rejected ones were given back.
+UPDATE payouts SET status = 'held' WHERE status = 'pending';
+UPDATE payouts SET status = 'sent', sent_at = COALESCE(processed_at, updated_at) WHERE status = 'processing';
+UPDATE payouts SET status = 'settled', settled_at = COALESCE(processed_at, updated_at) WHERE status = 'completed';
+UPDATE payouts SET status = 'refunded', rejected_at = COALESCE(processed_at, updated_at),
+    refunded_at = COALESCE(processed_at, updated_at) WHERE status = 'rejected';
+
+INSERT INTO ledger_accounts (code, account_type, currency, allow_negative) VALUES
+    ('system:payouts_paid', 'system', 'LC', TRUE)
+ON CONFLICT (code) DO NOTHING;
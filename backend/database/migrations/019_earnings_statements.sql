@@ -0,0 +1,34 @@
+-- Earnings Statements Migration
+-- Monthly statement per creator: gifts received, the platform fee, payouts
+-- and the balance at both ends of the month. The CSV and PDF are archived in
+-- the gifts bucket (statements/<user_id>/<YYYY-MM>.csv|pdf).
+
+CREATE TABLE IF NOT EXISTS earnings_statements (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    period DATE NOT NULL, -- First day of the Africa/Addis_Ababa calendar month
+
+    gift_count INTEGER NOT NULL DEFAULT 0,
+    sender_count INTEGER NOT NULL DEFAULT 0,
+    gift_coins BIGINT NOT NULL DEFAULT 0,
+    gross_birr DECIMAL(12,2) NOT NULL DEFAULT 0,
+
+    platform_fee_percentage INTEGER NOT NULL,
+    platform_fee_birr DECIMAL(12,2) NOT NULL DEFAULT 0,
+    net_birr DECIMAL(12,2) NOT NULL DEFAULT 0,
+
+    payout_count INTEGER NOT NULL DEFAULT 0,
+    payouts_birr DECIMAL(12,2) NOT NULL DEFAULT 0,
+
+    opening_balance_birr DECIMAL(12,2) NOT NULL DEFAULT 0,
+    closing_balance_birr DECIMAL(12,2) NOT NULL DEFAULT 0,
+
+    csv_key VARCHAR(255),
+    pdf_key VARCHAR(255),
+
+    generated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT earnings_statements_user_period UNIQUE (user_id, period)
+);
+
+CREATE INDEX IF NOT EXISTS idx_gift_transactions_receiver_created ON gift_transactions(receiver_id, created_at);
@@ -0,0 +1,71 @@
+-- Economy Config Migration
+-- Prices and rates (coin value, platform fee, minimums, reveal prices,
+-- referral reward, coin packs) live in versioned rows instead of code. A new
+-- version takes over at its effective_from; versions are never edited.
+-- Every journal entry records the version that priced it.
+
+CREATE TABLE IF NOT EXISTS economy_configs (
+    version SERIAL PRIMARY KEY,
+    params JSONB NOT NULL,
+    effective_from TIMESTAMPTZ NOT NULL,
+    note TEXT,
+    created_by UUID REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_economy_configs_effective_from ON economy_configs(effective_from DESC);
+
+-- Version 1: the values that were hard-coded until now
+INSERT INTO economy_configs (version, params, effective_from, note)
+VALUES (1, '{
+    "coin_birr_rate": 0.1,
+    "platform_fee_percentage": 25,
+    "min_cashout_coins": 50000,
+    "min_payout_birr": 1000,
+    "min_withdrawal": 10,
+    "reveal_one_coins": 99,
+    "reveal_all_coins": 299,
+    "referral_reward_coins": 50,
+    "coin_packs": [
+        {"id": "spark", "name": "Spark", "etb_price": 55, "coins": 600},
+        {"id": "flame", "name": "Flame", "etb_price": 110, "coins": 1300},
+        {"id": "blaze", "name": "Blaze", "etb_price": 275, "coins": 3500},
+        {"id": "inferno", "name": "Inferno", "etb_price": 550, "coins": 8000},
+        {"id": "galaxy", "name": "Galaxy", "etb_price": 1100, "coins": 18000},
+        {"id": "universe", "name": "Universe", "etb_price": 5500, "coins": 100000}
+    ]
+}'::jsonb, '1970-01-01T00:00:00Z', 'Initial pricing')
+ON CONFLICT (version) DO NOTHING;
+
+SELECT setval(pg_get_serial_sequence('economy_configs', 'version'), GREATEST((SELECT MAX(version) FROM economy_configs), 1));
+
+-- Existing entries were priced by version 1. The constant default fills them
+-- without rewriting the table (and without tripping the append-only trigger).
+ALTER TABLE journal_entries ADD COLUMN IF NOT EXISTS economy_version INTEGER NOT NULL DEFAULT 1 REFERENCES economy_configs(version);
+ALTER TABLE journal_entries ALTER COLUMN economy_version DROP DEFAULT;
+
+-- Entries posted without an explicit version get the one in force
+CREATE OR REPLACE FUNCTION journal_entries_set_economy_version() RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.economy_version IS NULL THEN
+        SELECT version INTO NEW.economy_version
+        FROM economy_configs
+        WHERE effective_from <= NOW()
+        ORDER BY effective_from DESC, version DESC
+        LIMIT 1;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS journal_entries_economy_version ON journal_entries;
+CREATE TRIGGER journal_entries_economy_version
+BEFORE INSERT ON journal_entries
+FOR EACH ROW EXECUTE FUNCTION journal_entries_set_economy_version();
+
+CREATE INDEX IF NOT EXISTS idx_journal_entries_economy_version ON journal_entries(economy_version);
+
+-- Purchases and payouts are priced when they're created and posted later,
+-- so they keep the version they were priced with
+ALTER TABLE coin_transactions ADD COLUMN IF NOT EXISTS economy_version INTEGER REFERENCES economy_configs(version);
+ALTER TABLE payouts ADD COLUMN IF NOT EXISTS economy_version INTEGER REFERENCES economy_configs(version);
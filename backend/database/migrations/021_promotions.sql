@@ -0,0 +1,55 @@
+-- Coin Purchase Promotions Migration
+-- First-purchase bonuses, happy-hour discounts and promo codes. A purchase's
+-- redemptions are created with it; usage limits count every redemption whose
+-- purchase didn't fail. Bonus coins are credited from system:promotions.
+
+CREATE TABLE IF NOT EXISTS promotions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('first_purchase', 'happy_hour', 'promo_code')),
+    name VARCHAR(255) NOT NULL,
+    code VARCHAR(50) UNIQUE,
+
+    discount_percentage INTEGER NOT NULL DEFAULT 0 CHECK (discount_percentage BETWEEN 0 AND 90),
+    bonus_percentage INTEGER NOT NULL DEFAULT 0 CHECK (bonus_percentage >= 0),
+    bonus_coins INTEGER NOT NULL DEFAULT 0 CHECK (bonus_coins >= 0),
+
+    pack_ids JSONB NOT NULL DEFAULT '[]',
+
+    starts_at TIMESTAMPTZ,
+    ends_at TIMESTAMPTZ,
+
+    max_redemptions INTEGER CHECK (max_redemptions > 0),
+    max_per_user INTEGER CHECK (max_per_user > 0),
+
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by UUID REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT promotions_code_kind CHECK ((kind = 'promo_code') = (code IS NOT NULL)),
+    CONSTRAINT promotions_window CHECK (starts_at IS NULL OR ends_at IS NULL OR starts_at < ends_at),
+    CONSTRAINT promotions_has_effect CHECK (discount_percentage > 0 OR bonus_percentage > 0 OR bonus_coins > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_promotions_kind_active ON promotions(kind, is_active);
+
+CREATE TABLE IF NOT EXISTS promotion_redemptions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    promotion_id UUID NOT NULL REFERENCES promotions(id),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    coin_transaction_id UUID NOT NULL REFERENCES coin_transactions(id) ON DELETE CASCADE,
+    discount_birr DECIMAL(10,2) NOT NULL DEFAULT 0,
+    bonus_coins INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT promotion_redemptions_once_per_purchase UNIQUE (promotion_id, coin_transaction_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_promotion_redemptions_promotion_user ON promotion_redemptions(promotion_id, user_id);
+
+-- Paid coins stay in coin_amount; promotional coins are tracked apart
+ALTER TABLE coin_transactions ADD COLUMN IF NOT EXISTS bonus_coins INTEGER NOT NULL DEFAULT 0;
+
+INSERT INTO ledger_accounts (code, account_type, currency, allow_negative) VALUES
+    ('system:promotions', 'system', 'LC', TRUE)
+ON CONFLICT (code) DO NOTHING;
@@ -0,0 +1,30 @@
+-- Refresh Token Rotation Migration
+-- Refresh tokens are stored hashed, one row per issued token. Each login starts
+-- a family; refreshing uses up the row and issues the next one in the family.
+-- Presenting a used token again revokes the whole family. The access token
+-- issued alongside each refresh token is revoked with it.
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id UUID PRIMARY KEY, -- refresh_uuid claim
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id UUID NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE, -- SHA-256 of the signed token
+
+    access_uuid UUID NOT NULL,
+    access_expires_at TIMESTAMPTZ NOT NULL,
+
+    device_id VARCHAR(255),
+    user_agent TEXT,
+    ip_address VARCHAR(45),
+
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    replaced_by UUID REFERENCES refresh_tokens(id),
+    revoked_at TIMESTAMPTZ,
+    revoke_reason VARCHAR(50),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user ON refresh_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens(family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_access_uuid ON refresh_tokens(access_uuid);
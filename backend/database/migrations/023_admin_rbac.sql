@@ -0,0 +1,60 @@
+-- Admin RBAC Migration
+-- Admins log in separately (admin_users: bcrypt password, optional TOTP) and
+-- each role gets a fixed set of permissions (see models.AdminRole.Can). An
+-- admin acts on the app through a linked users row, so existing *_by columns
+-- keep referencing users(id). Every admin action lands in admin_audit_logs.
+
+CREATE TABLE IF NOT EXISTS admin_users (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    email VARCHAR(255) UNIQUE NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    full_name VARCHAR(255) NOT NULL,
+    role VARCHAR(50) DEFAULT 'moderator',
+    is_active BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+ALTER TABLE admin_users ADD COLUMN IF NOT EXISTS user_id UUID UNIQUE REFERENCES users(id);
+ALTER TABLE admin_users ADD COLUMN IF NOT EXISTS totp_secret VARCHAR(64);
+ALTER TABLE admin_users ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE admin_users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0; -- Last accepted code, no replays
+ALTER TABLE admin_users ADD COLUMN IF NOT EXISTS failed_logins INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE admin_users ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;
+ALTER TABLE admin_users ADD COLUMN IF NOT EXISTS last_login_at TIMESTAMPTZ;
+
+ALTER TABLE admin_users DROP CONSTRAINT IF EXISTS admin_users_role_check;
+ALTER TABLE admin_users ADD CONSTRAINT admin_users_role_check
+    CHECK (role IN ('moderator', 'finance', 'admin', 'super_admin'));
+
+CREATE INDEX IF NOT EXISTS idx_admin_users_role ON admin_users(role);
+
+CREATE TABLE IF NOT EXISTS admin_audit_logs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    admin_user_id UUID REFERENCES admin_users(id), -- NULL for failed logins of unknown emails
+    admin_role VARCHAR(50),
+
+    action VARCHAR(255) NOT NULL, -- Route, e.g. "POST /api/v1/admin/payouts/:id/approve"
+    path TEXT NOT NULL,
+    status_code INTEGER NOT NULL,
+
+    entity_type VARCHAR(50),
+    entity_id VARCHAR(100),
+    request JSONB, -- Body with secrets redacted
+    before JSONB,
+    after JSONB,
+
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_audit_logs_admin ON admin_audit_logs(admin_user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_logs_entity ON admin_audit_logs(entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_logs_created ON admin_audit_logs(created_at DESC);
+
+-- The audit log is append-only
+DROP TRIGGER IF EXISTS admin_audit_logs_append_only ON admin_audit_logs;
+CREATE TRIGGER admin_audit_logs_append_only
+BEFORE UPDATE OR DELETE ON admin_audit_logs
+FOR EACH ROW EXECUTE FUNCTION ledger_forbid_change();
@@ -0,0 +1,26 @@
+-- Account Linking and Merge Migration
+-- A user who signed up twice (e.g. once with Telegram, once with a phone
+-- number) can merge the second account into the first. The source account's
+-- rows are moved to the target user, its coins are moved by a ledger entry
+-- and it is soft-deleted, pointing at the account it was merged into.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS merged_into_id UUID REFERENCES users(id);
+
+CREATE TABLE IF NOT EXISTS account_merges (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    target_user_id UUID NOT NULL REFERENCES users(id),
+    source_user_id UUID NOT NULL UNIQUE REFERENCES users(id), -- An account is merged once
+
+    journal_entry_id UUID REFERENCES journal_entries(id), -- NULL when there were no coins to move
+    coins_moved BIGINT NOT NULL DEFAULT 0,
+    gift_balance_moved DECIMAL(10,2) NOT NULL DEFAULT 0,
+
+    moved_rows JSONB NOT NULL DEFAULT '{}',   -- "table.column" -> rows moved
+    skipped_rows JSONB NOT NULL DEFAULT '{}', -- Rows left on the source (target already had one)
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT account_merges_distinct_users CHECK (target_user_id <> source_user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_merges_target ON account_merges(target_user_id);
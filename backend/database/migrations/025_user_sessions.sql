@@ -0,0 +1,41 @@
+-- User Sessions Migration
+-- One row per login (refresh token family, the "sid" claim): which device and
+-- app version, where from (IP and offline GeoIP) and when it was first and
+-- last used. Users list these as "your devices" and can log one out. A login
+-- from a device or country the user hasn't used before sends a Telegram alert.
+
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id UUID PRIMARY KEY, -- refresh_tokens.family_id
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    device_id VARCHAR(255),
+    device_name VARCHAR(100),
+    platform VARCHAR(20),
+    app_version VARCHAR(50),
+    user_agent TEXT,
+
+    ip_address VARCHAR(45),
+    country_code VARCHAR(2),
+    region VARCHAR(100),
+    city VARCHAR(100),
+
+    first_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    revoke_reason VARCHAR(50)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user ON user_sessions(user_id, last_seen_at DESC);
+CREATE INDEX IF NOT EXISTS idx_user_sessions_device ON user_sessions(user_id, device_id) WHERE device_id IS NOT NULL;
+
+-- Logins from before this migration: one session per family, from its latest token
+INSERT INTO user_sessions (id, user_id, device_id, user_agent, ip_address,
+                           first_seen_at, last_seen_at, expires_at, revoked_at, revoke_reason)
+SELECT DISTINCT ON (rt.family_id)
+       rt.family_id, rt.user_id, rt.device_id, rt.user_agent, rt.ip_address,
+       (SELECT MIN(f.created_at) FROM refresh_tokens f WHERE f.family_id = rt.family_id),
+       rt.created_at, rt.expires_at, rt.revoked_at, rt.revoke_reason
+FROM refresh_tokens rt
+ORDER BY rt.family_id, rt.created_at DESC
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,20 @@
+-- Admin Impersonation Migration
+-- Support admins can act as a user to reproduce an issue. Each impersonation
+-- is recorded with its reason and issues one short-lived access token (no
+-- refresh token) flagged with impersonator_id. Ending the impersonation
+-- revokes the token. Requests made with it land in admin_audit_logs.
+
+CREATE TABLE IF NOT EXISTS admin_impersonations (
+    id UUID PRIMARY KEY, -- sid claim of the token
+    admin_user_id UUID NOT NULL REFERENCES admin_users(id),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    access_uuid UUID NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    ended_at TIMESTAMPTZ,
+    ip_address VARCHAR(45),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_impersonations_admin ON admin_impersonations(admin_user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_admin_impersonations_user ON admin_impersonations(user_id, created_at DESC);
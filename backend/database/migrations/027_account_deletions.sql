@@ -0,0 +1,47 @@
+-- Account Deletion Migration
+-- Deleting an account is a request with a 14 day grace period: the user is
+-- logged out and hidden, and logging in again cancels it. After the grace
+-- period the purger deletes the user's files and personal rows. The users row
+-- stays as an anonymous tombstone so ledger entries, gifts and payouts keep
+-- pointing at an ID that no longer identifies anyone. The account_deletions
+-- row is the deletion receipt.
+
+CREATE TABLE IF NOT EXISTS account_deletions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(), -- Receipt number given to the user
+    user_id UUID NOT NULL, -- No foreign key: the receipt outlives the account's data
+    status VARCHAR(20) NOT NULL DEFAULT 'scheduled' CHECK (status IN ('scheduled', 'canceled', 'purged')),
+    reason TEXT,
+    was_active BOOLEAN NOT NULL DEFAULT TRUE, -- Restored when the deletion is canceled
+
+    requested_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    purge_after TIMESTAMPTZ NOT NULL,
+    canceled_at TIMESTAMPTZ,
+    purged_at TIMESTAMPTZ,
+
+    objects_deleted INTEGER NOT NULL DEFAULT 0, -- S3 objects removed
+    deleted_rows JSONB NOT NULL DEFAULT '{}', -- "table.column" -> rows deleted
+    anonymized_rows JSONB NOT NULL DEFAULT '{}', -- "table" -> rows scrubbed but kept
+    retained_rows JSONB NOT NULL DEFAULT '{}', -- "table.column" -> rows kept on the tombstone (accounting)
+    last_error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- One pending deletion per account
+CREATE UNIQUE INDEX IF NOT EXISTS idx_account_deletions_user_scheduled
+ON account_deletions(user_id) WHERE status = 'scheduled';
+
+CREATE INDEX IF NOT EXISTS idx_account_deletions_due
+ON account_deletions(purge_after) WHERE status = 'scheduled';
+
+CREATE INDEX IF NOT EXISTS idx_account_deletions_user ON account_deletions(user_id, created_at DESC);
+
+-- Accounts deleted before this migration only had deleted_at set: purge them
+-- 14 days after that
+INSERT INTO account_deletions (user_id, status, reason, requested_at, purge_after)
+SELECT id, 'scheduled', 'deleted before account purging existed', deleted_at, deleted_at + INTERVAL '14 days'
+FROM users
+WHERE deleted_at IS NOT NULL AND merged_into_id IS NULL
+AND NOT EXISTS (SELECT 1 FROM account_deletions d WHERE d.user_id = users.id);
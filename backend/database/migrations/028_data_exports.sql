@@ -0,0 +1,33 @@
+-- Data Export Migration
+-- A user can download their data: an export job gathers it into a ZIP of
+-- JSON files in the gifts bucket (exports/<user_id>/<export_id>.zip), tells
+-- the user it's ready and serves it through short-lived download links until
+-- it expires. One export a day per user.
+
+CREATE TABLE IF NOT EXISTS data_exports (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'processing', 'ready', 'failed', 'expired')),
+
+    object_key VARCHAR(255),
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    files JSONB NOT NULL DEFAULT '{}', -- file name -> records in it
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+
+    requested_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ, -- The ZIP is deleted after this
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_user ON data_exports(user_id, requested_at DESC);
+CREATE INDEX IF NOT EXISTS idx_data_exports_queue ON data_exports(requested_at) WHERE status IN ('pending', 'processing');
+CREATE INDEX IF NOT EXISTS idx_data_exports_expiry ON data_exports(expires_at) WHERE status = 'ready';
+
+-- One export in the queue per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_data_exports_user_queued
+ON data_exports(user_id) WHERE status IN ('pending', 'processing');
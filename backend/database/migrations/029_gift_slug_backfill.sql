@@ -0,0 +1,13 @@
+-- Gift Slug Backfill
+-- Gifts created before the catalog (011) were left without a slug, and saving
+-- one of them from the admin wrote an empty slug, which the second such gift
+-- then collided with on idx_gifts_slug. Give every gift without a slug one made
+-- from its English name and ID, and make the column required.
+
+UPDATE gifts
+SET slug = LEFT(TRIM(BOTH '_' FROM LOWER(REGEXP_REPLACE(name_en, '[^A-Za-z0-9]+', '_', 'g'))), 36)
+    || '_' || LEFT(REPLACE(id::text, '-', ''), 8),
+    updated_at = NOW()
+WHERE slug IS NULL OR slug = '';
+
+ALTER TABLE gifts ALTER COLUMN slug SET NOT NULL;
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// LocalsKey is where the middleware stores the *Claims of the request
+// (fiber.Ctx and websocket.Conn locals alike)
+const LocalsKey = "auth"
+
+// SetClaims stores the verified claims on the request
+func SetClaims(c *fiber.Ctx, claims *Claims) {
+	c.Locals(LocalsKey, claims)
+}
+
+// ClaimsFrom returns the verified claims of the request, or ErrUnauthenticated
+func ClaimsFrom(c *fiber.Ctx) (*Claims, error) {
+	claims, ok := c.Locals(LocalsKey).(*Claims)
+	if !ok || claims == nil || claims.UserID == uuid.Nil {
+		return nil, ErrUnauthenticated
+	}
+	return claims, nil
+}
+
+// UserID returns the authenticated user's ID. It fails closed: without
+// verified claims it returns ErrUnauthenticated, never uuid.Nil and nil.
+func UserID(c *fiber.Ctx) (uuid.UUID, error) {
+	claims, err := ClaimsFrom(c)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return claims.UserID, nil
+}
+
+// Unauthorized writes the 401 response handlers return when UserID fails
+func Unauthorized(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+}
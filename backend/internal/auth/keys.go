@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// defaultKID names the key built from a single JWT secret
+const defaultKID = "default"
+
+// Keyring holds the HMAC keys of one token type. Tokens are signed with the
+// active key; any key in the ring verifies. Rotating a key: add the new one,
+// make it active, and drop the old one once its tokens have expired.
+type Keyring struct {
+	activeKID string
+	keys      map[string][]byte
+}
+
+// NewKeyring builds a keyring from a "kid:secret,kid:secret" spec. An empty
+// spec gives a single key made of fallbackSecret.
+func NewKeyring(spec, activeKID, fallbackSecret string) (*Keyring, error) {
+	ring := &Keyring{keys: map[string][]byte{}}
+
+	if strings.TrimSpace(spec) == "" {
+		if fallbackSecret == "" {
+			return nil, errors.New("no signing key configured")
+		}
+		ring.keys[defaultKID] = []byte(fallbackSecret)
+		ring.activeKID = defaultKID
+		return ring, nil
+	}
+
+	for _, pair := range strings.Split(spec, ",") {
+		kid, secret, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		kid = strings.TrimSpace(kid)
+		if !ok || kid == "" || secret == "" {
+			return nil, fmt.Errorf("invalid key entry %q, expected kid:secret", kid)
+		}
+		if _, dup := ring.keys[kid]; dup {
+			return nil, fmt.Errorf("duplicate kid %q", kid)
+		}
+		ring.keys[kid] = []byte(secret)
+	}
+
+	ring.activeKID = activeKID
+	if ring.activeKID == "" && len(ring.keys) == 1 {
+		for kid := range ring.keys {
+			ring.activeKID = kid
+		}
+	}
+	if _, ok := ring.keys[ring.activeKID]; !ok {
+		return nil, fmt.Errorf("active kid %q is not in the key list", ring.activeKID)
+	}
+	return ring, nil
+}
+
+// sign signs claims with the active key and sets the kid header
+func (k *Keyring) sign(claims *Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = k.activeKID
+	return token.SignedString(k.keys[k.activeKID])
+}
+
+// keyFunc picks the verification key from the kid header. Tokens without a
+// kid, or with a kid that was retired, are rejected.
+func (k *Keyring) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
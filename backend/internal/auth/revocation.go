@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"context"
+	"log"
+
+	"lomi-backend/internal/database"
+
+	"github.com/google/uuid"
+)
+
+const revokedAccessKeyPrefix = "auth:revoked_access:"
+
+// RevokedAccessKey is the Redis key marking an access token as revoked
+func RevokedAccessKey(accessUUID uuid.UUID) string {
+	return revokedAccessKeyPrefix + accessUUID.String()
+}
+
+// IsAccessTokenRevoked reports whether the login an access token belongs to
+// was revoked. Redis only caches revocations: a key there answers yes, but a
+// missing one may be a failed write or a flushed Redis, so the database
+// (refresh_tokens, admin_impersonations) decides.
+func IsAccessTokenRevoked(ctx context.Context, accessUUID uuid.UUID) (bool, error) {
+	if database.RedisClient != nil {
+		n, err := database.RedisClient.Exists(ctx, RevokedAccessKey(accessUUID)).Result()
+		if err == nil && n > 0 {
+			return true, nil
+		}
+		if err != nil {
+			log.Printf("⚠️ Revoked token lookup failed in Redis, using database: %v", err)
+		}
+	}
+
+	var revoked bool
+	err := database.DB.WithContext(ctx).Raw(
+		`SELECT EXISTS (SELECT 1 FROM refresh_tokens WHERE access_uuid = ? AND revoked_at IS NOT NULL)
+		     OR EXISTS (SELECT 1 FROM admin_impersonations WHERE access_uuid = ? AND ended_at IS NOT NULL)`,
+		accessUUID, accessUUID,
+	).Scan(&revoked).Error
+	return revoked, err
+}
+
+// ValidateAccessToken verifies an access token and checks it wasn't revoked.
+// HTTP requests and WebSocket connections are authenticated with it.
+func ValidateAccessToken(ctx context.Context, tokenString string) (*Claims, error) {
+	claims, err := ParseAccessToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	accessUUID, _ := claims.TokenID()
+	revoked, err := IsAccessTokenRevoked(ctx, accessUUID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"lomi-backend/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// ==================== TOKENS ====================
+// Access and refresh tokens share one claims shape and are told apart by
+// token_type, audience and signing keys: a refresh token never verifies as
+// an access token, or the other way around.
+
+const (
+	Issuer = "lomi-backend"
+
+	AudienceAPI     = "lomi-api"     // Access tokens
+	AudienceRefresh = "lomi-refresh" // Refresh tokens
+	AudienceAdmin   = "lomi-admin"   // Admin API tokens
+
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	TokenTypeAdmin   = "admin"
+
+	defaultAccessTTL  = 24 * time.Hour
+	defaultRefreshTTL = 7 * 24 * time.Hour
+	adminTokenTTL     = 8 * time.Hour // Admins log in again, there is no admin refresh token
+)
+
+var (
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrInvalidToken    = errors.New("invalid or expired token")
+	ErrTokenRevoked    = errors.New("session has been logged out")
+)
+
+// Claims are the claims of every token this API issues
+type Claims struct {
+	UserID               uuid.UUID  `json:"user_id"`
+	Role                 string     `json:"role,omitempty"`
+	SessionID            uuid.UUID  `json:"sid"` // The login (refresh token family) the token belongs to
+	TokenType            string     `json:"token_type"`
+	AdminID              *uuid.UUID `json:"admin_id,omitempty"`        // admin_users.id, admin tokens only
+	ImpersonatorID       *uuid.UUID `json:"impersonator_id,omitempty"` // admin_users.id of support acting as the user
+	jwt.RegisteredClaims            // iss, aud, iat, exp; jti is the access/refresh UUID
+}
+
+// TokenID returns the jti as a UUID
+func (c *Claims) TokenID() (uuid.UUID, error) {
+	return uuid.Parse(c.ID)
+}
+
+// Impersonated reports whether an admin is acting as the user
+func (c *Claims) Impersonated() bool {
+	return c.ImpersonatorID != nil
+}
+
+type TokenDetails struct {
+	AccessToken  string
+	RefreshToken string
+	AccessUUID   uuid.UUID
+	RefreshUUID  uuid.UUID
+	AtExpires    time.Time
+	RtExpires    time.Time
+}
+
+var (
+	accessKeys  *Keyring
+	refreshKeys *Keyring
+	accessTTL   = defaultAccessTTL
+	refreshTTL  = defaultRefreshTTL
+)
+
+// Init loads the signing keys and token lifetimes from config
+func Init(cfg *config.Config) error {
+	var err error
+	if accessKeys, err = NewKeyring(cfg.JWTKeys, cfg.JWTActiveKID, cfg.JWTSecret); err != nil {
+		return fmt.Errorf("access token keys: %w", err)
+	}
+	if refreshKeys, err = NewKeyring(cfg.JWTRefreshKeys, cfg.JWTRefreshActiveKID, cfg.JWTRefreshSecret); err != nil {
+		return fmt.Errorf("refresh token keys: %w", err)
+	}
+	if d, err := time.ParseDuration(cfg.JWTAccessExpiry); err == nil && d > 0 {
+		accessTTL = d
+	}
+	if d, err := time.ParseDuration(cfg.JWTRefreshExpiry); err == nil && d > 0 {
+		refreshTTL = d
+	}
+	return nil
+}
+
+// CreateToken signs an access/refresh pair for a login
+func CreateToken(userID uuid.UUID, role string, sessionID uuid.UUID) (*TokenDetails, error) {
+	if accessKeys == nil || refreshKeys == nil {
+		return nil, errors.New("auth keys are not loaded")
+	}
+
+	now := time.Now()
+	td := &TokenDetails{
+		AccessUUID:  uuid.New(),
+		RefreshUUID: uuid.New(),
+		AtExpires:   now.Add(accessTTL),
+		RtExpires:   now.Add(refreshTTL),
+	}
+
+	var err error
+	td.AccessToken, err = accessKeys.sign(&Claims{
+		UserID:    userID,
+		Role:      role,
+		SessionID: sessionID,
+		TokenType: TokenTypeAccess,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        td.AccessUUID.String(),
+			Issuer:    Issuer,
+			Audience:  jwt.ClaimStrings{AudienceAPI},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(td.AtExpires),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	td.RefreshToken, err = refreshKeys.sign(&Claims{
+		UserID:    userID,
+		SessionID: sessionID,
+		TokenType: TokenTypeRefresh,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        td.RefreshUUID.String(),
+			Issuer:    Issuer,
+			Audience:  jwt.ClaimStrings{AudienceRefresh},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(td.RtExpires),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return td, nil
+}
+
+// CreateAdminToken signs an admin API token. UserID is the admin's linked
+// user account, so handlers record actions the same way for both APIs.
+func CreateAdminToken(adminID, userID uuid.UUID, role string) (string, time.Time, error) {
+	if accessKeys == nil {
+		return "", time.Time{}, errors.New("auth keys are not loaded")
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(adminTokenTTL)
+	token, err := accessKeys.sign(&Claims{
+		UserID:    userID,
+		Role:      role,
+		SessionID: uuid.New(),
+		TokenType: TokenTypeAdmin,
+		AdminID:   &adminID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    Issuer,
+			Audience:  jwt.ClaimStrings{AudienceAdmin},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	})
+	return token, expiresAt, err
+}
+
+// CreateImpersonationToken signs an access token for an admin acting as a
+// user. It has no refresh token and is flagged with impersonator_id.
+func CreateImpersonationToken(userID uuid.UUID, role string, adminID, sessionID, accessUUID uuid.UUID, expiresAt time.Time) (string, error) {
+	if accessKeys == nil {
+		return "", errors.New("auth keys are not loaded")
+	}
+	return accessKeys.sign(&Claims{
+		UserID:         userID,
+		Role:           role,
+		SessionID:      sessionID,
+		TokenType:      TokenTypeAccess,
+		ImpersonatorID: &adminID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        accessUUID.String(),
+			Issuer:    Issuer,
+			Audience:  jwt.ClaimStrings{AudienceAPI},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	})
+}
+
+// parse verifies the signature and the registered claims of a token
+func parse(tokenString string, keys *Keyring, audience, tokenType string) (*Claims, error) {
+	if keys == nil {
+		return nil, errors.New("auth keys are not loaded")
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, keys.keyFunc,
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(Issuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	if claims.TokenType != tokenType || claims.UserID == uuid.Nil || claims.SessionID == uuid.Nil {
+		return nil, ErrInvalidToken
+	}
+	if _, err := claims.TokenID(); err != nil {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// ParseAccessToken verifies an access token. It doesn't check revocation,
+// use ValidateAccessToken for requests.
+func ParseAccessToken(tokenString string) (*Claims, error) {
+	return parse(tokenString, accessKeys, AudienceAPI, TokenTypeAccess)
+}
+
+// ParseAdminToken verifies an admin API token
+func ParseAdminToken(tokenString string) (*Claims, error) {
+	claims, err := parse(tokenString, accessKeys, AudienceAdmin, TokenTypeAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if claims.AdminID == nil || *claims.AdminID == uuid.Nil {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// ParseRefreshToken verifies a refresh token
+func ParseRefreshToken(tokenString string) (*Claims, error) {
+	return parse(tokenString, refreshKeys, AudienceRefresh, TokenTypeRefresh)
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"log"
@@ -11,6 +12,7 @@ import (
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 var S3Client *s3.Client
@@ -136,3 +138,84 @@ func GeneratePresignedDownloadURL(ctx context.Context, bucket, key string, expir
 	return request.URL, nil
 }
 
+
+// UploadObject stores a small generated file (statements, exports) in R2/S3
+func UploadObject(ctx context.Context, bucket, key, contentType string, body []byte) error {
+	if S3Client == nil {
+		return fmt.Errorf("S3Client is not initialized")
+	}
+
+	_, err := S3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload %s/%s: %w", bucket, key, err)
+	}
+	return nil
+}
+
+// DeleteObjects deletes keys from a bucket, up to 1000 per request. Missing
+// keys are not an error. Returns how many objects were deleted.
+func DeleteObjects(ctx context.Context, bucket string, keys []string) (int, error) {
+	if S3Client == nil {
+		return 0, fmt.Errorf("S3Client is not initialized")
+	}
+
+	deleted := 0
+	for start := 0; start < len(keys); start += 1000 {
+		end := start + 1000
+		if end > len(keys) {
+			end = len(keys)
+		}
+		objects := make([]types.ObjectIdentifier, 0, end-start)
+		for _, key := range keys[start:end] {
+			objects = append(objects, types.ObjectIdentifier{Key: aws.String(key)})
+		}
+		out, err := S3Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete objects in %s: %w", bucket, err)
+		}
+		if len(out.Errors) > 0 {
+			e := out.Errors[0]
+			return deleted, fmt.Errorf("failed to delete %s/%s: %s", bucket, aws.ToString(e.Key), aws.ToString(e.Message))
+		}
+		deleted += len(objects)
+	}
+	return deleted, nil
+}
+
+// DeletePrefix deletes every object under a prefix. Returns how many objects
+// were deleted.
+func DeletePrefix(ctx context.Context, bucket, prefix string) (int, error) {
+	if S3Client == nil {
+		return 0, fmt.Errorf("S3Client is not initialized")
+	}
+
+	deleted := 0
+	paginator := s3.NewListObjectsV2Paginator(S3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to list %s/%s: %w", bucket, prefix, err)
+		}
+		keys := make([]string, 0, len(page.Contents))
+		for _, object := range page.Contents {
+			keys = append(keys, aws.ToString(object.Key))
+		}
+		n, err := DeleteObjects(ctx, bucket, keys)
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
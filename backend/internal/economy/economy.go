@@ -0,0 +1,152 @@
+// Package economy serves the prices and rates of the coin economy from the
+// economy_configs table. Each change is a new immutable version with an
+// effective-from time, so finance can change or schedule pricing without a
+// deploy, and ledger entries record the version that priced them.
+package economy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Versions are cached per instance; a version published on another instance
+// is picked up within cacheTTL. Scheduled versions switch on time everywhere
+// because the version in force is resolved on every read.
+const cacheTTL = 1 * time.Minute
+
+var (
+	ErrNoConfig        = errors.New("no economy config in force")
+	ErrVersionNotFound = errors.New("economy config version not found")
+	ErrVersionInForce  = errors.New("economy config version is already in force")
+)
+
+var (
+	mu       sync.Mutex
+	versions []models.EconomyConfig // Newest effective_from first
+	loadedAt time.Time
+)
+
+// load returns all versions, refreshing the cache when it's stale. If the
+// refresh fails the stale copy is used so pricing keeps working.
+func load(ctx context.Context) ([]models.EconomyConfig, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if versions != nil && time.Since(loadedAt) < cacheTTL {
+		return versions, nil
+	}
+
+	var fresh []models.EconomyConfig
+	if err := database.DB.WithContext(ctx).
+		Order("effective_from DESC, version DESC").
+		Find(&fresh).Error; err != nil {
+		if versions != nil {
+			log.Printf("⚠️ Failed to refresh economy config, using cached versions: %v", err)
+			return versions, nil
+		}
+		return nil, fmt.Errorf("failed to load economy config: %w", err)
+	}
+	versions = fresh
+	loadedAt = time.Now()
+	return versions, nil
+}
+
+// Invalidate drops the cached versions so the next read hits the database
+func Invalidate() {
+	mu.Lock()
+	versions = nil
+	mu.Unlock()
+}
+
+// Current returns the version in force now
+func Current(ctx context.Context) (*models.EconomyConfig, error) {
+	return At(ctx, time.Now())
+}
+
+// At returns the version that was in force at t
+func At(ctx context.Context, t time.Time) (*models.EconomyConfig, error) {
+	all, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range all {
+		if !all[i].EffectiveFrom.After(t) {
+			cfg := all[i]
+			return &cfg, nil
+		}
+	}
+	return nil, ErrNoConfig
+}
+
+// List returns every version, scheduled ones included, newest first
+func List(ctx context.Context) ([]models.EconomyConfig, error) {
+	var all []models.EconomyConfig
+	err := database.DB.WithContext(ctx).Order("effective_from DESC, version DESC").Find(&all).Error
+	return all, err
+}
+
+// Get returns one version
+func Get(ctx context.Context, version int) (*models.EconomyConfig, error) {
+	var cfg models.EconomyConfig
+	result := database.DB.WithContext(ctx).Where("version = ?", version).Limit(1).Find(&cfg)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrVersionNotFound
+	}
+	return &cfg, nil
+}
+
+// Publish creates a new version taking over at effectiveFrom. Versions can't
+// be backdated: entries already posted were priced by the version in force.
+func Publish(ctx context.Context, params models.EconomyParams, effectiveFrom time.Time, adminID uuid.UUID, note string) (*models.EconomyConfig, error) {
+	if err := Validate(params); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if effectiveFrom.IsZero() || effectiveFrom.Before(now) {
+		effectiveFrom = now
+	}
+
+	cfg := models.EconomyConfig{
+		Params:        params,
+		EffectiveFrom: effectiveFrom,
+		Note:          note,
+		CreatedBy:     &adminID,
+	}
+	if err := database.DB.WithContext(ctx).Create(&cfg).Error; err != nil {
+		return nil, fmt.Errorf("failed to save economy config: %w", err)
+	}
+	Invalidate()
+
+	log.Printf("💱 Economy config v%d published by %s, effective %s", cfg.Version, adminID, cfg.EffectiveFrom.Format(time.RFC3339))
+	return &cfg, nil
+}
+
+// CancelScheduled deletes a version that hasn't taken effect yet
+func CancelScheduled(ctx context.Context, version int) error {
+	result := database.DB.WithContext(ctx).
+		Where("version = ? AND effective_from > NOW()", version).
+		Delete(&models.EconomyConfig{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		if _, err := Get(ctx, version); err != nil {
+			return err
+		}
+		return ErrVersionInForce
+	}
+	Invalidate()
+	return nil
+}
@@ -0,0 +1,59 @@
+package economy
+
+import (
+	"fmt"
+	"strings"
+
+	"lomi-backend/internal/models"
+)
+
+// ValidationError is an economy config that can't be published
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s %s", e.Field, e.Reason)
+}
+
+// Validate checks that params are usable as prices
+func Validate(p models.EconomyParams) error {
+	switch {
+	case p.CoinBirrRate <= 0:
+		return &ValidationError{"coin_birr_rate", "must be greater than 0"}
+	case p.PlatformFeePercentage < 0 || p.PlatformFeePercentage >= 100:
+		return &ValidationError{"platform_fee_percentage", "must be between 0 and 99"}
+	case p.MinCashoutCoins <= 0:
+		return &ValidationError{"min_cashout_coins", "must be greater than 0"}
+	case p.MinPayoutBirr <= 0:
+		return &ValidationError{"min_payout_birr", "must be greater than 0"}
+	case p.MinWithdrawal < 0:
+		return &ValidationError{"min_withdrawal", "can't be negative"}
+	case p.RevealOneCoins <= 0:
+		return &ValidationError{"reveal_one_coins", "must be greater than 0"}
+	case p.RevealAllCoins <= 0:
+		return &ValidationError{"reveal_all_coins", "must be greater than 0"}
+	case p.ReferralRewardCoins < 0:
+		return &ValidationError{"referral_reward_coins", "can't be negative"}
+	case len(p.CoinPacks) == 0:
+		return &ValidationError{"coin_packs", "must have at least one pack"}
+	}
+
+	seen := make(map[string]bool, len(p.CoinPacks))
+	for i, pack := range p.CoinPacks {
+		field := fmt.Sprintf("coin_packs[%d]", i)
+		switch {
+		case strings.TrimSpace(pack.ID) == "" || strings.TrimSpace(pack.Name) == "":
+			return &ValidationError{field, "needs an id and a name"}
+		case seen[pack.ID]:
+			return &ValidationError{field, "has a duplicate id"}
+		case pack.ETBPrice <= 0:
+			return &ValidationError{field + ".etb_price", "must be greater than 0"}
+		case pack.Coins <= 0:
+			return &ValidationError{field + ".coins", "must be greater than 0"}
+		}
+		seen[pack.ID] = true
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package geoip
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+
+	"lomi-backend/config"
+)
+
+// ==================== GEOIP ====================
+// Offline IP -> location lookups from a DB-IP Lite CSV download
+// (https://db-ip.com/db/lite.php), plain or gzipped. The country file has
+// rows "start,end,country"; the city file "start,end,continent,country,
+// region,city,lat,lon". Both IPv4 and IPv6 ranges are supported. Without a
+// database every lookup is empty.
+
+// Location is where an IP address is. Fields are empty when unknown.
+type Location struct {
+	CountryCode string `json:"country_code,omitempty"` // ISO 3166-1 alpha-2
+	Region      string `json:"region,omitempty"`
+	City        string `json:"city,omitempty"`
+}
+
+type ipRange struct {
+	start netip.Addr
+	end   netip.Addr
+	loc   Location
+}
+
+var ranges []ipRange
+
+// Init loads the database at GEOIP_DB_PATH. An unset path disables lookups.
+func Init(cfg *config.Config) error {
+	if cfg.GeoIPDBPath == "" {
+		log.Printf("⚠️ GEOIP_DB_PATH not set: login locations are unknown")
+		return nil
+	}
+	loaded, err := load(cfg.GeoIPDBPath)
+	if err != nil {
+		return err
+	}
+	ranges = loaded
+	log.Printf("🌍 GeoIP database loaded: %d ranges", len(ranges))
+	return nil
+}
+
+func load(path string) ([]ipRange, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	var loaded []ipRange
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("geoip line %d: %w", line, err)
+		}
+		if len(record) < 3 {
+			return nil, fmt.Errorf("geoip line %d: expected at least 3 columns", line)
+		}
+		start, err := netip.ParseAddr(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("geoip line %d: %w", line, err)
+		}
+		end, err := netip.ParseAddr(record[1])
+		if err != nil {
+			return nil, fmt.Errorf("geoip line %d: %w", line, err)
+		}
+
+		entry := ipRange{start: start, end: end}
+		if len(record) >= 6 {
+			entry.loc = Location{CountryCode: record[3], Region: record[4], City: record[5]}
+		} else {
+			entry.loc = Location{CountryCode: record[2]}
+		}
+		if entry.loc.CountryCode == "ZZ" {
+			continue // Reserved/unassigned
+		}
+		loaded = append(loaded, entry)
+	}
+
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].start.Less(loaded[j].start) })
+	return loaded, nil
+}
+
+// Enabled reports whether a database is loaded
+func Enabled() bool {
+	return len(ranges) > 0
+}
+
+// Lookup returns the location of an IP address
+func Lookup(ip string) Location {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || len(ranges) == 0 {
+		return Location{}
+	}
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() {
+		return Location{}
+	}
+
+	// Last range starting at or before addr
+	i := sort.Search(len(ranges), func(i int) bool { return addr.Less(ranges[i].start) }) - 1
+	if i < 0 || ranges[i].end.Less(addr) {
+		return Location{}
+	}
+	return ranges[i].loc
+}
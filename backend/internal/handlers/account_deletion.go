@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"lomi-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AdminGetAccountDeletion looks up a deletion request or receipt by the
+// number the user was given (GET /admin/account-deletions/:id)
+func AdminGetAccountDeletion(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid deletion ID"})
+	}
+
+	deletion, err := services.GetAccountDeletion(c.Context(), id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Deletion not found"})
+	}
+	if err != nil {
+		log.Printf("❌ Failed to load account deletion %s: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load deletion"})
+	}
+	return c.JSON(fiber.Map{"deletion": deletion})
+}
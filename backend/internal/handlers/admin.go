@@ -4,14 +4,16 @@ import (
 	"context"
 	"log"
 	"lomi-backend/config"
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/database"
+	"lomi-backend/internal/ledger"
 	"lomi-backend/internal/models"
 	"lomi-backend/internal/queue"
+	"lomi-backend/internal/services"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -41,10 +43,10 @@ func GetPendingReports(c *fiber.Ctx) error {
 
 // ReviewReport allows admin to review and take action on a report
 func ReviewReport(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	adminIDStr := claims["user_id"].(string)
-	adminID, _ := uuid.Parse(adminIDStr)
+	adminID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	reportID := c.Params("id")
 	var req struct {
@@ -88,16 +90,18 @@ func ReviewReport(c *fiber.Ctx) error {
 	})
 }
 
-// GetPendingPayouts returns all pending payout requests for admin review
+// GetPendingPayouts lists payouts waiting on admins (?status=held by default;
+// approved ones are waiting for the next disbursement batch)
 func GetPendingPayouts(c *fiber.Ctx) error {
 	page := c.QueryInt("page", 1)
 	limit := c.QueryInt("limit", 50)
 	offset := (page - 1) * limit
+	status := models.PayoutStatus(c.Query("status", string(models.PayoutStatusHeld)))
 
 	var payouts []models.Payout
-	if err := database.DB.Where("status = ?", models.PayoutStatusPending).
+	if err := database.DB.Where("status = ?", status).
 		Preload("User").
-		Order("created_at DESC").
+		Order("created_at ASC").
 		Limit(limit).
 		Offset(offset).
 		Find(&payouts).Error; err != nil {
@@ -111,62 +115,41 @@ func GetPendingPayouts(c *fiber.Ctx) error {
 	})
 }
 
-// ProcessPayout allows admin to approve or reject a payout request
+// ProcessPayout approves or rejects a payout request (kept for the admin
+// dashboard; same as POST /admin/payouts/:id/approve and /reject)
 func ProcessPayout(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	adminIDStr := claims["user_id"].(string)
-	adminID, _ := uuid.Parse(adminIDStr)
+	adminID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
-	payoutID := c.Params("id")
+	payoutID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid payout ID"})
+	}
 	var req struct {
-		Action           string `json:"action"` // "approve", "reject"
-		PaymentReference string `json:"payment_reference,omitempty"`
-		RejectionReason  string `json:"rejection_reason,omitempty"`
-		AdminNotes       string `json:"admin_notes,omitempty"`
+		Action          string `json:"action"` // "approve", "reject"
+		RejectionReason string `json:"rejection_reason,omitempty"`
+		AdminNotes      string `json:"admin_notes,omitempty"`
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	var payout models.Payout
-	if err := database.DB.First(&payout, "id = ?", payoutID).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Payout not found"})
-	}
-
-	if payout.Status != models.PayoutStatusPending {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Payout already processed"})
-	}
-
-	now := time.Now()
-	payout.ProcessedBy = &adminID
-	payout.ProcessedAt = &now
-	payout.AdminNotes = req.AdminNotes
-
-	if req.Action == "approve" {
-		payout.Status = models.PayoutStatusProcessing
-		payout.PaymentReference = req.PaymentReference
-
-		// TODO: Integrate with payment gateway to process payout
-		// For now, mark as processing. In production, you'd:
-		// 1. Call Telebirr/CBE Birr API to send money
-		// 2. Update status to "completed" on success
-		// 3. Update status to "rejected" on failure
-
-	} else if req.Action == "reject" {
-		payout.Status = models.PayoutStatusRejected
-		payout.RejectionReason = req.RejectionReason
-
-		// Refund the amount back to user's gift balance
-		var user models.User
-		if err := database.DB.First(&user, "id = ?", payout.UserID).Error; err == nil {
-			user.GiftBalance += payout.GiftBalanceAmount
-			database.DB.Save(&user)
+	var payout *models.Payout
+	switch req.Action {
+	case "approve":
+		payout, err = services.ApprovePayout(c.Context(), payoutID, adminID, req.AdminNotes)
+	case "reject":
+		if req.RejectionReason == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "rejection_reason is required"})
 		}
+		payout, err = services.RejectPayout(c.Context(), payoutID, adminID, req.RejectionReason)
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "action must be approve or reject"})
 	}
-
-	if err := database.DB.Save(&payout).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update payout"})
+	if err != nil {
+		return payoutErrorResponse(c, err)
 	}
 
 	return c.JSON(fiber.Map{
@@ -175,6 +158,36 @@ func ProcessPayout(c *fiber.Ctx) error {
 	})
 }
 
+// GetLiveChatStats returns live chat persister throughput and per-stream consumer lag
+func GetLiveChatStats(c *fiber.Ctx) error {
+	stats, err := services.GetLiveChatPersisterStats()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to get live chat stats",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(stats)
+}
+
+// VerifyLedger checks that the coin ledger balances and the balance projections match it
+func VerifyLedger(c *fiber.Ctx) error {
+	report, err := ledger.Verify(c.Context(), database.SqlxDB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to verify ledger",
+			"details": err.Error(),
+		})
+	}
+
+	if !report.Balanced {
+		log.Printf("⚠️ Ledger verification failed: trial=%v mismatches=%d drift=%d",
+			report.TrialBalance, len(report.AccountMismatches), len(report.ProjectionDrift))
+	}
+	return c.JSON(report)
+}
+
 // GetQueueStats returns statistics about the photo moderation queue
 func GetQueueStats(c *fiber.Ctx) error {
 	queueLength, err := queue.GetQueueLength()
@@ -310,10 +323,10 @@ func GetModerationDashboard(c *fiber.Ctx) error {
 
 // VerifyRejectedPhoto allows admin to verify a rejected photo (mark as reviewed)
 func VerifyRejectedPhoto(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	adminIDStr := claims["user_id"].(string)
-	adminID, _ := uuid.Parse(adminIDStr)
+	adminID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	mediaID := c.Params("id")
 	var media models.Media
@@ -353,10 +366,9 @@ func VerifyRejectedPhoto(c *fiber.Ctx) error {
 // DeleteRejectedPhoto allows admin to delete a rejected photo after verification
 // This deletes both the database record and the file from R2/S3
 func DeleteRejectedPhoto(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	adminIDStr := claims["user_id"].(string)
-	_, _ = uuid.Parse(adminIDStr) // Admin ID for logging
+	if _, err := auth.UserID(c); err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	mediaID := c.Params("id")
 	var media models.Media
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"lomi-backend/internal/auth"
+	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// ==================== ADMIN AUTH ====================
+// Admins log in with their admin_users account, not a user token. The admin
+// token only works on /admin routes.
+
+// adminIDFromClaims returns the admin_users ID of an admin request
+func adminIDFromClaims(c *fiber.Ctx) (uuid.UUID, error) {
+	claims, err := auth.ClaimsFrom(c)
+	if err != nil || claims.AdminID == nil {
+		return uuid.Nil, auth.ErrUnauthenticated
+	}
+	return *claims.AdminID, nil
+}
+
+// AdminLogin checks email, password and (once enabled) the TOTP code
+// (POST /admin/auth/login)
+func AdminLogin(c *fiber.Ctx) error {
+	var req struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+		TOTPCode string `json:"totp_code"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Email == "" || req.Password == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "email and password are required"})
+	}
+
+	result, known, err := services.AdminLogin(c.Context(), req.Email, req.Password, req.TOTPCode)
+
+	entry := &models.AdminAuditLog{
+		Action:     c.Method() + " " + c.Route().Path,
+		Path:       c.OriginalURL(),
+		StatusCode: fiber.StatusOK,
+		Request:    models.JSONB{"email": req.Email},
+		IPAddress:  c.IP(),
+		UserAgent:  c.Get("User-Agent"),
+	}
+	if known != nil {
+		entry.AdminUserID = &known.ID
+		entry.AdminRole = known.Role
+	}
+
+	switch {
+	case errors.Is(err, services.ErrAdminTOTPRequired):
+		// Password was right: the client asks for the code and retries
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error(), "totp_required": true})
+	case errors.Is(err, services.ErrAdminInvalidCredentials),
+		errors.Is(err, services.ErrAdminNotLinked):
+		entry.StatusCode = fiber.StatusUnauthorized
+	case errors.Is(err, services.ErrAdminLocked):
+		entry.StatusCode = fiber.StatusTooManyRequests
+	case err != nil:
+		log.Printf("❌ Admin login failed: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Login failed"})
+	}
+	services.RecordAdminAudit(c.Context(), entry)
+	if err != nil {
+		return c.Status(entry.StatusCode).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("🛡️ Admin logged in: %s (%s)", result.Admin.Email, result.Admin.Role)
+	return c.JSON(fiber.Map{
+		"access_token": result.Token,
+		"expires_at":   result.ExpiresAt,
+		"admin":        result.Admin,
+	})
+}
+
+// AdminMe returns the logged in admin and what their role can do (GET /admin/auth/me)
+func AdminMe(c *fiber.Ctx) error {
+	adminID, err := adminIDFromClaims(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+	admin, err := services.GetAdminUser(c.Context(), adminID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Admin not found"})
+	}
+
+	permissions := []models.AdminPermission{}
+	for _, perm := range []models.AdminPermission{
+		models.AdminPermReports, models.AdminPermModeration, models.AdminPermPayouts,
+		models.AdminPermPayments, models.AdminPermEconomy, models.AdminPermAdmins,
+	} {
+		if admin.Role.Can(perm) {
+			permissions = append(permissions, perm)
+		}
+	}
+	return c.JSON(fiber.Map{
+		"admin":       admin,
+		"permissions": permissions,
+	})
+}
+
+// AdminSetupTOTP starts two-factor setup for the logged in admin
+// (POST /admin/auth/totp/setup)
+func AdminSetupTOTP(c *fiber.Ctx) error {
+	adminID, err := adminIDFromClaims(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+	secret, otpauthURL, err := services.SetupAdminTOTP(c.Context(), adminID)
+	if errors.Is(err, services.ErrAdminTOTPEnabled) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		log.Printf("❌ Failed to start TOTP setup: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to start two-factor setup"})
+	}
+	return c.JSON(fiber.Map{
+		"secret":      secret,
+		"otpauth_url": otpauthURL,
+	})
+}
+
+// AdminEnableTOTP confirms setup with a code from the app (POST /admin/auth/totp/enable)
+func AdminEnableTOTP(c *fiber.Ctx) error {
+	adminID, err := adminIDFromClaims(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	err = services.EnableAdminTOTP(c.Context(), adminID, req.Code)
+	switch {
+	case errors.Is(err, services.ErrAdminTOTPEnabled):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrAdminTOTPNotStarted), errors.Is(err, services.ErrAdminInvalidTOTP):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to enable two-factor authentication"})
+	}
+	return c.JSON(fiber.Map{"message": "Two-factor authentication enabled"})
+}
+
+// ==================== ADMIN: ACCOUNTS & AUDIT LOG ====================
+
+// AdminListAdmins returns every admin account (GET /admin/admins)
+func AdminListAdmins(c *fiber.Ctx) error {
+	admins, err := services.ListAdminUsers(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch admins"})
+	}
+	return c.JSON(fiber.Map{
+		"admins": admins,
+		"count":  len(admins),
+	})
+}
+
+// AdminCreateAdmin adds an admin account (POST /admin/admins)
+func AdminCreateAdmin(c *fiber.Ctx) error {
+	var input services.AdminUserInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	admin, err := services.CreateAdminUser(c.Context(), input)
+	if errors.Is(err, services.ErrAdminEmailTaken) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Admin created",
+		"admin":   admin,
+	})
+}
+
+// AdminUpdateAdmin changes an admin's role, password, status or linked user
+// (PUT /admin/admins/:id)
+func AdminUpdateAdmin(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid admin ID"})
+	}
+	var input services.AdminUserInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	admin, err := services.UpdateAdminUser(c.Context(), id, input)
+	switch {
+	case errors.Is(err, services.ErrAdminNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrAdminEmailTaken), errors.Is(err, services.ErrAdminLastSuperAdmin):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{
+		"message": "Admin updated",
+		"admin":   admin,
+	})
+}
+
+// AdminListAuditLogs returns admin actions, newest first
+// (GET /admin/audit-logs?admin_id=&entity_type=&entity_id=&before=&limit=)
+func AdminListAuditLogs(c *fiber.Ctx) error {
+	filter := services.AdminAuditFilter{
+		EntityType: c.Query("entity_type"),
+		EntityID:   c.Query("entity_id"),
+	}
+	if raw := c.Query("admin_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid admin_id"})
+		}
+		filter.AdminUserID = &id
+	}
+	if raw := c.Query("before"); raw != "" {
+		before, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "before must be an RFC 3339 time"})
+		}
+		filter.Before = &before
+	}
+	filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+
+	logs, err := services.ListAdminAuditLogs(c.Context(), filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch audit log"})
+	}
+	return c.JSON(fiber.Map{
+		"logs":  logs,
+		"count": len(logs),
+	})
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"lomi-backend/internal/auth"
+	"lomi-backend/internal/economy"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ==================== ECONOMY CONFIG ====================
+// Prices and rates are versioned rows: admins publish a new version, now or
+// from a future date, instead of editing the current one.
+
+func economyErrorResponse(c *fiber.Ctx, err error) error {
+	log.Printf("❌ Failed to load economy config: %v", err)
+	return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Pricing is unavailable, try again shortly"})
+}
+
+// GetEconomy returns the prices in force: coin packs, reveal prices, minimums (GET /economy)
+func GetEconomy(c *fiber.Ctx) error {
+	pricing, err := economy.Current(c.Context())
+	if err != nil {
+		return economyErrorResponse(c, err)
+	}
+	return c.JSON(pricing)
+}
+
+// AdminListEconomyConfigs returns every version, scheduled ones included (GET /admin/economy)
+func AdminListEconomyConfigs(c *fiber.Ctx) error {
+	versions, err := economy.List(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch economy configs"})
+	}
+	current, err := economy.Current(c.Context())
+	if err != nil {
+		return economyErrorResponse(c, err)
+	}
+	return c.JSON(fiber.Map{
+		"current_version": current.Version,
+		"versions":        versions,
+	})
+}
+
+// AdminGetEconomyConfig returns one version (GET /admin/economy/:version)
+func AdminGetEconomyConfig(c *fiber.Ctx) error {
+	version, err := strconv.Atoi(c.Params("version"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid version"})
+	}
+	cfg, err := economy.Get(c.Context(), version)
+	if errors.Is(err, economy.ErrVersionNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch economy config"})
+	}
+	return c.JSON(cfg)
+}
+
+// AdminPublishEconomyConfig publishes a new version (POST /admin/economy).
+// Fields left out of params keep the values of the version in force;
+// effective_from defaults to now and can't be in the past.
+func AdminPublishEconomyConfig(c *fiber.Ctx) error {
+	adminID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+
+	var req struct {
+		Params        json.RawMessage `json:"params"`
+		EffectiveFrom *time.Time      `json:"effective_from"`
+		Note          string          `json:"note"`
+	}
+	if err := c.BodyParser(&req); err != nil || len(req.Params) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "params is required"})
+	}
+
+	current, err := economy.Current(c.Context())
+	if err != nil {
+		return economyErrorResponse(c, err)
+	}
+	params := current.Params
+	params.CoinPacks = append(params.CoinPacks[:0:0], params.CoinPacks...)
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid params"})
+	}
+
+	var effectiveFrom time.Time
+	if req.EffectiveFrom != nil {
+		if req.EffectiveFrom.Before(time.Now().Add(-time.Minute)) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "effective_from can't be in the past"})
+		}
+		effectiveFrom = *req.EffectiveFrom
+	}
+
+	cfg, err := economy.Publish(c.Context(), params, effectiveFrom, adminID, req.Note)
+	var invalid *economy.ValidationError
+	if errors.As(err, &invalid) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		log.Printf("❌ Failed to publish economy config: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to publish economy config"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(cfg)
+}
+
+// AdminCancelEconomyConfig deletes a version that hasn't taken effect yet
+// (DELETE /admin/economy/:version)
+func AdminCancelEconomyConfig(c *fiber.Ctx) error {
+	version, err := strconv.Atoi(c.Params("version"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid version"})
+	}
+	err = economy.CancelScheduled(c.Context(), version)
+	switch {
+	case errors.Is(err, economy.ErrVersionNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, economy.ErrVersionInForce):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to cancel economy config"})
+	}
+	return c.JSON(fiber.Map{"message": "Scheduled economy config cancelled"})
+}
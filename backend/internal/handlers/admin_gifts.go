@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"lomi-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// ==================== ADMIN: GIFT CATALOG ====================
+
+// AdminListGifts returns the full catalog including inactive and out-of-season gifts
+func AdminListGifts(c *fiber.Ctx) error {
+	gifts, err := services.GiftCatalogSvc.ListAll(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch gifts"})
+	}
+
+	return c.JSON(fiber.Map{
+		"gifts": gifts,
+		"count": len(gifts),
+	})
+}
+
+// AdminCreateGift adds a gift to the catalog
+func AdminCreateGift(c *fiber.Ctx) error {
+	var input services.GiftInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	gift, err := services.GiftCatalogSvc.Create(c.Context(), input)
+	if err != nil {
+		return giftCatalogError(c, "create", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Gift created",
+		"gift":    gift,
+	})
+}
+
+// AdminUpdateGift edits a gift (price, media, featured flag, availability window...)
+func AdminUpdateGift(c *fiber.Ctx) error {
+	giftID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid gift ID"})
+	}
+
+	var input services.GiftInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	gift, err := services.GiftCatalogSvc.Update(c.Context(), giftID, input)
+	if err != nil {
+		return giftCatalogError(c, "update", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Gift updated",
+		"gift":    gift,
+	})
+}
+
+// AdminDeleteGift deactivates a gift (history keeps referencing it)
+func AdminDeleteGift(c *fiber.Ctx) error {
+	giftID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid gift ID"})
+	}
+
+	if err := services.GiftCatalogSvc.Deactivate(c.Context(), giftID); err != nil {
+		if errors.Is(err, services.ErrGiftNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Gift not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to deactivate gift"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Gift deactivated"})
+}
+
+// giftCatalogError writes the response for a failed create or update.
+// Only validation errors are shown to the admin, the rest is logged.
+func giftCatalogError(c *fiber.Ctx, action string, err error) error {
+	var invalid *services.GiftValidationError
+	switch {
+	case errors.Is(err, services.ErrGiftNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Gift not found"})
+	case errors.As(err, &invalid):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": invalid.Reason})
+	case errors.Is(err, services.ErrGiftSlugTaken):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	log.Printf("❌ Failed to %s gift: %v", action, err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to " + action + " gift"})
+}
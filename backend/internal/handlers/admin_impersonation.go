@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"lomi-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AdminImpersonateUser issues a short-lived access token to act as a user
+// (POST /admin/users/:id/impersonate). A reason is required; ttl_minutes
+// defaults to 15 and is capped at 60.
+func AdminImpersonateUser(c *fiber.Ctx) error {
+	adminID, err := adminIDFromClaims(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	var req struct {
+		Reason     string `json:"reason"`
+		TTLMinutes int    `json:"ttl_minutes"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	result, err := services.StartImpersonation(c.Context(), adminID, userID, req.Reason,
+		time.Duration(req.TTLMinutes)*time.Minute, c.IP())
+	switch {
+	case errors.Is(err, services.ErrImpersonationReason):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrImpersonateAdmin):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	case err != nil:
+		log.Printf("❌ Failed to impersonate user %s: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to start impersonation"})
+	}
+
+	return c.JSON(fiber.Map{
+		"impersonation": result.Impersonation,
+		"access_token":  result.AccessToken,
+		"expires_in":    int(time.Until(result.Impersonation.ExpiresAt).Seconds()),
+		"impersonated":  true,
+	})
+}
+
+// AdminEndImpersonation revokes an impersonation token early
+// (DELETE /admin/impersonations/:id)
+func AdminEndImpersonation(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid impersonation ID"})
+	}
+	if err := services.EndImpersonation(c.Context(), id); err != nil {
+		if errors.Is(err, services.ErrImpersonationNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Printf("❌ Failed to end impersonation %s: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to end impersonation"})
+	}
+	return c.JSON(fiber.Map{"message": "Impersonation ended"})
+}
+
+// AdminListImpersonations lists recent impersonations, optionally of one user
+// (GET /admin/impersonations?user_id=)
+func AdminListImpersonations(c *fiber.Ctx) error {
+	var userID *uuid.UUID
+	if raw := c.Query("user_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user_id"})
+		}
+		userID = &id
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	impersonations, err := services.ListImpersonations(c.Context(), userID, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch impersonations"})
+	}
+	return c.JSON(fiber.Map{
+		"impersonations": impersonations,
+		"count":          len(impersonations),
+	})
+}
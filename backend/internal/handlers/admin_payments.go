@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"lomi-backend/internal/auth"
+	"lomi-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// ==================== ADMIN: PAYMENT RECONCILIATION ====================
+
+// AdminReconcilePayments runs a reconciliation pass now instead of waiting for the next tick
+func AdminReconcilePayments(c *fiber.Ctx) error {
+	result := services.ReconcilePendingPurchases(c.Context())
+	return c.JSON(result)
+}
+
+// AdminGetReconciliationReport returns the expected vs. settled birr per provider
+// for one day (?date=YYYY-MM-DD, default yesterday). ?format=csv for finance.
+func AdminGetReconciliationReport(c *fiber.Ctx) error {
+	day := time.Now().AddDate(0, 0, -1)
+	if date := c.Query("date"); date != "" {
+		parsed, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "date must be YYYY-MM-DD"})
+		}
+		// Noon keeps the calendar day in any timezone conversion
+		day = parsed.Add(12 * time.Hour)
+	}
+
+	day = services.ReconciliationDay(day)
+
+	reports, err := services.GenerateReconciliationReport(c.Context(), day)
+	if err != nil {
+		log.Printf("❌ Failed to build reconciliation report: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to build report"})
+	}
+
+	if c.Query("format") != "csv" {
+		return c.JSON(fiber.Map{
+			"date":      day.Format("2006-01-02"),
+			"providers": reports,
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="reconciliation-%s.csv"`, day.Format("2006-01-02")))
+
+	w := csv.NewWriter(c)
+	w.Write([]string{
+		"date", "provider", "initiated_count", "expected_birr", "settled_count", "settled_birr",
+		"reconciled_count", "failed_count", "failed_birr", "expired_count", "pending_count", "pending_birr",
+		"refunded_count", "refunded_birr", "coins_credited", "ledger_coins", "has_discrepancy",
+	})
+	for _, r := range reports {
+		w.Write([]string{
+			r.ReportDate.Format("2006-01-02"), r.Provider,
+			strconv.Itoa(r.InitiatedCount), fmt.Sprintf("%.2f", r.ExpectedBirr),
+			strconv.Itoa(r.SettledCount), fmt.Sprintf("%.2f", r.SettledBirr),
+			strconv.Itoa(r.ReconciledCount),
+			strconv.Itoa(r.FailedCount), fmt.Sprintf("%.2f", r.FailedBirr),
+			strconv.Itoa(r.ExpiredCount),
+			strconv.Itoa(r.PendingCount), fmt.Sprintf("%.2f", r.PendingBirr),
+			strconv.Itoa(r.RefundedCount), fmt.Sprintf("%.2f", r.RefundedBirr),
+			strconv.FormatInt(r.CoinsCredited, 10), strconv.FormatInt(r.LedgerCoins, 10),
+			strconv.FormatBool(r.HasDiscrepancy),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// ==================== ADMIN: REFUNDS ====================
+
+// AdminRefundPurchase refunds a completed coin purchase
+// (POST /admin/payments/purchases/:id/refund). Unspent coins are taken back;
+// spent ones leave the user in debt. Body: reason, optional external_refund_id
+// for a refund already made in the provider's merchant portal. When the
+// provider call fails the coins stay reversed; calling again retries it.
+func AdminRefundPurchase(c *fiber.Ctx) error {
+	adminID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+
+	purchaseID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid purchase ID"})
+	}
+
+	var req struct {
+		Reason           string `json:"reason"`
+		ExternalRefundID string `json:"external_refund_id"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Reason is required"})
+	}
+
+	refund, err := services.RefundPurchase(c.Context(), purchaseID, adminID, req.Reason, strings.TrimSpace(req.ExternalRefundID))
+	switch {
+	case errors.Is(err, services.ErrPurchaseNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrPurchaseNotRefundable), errors.Is(err, services.ErrRefundNeedsExternalID):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrProviderRefundFailed):
+		log.Printf("❌ Provider refund of purchase %s failed: %v", purchaseID, err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": services.ErrProviderRefundFailed.Error(), "details": err.Error()})
+	case err != nil:
+		log.Printf("❌ Refund of purchase %s failed: %v", purchaseID, err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Refund failed", "details": err.Error()})
+	}
+
+	log.Printf("↩️ Admin %s refunded purchase %s: %d coins removed, debt %d",
+		adminID, purchaseID, refund.CoinsRemoved, refund.Debt)
+	return c.JSON(refund)
+}
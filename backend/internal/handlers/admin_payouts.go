@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+
+	"lomi-backend/internal/auth"
+	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ==================== ADMIN: PAYOUTS ====================
+// held → approve (twice above PAYOUT_DUAL_APPROVAL_ETB) → export batch (sent)
+// → settle. Reject works until a payout settles and returns the funds.
+
+func payoutErrorResponse(c *fiber.Ctx, err error) error {
+	var transition *services.PayoutTransitionError
+	switch {
+	case errors.Is(err, services.ErrPayoutNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Payout not found"})
+	case errors.Is(err, services.ErrPayoutSelfApproval), errors.Is(err, services.ErrPayoutSameApprover):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.As(err, &transition):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		log.Printf("❌ Payout operation failed: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update payout"})
+	}
+}
+
+// AdminApprovePayout approves a held payout (POST /admin/payouts/:id/approve)
+func AdminApprovePayout(c *fiber.Ctx) error {
+	adminID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+
+	payoutID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid payout ID"})
+	}
+	var req struct {
+		AdminNotes string `json:"admin_notes"`
+	}
+	c.BodyParser(&req)
+
+	payout, err := services.ApprovePayout(c.Context(), payoutID, adminID, req.AdminNotes)
+	if err != nil {
+		return payoutErrorResponse(c, err)
+	}
+
+	message := "Payout approved"
+	if payout.Status == models.PayoutStatusHeld {
+		message = "First approval recorded; a second admin must approve this payout"
+	}
+	return c.JSON(fiber.Map{"message": message, "payout": payout})
+}
+
+// AdminRejectPayout rejects a payout and refunds the held funds
+// (POST /admin/payouts/:id/reject). Also used when a disbursement fails.
+func AdminRejectPayout(c *fiber.Ctx) error {
+	adminID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+
+	payoutID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid payout ID"})
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "reason is required"})
+	}
+
+	payout, err := services.RejectPayout(c.Context(), payoutID, adminID, req.Reason)
+	if err != nil {
+		return payoutErrorResponse(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "Payout rejected and refunded", "payout": payout})
+}
+
+// AdminSettlePayout confirms a sent payout was paid (POST /admin/payouts/:id/settle)
+func AdminSettlePayout(c *fiber.Ctx) error {
+	adminID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+
+	payoutID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid payout ID"})
+	}
+	var req struct {
+		PaymentReference string `json:"payment_reference"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.PaymentReference == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "payment_reference is required"})
+	}
+
+	payout, err := services.SettlePayout(c.Context(), payoutID, adminID, req.PaymentReference)
+	if err != nil {
+		return payoutErrorResponse(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "Payout settled", "payout": payout})
+}
+
+// AdminExportPayouts puts all approved payouts into a disbursement batch, marks
+// them sent and returns the bulk disbursement CSV
+// (POST /admin/payouts/export?payment_method=telebirr)
+func AdminExportPayouts(c *fiber.Ctx) error {
+	adminID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+
+	method := models.PaymentMethod(c.Query("payment_method", string(models.PaymentMethodTelebirr)))
+
+	batch, payouts, err := services.ExportPayoutBatch(c.Context(), method, adminID)
+	if errors.Is(err, services.ErrNoApprovedPayouts) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		log.Printf("❌ Failed to export payouts: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to export payouts"})
+	}
+	return writePayoutBatchCSV(c, batch, payouts)
+}
+
+// AdminExportPayoutBatch downloads an exported batch again (GET /admin/payouts/batches/:id/export)
+func AdminExportPayoutBatch(c *fiber.Ctx) error {
+	batchID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid batch ID"})
+	}
+
+	batch, payouts, err := services.GetPayoutBatch(c.Context(), batchID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Batch not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load batch"})
+	}
+	return writePayoutBatchCSV(c, batch, payouts)
+}
+
+// writePayoutBatchCSV writes the bulk disbursement file: one row per payout,
+// the payout ID is the reference that comes back in the disbursement report
+func writePayoutBatchCSV(c *fiber.Ctx, batch *models.PayoutBatch, payouts []models.Payout) error {
+	c.Set(fiber.HeaderContentType, "text/csv")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="payouts-%s-%s.csv"`,
+		batch.PaymentMethod, batch.CreatedAt.Format("20060102-150405")))
+	c.Set("X-Payout-Batch-ID", batch.ID.String())
+
+	w := csv.NewWriter(c)
+	w.Write([]string{"msisdn", "name", "amount", "currency", "reference", "remark"})
+	for _, p := range payouts {
+		w.Write([]string{
+			p.PaymentAccount,
+			p.PaymentAccountName,
+			fmt.Sprintf("%.2f", p.NetAmount),
+			"ETB",
+			p.ID.String(),
+			"Lomi payout",
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -1,11 +1,11 @@
 package handlers
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
@@ -15,14 +15,14 @@ import (
 	"time"
 
 	"lomi-backend/config"
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/database"
 	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
 	"lomi-backend/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	initdata "github.com/telegram-mini-apps/init-data-golang"
 	"gorm.io/gorm"
 )
 
@@ -65,6 +65,7 @@ func (h *AuthHandler) TelegramLogin(c *fiber.Ctx) error {
 			log.Println("No initData found in header or body")
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error":   "Missing Authorization header",
+				"code":    utils.TelegramErrMissing,
 				"message": "Expected format: Authorization: tma <initData>",
 			})
 		}
@@ -76,6 +77,7 @@ func (h *AuthHandler) TelegramLogin(c *fiber.Ctx) error {
 	if len(authParts) != 2 {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error":   "Invalid Authorization header format",
+			"code":    utils.TelegramErrMalformed,
 			"message": "Expected format: Authorization: tma <initData>",
 		})
 	}
@@ -87,85 +89,15 @@ func (h *AuthHandler) TelegramLogin(c *fiber.Ctx) error {
 	if authType != "tma" {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error":   "Unsupported authorization type",
+			"code":    utils.TelegramErrMalformed,
 			"message": fmt.Sprintf("Expected 'tma', got '%s'", authType),
 		})
 	}
 
-	if initData == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Missing initData in Authorization header",
-		})
-	}
-
-	// Check if bot token is configured
-	if h.cfg.TelegramBotToken == "" {
-		log.Printf("❌ TelegramBotToken is not configured")
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   "Server configuration error",
-			"details": "Telegram bot token is not configured",
-		})
-	}
-
-	// 1. Validate Telegram Data using official library
-	// Consider initData valid for 1 hour from creation moment
-	// Wrap in recover to catch any panics from the library
-	var validateErr error
-	func() {
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("❌ Panic during initData validation: %v", r)
-				validateErr = fmt.Errorf("validation panic: %v", r)
-			}
-		}()
-		validateErr = initdata.Validate(initData, h.cfg.TelegramBotToken, time.Hour)
-	}()
-
-	if validateErr != nil {
-		log.Printf("❌ InitData validation failed: %v", validateErr)
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error":   "Invalid Telegram data",
-			"details": validateErr.Error(),
-		})
-	}
-
-	// 2. Parse initData to get user information
-	// Wrap in recover to catch any panics from the library
-	var parsedData initdata.InitData
-	var parseErr error
-	func() {
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("❌ Panic during initData parsing: %v", r)
-				parseErr = fmt.Errorf("parsing panic: %v", r)
-			}
-		}()
-		parsedData, parseErr = initdata.Parse(initData)
-	}()
-
-	if parseErr != nil {
-		log.Printf("❌ InitData parsing failed: %v", parseErr)
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error":   "Failed to parse Telegram data",
-			"details": parseErr.Error(),
-		})
-	}
-
-	// Extract user from parsed data
-	// parsedData.User is a struct (not a pointer), check if ID is valid
-	if parsedData.User.ID == 0 {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "User data missing in initData",
-		})
-	}
-
-	tgUser := &utils.TelegramUser{
-		ID:           parsedData.User.ID,
-		FirstName:    parsedData.User.FirstName,
-		LastName:     parsedData.User.LastName,
-		Username:     parsedData.User.Username,
-		LanguageCode: parsedData.User.LanguageCode,
-		IsPremium:    parsedData.User.IsPremium,
-		PhotoURL:     parsedData.User.PhotoURL,
+	// 1-2. Validate initData (signature, age, replay) and read its user
+	tgUser, err := telegramUserFromInitData(c, initData)
+	if err != nil {
+		return telegramErrorResponse(c, err)
 	}
 
 	// Check database connection
@@ -180,6 +112,13 @@ func (h *AuthHandler) TelegramLogin(c *fiber.Ctx) error {
 	// 3. Find or Create User
 	var user models.User
 	result := database.DB.Where("telegram_id = ?", tgUser.ID).First(&user)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		// Linked to an account that already has another telegram_id (e.g. after a merge)
+		if linked, err := services.FindUserByAuthProvider(database.DB, models.AuthProviderTelegram, fmt.Sprintf("%d", tgUser.ID)); err == nil {
+			user = *linked
+			result.Error = nil
+		}
+	}
 
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
@@ -313,13 +252,45 @@ func (h *AuthHandler) TelegramLogin(c *fiber.Ctx) error {
 	}
 
 	// Ensure auth_providers entry exists for Telegram
-	if err := upsertAuthProvider(database.DB, user.ID, "telegram", fmt.Sprintf("%d", tgUser.ID), user.Email); err != nil {
+	if err := services.UpsertAuthProvider(database.DB, user.ID, models.AuthProviderTelegram, fmt.Sprintf("%d", tgUser.ID), user.Email); err != nil {
 		log.Printf("⚠️ Failed to upsert Telegram auth provider: %v", err)
 	}
 
 	return h.respondWithAuthTokens(c, &user, "Telegram")
 }
 
+// telegramUserFromInitData validates Mini App initData and returns the
+// Telegram user it was issued to
+func telegramUserFromInitData(c *fiber.Ctx, initData string) (*utils.TelegramUser, error) {
+	data, err := services.VerifyTelegramInitData(c.Context(), initData)
+	if err != nil {
+		return nil, err
+	}
+	return &data.User, nil
+}
+
+// telegramErrorResponse returns a rejected initData with its error code
+func telegramErrorResponse(c *fiber.Ctx, err error) error {
+	var initDataErr *utils.TelegramInitDataError
+	if !errors.As(err, &initDataErr) {
+		log.Printf("❌ Telegram validation failed: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Telegram validation failed"})
+	}
+	if initDataErr.Code == utils.TelegramErrNotConfigured {
+		log.Printf("❌ Telegram bot token / bot ID is not configured")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Server configuration error",
+			"code":  initDataErr.Code,
+		})
+	}
+	log.Printf("❌ InitData rejected: %s (%s)", initDataErr.Code, initDataErr.Message)
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		"error":   "Invalid Telegram data",
+		"code":    initDataErr.Code,
+		"details": initDataErr.Message,
+	})
+}
+
 // GoogleLogin handles Firebase/Google Sign-In tokens for Web/PWA users
 func (h *AuthHandler) GoogleLogin(c *fiber.Ctx) error {
 	var req struct {
@@ -346,95 +317,18 @@ func (h *AuthHandler) GoogleLogin(c *fiber.Ctx) error {
 		}
 	}
 
-	if h.cfg.GoogleClientID == "" {
-		log.Printf("❌ GoogleClientID is not configured")
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   "Server configuration error",
-			"details": "Google client ID is not configured",
-		})
-	}
-
-	log.Printf("🔍 Validating Google token with client ID: %s", h.cfg.GoogleClientID)
-	log.Printf("🔍 Token length: %d", len(req.IDToken))
-
-	// TEMPORARY: Decode Firebase token without validation
-	// Firebase has already validated this token on the client side
-	// TODO: Add proper Firebase Admin SDK validation for production
-	parts = strings.Split(req.IDToken, ".")
-	if len(parts) < 2 {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Invalid token format",
-		})
-	}
-
-	decoded, err := base64.RawURLEncoding.DecodeString(parts[1])
+	identity, err := googleIdentityFromToken(c.Context(), req.IDToken)
 	if err != nil {
-		log.Printf("❌ Failed to decode token: %v", err)
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error":   "Failed to decode token",
-			"details": err.Error(),
-		})
-	}
-
-	var claims map[string]interface{}
-	if err := json.Unmarshal(decoded, &claims); err != nil {
-		log.Printf("❌ Failed to parse token claims: %v", err)
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error":   "Failed to parse token",
-			"details": err.Error(),
-		})
-	}
-
-	log.Printf("✅ Token decoded successfully")
-
-	// Extract user info from claims
-	sub, _ := claims["sub"].(string)
-	if sub == "" {
-		sub = fmt.Sprint(claims["sub"])
-	}
-	if sub == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Google token missing subject",
-		})
-	}
-
-	email, _ := claims["email"].(string)
-	email = strings.TrimSpace(strings.ToLower(email))
-
-	emailVerified := false
-	switch v := claims["email_verified"].(type) {
-	case bool:
-		emailVerified = v
-	case string:
-		emailVerified = strings.EqualFold(v, "true")
-	}
-
-	if email == "" || !emailVerified {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Verified email is required for Google authentication",
-		})
-	}
-
-	fullName, _ := claims["name"].(string)
-	if fullName == "" {
-		given, _ := claims["given_name"].(string)
-		family, _ := claims["family_name"].(string)
-		fullName = strings.TrimSpace(strings.Join([]string{given, family}, " "))
-	}
-	if fullName == "" && email != "" {
-		fullName = strings.Split(email, "@")[0]
+		log.Printf("❌ Google token rejected: %v", err)
+		return googleTokenErrorResponse(c, err)
 	}
-	if fullName == "" {
-		fullName = "Lomi Member"
-	}
-
-	photoURL, _ := claims["picture"].(string)
+	sub, email, fullName, photoURL := identity.Sub, identity.Email, identity.Name, identity.PhotoURL
 
 	var user models.User
 
 	err = database.DB.Transaction(func(tx *gorm.DB) error {
 		// 1. Try to find by auth provider
-		existingUser, providerErr := findUserByAuthProvider(tx, "google", sub)
+		existingUser, providerErr := services.FindUserByAuthProvider(tx, models.AuthProviderGoogle, sub)
 		if providerErr == nil && existingUser != nil {
 			user = *existingUser
 			return nil
@@ -460,20 +354,9 @@ func (h *AuthHandler) GoogleLogin(c *fiber.Ctx) error {
 			username = strings.ReplaceAll(username, ".", "_")
 			username = strings.ReplaceAll(username, "+", "_")
 
-			// Check if username exists and make it unique
-			var existingUser models.User
-			baseUsername := username
-			counter := 1
-			for {
-				if err := tx.Where("username = ?", username).First(&existingUser).Error; err != nil {
-					if errors.Is(err, gorm.ErrRecordNotFound) {
-						break // Username is available
-					}
-					return err
-				}
-				// Username exists, try with counter
-				username = fmt.Sprintf("%s%d", baseUsername, counter)
-				counter++
+			username, err := uniqueUsername(tx, username)
+			if err != nil {
+				return err
 			}
 
 			newUser := models.User{
@@ -521,7 +404,7 @@ func (h *AuthHandler) GoogleLogin(c *fiber.Ctx) error {
 		}
 
 		// Ensure auth_providers entry exists
-		if err := upsertAuthProvider(tx, user.ID, "google", sub, email); err != nil {
+		if err := services.UpsertAuthProvider(tx, user.ID, models.AuthProviderGoogle, sub, email); err != nil {
 			return err
 		}
 
@@ -540,42 +423,143 @@ func (h *AuthHandler) GoogleLogin(c *fiber.Ctx) error {
 	return h.respondWithAuthTokens(c, &user, "Google")
 }
 
-// RefreshToken handles token refresh
+// googleIdentity is the account a Google ID token was issued to
+type googleIdentity struct {
+	Sub      string
+	Email    string // Lowercased, verified
+	Name     string
+	PhotoURL string
+}
+
+var errGoogleEmailUnverified = errors.New("Verified email is required for Google authentication")
+
+// googleIdentityFromToken verifies a Firebase/Google ID token and reads its claims
+func googleIdentityFromToken(ctx context.Context, idToken string) (*googleIdentity, error) {
+	claims, err := services.VerifyGoogleIDToken(ctx, idToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// Extract user info from claims
+	sub, _ := claims["sub"].(string)
+	if sub == "" && claims["sub"] != nil {
+		sub = fmt.Sprint(claims["sub"])
+	}
+	if sub == "" {
+		return nil, errors.New("Google token missing subject")
+	}
+
+	email, _ := claims["email"].(string)
+	email = strings.TrimSpace(strings.ToLower(email))
+
+	emailVerified := false
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		emailVerified = v
+	case string:
+		emailVerified = strings.EqualFold(v, "true")
+	}
+
+	if email == "" || !emailVerified {
+		return nil, errGoogleEmailUnverified
+	}
+
+	fullName, _ := claims["name"].(string)
+	if fullName == "" {
+		given, _ := claims["given_name"].(string)
+		family, _ := claims["family_name"].(string)
+		fullName = strings.TrimSpace(strings.Join([]string{given, family}, " "))
+	}
+	if fullName == "" {
+		fullName = strings.Split(email, "@")[0]
+	}
+
+	photoURL, _ := claims["picture"].(string)
+	return &googleIdentity{Sub: sub, Email: email, Name: fullName, PhotoURL: photoURL}, nil
+}
+
+// googleTokenErrorResponse maps googleIdentityFromToken errors to responses
+func googleTokenErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errGoogleEmailUnverified):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrGoogleAuthDisabled):
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": services.ErrGoogleTokenInvalid.Error()})
+	}
+}
+
+// deviceFromRequest describes the client a token is issued to. Apps send a
+// stable X-Device-ID so logging in again replaces that device's session, and
+// may send X-Device-Name, X-Platform and X-App-Version for the device list.
+func deviceFromRequest(c *fiber.Ctx) services.DeviceInfo {
+	return services.DeviceInfo{
+		DeviceID:   c.Get("X-Device-ID"),
+		DeviceName: c.Get("X-Device-Name"),
+		Platform:   strings.ToLower(c.Get("X-Platform")),
+		AppVersion: c.Get("X-App-Version"),
+		UserAgent:  c.Get("User-Agent"),
+		IP:         c.IP(),
+	}
+}
+
+// RefreshToken rotates a refresh token: the presented token is used up and a
+// new pair is returned. Replaying a used token logs out that login everywhere.
 func RefreshToken(c *fiber.Ctx) error {
 	var req struct {
 		RefreshToken string `json:"refresh_token"`
 	}
-	if err := c.BodyParser(&req); err != nil {
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	cfg := config.Cfg
+	tokens, err := services.RefreshTokens(c.Context(), req.RefreshToken, deviceFromRequest(c))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Printf("❌ Failed to refresh tokens: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate tokens"})
+	}
 
-	// Parse refresh token
-	token, err := jwt.Parse(req.RefreshToken, func(token *jwt.Token) (interface{}, error) {
-		return []byte(cfg.JWTSecret), nil
+	return c.JSON(fiber.Map{
+		"access_token":  tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
 	})
+}
 
-	if err != nil || !token.Valid {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid refresh token"})
+// Logout revokes the current session: its refresh tokens and access token
+func Logout(c *fiber.Ctx) error {
+	claims, err := auth.ClaimsFrom(c)
+	if err != nil {
+		return auth.Unauthorized(c)
 	}
+	userID := claims.UserID
 
-	claims := token.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, err := uuid.Parse(userIDStr)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID in token"})
+	if err := services.Logout(c.Context(), userID, claims.SessionID); err != nil {
+		log.Printf("❌ Failed to log out user %s: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to log out"})
 	}
+	return c.JSON(fiber.Map{"message": "Logged out"})
+}
 
-	// Generate new tokens
-	tokens, err := utils.CreateToken(userID, cfg.JWTSecret)
+// LogoutAllDevices revokes every session of the user, this one included
+func LogoutAllDevices(c *fiber.Ctx) error {
+	userID, err := auth.UserID(c)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate tokens"})
+		return auth.Unauthorized(c)
 	}
 
+	sessions, err := services.LogoutAllDevices(c.Context(), userID)
+	if err != nil {
+		log.Printf("❌ Failed to log out all devices of user %s: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to log out"})
+	}
+	log.Printf("✅ Logged out all devices: user_id=%s sessions=%d", userID, sessions)
 	return c.JSON(fiber.Map{
-		"access_token":  tokens.AccessToken,
-		"refresh_token": tokens.RefreshToken,
+		"message":          "Logged out of all devices",
+		"revoked_sessions": sessions,
 	})
 }
 
@@ -588,7 +572,14 @@ func (h *AuthHandler) respondWithAuthTokens(c *fiber.Ctx, user *models.User, sou
 		})
 	}
 
-	tokens, err := utils.CreateToken(user.ID, h.cfg.JWTSecret)
+	// Logging in during the grace period keeps the account
+	canceled, err := services.CancelAccountDeletion(c.Context(), user.ID)
+	if err != nil {
+		log.Printf("❌ Failed to cancel account deletion on %s login for %s: %v", source, user.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not log in, please try again"})
+	}
+
+	tokens, err := services.IssueTokens(c.Context(), user.ID, deviceFromRequest(c))
 	if err != nil {
 		log.Printf("❌ Failed to generate tokens for %s login: %v", source, err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -602,7 +593,7 @@ func (h *AuthHandler) respondWithAuthTokens(c *fiber.Ctx, user *models.User, sou
 	log.Printf("✅ %s auth success: user_id=%s telegram_id=%s onboarding_step=%d completed=%v",
 		source, user.ID, utils.TelegramIDString(user.TelegramID), user.OnboardingStep, user.OnboardingCompleted)
 
-	return c.JSON(fiber.Map{
+	response := fiber.Map{
 		"access_token":  tokens.AccessToken,
 		"refresh_token": tokens.RefreshToken,
 		"user": fiber.Map{
@@ -613,56 +604,29 @@ func (h *AuthHandler) respondWithAuthTokens(c *fiber.Ctx, user *models.User, sou
 			"onboarding_step":      user.OnboardingStep,
 			"onboarding_completed": user.OnboardingCompleted,
 		},
-	})
-}
-
-func findUserByAuthProvider(tx *gorm.DB, provider, providerID string) (*models.User, error) {
-	var authProvider models.AuthProvider
-	if err := tx.Where("provider = ? AND provider_id = ?", provider, providerID).First(&authProvider).Error; err != nil {
-		return nil, err
 	}
-
-	var user models.User
-	if err := tx.Where("id = ?", authProvider.UserID).First(&user).Error; err != nil {
-		return nil, err
+	if canceled != nil {
+		response["deletion_canceled"] = true
 	}
-	return &user, nil
+	return c.JSON(response)
 }
 
-func upsertAuthProvider(tx *gorm.DB, userID uuid.UUID, provider, providerID, email string) error {
-	if provider == "" || providerID == "" {
-		return fmt.Errorf("provider information missing")
-	}
-
-	var existing models.AuthProvider
-	if err := tx.Where("provider = ? AND provider_id = ?", provider, providerID).First(&existing).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			entry := models.AuthProvider{
-				UserID:     userID,
-				Provider:   provider,
-				ProviderID: providerID,
-				Email:      email,
-				LinkedAt:   time.Now(),
+// uniqueUsername returns base, or base with a counter when it's taken
+func uniqueUsername(tx *gorm.DB, base string) (string, error) {
+	username := base
+	counter := 1
+	for {
+		var existing models.User
+		if err := tx.Where("username = ?", username).First(&existing).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return username, nil // Username is available
 			}
-			return tx.Create(&entry).Error
+			return "", err
 		}
-		return err
-	}
-
-	needsUpdate := false
-	if existing.UserID != userID {
-		existing.UserID = userID
-		needsUpdate = true
-	}
-	if email != "" && !strings.EqualFold(existing.Email, email) {
-		existing.Email = email
-		needsUpdate = true
-	}
-	if needsUpdate {
-		existing.LinkedAt = time.Now()
-		return tx.Save(&existing).Error
+		// Username exists, try with counter
+		username = fmt.Sprintf("%s%d", base, counter)
+		counter++
 	}
-	return nil
 }
 
 // TelegramWidgetLogin - REMOVED: No longer needed
@@ -874,7 +838,7 @@ func (h *AuthHandler) TelegramWidgetLogin_DEPRECATED(c *fiber.Ctx) error {
 	}
 
 	// Generate JWT Tokens
-	tokens, err := utils.CreateToken(user.ID, h.cfg.JWTSecret)
+	tokens, err := services.IssueTokens(c.Context(), user.ID, deviceFromRequest(c))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not generate tokens"})
 	}
@@ -0,0 +1,664 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"lomi-backend/internal/auth"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ==================== LIVE PK BATTLES ====================
+// Two broadcasters link their live streams for a timed round.
+// - Scores live in Redis (battle:<id>:scores) while the round is running
+// - Every gift to either side pushes a scoreboard to BOTH live:<stream> channels
+// - Each gift is also added to live_battles, so a round can be settled after a restart
+//   even once the Redis keys are gone
+// - When the timer fires the result is persisted and a system message names the winner
+
+const (
+	battleDefaultDuration = 300  // 5 minutes
+	battleMinDuration     = 60   // 1 minute
+	battleMaxDuration     = 1800 // 30 minutes
+	battleInviteTTL       = 2 * time.Minute
+)
+
+func battleStreamKey(liveStreamID string) string {
+	return fmt.Sprintf("live:%s:battle", liveStreamID)
+}
+
+func battleMetaKey(battleID string) string {
+	return fmt.Sprintf("battle:%s", battleID)
+}
+
+func battleScoresKey(battleID string) string {
+	return fmt.Sprintf("battle:%s:scores", battleID)
+}
+
+func battleSupportersKey(battleID string) string {
+	return fmt.Sprintf("battle:%s:supporters", battleID)
+}
+
+// checkBattleStream makes sure a stream belongs to the broadcaster and is live.
+// Streams without a live_streams record are chat-only streams keyed by the
+// broadcaster's user ID; they count as live while anyone is connected to the chat.
+func checkBattleStream(liveStreamID, broadcasterID uuid.UUID) error {
+	var stream models.LiveStream
+	err := database.DB.Select("id", "user_id", "status").First(&stream, "id = ?", liveStreamID).Error
+	switch {
+	case err == nil:
+		if stream.UserID != broadcasterID {
+			return errors.New("stream belongs to another broadcaster")
+		}
+		if stream.Status != models.LiveStreamStatusLive {
+			return errors.New("stream is not live")
+		}
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if liveStreamID != broadcasterID {
+			return errors.New("stream belongs to another broadcaster")
+		}
+		viewers, _ := database.RedisClient.Get(ctx, fmt.Sprintf("live:%s:viewers", liveStreamID)).Int()
+		if viewers <= 0 {
+			return errors.New("stream is not live")
+		}
+		return nil
+	default:
+		return fmt.Errorf("failed to load stream: %w", err)
+	}
+}
+
+// scheduleBattleEnd settles the battle when its round is over
+func scheduleBattleEnd(battleID uuid.UUID, endsAt time.Time) {
+	time.AfterFunc(time.Until(endsAt), func() {
+		finishBattle(battleID)
+	})
+}
+
+// ResumeBattleTimers re-arms the end timer of every active battle. Timers only
+// live in process memory, so this runs at startup; rounds that ended while the
+// server was down are settled straight away.
+func ResumeBattleTimers() {
+	var battles []models.LiveBattle
+	if err := database.DB.Select("id", "ends_at").
+		Where("status = ?", models.LiveBattleStatusActive).
+		Find(&battles).Error; err != nil {
+		log.Printf("❌ Failed to load active battles: %v", err)
+		return
+	}
+	for _, battle := range battles {
+		endsAt := time.Now()
+		if battle.EndsAt != nil {
+			endsAt = *battle.EndsAt
+		}
+		scheduleBattleEnd(battle.ID, endsAt)
+	}
+	if len(battles) > 0 {
+		log.Printf("⚔️ Resumed %d battle timer(s)", len(battles))
+	}
+}
+
+// StartBattle invites another live broadcaster to a PK battle
+func StartBattle(c *fiber.Ctx) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+
+	var req struct {
+		LiveStreamID     string `json:"live_stream_id"`
+		OpponentStreamID string `json:"opponent_stream_id"`
+		OpponentID       string `json:"opponent_id"`
+		DurationSeconds  int    `json:"duration_seconds"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	streamAID, err := uuid.Parse(req.LiveStreamID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid live stream ID"})
+	}
+	streamBID, err := uuid.Parse(req.OpponentStreamID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid opponent stream ID"})
+	}
+	opponentID, err := uuid.Parse(req.OpponentID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid opponent ID"})
+	}
+	if opponentID == userID || streamAID == streamBID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot battle yourself"})
+	}
+
+	if req.DurationSeconds == 0 {
+		req.DurationSeconds = battleDefaultDuration
+	}
+	if req.DurationSeconds < battleMinDuration || req.DurationSeconds > battleMaxDuration {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Duration must be between %d and %d seconds", battleMinDuration, battleMaxDuration),
+		})
+	}
+
+	var opponent models.User
+	if err := database.DB.First(&opponent, "id = ?", opponentID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Opponent not found"})
+	}
+
+	if err := checkBattleStream(streamAID, userID); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Your stream: " + err.Error()})
+	}
+	if err := checkBattleStream(streamBID, opponentID); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Opponent stream: " + err.Error()})
+	}
+
+	battle := models.LiveBattle{
+		ID:              uuid.New(),
+		StreamAID:       streamAID,
+		BroadcasterAID:  userID,
+		StreamBID:       streamBID,
+		BroadcasterBID:  opponentID,
+		DurationSeconds: req.DurationSeconds,
+		Status:          models.LiveBattleStatusPending,
+	}
+
+	// Reserve both streams so neither can join a second battle at the same time
+	battleID := battle.ID.String()
+	okA, err := database.RedisClient.SetNX(ctx, battleStreamKey(streamAID.String()), battleID+":a", battleInviteTTL).Result()
+	if err != nil {
+		log.Printf("❌ Failed to reserve stream for battle: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to start battle"})
+	}
+	if !okA {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Your stream is already in a battle"})
+	}
+	okB, err := database.RedisClient.SetNX(ctx, battleStreamKey(streamBID.String()), battleID+":b", battleInviteTTL).Result()
+	if err != nil || !okB {
+		database.RedisClient.Del(ctx, battleStreamKey(streamAID.String()))
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Opponent is already in a battle"})
+	}
+
+	if err := database.DB.Create(&battle).Error; err != nil {
+		database.RedisClient.Del(ctx, battleStreamKey(streamAID.String()), battleStreamKey(streamBID.String()))
+		log.Printf("❌ Failed to create battle: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to start battle"})
+	}
+
+	// Let the opponent's stream know about the invite
+	chatHub.publishToLive(streamBID.String(), &WSChatMessage{
+		Type:         "battle_invite",
+		Mode:         ChatModeLive,
+		LiveStreamID: streamBID.String(),
+		SenderID:     userID.String(),
+		Timestamp:    time.Now().Format(time.RFC3339),
+		Metadata: map[string]interface{}{
+			"battle_id":        battleID,
+			"from_stream_id":   streamAID.String(),
+			"duration_seconds": battle.DurationSeconds,
+		},
+	})
+
+	log.Printf("⚔️ Battle invite: %s (%s) -> %s (%s)", userID, streamAID, opponentID, streamBID)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Battle invite sent",
+		"battle":  battle,
+	})
+}
+
+// AcceptBattle starts the round. Only the invited broadcaster can accept.
+func AcceptBattle(c *fiber.Ctx) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+
+	battleID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid battle ID"})
+	}
+
+	var battle models.LiveBattle
+	if err := database.DB.First(&battle, "id = ?", battleID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Battle not found"})
+	}
+	if battle.BroadcasterBID != userID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Only the invited broadcaster can accept"})
+	}
+	if battle.Status != models.LiveBattleStatusPending {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Battle is no longer pending"})
+	}
+	// Either broadcaster may have ended their stream since the invite
+	for _, stream := range []struct {
+		id, owner uuid.UUID
+	}{{battle.StreamAID, battle.BroadcasterAID}, {battle.StreamBID, battle.BroadcasterBID}} {
+		if err := checkBattleStream(stream.id, stream.owner); err != nil {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	// The invite is only valid while both stream reservations are still held
+	keyA := battleStreamKey(battle.StreamAID.String())
+	keyB := battleStreamKey(battle.StreamBID.String())
+	heldA, _ := database.RedisClient.Get(ctx, keyA).Result()
+	heldB, _ := database.RedisClient.Get(ctx, keyB).Result()
+	if heldA != battleID.String()+":a" || heldB != battleID.String()+":b" {
+		database.DB.Model(&models.LiveBattle{}).
+			Where("id = ? AND status = ?", battleID, models.LiveBattleStatusPending).
+			Update("status", models.LiveBattleStatusCancelled)
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": "Battle invite expired"})
+	}
+
+	now := time.Now()
+	duration := time.Duration(battle.DurationSeconds) * time.Second
+	endsAt := now.Add(duration)
+
+	result := database.DB.Model(&models.LiveBattle{}).
+		Where("id = ? AND status = ?", battleID, models.LiveBattleStatusPending).
+		Updates(map[string]interface{}{
+			"status":     models.LiveBattleStatusActive,
+			"started_at": now,
+			"ends_at":    endsAt,
+			"updated_at": now,
+		})
+	if result.Error != nil {
+		log.Printf("❌ Failed to start battle: %v", result.Error)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to start battle"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Battle is no longer pending"})
+	}
+	battle.Status = models.LiveBattleStatusActive
+	battle.StartedAt = &now
+	battle.EndsAt = &endsAt
+
+	// Keep the reservations (plus some slack) for the length of the round
+	ttl := duration + time.Minute
+	pipe := database.RedisClient.TxPipeline()
+	pipe.Set(ctx, keyA, battleID.String()+":a", ttl)
+	pipe.Set(ctx, keyB, battleID.String()+":b", ttl)
+	pipe.HSet(ctx, battleMetaKey(battleID.String()), map[string]interface{}{
+		"stream_a":      battle.StreamAID.String(),
+		"broadcaster_a": battle.BroadcasterAID.String(),
+		"stream_b":      battle.StreamBID.String(),
+		"broadcaster_b": battle.BroadcasterBID.String(),
+		"ends_at":       endsAt.Unix(),
+	})
+	pipe.HSet(ctx, battleScoresKey(battleID.String()), "a", 0, "b", 0, "gifts_a", 0, "gifts_b", 0)
+	pipe.Expire(ctx, battleMetaKey(battleID.String()), ttl)
+	pipe.Expire(ctx, battleScoresKey(battleID.String()), ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("⚠️ Failed to initialise battle state in Redis: %v", err)
+	}
+
+	scheduleBattleEnd(battleID, endsAt)
+
+	publishBattleScoreboard(&battle, "battle_start", 0, 0)
+
+	log.Printf("⚔️ Battle started: %s (%ds)", battleID, battle.DurationSeconds)
+
+	return c.JSON(fiber.Map{
+		"message": "Battle started",
+		"battle":  battle,
+	})
+}
+
+// CancelBattle withdraws or declines a pending invite (either broadcaster)
+func CancelBattle(c *fiber.Ctx) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+
+	battleID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid battle ID"})
+	}
+
+	var battle models.LiveBattle
+	if err := database.DB.First(&battle, "id = ?", battleID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Battle not found"})
+	}
+	if battle.BroadcasterAID != userID && battle.BroadcasterBID != userID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Not a participant in this battle"})
+	}
+
+	result := database.DB.Model(&models.LiveBattle{}).
+		Where("id = ? AND status = ?", battleID, models.LiveBattleStatusPending).
+		Updates(map[string]interface{}{
+			"status":     models.LiveBattleStatusCancelled,
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to cancel battle"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Only pending battles can be cancelled"})
+	}
+
+	releaseBattleStreams(&battle)
+
+	for _, streamID := range []uuid.UUID{battle.StreamAID, battle.StreamBID} {
+		chatHub.publishToLive(streamID.String(), &WSChatMessage{
+			Type:         "battle_cancelled",
+			Mode:         ChatModeLive,
+			LiveStreamID: streamID.String(),
+			SenderID:     userID.String(),
+			Timestamp:    time.Now().Format(time.RFC3339),
+			Metadata:     map[string]interface{}{"battle_id": battleID.String()},
+		})
+	}
+
+	return c.JSON(fiber.Map{"message": "Battle cancelled"})
+}
+
+// GetBattle returns a battle with its live (or final) scores
+func GetBattle(c *fiber.Ctx) error {
+	battleID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid battle ID"})
+	}
+
+	var battle models.LiveBattle
+	if err := database.DB.First(&battle, "id = ?", battleID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Battle not found"})
+	}
+
+	if battle.Status == models.LiveBattleStatusActive {
+		// Settle rounds whose timer was lost (the process stopped before it fired)
+		if battle.EndsAt != nil && time.Now().After(*battle.EndsAt) {
+			finishBattle(battle.ID)
+			database.DB.First(&battle, "id = ?", battleID)
+		} else {
+			battle.ScoreA, battle.ScoreB, battle.GiftCountA, battle.GiftCountB = readBattleScores(battle.ID.String())
+		}
+	}
+
+	return c.JSON(fiber.Map{"battle": battle})
+}
+
+// GetStreamBattle returns the pending or active battle for a live stream, if any
+func GetStreamBattle(c *fiber.Ctx) error {
+	liveStreamID := c.Params("id")
+
+	held, err := database.RedisClient.Get(ctx, battleStreamKey(liveStreamID)).Result()
+	if err != nil {
+		return c.JSON(fiber.Map{"battle": nil})
+	}
+	battleID, _, _ := strings.Cut(held, ":")
+
+	var battle models.LiveBattle
+	if err := database.DB.First(&battle, "id = ?", battleID).Error; err != nil {
+		return c.JSON(fiber.Map{"battle": nil})
+	}
+	if battle.Status == models.LiveBattleStatusActive {
+		battle.ScoreA, battle.ScoreB, battle.GiftCountA, battle.GiftCountB = readBattleScores(battle.ID.String())
+	}
+
+	return c.JSON(fiber.Map{"battle": battle})
+}
+
+// ==================== SCORING ====================
+
+// recordBattleGift adds a gift to the battle score when the receiving stream is
+// in an active battle. It is called after the gift transaction has committed, so
+// failures here never affect the gift itself.
+func recordBattleGift(liveStreamID string, senderID, receiverID uuid.UUID, coins int) {
+	if liveStreamID == "" || coins <= 0 || database.RedisClient == nil {
+		return
+	}
+
+	held, err := database.RedisClient.Get(ctx, battleStreamKey(liveStreamID)).Result()
+	if err != nil {
+		return // Stream isn't battling
+	}
+	battleID, side, ok := strings.Cut(held, ":")
+	if !ok || (side != "a" && side != "b") {
+		return
+	}
+
+	meta, err := database.RedisClient.HGetAll(ctx, battleMetaKey(battleID)).Result()
+	if err != nil || len(meta) == 0 {
+		return // Invite still pending
+	}
+
+	// Only gifts to the broadcaster of that side count, and only before the bell
+	if meta["broadcaster_"+side] != receiverID.String() {
+		return
+	}
+	endsAt, _ := strconv.ParseInt(meta["ends_at"], 10, 64)
+	if time.Now().Unix() >= endsAt {
+		return
+	}
+
+	scoresKey := battleScoresKey(battleID)
+	pipe := database.RedisClient.TxPipeline()
+	pipe.HIncrBy(ctx, scoresKey, side, int64(coins))
+	pipe.HIncrBy(ctx, scoresKey, "gifts_"+side, 1)
+	pipe.ZIncrBy(ctx, battleSupportersKey(battleID), float64(coins), senderID.String())
+	pipe.Expire(ctx, battleSupportersKey(battleID), time.Until(time.Unix(endsAt, 0))+time.Minute)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("❌ Failed to record battle gift: %v", err)
+		return
+	}
+
+	// Keep the score in the battle row too, it's what the result is settled from
+	if err := database.DB.Model(&models.LiveBattle{}).
+		Where("id = ? AND status = ? AND ends_at > ?", battleID, models.LiveBattleStatusActive, time.Now()).
+		Updates(map[string]interface{}{
+			"score_" + side:      gorm.Expr("score_"+side+" + ?", coins),
+			"gift_count_" + side: gorm.Expr("gift_count_" + side + " + 1"),
+		}).Error; err != nil {
+		log.Printf("❌ Failed to persist battle gift: %v", err)
+	}
+
+	scoreA, scoreB, giftsA, giftsB := readBattleScores(battleID)
+	streamA, streamB := meta["stream_a"], meta["stream_b"]
+	for _, streamID := range []string{streamA, streamB} {
+		chatHub.publishToLive(streamID, &WSChatMessage{
+			Type:         "battle_score",
+			Mode:         ChatModeLive,
+			LiveStreamID: streamID,
+			SenderID:     senderID.String(),
+			Timestamp:    time.Now().Format(time.RFC3339),
+			Metadata: map[string]interface{}{
+				"battle_id":   battleID,
+				"stream_a_id": streamA,
+				"stream_b_id": streamB,
+				"score_a":     scoreA,
+				"score_b":     scoreB,
+				"gifts_a":     giftsA,
+				"gifts_b":     giftsB,
+				"ends_at":     endsAt,
+				"last_gift":   fiber.Map{"side": side, "coins": coins},
+			},
+		})
+	}
+}
+
+func readBattleScores(battleID string) (scoreA, scoreB, giftsA, giftsB int) {
+	scores, err := database.RedisClient.HGetAll(ctx, battleScoresKey(battleID)).Result()
+	if err != nil {
+		return
+	}
+	scoreA, _ = strconv.Atoi(scores["a"])
+	scoreB, _ = strconv.Atoi(scores["b"])
+	giftsA, _ = strconv.Atoi(scores["gifts_a"])
+	giftsB, _ = strconv.Atoi(scores["gifts_b"])
+	return
+}
+
+func publishBattleScoreboard(battle *models.LiveBattle, msgType string, scoreA, scoreB int) {
+	metadata := map[string]interface{}{
+		"battle_id":        battle.ID.String(),
+		"stream_a_id":      battle.StreamAID.String(),
+		"stream_b_id":      battle.StreamBID.String(),
+		"broadcaster_a_id": battle.BroadcasterAID.String(),
+		"broadcaster_b_id": battle.BroadcasterBID.String(),
+		"score_a":          scoreA,
+		"score_b":          scoreB,
+		"duration_seconds": battle.DurationSeconds,
+	}
+	if battle.EndsAt != nil {
+		metadata["ends_at"] = battle.EndsAt.Unix()
+	}
+	if battle.WinnerID != nil {
+		metadata["winner_id"] = battle.WinnerID.String()
+	}
+	if battle.Status == models.LiveBattleStatusFinished {
+		metadata["is_draw"] = battle.IsDraw
+	}
+
+	for _, streamID := range []uuid.UUID{battle.StreamAID, battle.StreamBID} {
+		chatHub.publishToLive(streamID.String(), &WSChatMessage{
+			Type:         msgType,
+			Mode:         ChatModeLive,
+			LiveStreamID: streamID.String(),
+			Timestamp:    time.Now().Format(time.RFC3339),
+			Metadata:     metadata,
+		})
+	}
+}
+
+// ==================== RESULT ====================
+
+// finishBattle settles a round exactly once: the status flip from active to
+// finished is conditional, so a timer firing alongside a lazy GetBattle is safe.
+func finishBattle(battleID uuid.UUID) {
+	var battle models.LiveBattle
+	if err := database.DB.First(&battle, "id = ?", battleID).Error; err != nil {
+		log.Printf("❌ Failed to load battle %s: %v", battleID, err)
+		return
+	}
+	if battle.Status != models.LiveBattleStatusActive {
+		return
+	}
+
+	// The row has every gift counted before the bell, Redis may have expired
+	scoreA, scoreB, giftsA, giftsB := battle.ScoreA, battle.ScoreB, battle.GiftCountA, battle.GiftCountB
+
+	var winnerID *uuid.UUID
+	isDraw := scoreA == scoreB
+	if scoreA > scoreB {
+		winnerID = &battle.BroadcasterAID
+	} else if scoreB > scoreA {
+		winnerID = &battle.BroadcasterBID
+	}
+
+	var topSupporter *uuid.UUID
+	top, err := database.RedisClient.ZRevRangeWithScores(ctx, battleSupportersKey(battleID.String()), 0, 0).Result()
+	if err == nil && len(top) > 0 {
+		if member, ok := top[0].Member.(string); ok {
+			if id, err := uuid.Parse(member); err == nil {
+				topSupporter = &id
+			}
+		}
+	}
+
+	now := time.Now()
+	result := database.DB.Model(&models.LiveBattle{}).
+		Where("id = ? AND status = ?", battleID, models.LiveBattleStatusActive).
+		Updates(map[string]interface{}{
+			"status":        models.LiveBattleStatusFinished,
+			"score_a":       scoreA,
+			"score_b":       scoreB,
+			"gift_count_a":  giftsA,
+			"gift_count_b":  giftsB,
+			"winner_id":     winnerID,
+			"is_draw":       isDraw,
+			"top_supporter": topSupporter,
+			"ended_at":      now,
+			"updated_at":    now,
+		})
+	if result.Error != nil {
+		log.Printf("❌ Failed to persist battle result %s: %v", battleID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return // Someone else settled it
+	}
+
+	battle.Status = models.LiveBattleStatusFinished
+	battle.ScoreA, battle.ScoreB = scoreA, scoreB
+	battle.WinnerID = winnerID
+	battle.IsDraw = isDraw
+	battle.EndedAt = &now
+
+	releaseBattleStreams(&battle)
+	database.RedisClient.Del(ctx,
+		battleMetaKey(battleID.String()),
+		battleScoresKey(battleID.String()),
+		battleSupportersKey(battleID.String()),
+	)
+
+	publishBattleScoreboard(&battle, "battle_end", scoreA, scoreB)
+
+	// Announce the result as a system message on both streams
+	content := fmt.Sprintf("⚔️ Battle ended in a draw! %d - %d", scoreA, scoreB)
+	if winnerID != nil {
+		var winner models.User
+		database.DB.Select("id", "name").First(&winner, "id = ?", *winnerID)
+		content = fmt.Sprintf("🏆 %s wins the battle! %d - %d", winner.Name, scoreA, scoreB)
+	}
+	metadata := map[string]interface{}{"battle_id": battleID.String()}
+	chatHub.broadcastLiveSystemMessage(battle.StreamAID.String(), battle.BroadcasterAID, content, metadata)
+	chatHub.broadcastLiveSystemMessage(battle.StreamBID.String(), battle.BroadcasterBID, content, metadata)
+
+	log.Printf("🏁 Battle finished: %s (%d - %d)", battleID, scoreA, scoreB)
+}
+
+// releaseBattleStreams frees both stream reservations held by the battle
+func releaseBattleStreams(battle *models.LiveBattle) {
+	for _, pair := range []struct {
+		streamID uuid.UUID
+		side     string
+	}{{battle.StreamAID, "a"}, {battle.StreamBID, "b"}} {
+		key := battleStreamKey(pair.streamID.String())
+		// Only delete the key if it still points at this battle
+		held, err := database.RedisClient.Get(ctx, key).Result()
+		if err != nil {
+			continue
+		}
+		if held == battle.ID.String()+":"+pair.side {
+			database.RedisClient.Del(ctx, key)
+		}
+	}
+}
+
+// broadcastLiveSystemMessage sends a server-generated system message to a live
+// stream with the same sequencing and history (and so persistence) as chat messages.
+func (h *ChatHub) broadcastLiveSystemMessage(liveStreamID string, senderID uuid.UUID, content string, metadata map[string]interface{}) {
+	seqKey := fmt.Sprintf("live:%s:seq", liveStreamID)
+	seq, err := database.RedisClient.Incr(ctx, seqKey).Result()
+	if err != nil {
+		log.Printf("❌ Failed to generate sequence: %v", err)
+		return
+	}
+	database.RedisClient.Expire(ctx, seqKey, 24*time.Hour)
+
+	wsMsg := &WSChatMessage{
+		Type:         "system",
+		Mode:         ChatModeLive,
+		LiveStreamID: liveStreamID,
+		Seq:          seq,
+		IsSystem:     true,
+		Content:      content,
+		MessageType:  "system",
+		MessageID:    uuid.New().String(),
+		SenderID:     senderID.String(),
+		Timestamp:    time.Now().Format(time.RFC3339),
+		Metadata:     metadata,
+	}
+
+	h.publishToLive(liveStreamID, wsMsg)
+	appendLiveHistory(liveStreamID, wsMsg)
+}
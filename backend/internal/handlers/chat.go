@@ -1,22 +1,22 @@
 package handlers
 
 import (
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/database"
 	"lomi-backend/internal/models"
 	"lomi-backend/internal/services"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
 // GetChats returns all conversations for the current user
 func GetChats(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var matches []models.Match
 	if err := database.DB.Where("(user1_id = ? OR user2_id = ?) AND is_active = ?", userID, userID, true).
@@ -70,10 +70,10 @@ func GetChats(c *fiber.Ctx) error {
 
 // GetMessages returns messages for a specific match
 func GetMessages(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	matchID := c.Params("id")
 	page := c.QueryInt("page", 1)
@@ -117,10 +117,10 @@ func GetMessages(c *fiber.Ctx) error {
 
 // SendMessage creates a new message
 func SendMessage(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	senderID, _ := uuid.Parse(userIDStr)
+	senderID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req struct {
 		MatchID     string                 `json:"match_id"`
@@ -200,10 +200,10 @@ func SendMessage(c *fiber.Ctx) error {
 
 // MarkMessagesAsRead marks all messages in a match as read
 func MarkMessagesAsRead(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	matchID := c.Params("id")
 
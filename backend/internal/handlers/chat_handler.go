@@ -9,12 +9,13 @@ import (
 	"sync"
 	"time"
 
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/database"
 	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
@@ -301,33 +302,14 @@ func init() {
 // ==================== WEBSOCKET HANDLER ====================
 
 func HandleUnifiedChat(c *websocket.Conn) {
-	// Extract token from query params
-	tokenString := c.Query("token")
-	if tokenString == "" {
-		c.WriteJSON(fiber.Map{"error": "Token required"})
-		c.Close()
-		return
-	}
-
-	// Parse JWT token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte("your-super-secret-jwt-key-change-in-production"), nil // TODO: Get from config
-	})
-
-	if err != nil || !token.Valid {
-		c.WriteJSON(fiber.Map{"error": "Invalid token"})
-		c.Close()
-		return
-	}
-
-	claims := token.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, err := uuid.Parse(userIDStr)
-	if err != nil {
-		c.WriteJSON(fiber.Map{"error": "Invalid user ID"})
+	// Authenticated by middleware.WebSocketAuth before the upgrade
+	claims, ok := c.Locals(auth.LocalsKey).(*auth.Claims)
+	if !ok {
+		c.WriteJSON(fiber.Map{"error": "Unauthorized"})
 		c.Close()
 		return
 	}
+	userID := claims.UserID
 
 	// Get user info
 	var user models.User
@@ -584,6 +566,7 @@ func (c *ChatClient) handleLiveChatMessage(wsMsg *WSChatMessage) {
 	database.RedisClient.Expire(ctx, seqKey, 24*time.Hour)
 
 	// Prepare message
+	wsMsg.MessageID = uuid.New().String()
 	wsMsg.Seq = seq
 	wsMsg.SenderID = c.UserID.String()
 	wsMsg.SenderName = c.UserName
@@ -598,20 +581,28 @@ func (c *ChatClient) handleLiveChatMessage(wsMsg *WSChatMessage) {
 	// Publish to Redis Pub/Sub for real-time delivery
 	c.Hub.publishToLive(liveStreamID, wsMsg)
 
-	// Add to Redis Stream for persistence and replay
-	streamKey := fmt.Sprintf("live:%s:history", liveStreamID)
+	// Add to Redis Stream for replay; the live chat persister writes it to PostgreSQL
+	appendLiveHistory(liveStreamID, wsMsg)
+}
+
+// appendLiveHistory adds a sequenced live message to the stream's Redis history
+// and registers the stream with the live chat persister.
+func appendLiveHistory(liveStreamID string, wsMsg *WSChatMessage) {
+	streamKey := services.LiveChatHistoryKey(liveStreamID)
 	msgJSON, _ := json.Marshal(wsMsg)
-	database.RedisClient.XAdd(ctx, &redis.XAddArgs{
+	pipe := database.RedisClient.Pipeline()
+	pipe.XAdd(ctx, &redis.XAddArgs{
 		Stream: streamKey,
 		Values: map[string]interface{}{
-			"seq":     seq,
+			"seq":     wsMsg.Seq,
 			"message": string(msgJSON),
 		},
 	})
-	database.RedisClient.Expire(ctx, streamKey, 24*time.Hour)
-
-	// Async save to PostgreSQL
-	go c.saveLiveMessageToDB(wsMsg)
+	pipe.Expire(ctx, streamKey, 24*time.Hour)
+	pipe.SAdd(ctx, services.LiveChatActiveStreamsKey, streamKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("❌ Failed to append live history: %v", err)
+	}
 }
 
 func (c *ChatClient) handleLiveGift(wsMsg *WSChatMessage) {
@@ -685,7 +676,7 @@ func (c *ChatClient) sendMissedMessages(lastSeq int64) {
 	}
 
 	liveStreamID := c.LiveStreamID.String()
-	streamKey := fmt.Sprintf("live:%s:history", liveStreamID)
+	streamKey := services.LiveChatHistoryKey(liveStreamID)
 
 	// Read from Redis Stream
 	messages, err := database.RedisClient.XRange(ctx, streamKey, "-", "+").Result()
@@ -730,49 +721,6 @@ func (c *ChatClient) sendMissedMessages(lastSeq int64) {
 	}
 }
 
-// ==================== DATABASE PERSISTENCE ====================
-
-func (c *ChatClient) saveLiveMessageToDB(wsMsg *WSChatMessage) {
-	if c.LiveStreamID == nil {
-		return
-	}
-
-	msg := models.Message{
-		LiveStreamID: c.LiveStreamID,
-		SenderID:     c.UserID,
-		MessageType:  models.MessageType(wsMsg.MessageType),
-		Content:      "",
-		IsLive:       true,
-		IsSystem:     wsMsg.Type == "system",
-		Seq:          wsMsg.Seq,
-		Pinned:       wsMsg.IsPinned,
-	}
-
-	if wsMsg.Content != nil {
-		if contentStr, ok := wsMsg.Content.(string); ok {
-			msg.Content = contentStr
-		}
-	}
-
-	if wsMsg.GiftID != "" {
-		giftID, _ := uuid.Parse(wsMsg.GiftID)
-		msg.GiftID = &giftID
-	}
-
-	if wsMsg.MediaURL != "" {
-		msg.MediaURL = wsMsg.MediaURL
-	}
-
-	if wsMsg.Metadata != nil {
-		msg.Metadata = models.JSONMap(wsMsg.Metadata)
-	}
-
-	// Async save (non-blocking)
-	if err := database.DB.Create(&msg).Error; err != nil {
-		log.Printf("❌ Failed to save live message: %v", err)
-	}
-}
-
 // ==================== RATE LIMITER ====================
 
 type RateLimiter struct {
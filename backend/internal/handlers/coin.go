@@ -1,43 +1,50 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/database"
+	"lomi-backend/internal/economy"
 	"lomi-backend/internal/models"
+	"lomi-backend/internal/payments"
+	"lomi-backend/internal/services"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
 )
 
 // GetCoinBalance returns the current user's coin balance
 func GetCoinBalance(c *fiber.Ctx) error {
-	token := c.Locals("user").(*jwt.Token)
-	claims := token.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var user models.User
 	if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
 	}
 
+	// A negative coin balance is debt left by a refunded purchase
 	return c.JSON(fiber.Map{
 		"coin_balance": user.CoinBalance,
 		"gift_balance": user.GiftBalance,
+		"in_debt":      user.CoinBalance < 0,
 	})
 }
 
 // PurchaseCoins initiates a coin purchase (creates pending transaction)
 func PurchaseCoins(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req struct {
 		CoinAmount    int    `json:"coin_amount"`
 		PaymentMethod string `json:"payment_method"` // telebirr, cbe_birr, hellocash, amole
+		PromoCode     string `json:"promo_code"`     // Optional
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
@@ -47,130 +54,76 @@ func PurchaseCoins(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid coin amount"})
 	}
 
-	// Calculate Birr amount (1 coin = 0.10 Birr, or configurable)
-	coinToBirrRate := 0.10
-	birrAmount := float64(req.CoinAmount) * coinToBirrRate
+	provider, err := payments.Get(models.PaymentMethod(req.PaymentMethod))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unsupported payment method"})
+	}
+
+	pricing, err := economy.Current(c.Context())
+	if err != nil {
+		return economyErrorResponse(c, err)
+	}
+	// A custom amount only gets promotions that aren't limited to packs
+	quote, err := services.QuotePurchase(c.Context(), userID, "", req.CoinAmount, pricing.CoinsToBirr(req.CoinAmount), req.PromoCode)
+	if err != nil {
+		return promotionErrorResponse(c, err)
+	}
 
 	// Create pending transaction
 	transaction := models.CoinTransaction{
 		UserID:          userID,
 		TransactionType: models.TransactionTypePurchase,
-		CoinAmount:      req.CoinAmount,
-		BirrAmount:      birrAmount,
-		PaymentMethod:   models.PaymentMethod(req.PaymentMethod),
+		PaymentMethod:   provider.Method(),
 		PaymentStatus:   models.PaymentStatusPending,
 		BalanceAfter:    0, // Will be updated after payment confirmation
+		EconomyVersion:  &pricing.Version,
 	}
 
-	if err := database.DB.Create(&transaction).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create transaction"})
+	if err := services.CreateCoinPurchase(c.Context(), &transaction, quote); err != nil {
+		return promotionErrorResponse(c, err)
 	}
 
-	// Generate payment URL based on payment method
-	paymentURL := generatePaymentURL(models.PaymentMethod(req.PaymentMethod), transaction.ID.String(), birrAmount, userID)
+	checkout, err := startCheckout(c.Context(), provider, &transaction)
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Failed to start payment"})
+	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"transaction_id": transaction.ID,
 		"coin_amount":    req.CoinAmount,
-		"birr_amount":    birrAmount,
+		"bonus_coins":    quote.BonusCoins,
+		"birr_amount":    quote.PriceBirr,
+		"promotions":     quote.Promotions,
 		"payment_method": req.PaymentMethod,
-		"payment_url":    paymentURL,
+		"payment_url":    checkout.PaymentURL,
 		"status":         "pending",
 	})
 }
 
-// generatePaymentURL generates payment gateway redirect URL
-func generatePaymentURL(paymentMethod models.PaymentMethod, transactionID string, amount float64, userID uuid.UUID) string {
-	// Base URL for payment gateways (configure these in your config)
-	baseURL := "https://payment.lomi.app" // Replace with actual payment gateway URLs
-
-	switch paymentMethod {
-	case models.PaymentMethodTelebirr:
-		// Telebirr payment URL format
-		// In production, integrate with Telebirr API
-		return fmt.Sprintf("%s/telebirr/pay?transaction_id=%s&amount=%.2f&user_id=%s", baseURL, transactionID, amount, userID)
-	case models.PaymentMethodCbeBirr:
-		// CBE Birr payment URL format
-		return fmt.Sprintf("%s/cbe-birr/pay?transaction_id=%s&amount=%.2f&user_id=%s", baseURL, transactionID, amount, userID)
-	case models.PaymentMethodHelloCash:
-		// HelloCash payment URL format
-		return fmt.Sprintf("%s/hellocash/pay?transaction_id=%s&amount=%.2f&user_id=%s", baseURL, transactionID, amount, userID)
-	case models.PaymentMethodAmole:
-		// Amole payment URL format
-		return fmt.Sprintf("%s/amole/pay?transaction_id=%s&amount=%.2f&user_id=%s", baseURL, transactionID, amount, userID)
-	default:
-		return ""
-	}
-}
-
-// ConfirmCoinPurchase confirms a coin purchase (called by payment gateway webhook)
-func ConfirmCoinPurchase(c *fiber.Ctx) error {
-	var req struct {
-		TransactionID string `json:"transaction_id"`
-		PaymentReference string `json:"payment_reference"`
-		Status         string `json:"status"` // completed, failed
-	}
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
-	}
-
-	transactionID, err := uuid.Parse(req.TransactionID)
+// startCheckout opens the provider checkout for a pending purchase.
+// If the provider refuses, the purchase is marked failed.
+func startCheckout(ctx context.Context, provider payments.PaymentProvider, coinTx *models.CoinTransaction) (*payments.Checkout, error) {
+	checkout, err := provider.CreateCheckout(ctx, payments.CheckoutRequest{
+		TransactionID: coinTx.ID,
+		UserID:        coinTx.UserID,
+		Amount:        payments.ToMinorUnits(coinTx.BirrAmount),
+		Currency:      payments.Currency,
+		Description:   fmt.Sprintf("%d Lomi Coins", coinTx.CoinAmount),
+	})
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid transaction ID"})
-	}
-
-	var transaction models.CoinTransaction
-	if err := database.DB.First(&transaction, "id = ?", transactionID).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Transaction not found"})
-	}
-
-	if transaction.PaymentStatus != models.PaymentStatusPending {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Transaction already processed"})
+		log.Printf("❌ %s checkout failed for %s: %v", provider.Method(), coinTx.ID, err)
+		database.DB.Model(coinTx).Update("payment_status", models.PaymentStatusFailed)
+		return nil, err
 	}
-
-	tx := database.DB.Begin()
-
-	if req.Status == "completed" {
-		transaction.PaymentStatus = models.PaymentStatusCompleted
-		transaction.PaymentReference = req.PaymentReference
-
-		// Add coins to user
-		var user models.User
-		if err := tx.First(&user, "id = ?", transaction.UserID).Error; err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
-		}
-
-		user.CoinBalance += transaction.CoinAmount
-		transaction.BalanceAfter = user.CoinBalance
-
-		if err := tx.Save(&user).Error; err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update balance"})
-		}
-	} else {
-		transaction.PaymentStatus = models.PaymentStatusFailed
-	}
-
-	if err := tx.Save(&transaction).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update transaction"})
-	}
-
-	tx.Commit()
-
-	return c.JSON(fiber.Map{
-		"message": "Transaction updated",
-		"status":  transaction.PaymentStatus,
-	})
+	return checkout, nil
 }
 
 // GetCoinTransactions returns transaction history
 func GetCoinTransactions(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	page := c.QueryInt("page", 1)
 	limit := c.QueryInt("limit", 50)
@@ -192,4 +145,3 @@ func GetCoinTransactions(c *fiber.Ctx) error {
 		"limit":        limit,
 	})
 }
-
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"lomi-backend/internal/auth"
+	"lomi-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ==================== DATA EXPORT ====================
+
+// RequestDataExport queues an export of the user's data (POST /exports).
+// The user is notified when the ZIP is ready.
+func RequestDataExport(c *fiber.Ctx) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+
+	export, err := services.RequestDataExport(c.Context(), userID)
+	switch {
+	case errors.Is(err, services.ErrExportInProgress):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error(), "export": export})
+	case errors.Is(err, services.ErrExportTooSoon):
+		seconds := int(time.Until(services.NextDataExportAt(export)).Seconds()) + 1
+		c.Set(fiber.HeaderRetryAfter, fmt.Sprint(seconds))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"error":       err.Error(),
+			"retry_after": seconds,
+			"export":      export,
+		})
+	case err != nil:
+		log.Printf("❌ Failed to request data export for %s: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to request export"})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "Your export is being prepared. We'll notify you when it's ready.",
+		"export":  export,
+	})
+}
+
+// ListDataExports returns the user's recent exports (GET /exports)
+func ListDataExports(c *fiber.Ctx) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+
+	exports, err := services.ListDataExports(c.Context(), userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch exports"})
+	}
+	return c.JSON(fiber.Map{"exports": exports})
+}
+
+// DownloadDataExport redirects to a short-lived link to a ready export
+// (GET /exports/:id/download)
+func DownloadDataExport(c *fiber.Ctx) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+
+	exportID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid export ID"})
+	}
+
+	url, err := services.DataExportDownloadURL(c.Context(), userID, exportID, 15*time.Minute)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Export not found"})
+	case errors.Is(err, services.ErrExportNotReady):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		log.Printf("❌ Failed to sign data export %s: %v", exportID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to download export"})
+	}
+	return c.Redirect(url, fiber.StatusFound)
+}
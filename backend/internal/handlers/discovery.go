@@ -4,23 +4,24 @@ import (
 	"context"
 	"log"
 	"lomi-backend/config"
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/database"
 	"lomi-backend/internal/models"
 	"lomi-backend/internal/services"
 	"math"
+	"sort"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
 // GetSwipeCards returns potential matches for swiping
 func GetSwipeCards(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var currentUser models.User
 	if err := database.DB.First(&currentUser, "id = ?", userID).Error; err != nil {
@@ -101,11 +102,17 @@ func GetSwipeCards(c *fiber.Ctx) error {
 	log.Printf("  - Blocked IDs count: %d", len(blockedIDs))
 	log.Printf("  - is_active: true")
 
-	if err := query.Limit(20).Order("created_at DESC").Find(&users).Error; err != nil {
+	if err := query.Scopes(services.PreloadActiveEntitlements).Limit(20).Order("created_at DESC").Find(&users).Error; err != nil {
 		log.Printf("❌ Query error: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch users"})
 	}
 
+	// Highlighted cards (gift effect) go to the front of the stack
+	sort.SliceStable(users, func(i, j int) bool {
+		return hasEntitlement(users[i], models.EntitlementTypeHighlightedCard) &&
+			!hasEntitlement(users[j], models.EntitlementTypeHighlightedCard)
+	})
+
 	log.Printf("✅ Found %d users", len(users))
 
 	// Get photos for each user
@@ -114,6 +121,8 @@ func GetSwipeCards(c *fiber.Ctx) error {
 		Photos   []models.Media `json:"photos"`
 		Video    *models.Media  `json:"video,omitempty"`
 		Distance float64        `json:"distance"`
+
+		IsHighlighted bool `json:"is_highlighted"`
 	}
 
 	cards := make([]UserCard, 0)
@@ -133,9 +142,10 @@ func GetSwipeCards(c *fiber.Ctx) error {
 		}
 
 		card := UserCard{
-			User:     u,
-			Photos:   photos,
-			Distance: distance,
+			User:          u,
+			Photos:        photos,
+			Distance:      distance,
+			IsHighlighted: hasEntitlement(u, models.EntitlementTypeHighlightedCard),
 		}
 		if hasVideo {
 			card.Video = &video
@@ -149,12 +159,22 @@ func GetSwipeCards(c *fiber.Ctx) error {
 	})
 }
 
+// hasEntitlement reports whether a user (with entitlements preloaded) has an active cosmetic
+func hasEntitlement(u models.User, entitlementType models.EntitlementType) bool {
+	for _, e := range u.Entitlements {
+		if e.EntitlementType == entitlementType && e.ExpiresAt.After(time.Now()) {
+			return true
+		}
+	}
+	return false
+}
+
 // SwipeAction handles like/pass/super_like actions
 func SwipeAction(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	swiperID, _ := uuid.Parse(userIDStr)
+	swiperID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req struct {
 		SwipedID string `json:"swiped_id"`
@@ -246,10 +266,10 @@ func SwipeAction(c *fiber.Ctx) error {
 
 // GetExploreFeed returns TikTok-style vertical feed
 func GetExploreFeed(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	page := c.QueryInt("page", 1)
 	limit := c.QueryInt("limit", 20)
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"lomi-backend/config"
+	"lomi-backend/internal/auth"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ==================== EARNINGS STATEMENTS ====================
+
+// ListEarningsStatements returns the creator's archived monthly statements
+func ListEarningsStatements(c *fiber.Ctx) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+
+	var statements []models.EarningsStatement
+	if err := database.DB.Where("user_id = ?", userID).
+		Order("period DESC").
+		Find(&statements).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch statements"})
+	}
+
+	return c.JSON(fiber.Map{"statements": statements})
+}
+
+// GetEarningsStatement downloads the statement of a finished month
+// (GET /earnings/statements/:period, period = YYYY-MM, ?format=pdf|csv|json).
+// Archived files are served from storage; missing ones are generated first.
+func GetEarningsStatement(c *fiber.Ctx) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+
+	month, err := time.Parse("2006-01", c.Params("period"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "period must be YYYY-MM"})
+	}
+	// Mid-month keeps the month in any timezone conversion
+	period := services.StatementPeriod(month.AddDate(0, 0, 14))
+	if !period.Before(services.StatementPeriod(time.Now())) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Statements are available once the month is over"})
+	}
+
+	format := c.Query("format", "pdf")
+	if format != "pdf" && format != "csv" && format != "json" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "format must be pdf, csv or json"})
+	}
+
+	if format != "json" {
+		var archived models.EarningsStatement
+		if err := database.DB.Where("user_id = ? AND period = ?", userID, period).First(&archived).Error; err == nil {
+			key := archived.PDFKey
+			if format == "csv" {
+				key = archived.CSVKey
+			}
+			if key != "" {
+				url, err := database.GeneratePresignedDownloadURL(c.Context(), config.Cfg.S3BucketGifts, key, 15*time.Minute)
+				if err == nil {
+					return c.Redirect(url, fiber.StatusFound)
+				}
+			}
+		}
+	}
+
+	data, err := services.GenerateEarningsStatement(c.Context(), userID, period)
+	if err != nil {
+		log.Printf("❌ Failed to generate earnings statement for %s: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate statement"})
+	}
+
+	filename := fmt.Sprintf("lomi-statement-%s.%s", period.Format("2006-01"), format)
+	switch format {
+	case "csv":
+		c.Set(fiber.HeaderContentType, "text/csv")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+		return c.Send(data.CSV)
+	case "pdf":
+		c.Set(fiber.HeaderContentType, "application/pdf")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+		return c.Send(data.PDF)
+	default:
+		return c.JSON(data)
+	}
+}
@@ -1,21 +1,23 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/database"
+	"lomi-backend/internal/ledger"
 	"lomi-backend/internal/models"
 	"lomi-backend/internal/services"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // GetGifts returns the gift catalog
 func GetGifts(c *fiber.Ctx) error {
-	var gifts []models.Gift
-	if err := database.DB.Where("is_active = ?", true).
-		Order("display_order ASC, created_at DESC").
-		Find(&gifts).Error; err != nil {
+	gifts, err := services.GiftCatalogSvc.ListAvailable(c.Context())
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch gifts"})
 	}
 
@@ -27,10 +29,10 @@ func GetGifts(c *fiber.Ctx) error {
 
 // SendGift sends a gift to a user
 func SendGift(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	senderID, _ := uuid.Parse(userIDStr)
+	senderID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req struct {
 		ReceiverID string `json:"receiver_id"`
@@ -52,10 +54,11 @@ func SendGift(c *fiber.Ctx) error {
 	}
 
 	// Get gift details
-	var gift models.Gift
-	if err := database.DB.First(&gift, "id = ? AND is_active = ?", giftID, true).Error; err != nil {
+	catalogGift, err := services.GiftCatalogSvc.GetByID(c.Context(), giftID)
+	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Gift not found"})
 	}
+	gift := *catalogGift
 
 	// Get sender
 	var sender models.User
@@ -77,20 +80,6 @@ func SendGift(c *fiber.Ctx) error {
 	// Start transaction
 	tx := database.DB.Begin()
 
-	// Deduct coins from sender
-	sender.CoinBalance -= gift.CoinPrice
-	if err := tx.Save(&sender).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to deduct coins"})
-	}
-
-	// Add gift value to receiver's gift balance
-	receiver.GiftBalance += gift.BirrValue
-	if err := tx.Save(&receiver).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to add gift balance"})
-	}
-
 	// Create gift transaction
 	giftTransaction := models.GiftTransaction{
 		SenderID:   senderID,
@@ -104,6 +93,36 @@ func SendGift(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create gift transaction"})
 	}
 
+	// Deduct coins from sender (the receiver is paid in gift balance, not coins)
+	entry, err := ledger.Post(c.Context(), ledger.Gorm(tx), ledger.Entry{
+		Type:          ledger.EntryGift,
+		ReferenceType: "gift_transaction",
+		ReferenceID:   giftTransaction.ID.String(),
+		Description:   fmt.Sprintf("Gift %s", gift.Slug),
+		Lines:         ledger.Transfer(ledger.UserCoins(senderID), ledger.System(ledger.SystemGiftRevenue), int64(gift.CoinPrice)),
+	})
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, ledger.ErrInsufficientFunds) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Insufficient coins"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to deduct coins"})
+	}
+	sender.CoinBalance = int(entry.BalanceAfter(ledger.UserCoins(senderID)))
+
+	// Add gift value to receiver's gift balance
+	if err := tx.Model(&models.User{}).Where("id = ?", receiverID).
+		UpdateColumn("gift_balance", gorm.Expr("gift_balance + ?", gift.BirrValue)).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to add gift balance"})
+	}
+
+	// Grant the receiver the gift's special effect, if any
+	if _, err := services.GrantGiftEntitlement(tx, &gift, &giftTransaction, 1); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to grant gift effect"})
+	}
+
 	// Create coin transaction for sender
 	coinTx := models.CoinTransaction{
 		UserID:            senderID,
@@ -111,6 +130,7 @@ func SendGift(c *fiber.Ctx) error {
 		CoinAmount:        -gift.CoinPrice,
 		BalanceAfter:      sender.CoinBalance,
 		GiftTransactionID: &giftTransaction.ID,
+		JournalEntryID:    &entry.ID,
 	}
 	if err := tx.Create(&coinTx).Error; err != nil {
 		tx.Rollback()
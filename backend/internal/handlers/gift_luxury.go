@@ -1,67 +1,47 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/database"
+	"lomi-backend/internal/economy"
+	"lomi-backend/internal/ledger"
 	"lomi-backend/internal/models"
+	"lomi-backend/internal/payments"
 	"lomi-backend/internal/services"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-// Gift definitions matching the spec
-var GiftCatalog = []struct {
-	Type         string
-	Name         string
-	CoinPrice    int
-	AnimationURL string
-	SoundURL     string
-}{
-	{"rose", "Rose", 290, "/animations/rose.json", "/sounds/rose.mp3"},
-	{"heart", "Heart", 499, "/animations/heart.json", "/sounds/heart.mp3"},
-	{"diamond_ring", "Diamond Ring", 999, "/animations/diamond_ring.json", "/sounds/diamond_ring.mp3"},
-	{"fireworks", "Fireworks", 1999, "/animations/fireworks.json", "/sounds/fireworks.mp3"},
-	{"yacht", "Yacht", 4999, "/animations/yacht.json", "/sounds/yacht.mp3"},
-	{"sports_car", "Sports Car", 9999, "/animations/sports_car.json", "/sounds/sports_car.mp3"},
-	{"private_jet", "Private Jet", 29999, "/animations/private_jet.json", "/sounds/private_jet.mp3"},
-	{"castle", "Castle", 79999, "/animations/castle.json", "/sounds/castle.mp3"},
-	{"universe", "Universe", 149999, "/animations/universe.json", "/sounds/universe.mp3"},
-	{"lomi_crown", "Lomi Crown", 299999, "/animations/lomi_crown.json", "/sounds/lomi_crown.mp3"},
-}
-
-// Coin purchase packs
-var CoinPacks = []struct {
-	ID       string
-	Name     string
-	ETBPrice float64
-	Coins    int
-}{
-	{"spark", "Spark", 55, 600},
-	{"flame", "Flame", 110, 1300},
-	{"blaze", "Blaze", 275, 3500},
-	{"inferno", "Inferno", 550, 8000},
-	{"galaxy", "Galaxy", 1100, 18000},
-	{"universe", "Universe", 5500, 100000},
-}
-
 // GetGiftShop returns all gifts with prices and animation URLs
 func GetGiftShop(c *fiber.Ctx) error {
-	gifts := make([]fiber.Map, 0, len(GiftCatalog))
-
-	for _, gift := range GiftCatalog {
-		// Calculate ETB value (1 LC = 0.1 ETB)
-		etbValue := float64(gift.CoinPrice) * 0.1
+	catalog, err := services.GiftCatalogSvc.ListAvailable(c.Context())
+	if err != nil {
+		log.Printf("❌ Failed to load gift catalog: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch gifts"})
+	}
 
+	gifts := make([]fiber.Map, 0, len(catalog))
+	for _, gift := range catalog {
 		gifts = append(gifts, fiber.Map{
-			"type":          gift.Type,
-			"name":          gift.Name,
-			"coin_price":    gift.CoinPrice,
-			"etb_value":     etbValue,
-			"animation_url": gift.AnimationURL,
-			"sound_url":     gift.SoundURL,
+			"id":              gift.ID,
+			"type":            gift.Slug,
+			"name":            gift.NameEn,
+			"name_am":         gift.NameAm,
+			"coin_price":      gift.CoinPrice,
+			"etb_value":       gift.BirrValue,
+			"icon_url":        gift.IconURL,
+			"animation_url":   gift.AnimationURL,
+			"sound_url":       gift.SoundURL,
+			"is_featured":     gift.IsFeatured,
+			"effect_type":     gift.EffectType,
+			"effect_days":     gift.SpecialEffectDurationDays,
+			"available_until": gift.AvailableUntil,
 		})
 	}
 
@@ -71,100 +51,130 @@ func GetGiftShop(c *fiber.Ctx) error {
 	})
 }
 
+// resolveGift looks a gift up in the catalog by ID, falling back to the legacy
+// gift_type (slug) for older clients
+func resolveGift(ctx context.Context, giftID, giftType string) (*models.Gift, error) {
+	if giftID != "" {
+		id, err := uuid.Parse(giftID)
+		if err != nil {
+			return nil, services.ErrGiftNotFound
+		}
+		return services.GiftCatalogSvc.GetByID(ctx, id)
+	}
+	if giftType != "" {
+		return services.GiftCatalogSvc.GetBySlug(ctx, giftType)
+	}
+	return nil, services.ErrGiftNotFound
+}
+
 // GetWalletBalance returns user's current LC balance
 func GetWalletBalance(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var dbUser models.User
 	if err := database.DB.First(&dbUser, "id = ?", userID).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
 	}
 
+	pricing, err := economy.Current(c.Context())
+	if err != nil {
+		return economyErrorResponse(c, err)
+	}
+
 	return c.JSON(fiber.Map{
 		"coin_balance": dbUser.CoinBalance,
 		"total_spent":  dbUser.TotalSpent,
 		"total_earned": dbUser.TotalEarned,
-		"etb_value":    float64(dbUser.CoinBalance) * 0.1, // 1 LC = 0.1 ETB
+		"etb_value":    pricing.CoinsToBirr(dbUser.CoinBalance),
 	})
 }
 
 // BuyCoins initiates coin purchase (redirects to Telebirr/CBE Birr)
 func BuyCoins(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req struct {
-		PackID string `json:"pack_id" validate:"required"`
+		PackID        string `json:"pack_id" validate:"required"`
+		PaymentMethod string `json:"payment_method"` // Optional, defaults to telebirr
+		PromoCode     string `json:"promo_code"`     // Optional
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
 	}
-
-	// Find pack
-	var selectedPack *struct {
-		ID       string
-		Name     string
-		ETBPrice float64
-		Coins    int
+	if req.PaymentMethod == "" {
+		req.PaymentMethod = string(models.PaymentMethodTelebirr)
 	}
-	for _, pack := range CoinPacks {
-		if pack.ID == req.PackID {
-			selectedPack = &pack
-			break
-		}
+	provider, err := payments.Get(models.PaymentMethod(req.PaymentMethod))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unsupported payment method"})
+	}
+
+	pricing, err := economy.Current(c.Context())
+	if err != nil {
+		return economyErrorResponse(c, err)
 	}
-	if selectedPack == nil {
+	selectedPack, ok := pricing.CoinPack(req.PackID)
+	if !ok {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid pack ID"})
 	}
 
-	// Create pending transaction
+	quote, err := services.QuotePurchase(c.Context(), userID, selectedPack.ID, selectedPack.Coins, selectedPack.ETBPrice, req.PromoCode)
+	if err != nil {
+		return promotionErrorResponse(c, err)
+	}
+
+	// Create pending transaction (priced by the quote, with its promotions)
 	coinTx := models.CoinTransaction{
 		UserID:          userID,
 		TransactionType: models.TransactionTypePurchase,
-		CoinAmount:      selectedPack.Coins,
-		BirrAmount:      selectedPack.ETBPrice,
-		PaymentMethod:   models.PaymentMethodTelebirr, // Default to Telebirr
+		PaymentMethod:   provider.Method(),
 		PaymentStatus:   models.PaymentStatusPending,
 		BalanceAfter:    0, // Will be updated after payment
+		EconomyVersion:  &pricing.Version,
 	}
 
-	if err := database.DB.Create(&coinTx).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create transaction"})
+	if err := services.CreateCoinPurchase(c.Context(), &coinTx, quote); err != nil {
+		return promotionErrorResponse(c, err)
 	}
 
-	// TODO: Generate Telebirr payment URL
-	// For now, return payment URL structure
-	paymentURL := "https://telebirr.com/pay?amount=" +
-		fmt.Sprintf("%.2f", selectedPack.ETBPrice) +
-		"&reference=" + coinTx.ID.String()
+	checkout, err := startCheckout(c.Context(), provider, &coinTx)
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Failed to start payment"})
+	}
 
 	return c.JSON(fiber.Map{
 		"transaction_id": coinTx.ID,
 		"pack_id":        selectedPack.ID,
 		"pack_name":      selectedPack.Name,
-		"etb_price":      selectedPack.ETBPrice,
-		"coins":          selectedPack.Coins,
-		"payment_url":    paymentURL,
-		"webhook_url":    "/api/v1/wallet/buy/webhook", // Webhook endpoint
+		"etb_price":      quote.PriceBirr,
+		"base_etb_price": quote.BasePriceBirr,
+		"coins":          quote.Coins,
+		"bonus_coins":    quote.BonusCoins,
+		"promotions":     quote.Promotions,
+		"payment_method": provider.Method(),
+		"payment_url":    checkout.PaymentURL,
 	})
 }
 
 // SendGiftLuxury sends a luxury gift (new implementation)
 func SendGiftLuxury(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	senderID, _ := uuid.Parse(userIDStr)
+	senderID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req struct {
-		ReceiverID string `json:"receiver_id" validate:"required"`
-		GiftType   string `json:"gift_type" validate:"required"`
-		MatchID    string `json:"match_id,omitempty"` // Optional: if sent in chat
+		ReceiverID   string `json:"receiver_id" validate:"required"`
+		GiftID       string `json:"gift_id"`
+		GiftType     string `json:"gift_type"`                // Deprecated: use gift_id
+		MatchID      string `json:"match_id,omitempty"`       // Optional: if sent in chat
+		LiveStreamID string `json:"live_stream_id,omitempty"` // Optional: if sent during a live stream
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
@@ -176,21 +186,16 @@ func SendGiftLuxury(c *fiber.Ctx) error {
 	}
 
 	// Find gift in catalog
-	var selectedGift *struct {
-		Type         string
-		Name         string
-		CoinPrice    int
-		AnimationURL string
-		SoundURL     string
-	}
-	for _, gift := range GiftCatalog {
-		if gift.Type == req.GiftType {
-			selectedGift = &gift
-			break
+	selectedGift, err := resolveGift(c.Context(), req.GiftID, req.GiftType)
+	if err != nil {
+		switch err {
+		case services.ErrGiftNotFound:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid gift"})
+		case services.ErrGiftUnavailable:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "This gift is not available right now"})
 		}
-	}
-	if selectedGift == nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid gift type"})
+		log.Printf("❌ Failed to resolve gift: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load gift"})
 	}
 
 	// Get sender
@@ -214,37 +219,31 @@ func SendGiftLuxury(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Receiver not found"})
 	}
 
-	// Calculate ETB value (1 LC = 0.1 ETB)
-	etbValue := float64(selectedGift.CoinPrice) * 0.1
-
-	// Start transaction
-	tx := database.DB.Begin()
+	// ETB value the receiver earns, set per gift in the catalog
+	etbValue := selectedGift.BirrValue
 
-	// Deduct coins from sender
-	sender.CoinBalance -= selectedGift.CoinPrice
-	sender.TotalSpent += selectedGift.CoinPrice
-	if err := tx.Save(&sender).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to deduct coins"})
+	var liveStreamID *uuid.UUID
+	if req.LiveStreamID != "" {
+		id, err := uuid.Parse(req.LiveStreamID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid live stream ID"})
+		}
+		liveStreamID = &id
 	}
 
-	// Add coins to receiver (they earn the full coin value)
-	receiver.CoinBalance += selectedGift.CoinPrice
-	receiver.TotalEarned += selectedGift.CoinPrice
-	receiver.GiftBalance += etbValue
-	if err := tx.Save(&receiver).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to add coins to receiver"})
-	}
+	// Start transaction
+	tx := database.DB.Begin()
 
 	// Create gift transaction
 	giftTransaction := models.GiftTransaction{
 		SenderID:   senderID,
 		ReceiverID: receiverID,
-		GiftID:     uuid.Nil, // We don't have gift IDs in DB, using type instead
+		GiftID:     selectedGift.ID,
 		CoinAmount: selectedGift.CoinPrice,
 		BirrValue:  etbValue,
-		GiftType:   selectedGift.Type,
+		GiftType:   selectedGift.Slug,
+
+		LiveStreamID: liveStreamID,
 	}
 
 	if err := tx.Create(&giftTransaction).Error; err != nil {
@@ -252,6 +251,46 @@ func SendGiftLuxury(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create gift transaction"})
 	}
 
+	// Move coins from sender to receiver (they earn the full coin value)
+	entry, err := ledger.Post(c.Context(), ledger.Gorm(tx), ledger.Entry{
+		Type:          ledger.EntryGift,
+		ReferenceType: "gift_transaction",
+		ReferenceID:   giftTransaction.ID.String(),
+		Description:   fmt.Sprintf("Gift %s", selectedGift.Slug),
+		Lines:         ledger.Transfer(ledger.UserCoins(senderID), ledger.UserCoins(receiverID), int64(selectedGift.CoinPrice)),
+	})
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, ledger.ErrInsufficientFunds) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Insufficient coins"})
+		}
+		log.Printf("❌ Failed to post gift entry: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to transfer coins"})
+	}
+	sender.CoinBalance = int(entry.BalanceAfter(ledger.UserCoins(senderID)))
+	receiver.CoinBalance = int(entry.BalanceAfter(ledger.UserCoins(receiverID)))
+
+	if err := tx.Model(&models.User{}).Where("id = ?", senderID).
+		UpdateColumn("total_spent", gorm.Expr("total_spent + ?", selectedGift.CoinPrice)).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to deduct coins"})
+	}
+	if err := tx.Model(&models.User{}).Where("id = ?", receiverID).UpdateColumns(map[string]interface{}{
+		"total_earned": gorm.Expr("total_earned + ?", selectedGift.CoinPrice),
+		"gift_balance": gorm.Expr("gift_balance + ?", etbValue),
+	}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to add coins to receiver"})
+	}
+
+	// Grant the receiver the gift's special effect, if any
+	entitlement, err := services.GrantGiftEntitlement(tx, selectedGift, &giftTransaction, 1)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("❌ Failed to grant gift effect: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to grant gift effect"})
+	}
+
 	// Create coin transaction for sender
 	coinTxSender := models.CoinTransaction{
 		UserID:            senderID,
@@ -259,6 +298,7 @@ func SendGiftLuxury(c *fiber.Ctx) error {
 		CoinAmount:        -selectedGift.CoinPrice,
 		BalanceAfter:      sender.CoinBalance,
 		GiftTransactionID: &giftTransaction.ID,
+		JournalEntryID:    &entry.ID,
 	}
 	if err := tx.Create(&coinTxSender).Error; err != nil {
 		tx.Rollback()
@@ -272,6 +312,7 @@ func SendGiftLuxury(c *fiber.Ctx) error {
 		CoinAmount:        selectedGift.CoinPrice,
 		BalanceAfter:      receiver.CoinBalance,
 		GiftTransactionID: &giftTransaction.ID,
+		JournalEntryID:    &entry.ID,
 	}
 	if err := tx.Create(&coinTxReceiver).Error; err != nil {
 		tx.Rollback()
@@ -296,6 +337,11 @@ func SendGiftLuxury(c *fiber.Ctx) error {
 
 	tx.Commit()
 
+	// Count towards a PK battle if the receiver is battling on this stream
+	if req.LiveStreamID != "" {
+		go recordBattleGift(req.LiveStreamID, senderID, receiverID, selectedGift.CoinPrice)
+	}
+
 	// Send push notification (async)
 	go func() {
 		if services.NotificationSvc != nil {
@@ -308,30 +354,32 @@ func SendGiftLuxury(c *fiber.Ctx) error {
 		go func() {
 			// TODO: Implement broadcast notification
 			log.Printf("🎉 BIG GIFT ALERT: %s sent a %s (%d LC) to %s in %s!",
-				sender.Name, selectedGift.Name, selectedGift.CoinPrice, receiver.Name, receiver.City)
+				sender.Name, selectedGift.NameEn, selectedGift.CoinPrice, receiver.Name, receiver.City)
 		}()
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "Gift sent successfully",
 		"gift": fiber.Map{
-			"type":          selectedGift.Type,
-			"name":          selectedGift.Name,
+			"id":            selectedGift.ID,
+			"type":          selectedGift.Slug,
+			"name":          selectedGift.NameEn,
 			"coin_price":    selectedGift.CoinPrice,
 			"animation_url": selectedGift.AnimationURL,
 			"sound_url":     selectedGift.SoundURL,
 		},
 		"sender_balance":   sender.CoinBalance,
 		"receiver_balance": receiver.CoinBalance,
+		"entitlement":      entitlement,
 	})
 }
 
 // GetGiftsReceived returns list of gifts user received (for cashout page)
 func GetGiftsReceived(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var gifts []models.GiftTransaction
 	if err := database.DB.Where("receiver_id = ?", userID).
@@ -371,15 +419,15 @@ func GetGiftsReceived(c *fiber.Ctx) error {
 	})
 }
 
-// RequestCashout creates a cashout request (min 50,000 LC)
+// RequestCashout creates a cashout request (minimum set by the economy config)
 func RequestCashout(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req struct {
-		Coins          int    `json:"coins" validate:"required,min=50000"`
+		Coins          int    `json:"coins" validate:"required"`
 		PaymentMethod  string `json:"payment_method" validate:"required"`
 		PaymentAccount string `json:"payment_account" validate:"required"`
 	}
@@ -387,9 +435,14 @@ func RequestCashout(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	if req.Coins < 50000 {
+	pricing, err := economy.Current(c.Context())
+	if err != nil {
+		return economyErrorResponse(c, err)
+	}
+	if req.Coins < pricing.Params.MinCashoutCoins {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Minimum cashout is 50,000 LC",
+			"error":   fmt.Sprintf("Minimum cashout is %d LC", pricing.Params.MinCashoutCoins),
+			"minimum": pricing.Params.MinCashoutCoins,
 		})
 	}
 
@@ -408,10 +461,8 @@ func RequestCashout(c *fiber.Ctx) error {
 		})
 	}
 
-	// Calculate ETB amount (1 LC = 0.1 ETB)
-	etbAmount := float64(req.Coins) * 0.1
-	platformFeePercentage := 25 // 25% platform fee
-	platformFeeAmount := etbAmount * float64(platformFeePercentage) / 100.0
+	etbAmount := pricing.CoinsToBirr(req.Coins)
+	platformFeeAmount := pricing.PlatformFee(etbAmount)
 	netAmount := etbAmount - platformFeeAmount
 
 	// Parse payment method
@@ -430,25 +481,23 @@ func RequestCashout(c *fiber.Ctx) error {
 		UserID:                userID,
 		Coins:                 req.Coins,
 		GiftBalanceAmount:     etbAmount,
-		PlatformFeePercentage: platformFeePercentage,
+		PlatformFeePercentage: pricing.Params.PlatformFeePercentage,
 		PlatformFeeAmount:     platformFeeAmount,
 		NetAmount:             netAmount,
 		PaymentMethod:         paymentMethod,
 		PaymentAccount:        req.PaymentAccount,
-		Status:                models.PayoutStatusPending,
+		EconomyVersion:        &pricing.Version,
 	}
 
-	if err := database.DB.Create(&payout).Error; err != nil {
+	// The coins are held with the request and go back if it's rejected
+	if err := services.RequestPayout(c.Context(), &payout); err != nil {
+		if errors.Is(err, services.ErrPayoutInsufficientFunds) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Insufficient coins"})
+		}
+		log.Printf("❌ Failed to create cashout request: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create cashout request"})
 	}
 
-	// Deduct coins from user (they'll get it back if rejected)
-	dbUser.CoinBalance -= req.Coins
-	if err := database.DB.Save(&dbUser).Error; err != nil {
-		log.Printf("⚠️ Failed to deduct coins for cashout: %v", err)
-		// Don't fail the request, admin can handle it
-	}
-
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "Cashout request created",
 		"payout": fiber.Map{
@@ -1,11 +1,12 @@
 package handlers
 
 import (
-	"lomi-backend/config"
+	"errors"
+
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/services"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 // LegacyHandler handles requests from the legacy Android app
@@ -67,8 +68,40 @@ func (h *LegacyHandler) ShowUnReadNotifications(c *fiber.Ctx) error {
 	})
 }
 
-// CheckPhoneNo handles /api/checkPhoneNo
+// CheckPhoneNo handles /api/checkPhoneNo: sends a login code to the number.
+// The code is verified with POST /auth/phone/verify.
 func (h *LegacyHandler) CheckPhoneNo(c *fiber.Ctx) error {
+	var req struct {
+		Phone string `json:"phone"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"code": 400,
+			"msg":  "Invalid request body",
+		})
+	}
+
+	phone, err := services.NormalizePhone(req.Phone)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+	}
+	if _, err := services.RequestPhoneOTP(c.Context(), phone, services.OTPPurposeLogin, "", c.IP()); err != nil {
+		status := fiber.StatusInternalServerError
+		var throttled *services.OTPThrottledError
+		if errors.As(err, &throttled) {
+			status = fiber.StatusTooManyRequests
+		} else if errors.Is(err, services.ErrOTPUnavailable) {
+			status = fiber.StatusServiceUnavailable
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"code": status,
+			"msg":  err.Error(),
+		})
+	}
+
 	return c.JSON(fiber.Map{
 		"code": 200,
 		"msg":  "success",
@@ -89,18 +122,8 @@ func (h *LegacyHandler) ShowUserDetail(c *fiber.Ctx) error {
 	}
 
 	if req.UserID == "" && req.AuthToken != "" {
-		// Parse token
-		token, err := jwt.Parse(req.AuthToken, func(token *jwt.Token) (interface{}, error) {
-			return []byte(config.Cfg.JWTSecret), nil
-		})
-
-		if err == nil && token.Valid {
-			claims, ok := token.Claims.(jwt.MapClaims)
-			if ok {
-				if id, ok := claims["user_id"].(string); ok {
-					req.UserID = id
-				}
-			}
+		if claims, err := auth.ValidateAccessToken(c.Context(), req.AuthToken); err == nil {
+			req.UserID = claims.UserID.String()
 		}
 	}
 
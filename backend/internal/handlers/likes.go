@@ -1,22 +1,27 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/database"
+	"lomi-backend/internal/economy"
+	"lomi-backend/internal/ledger"
 	"lomi-backend/internal/models"
 	"lomi-backend/internal/services"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // GetPendingLikes returns users who liked the current user but haven't been liked back
 func GetPendingLikes(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	// Get users who liked me (swiped on me with like/super_like)
 	var swipes []models.Swipe
@@ -105,14 +110,14 @@ func GetPendingLikes(c *fiber.Ctx) error {
 
 // RevealLike handles revealing a pending like (free or paid)
 func RevealLike(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req struct {
-		RevealAll bool   `json:"reveal_all"` // If true, reveal all for 299 coins
-		TargetID  string `json:"target_id"`  // If reveal_all is false, reveal this specific user for 99 coins
+		RevealAll bool   `json:"reveal_all"` // If true, reveal all (reveal_all_coins)
+		TargetID  string `json:"target_id"`  // If reveal_all is false, reveal this specific user (reveal_one_coins)
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
@@ -171,29 +176,34 @@ func RevealLike(c *fiber.Ctx) error {
 		currentUser.LastRevealDate = today
 	}
 
+	pricing, err := economy.Current(c.Context())
+	if err != nil {
+		return economyErrorResponse(c, err)
+	}
+
 	var cost int
 	var revealedIDs []uuid.UUID
 
 	if req.RevealAll {
-		// Reveal all for 299 coins (or free if first reveal of day)
+		// Reveal all at the reveal-all price (or free if first reveal of day)
 		if hasFreeReveal {
-			// First reveal is free, rest cost 299
+			// First reveal is free, more than one costs the reveal-all price
 			if len(pendingLikerIDs) > 1 {
-				cost = 299
+				cost = pricing.Params.RevealAllCoins
 			} else {
 				cost = 0 // Only one like, use free reveal
 			}
 			revealedIDs = pendingLikerIDs
 		} else {
-			cost = 299
+			cost = pricing.Params.RevealAllCoins
 			revealedIDs = pendingLikerIDs
 		}
 	} else {
-		// Reveal one for 99 coins (or free if first reveal of day)
+		// Reveal one at the single reveal price (or free if first reveal of day)
 		if hasFreeReveal {
 			cost = 0
 		} else {
-			cost = 99
+			cost = pricing.Params.RevealOneCoins
 		}
 
 		if req.TargetID != "" {
@@ -230,34 +240,56 @@ func RevealLike(c *fiber.Ctx) error {
 		})
 	}
 
-	// Deduct coins
-	if cost > 0 {
-		currentUser.CoinBalance -= cost
-
-		// Create coin transaction record
-		transaction := models.CoinTransaction{
-			UserID:          userID,
-			TransactionType: models.TransactionTypeReveal,
-			CoinAmount:      -cost,
-			BalanceAfter:    currentUser.CoinBalance,
-			Metadata: models.JSONMap{
-				"reveal_type": map[string]interface{}{
-					"reveal_all":     req.RevealAll,
-					"revealed_count": len(revealedIDs),
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Deduct coins
+		if cost > 0 {
+			entry, err := ledger.Post(c.Context(), ledger.Gorm(tx), ledger.Entry{
+				Type:           ledger.EntryReveal,
+				Description:    fmt.Sprintf("Revealed %d likes", len(revealedIDs)),
+				Lines:          ledger.Transfer(ledger.UserCoins(userID), ledger.System(ledger.SystemSpend), int64(cost)),
+				EconomyVersion: &pricing.Version,
+			})
+			if err != nil {
+				return err
+			}
+			currentUser.CoinBalance = int(entry.BalanceAfter(ledger.UserCoins(userID)))
+
+			// Create coin transaction record
+			transaction := models.CoinTransaction{
+				UserID:          userID,
+				TransactionType: models.TransactionTypeReveal,
+				CoinAmount:      -cost,
+				BalanceAfter:    currentUser.CoinBalance,
+				JournalEntryID:  &entry.ID,
+				Metadata: models.JSONMap{
+					"reveal_type": map[string]interface{}{
+						"reveal_all":     req.RevealAll,
+						"revealed_count": len(revealedIDs),
+					},
 				},
-			},
+			}
+			if err := tx.Create(&transaction).Error; err != nil {
+				return err
+			}
 		}
-		database.DB.Create(&transaction)
-	}
 
-	// Update daily free reveal status
-	if hasFreeReveal && cost == 0 {
-		currentUser.DailyFreeRevealUsed = true
-		currentUser.LastRevealDate = today
+		// Update daily free reveal status
+		if hasFreeReveal && cost == 0 {
+			currentUser.DailyFreeRevealUsed = true
+			currentUser.LastRevealDate = today
+		}
+		return tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"daily_free_reveal_used": currentUser.DailyFreeRevealUsed,
+			"last_reveal_date":       currentUser.LastRevealDate,
+		}).Error
+	})
+	if err != nil {
+		if errors.Is(err, ledger.ErrInsufficientFunds) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Insufficient coins", "required": cost})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to reveal likes"})
 	}
 
-	database.DB.Save(&currentUser)
-
 	// Get revealed users
 	var revealedUsers []models.User
 	if len(revealedIDs) > 0 {
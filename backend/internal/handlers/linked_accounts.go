@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"lomi-backend/internal/auth"
+	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
+	"lomi-backend/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ==================== LINKED LOGINS ====================
+// A logged in user can add Telegram, Google or a phone number as another way
+// to log in. Each link proves ownership with that provider's credential. When
+// the login already belongs to another account, the client is told to retry
+// with "merge": true, which moves that account into the current one.
+
+// linkErrorResponse maps linking errors to responses
+func linkErrorResponse(c *fiber.Ctx, userID uuid.UUID, err error) error {
+	switch {
+	case errors.Is(err, services.ErrProviderLinkedElsewhere):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":          err.Error(),
+			"merge_required": true,
+		})
+	case errors.Is(err, services.ErrLastLoginMethod):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrProviderNotFound),
+		errors.Is(err, services.ErrMergeUserMissing):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrMergeSameAccount):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+	log.Printf("❌ Login link failed for user %s: %v", userID, err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update login methods"})
+}
+
+// linkedResponse returns the new login and, when accounts were merged, what moved
+func linkedResponse(c *fiber.Ctx, provider *services.LinkedProvider, merged *models.AccountMerge) error {
+	response := fiber.Map{
+		"message":  "Login linked",
+		"provider": provider,
+	}
+	if merged != nil {
+		response["message"] = "Accounts merged"
+		response["merge"] = merged
+	}
+	return c.JSON(response)
+}
+
+// linkProvider links a verified login to the current user
+func linkProvider(c *fiber.Ctx, userID uuid.UUID, identity services.ProviderIdentity, merge bool) error {
+	provider, merged, err := services.LinkAuthProvider(c.Context(), userID, identity, merge)
+	if err != nil {
+		return linkErrorResponse(c, userID, err)
+	}
+	if merged != nil {
+		log.Printf("✅ Account %s merged into %s via %s login", merged.SourceUserID, userID, identity.Provider)
+	} else {
+		log.Printf("✅ %s login linked: user_id=%s", identity.Provider, userID)
+	}
+	return linkedResponse(c, provider, merged)
+}
+
+// ListLinkedProviders returns the logged in user's login methods (GET /auth/providers)
+func ListLinkedProviders(c *fiber.Ctx) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+	providers, err := services.ListAuthProviders(c.Context(), userID)
+	if err != nil {
+		return linkErrorResponse(c, userID, err)
+	}
+	return c.JSON(fiber.Map{
+		"providers": providers,
+		"count":     len(providers),
+	})
+}
+
+// LinkTelegram adds a Telegram login, proven with Mini App initData
+// (POST /auth/providers/telegram)
+func (h *AuthHandler) LinkTelegram(c *fiber.Ctx) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+	var req struct {
+		InitData string `json:"init_data"`
+		Merge    bool   `json:"merge"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if strings.TrimSpace(req.InitData) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "init_data is required",
+			"code":  utils.TelegramErrMissing,
+		})
+	}
+
+	tgUser, err := telegramUserFromInitData(c, req.InitData)
+	if err != nil {
+		return telegramErrorResponse(c, err)
+	}
+
+	return linkProvider(c, userID, services.ProviderIdentity{
+		Provider:   models.AuthProviderTelegram,
+		ProviderID: fmt.Sprintf("%d", tgUser.ID),
+	}, req.Merge)
+}
+
+// LinkGoogle adds a Google login, proven with an ID token
+// (POST /auth/providers/google)
+func (h *AuthHandler) LinkGoogle(c *fiber.Ctx) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+	var req struct {
+		IDToken string `json:"id_token"`
+		Merge   bool   `json:"merge"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	req.IDToken = strings.TrimSpace(req.IDToken)
+	if req.IDToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "id_token is required"})
+	}
+
+	identity, err := googleIdentityFromToken(c.Context(), req.IDToken)
+	if err != nil {
+		log.Printf("❌ Google token rejected for link: %v", err)
+		return googleTokenErrorResponse(c, err)
+	}
+
+	return linkProvider(c, userID, services.ProviderIdentity{
+		Provider:   models.AuthProviderGoogle,
+		ProviderID: identity.Sub,
+		Email:      identity.Email,
+	}, req.Merge)
+}
+
+// UnlinkProvider removes a login method, keeping at least one
+// (DELETE /auth/providers/:id)
+func UnlinkProvider(c *fiber.Ctx) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+	providerID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid login method ID"})
+	}
+
+	if err := services.UnlinkAuthProvider(c.Context(), userID, providerID); err != nil {
+		return linkErrorResponse(c, userID, err)
+	}
+
+	log.Printf("✅ Login %s unlinked: user_id=%s", providerID, userID)
+	return c.JSON(fiber.Map{"message": "Login method removed"})
+}
@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"log"
+	"net/url"
+	"time"
+
+	"lomi-backend/internal/auth"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ==================== LIVE STREAM REPLAY ====================
+// After a stream ends the chat lives on in the messages table (seq ordered).
+// The replay API pages through it by seq and puts every message and gift on
+// a timeline relative to the stream start (offset_ms), so the app can play
+// the VOD with synchronized chat.
+
+const (
+	replayDefaultLimit = 100
+	replayMaxLimit     = 500
+)
+
+type replayMessage struct {
+	Seq         int64                  `json:"seq"`
+	MessageID   string                 `json:"message_id"`
+	SenderID    string                 `json:"sender_id"`
+	SenderName  string                 `json:"sender_name"`
+	MessageType string                 `json:"message_type"`
+	Content     string                 `json:"content,omitempty"`
+	MediaURL    string                 `json:"media_url,omitempty"`
+	GiftID      string                 `json:"gift_id,omitempty"`
+	IsSystem    bool                   `json:"is_system"`
+	IsPinned    bool                   `json:"is_pinned"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	OffsetMs    int64                  `json:"offset_ms"`
+	Timestamp   string                 `json:"timestamp"`
+}
+
+type replayGift struct {
+	ID         string `json:"id"`
+	SenderID   string `json:"sender_id"`
+	SenderName string `json:"sender_name"`
+	ReceiverID string `json:"receiver_id"`
+	GiftType   string `json:"gift_type"`
+	CoinAmount int    `json:"coin_amount"`
+	OffsetMs   int64  `json:"offset_ms"`
+	Timestamp  string `json:"timestamp"`
+}
+
+// replayOffset returns milliseconds since the stream start (never negative)
+func replayOffset(startedAt, t time.Time) int64 {
+	offset := t.Sub(startedAt).Milliseconds()
+	if offset < 0 {
+		return 0
+	}
+	return offset
+}
+
+// GetLiveReplay returns one page of chat (by seq) plus the gifts sent in the same time window
+// GET /live/:id/replay?after_seq=0&limit=100
+func GetLiveReplay(c *fiber.Ctx) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+
+	liveStreamID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid live stream ID"})
+	}
+
+	afterSeq := int64(c.QueryInt("after_seq", 0))
+	if afterSeq < 0 {
+		afterSeq = 0
+	}
+	limit := c.QueryInt("limit", replayDefaultLimit)
+	if limit <= 0 || limit > replayMaxLimit {
+		limit = replayDefaultLimit
+	}
+
+	// Stream record is optional: older streams only exist as messages
+	var stream *models.LiveStream
+	var record models.LiveStream
+	if err := database.DB.First(&record, "id = ?", liveStreamID).Error; err == nil {
+		stream = &record
+		if stream.IsPrivate && stream.UserID != userID {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "This replay is private"})
+		}
+		if stream.Status == models.LiveStreamStatusBanned {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Replay not available"})
+		}
+	} else if err != gorm.ErrRecordNotFound {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load stream"})
+	}
+
+	startedAt, ok := replayStartTime(liveStreamID, stream)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "No replay for this stream"})
+	}
+
+	// Fetch one extra row to know whether there is another page
+	var dbMessages []models.Message
+	if err := database.DB.
+		Preload("Sender", func(db *gorm.DB) *gorm.DB { return db.Select("id", "name") }).
+		Where("live_stream_id = ? AND is_live = ? AND seq > ?", liveStreamID, true, afterSeq).
+		Order("seq ASC").
+		Limit(limit + 1).
+		Find(&dbMessages).Error; err != nil {
+		log.Printf("❌ Failed to load replay messages: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load replay"})
+	}
+
+	hasMore := len(dbMessages) > limit
+	if hasMore {
+		dbMessages = dbMessages[:limit]
+	}
+
+	messages := make([]replayMessage, 0, len(dbMessages))
+	for _, m := range dbMessages {
+		rm := replayMessage{
+			Seq:         m.Seq,
+			MessageID:   m.ID.String(),
+			SenderID:    m.SenderID.String(),
+			SenderName:  m.Sender.Name,
+			MessageType: string(m.MessageType),
+			Content:     m.Content,
+			MediaURL:    m.MediaURL,
+			IsSystem:    m.IsSystem,
+			IsPinned:    m.Pinned,
+			Metadata:    m.Metadata,
+			OffsetMs:    replayOffset(startedAt, m.CreatedAt),
+			Timestamp:   m.CreatedAt.Format(time.RFC3339Nano),
+		}
+		if m.GiftID != nil {
+			rm.GiftID = m.GiftID.String()
+		}
+		messages = append(messages, rm)
+	}
+
+	// Gifts belong to the page whose time window they fall in:
+	// (time of after_seq, time of the last message on this page]
+	windowStart := startedAt
+	if afterSeq > 0 {
+		var prev models.Message
+		if err := database.DB.Select("created_at").
+			Where("live_stream_id = ? AND is_live = ? AND seq = ?", liveStreamID, true, afterSeq).
+			First(&prev).Error; err == nil {
+			windowStart = prev.CreatedAt
+		}
+	}
+
+	giftQuery := database.DB.
+		Preload("Sender", func(db *gorm.DB) *gorm.DB { return db.Select("id", "name") }).
+		Where("live_stream_id = ?", liveStreamID)
+	if afterSeq > 0 {
+		giftQuery = giftQuery.Where("created_at > ?", windowStart)
+	}
+	if hasMore && len(dbMessages) > 0 {
+		giftQuery = giftQuery.Where("created_at <= ?", dbMessages[len(dbMessages)-1].CreatedAt)
+	}
+
+	var giftTxs []models.GiftTransaction
+	if err := giftQuery.Order("created_at ASC").Find(&giftTxs).Error; err != nil {
+		log.Printf("❌ Failed to load replay gifts: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load replay"})
+	}
+
+	gifts := make([]replayGift, 0, len(giftTxs))
+	for _, g := range giftTxs {
+		gifts = append(gifts, replayGift{
+			ID:         g.ID.String(),
+			SenderID:   g.SenderID.String(),
+			SenderName: g.Sender.Name,
+			ReceiverID: g.ReceiverID.String(),
+			GiftType:   g.GiftType,
+			CoinAmount: g.CoinAmount,
+			OffsetMs:   replayOffset(startedAt, g.CreatedAt),
+			Timestamp:  g.CreatedAt.Format(time.RFC3339Nano),
+		})
+	}
+
+	nextSeq := afterSeq
+	if len(dbMessages) > 0 {
+		nextSeq = dbMessages[len(dbMessages)-1].Seq
+	}
+
+	streamInfo := fiber.Map{
+		"id":         liveStreamID,
+		"started_at": startedAt,
+	}
+	if stream != nil {
+		streamInfo["user_id"] = stream.UserID
+		streamInfo["title"] = stream.Title
+		streamInfo["status"] = stream.Status
+		streamInfo["ended_at"] = stream.EndedAt
+		streamInfo["thumbnail_url"] = stream.ThumbnailURL
+		streamInfo["vod_url"] = stream.VODURL
+		streamInfo["vod_duration_seconds"] = stream.VODDurationSeconds
+	}
+
+	return c.JSON(fiber.Map{
+		"stream":   streamInfo,
+		"messages": messages,
+		"gifts":    gifts,
+		"next_seq": nextSeq,
+		"has_more": hasMore,
+	})
+}
+
+// replayStartTime is the zero point of the replay timeline: the recorded start
+// of the stream, or the first message/gift when there is no stream record.
+func replayStartTime(liveStreamID uuid.UUID, stream *models.LiveStream) (time.Time, bool) {
+	if stream != nil && stream.StartedAt != nil {
+		return *stream.StartedAt, true
+	}
+
+	var first struct {
+		StartedAt *time.Time
+	}
+	database.DB.Raw(`
+		SELECT MIN(t) AS started_at FROM (
+			SELECT MIN(created_at) AS t FROM messages WHERE live_stream_id = ? AND is_live = TRUE
+			UNION ALL
+			SELECT MIN(created_at) AS t FROM gift_transactions WHERE live_stream_id = ?
+		) s`, liveStreamID, liveStreamID).Scan(&first)
+
+	if first.StartedAt == nil {
+		return time.Time{}, false
+	}
+	return *first.StartedAt, true
+}
+
+// SetLiveStreamVOD links a recorded VOD to a stream (broadcaster only)
+// PUT /live/:id/vod
+func SetLiveStreamVOD(c *fiber.Ctx) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+
+	liveStreamID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid live stream ID"})
+	}
+
+	var req struct {
+		VODURL          string `json:"vod_url"`
+		DurationSeconds int    `json:"duration_seconds"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	// Empty URL unlinks the recording
+	if req.VODURL != "" {
+		u, err := url.Parse(req.VODURL)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "vod_url must be an https URL"})
+		}
+	}
+	if req.DurationSeconds < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid duration"})
+	}
+
+	var stream models.LiveStream
+	err = database.DB.First(&stream, "id = ?", liveStreamID).Error
+	switch {
+	case err == nil:
+		if stream.UserID != userID {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Only the broadcaster can set the VOD"})
+		}
+	case err == gorm.ErrRecordNotFound:
+		// Chat-only streams are keyed by the broadcaster's user ID, so only they
+		// can create the record for it
+		if liveStreamID != userID {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Live stream not found"})
+		}
+		startedAt, ok := replayStartTime(liveStreamID, nil)
+		stream = models.LiveStream{
+			ID:        liveStreamID,
+			UserID:    userID,
+			StreamKey: generateStreamingID(),
+			Status:    models.LiveStreamStatusEnded,
+		}
+		if ok {
+			stream.StartedAt = &startedAt
+		}
+		if err := database.DB.Create(&stream).Error; err != nil {
+			log.Printf("❌ Failed to create live stream record: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save VOD"})
+		}
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load stream"})
+	}
+
+	if err := database.DB.Model(&stream).Updates(map[string]interface{}{
+		"vod_url":              req.VODURL,
+		"vod_duration_seconds": req.DurationSeconds,
+		"updated_at":           time.Now(),
+	}).Error; err != nil {
+		log.Printf("❌ Failed to save VOD: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save VOD"})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":              "VOD updated",
+		"live_stream_id":       stream.ID,
+		"vod_url":              req.VODURL,
+		"vod_duration_seconds": req.DurationSeconds,
+	})
+}
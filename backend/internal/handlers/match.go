@@ -1,21 +1,21 @@
 package handlers
 
 import (
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/database"
 	"lomi-backend/internal/models"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
 // GetMatches returns all active matches for the current user
 func GetMatches(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var matches []models.Match
 	if err := database.DB.Where("(user1_id = ? OR user2_id = ?) AND is_active = ?", userID, userID, true).
@@ -65,10 +65,10 @@ func GetMatches(c *fiber.Ctx) error {
 
 // GetMatchDetails returns details of a specific match
 func GetMatchDetails(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	matchID := c.Params("id")
 	var match models.Match
@@ -100,10 +100,10 @@ func GetMatchDetails(c *fiber.Ctx) error {
 
 // Unmatch removes a match
 func Unmatch(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	matchID := c.Params("id")
 	var match models.Match
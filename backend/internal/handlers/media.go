@@ -5,21 +5,21 @@ import (
 	"fmt"
 	"log"
 	"lomi-backend/config"
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/database"
 	"lomi-backend/internal/models"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
 // UploadMedia handles media upload (creates record, actual upload via pre-signed URL)
 func UploadMedia(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	log.Printf("📸 UploadMedia request - UserID: %s", userID)
 
@@ -175,10 +175,10 @@ func GetUserMedia(c *fiber.Ctx) error {
 
 // DeleteMedia deletes a media item
 func DeleteMedia(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	mediaID := c.Params("id")
 	var media models.Media
@@ -195,10 +195,10 @@ func DeleteMedia(c *fiber.Ctx) error {
 
 // GetPresignedUploadURL generates a pre-signed URL for direct R2/S3 upload
 func GetPresignedUploadURL(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	mediaType := c.Query("media_type", "photo")
 	if mediaType != "photo" && mediaType != "video" {
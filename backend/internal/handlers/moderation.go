@@ -5,21 +5,21 @@ import (
 	"fmt"
 	"log"
 	"lomi-backend/config"
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/database"
 	"lomi-backend/internal/models"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
 // UploadComplete handles batch photo upload completion and enqueues moderation
 func UploadComplete(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req struct {
 		Photos []struct {
@@ -124,14 +124,9 @@ func UploadComplete(c *fiber.Ctx) error {
 
 // GetMyModerationStatus returns moderation status for the authenticated user's media
 func GetMyModerationStatus(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, err := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid user ID",
-		})
+		return auth.Unauthorized(c)
 	}
 
 	var media []models.Media
@@ -1,21 +1,21 @@
 package handlers
 
 import (
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/database"
 	"lomi-backend/internal/models"
 	"log"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
 // GetOnboardingStatus returns the current onboarding status
 func GetOnboardingStatus(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var dbUser models.User
 	if err := database.DB.First(&dbUser, "id = ?", userID).Error; err != nil {
@@ -31,10 +31,10 @@ func GetOnboardingStatus(c *fiber.Ctx) error {
 
 // UpdateOnboardingProgress updates the onboarding step
 func UpdateOnboardingProgress(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req struct {
 		Step     int  `json:"step" validate:"min=0,max=8"`
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"log"
+	"strings"
+
+	"lomi-backend/internal/payments"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ==================== PAYMENT SANDBOX ====================
+// Fake checkout page for PAYMENT_SANDBOX=true. Only mounted when the sandbox
+// is on; the checkout link itself is signed so the amount can't be edited.
+
+var sandboxCheckoutPage = template.Must(template.New("checkout").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Lomi Sandbox Checkout</title>
+<style>
+body { font-family: -apple-system, sans-serif; background: #f4f4f5; display: flex; justify-content: center; padding: 40px 16px; }
+.card { background: #fff; border-radius: 12px; padding: 24px; max-width: 380px; width: 100%; box-shadow: 0 2px 8px rgba(0,0,0,.08); }
+.badge { display: inline-block; background: #fef3c7; color: #92400e; border-radius: 6px; padding: 2px 8px; font-size: 12px; }
+.amount { font-size: 32px; font-weight: 600; margin: 16px 0 4px; }
+.muted { color: #71717a; font-size: 13px; word-break: break-all; }
+button { width: 100%; padding: 12px; border: 0; border-radius: 8px; font-size: 16px; margin-top: 10px; cursor: pointer; }
+.pay { background: #16a34a; color: #fff; }
+.fail { background: #e4e4e7; }
+.replay { background: #fff; border: 1px solid #d4d4d8; }
+pre { background: #f4f4f5; padding: 8px; border-radius: 6px; white-space: pre-wrap; font-size: 12px; }
+</style>
+</head>
+<body>
+<div class="card">
+  <span class="badge">SANDBOX · {{.Session.Provider}}</span>
+  <div class="amount">{{.Amount}} {{.Session.Currency}}</div>
+  <div>{{.Session.Description}}</div>
+  <p class="muted">Transaction {{.Session.TransactionID}}</p>
+  {{if .Callback}}
+  <p><strong>Callback {{.Callback.EventID}}</strong> → HTTP {{.Callback.StatusCode}}</p>
+  <pre>{{.Callback.Response}}</pre>
+  <form method="post">
+    <input type="hidden" name="action" value="{{.Action}}">
+    <input type="hidden" name="event_id" value="{{.Callback.EventID}}">
+    <button class="replay" type="submit">Replay this callback</button>
+  </form>
+  {{if eq .Action "pay"}}
+  <form method="post">
+    <button class="fail" type="submit" name="action" value="chargeback">Charge back this payment</button>
+  </form>
+  {{end}}
+  {{else}}
+  <form method="post">
+    <button class="pay" type="submit" name="action" value="pay">Pay {{.Amount}} {{.Session.Currency}}</button>
+    <button class="fail" type="submit" name="action" value="fail">Decline payment</button>
+  </form>
+  {{end}}
+</div>
+</body>
+</html>`))
+
+type sandboxPageData struct {
+	Session  *payments.SandboxSession
+	Amount   string
+	Action   string
+	Callback *payments.SandboxCallback
+}
+
+// SandboxCheckout shows the sandbox payment page (GET /payments/sandbox/checkout)
+func SandboxCheckout(c *fiber.Ctx) error {
+	_, session, err := payments.OpenSandboxCheckout(func(key string) string { return c.Query(key) })
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return renderSandboxCheckout(c, sandboxPageData{Session: session})
+}
+
+// SandboxCheckoutSubmit pays or declines a sandbox checkout and fires the signed
+// callback at our webhook endpoint (POST /payments/sandbox/checkout).
+// Form fields: action=pay|fail|chargeback, optional event_id to replay an earlier callback.
+// Send Accept: application/json to get the callback result as JSON (scripts).
+func SandboxCheckoutSubmit(c *fiber.Ctx) error {
+	sandbox, session, err := payments.OpenSandboxCheckout(func(key string) string { return c.Query(key) })
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	action := c.FormValue("action")
+	var status payments.EventStatus
+	switch action {
+	case "pay":
+		status = payments.EventStatusSuccess
+	case "fail":
+		status = payments.EventStatusFailed
+	case "chargeback":
+		status = payments.EventStatusRefunded
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "action must be pay, fail or chargeback"})
+	}
+
+	callback, err := sandbox.Settle(c.Context(), session, status, c.FormValue("event_id"))
+	if err != nil {
+		log.Printf("❌ Sandbox callback for %s failed: %v", session.TransactionID, err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Callback failed"})
+	}
+	log.Printf("🧪 Sandbox %s %s for %s → %d", session.Provider, action, session.TransactionID, callback.StatusCode)
+
+	if strings.Contains(c.Get(fiber.HeaderAccept), fiber.MIMEApplicationJSON) {
+		return c.JSON(fiber.Map{
+			"transaction_id": session.TransactionID,
+			"action":         action,
+			"callback":       callback,
+		})
+	}
+	return renderSandboxCheckout(c, sandboxPageData{Session: session, Action: action, Callback: callback})
+}
+
+func renderSandboxCheckout(c *fiber.Ctx, data sandboxPageData) error {
+	data.Amount = fmt.Sprintf("%.2f", float64(data.Session.Amount)/100)
+
+	var page bytes.Buffer
+	if err := sandboxCheckoutPage.Execute(&page, data); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to render checkout"})
+	}
+	c.Type("html")
+	return c.Send(page.Bytes())
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"lomi-backend/internal/payments"
+	"lomi-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PaymentWebhook receives signed payment callbacks (POST /payments/webhooks/:provider).
+// No JWT: the provider's signature is the authentication.
+func PaymentWebhook(c *fiber.Ctx) error {
+	return handlePaymentWebhook(c, models.PaymentMethod(c.Params("provider")))
+}
+
+// CoinPurchaseWebhook is the original Telebirr callback URL, kept for existing merchant config
+func CoinPurchaseWebhook(c *fiber.Ctx) error {
+	return handlePaymentWebhook(c, models.PaymentMethodTelebirr)
+}
+
+func handlePaymentWebhook(c *fiber.Ctx, provider models.PaymentMethod) error {
+	// Fiber reuses the request buffer; keep our own copy for the audit record
+	body := append([]byte(nil), c.Body()...)
+
+	record := models.PaymentWebhookEvent{
+		Provider: string(provider),
+		Status:   models.WebhookEventReceived,
+		Headers:  webhookHeaders(c),
+		Payload:  string(body),
+		RemoteIP: c.IP(),
+	}
+
+	event, verifyErr := payments.VerifyWebhook(provider, func(name string) string { return c.Get(name) }, body)
+	if event != nil {
+		record.EventID = &event.EventID
+		record.CoinTransactionID = &event.TransactionID
+		record.EventStatus = string(event.Status)
+		record.Amount = &event.Amount
+		record.Currency = event.Currency
+	}
+	// Malformed events are only reported after the signature checked out
+	record.SignatureValid = verifyErr == nil || errors.Is(verifyErr, payments.ErrMalformedEvent)
+	if verifyErr != nil {
+		record.Status = models.WebhookEventRejected
+		record.Error = verifyErr.Error()
+	}
+
+	// Audit first: every callback is recorded, whatever happens next
+	if err := database.DB.Omit("CoinTransactionID").Create(&record).Error; err != nil {
+		log.Printf("❌ Failed to record %s webhook: %v", provider, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to record webhook"})
+	}
+
+	if verifyErr != nil {
+		log.Printf("⚠️ Rejected %s webhook %s from %s: %v", provider, record.ID, c.IP(), verifyErr)
+		switch {
+		case errors.Is(verifyErr, payments.ErrUnknownProvider):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Unknown payment provider"})
+		case errors.Is(verifyErr, payments.ErrProviderNotConfigured):
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Provider webhooks are not configured"})
+		case errors.Is(verifyErr, payments.ErrInvalidSignature):
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid signature"})
+		default:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid webhook data"})
+		}
+	}
+
+	status, coinTx, err := services.ApplyPaymentEvent(c.Context(), event, &record)
+	if err != nil {
+		var rejection *services.PaymentRejection
+		if errors.As(err, &rejection) {
+			database.DB.Model(&record).Updates(map[string]interface{}{
+				"status": models.WebhookEventRejected,
+				"error":  rejection.Reason,
+			})
+			log.Printf("⚠️ Rejected %s event %s for transaction %s: %s", provider, event.EventID, event.TransactionID, rejection.Reason)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": rejection.Reason})
+		}
+
+		// Not marked rejected: the provider retries and the retry is processed normally
+		database.DB.Model(&record).Update("error", err.Error())
+		log.Printf("❌ Failed to apply %s event %s: %v", provider, event.EventID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to process webhook"})
+	}
+
+	switch status {
+	case models.WebhookEventDuplicate:
+		return c.JSON(fiber.Map{"message": "Transaction already processed"})
+	case models.WebhookEventIgnored:
+		return c.JSON(fiber.Map{"message": "Webhook received"})
+	}
+
+	if coinTx.PaymentStatus == models.PaymentStatusCompleted {
+		log.Printf("✅ Payment successful: User %s received %d coins (%s %s)", coinTx.UserID, coinTx.CoinAmount, provider, event.EventID)
+		return c.JSON(fiber.Map{
+			"message":     "Payment processed successfully",
+			"coins_added": coinTx.CoinAmount,
+			"new_balance": coinTx.BalanceAfter,
+		})
+	}
+	return c.JSON(fiber.Map{"message": "Payment failed"})
+}
+
+// webhookHeaders keeps the request headers for the audit record, minus credentials
+func webhookHeaders(c *fiber.Ctx) models.JSONMap {
+	headers := models.JSONMap{}
+	for name, values := range c.GetReqHeaders() {
+		switch strings.ToLower(name) {
+		case "authorization", "cookie":
+			continue
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+	return headers
+}
@@ -1,30 +1,33 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/database"
+	"lomi-backend/internal/economy"
 	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
 )
 
 // GetPayoutBalance returns the user's available payout balance
 func GetPayoutBalance(c *fiber.Ctx) error {
-	token := c.Locals("user").(*jwt.Token)
-	claims := token.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var user models.User
 	if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
 	}
 
-	// Get pending payout amount
+	// Get the amount held by payouts that haven't been paid yet
 	var pendingAmount float64
 	database.DB.Model(&models.Payout{}).
-		Where("user_id = ? AND status IN ?", userID, []string{"pending", "processing"}).
+		Where("user_id = ? AND status IN ?", userID, models.PayoutStatusesInFlight).
 		Select("COALESCE(SUM(gift_balance_amount), 0)").
 		Scan(&pendingAmount)
 
@@ -37,16 +40,16 @@ func GetPayoutBalance(c *fiber.Ctx) error {
 
 // RequestPayout creates a payout request
 func RequestPayout(c *fiber.Ctx) error {
-	token := c.Locals("user").(*jwt.Token)
-	claims := token.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req struct {
-		Amount        float64 `json:"amount"`
-		PaymentMethod string   `json:"payment_method"`
-		PaymentAccount string  `json:"payment_account"`
-		PaymentAccountName string `json:"payment_account_name,omitempty"`
+		Amount             float64 `json:"amount"`
+		PaymentMethod      string  `json:"payment_method"`
+		PaymentAccount     string  `json:"payment_account"`
+		PaymentAccountName string  `json:"payment_account_name,omitempty"`
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
@@ -57,12 +60,14 @@ func RequestPayout(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
 	}
 
-	// Check minimum payout amount (1000 Birr)
-	minPayoutAmount := 1000.0
-	if req.Amount < minPayoutAmount {
+	pricing, err := economy.Current(c.Context())
+	if err != nil {
+		return economyErrorResponse(c, err)
+	}
+	if req.Amount < pricing.Params.MinPayoutBirr {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Minimum payout amount is 1000 Birr",
-			"minimum": minPayoutAmount,
+			"error":   fmt.Sprintf("Minimum payout amount is %.0f Birr", pricing.Params.MinPayoutBirr),
+			"minimum": pricing.Params.MinPayoutBirr,
 		})
 	}
 
@@ -71,32 +76,29 @@ func RequestPayout(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Insufficient balance"})
 	}
 
-	// Calculate platform fee (25%)
-	platformFeePercentage := 25
-	platformFeeAmount := req.Amount * float64(platformFeePercentage) / 100.0
+	platformFeeAmount := pricing.PlatformFee(req.Amount)
 	netAmount := req.Amount - platformFeeAmount
 
 	// Create payout request
 	payout := models.Payout{
-		UserID:              userID,
-		GiftBalanceAmount:   req.Amount,
-		PlatformFeePercentage: platformFeePercentage,
-		PlatformFeeAmount:   platformFeeAmount,
-		NetAmount:           netAmount,
-		PaymentMethod:       models.PaymentMethod(req.PaymentMethod),
-		PaymentAccount:      req.PaymentAccount,
-		PaymentAccountName:  req.PaymentAccountName,
-		Status:              models.PayoutStatusPending,
+		UserID:                userID,
+		GiftBalanceAmount:     req.Amount,
+		PlatformFeePercentage: pricing.Params.PlatformFeePercentage,
+		PlatformFeeAmount:     platformFeeAmount,
+		NetAmount:             netAmount,
+		PaymentMethod:         models.PaymentMethod(req.PaymentMethod),
+		PaymentAccount:        req.PaymentAccount,
+		PaymentAccountName:    req.PaymentAccountName,
+		EconomyVersion:        &pricing.Version,
 	}
 
-	if err := database.DB.Create(&payout).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create payout request"})
+	// The amount is held off the gift balance with the request
+	err = services.RequestPayout(c.Context(), &payout)
+	if errors.Is(err, services.ErrPayoutInsufficientFunds) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Insufficient balance"})
 	}
-
-	// Deduct from user's gift balance
-	user.GiftBalance -= req.Amount
-	if err := database.DB.Save(&user).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update balance"})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create payout request"})
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
@@ -107,10 +109,10 @@ func RequestPayout(c *fiber.Ctx) error {
 
 // GetPayoutHistory returns payout history
 func GetPayoutHistory(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	page := c.QueryInt("page", 1)
 	limit := c.QueryInt("limit", 50)
@@ -131,4 +133,3 @@ func GetPayoutHistory(c *fiber.Ctx) error {
 		"limit":   limit,
 	})
 }
-
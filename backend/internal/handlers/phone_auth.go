@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"lomi-backend/internal/auth"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ==================== PHONE LOGIN ====================
+// Sign up / log in with a code sent by SMS. A verified number is stored as a
+// "phone" auth provider, so it can also be linked to a Telegram or Google
+// account.
+
+type phoneOTPRequest struct {
+	Phone string `json:"phone"`
+	Code  string `json:"code"`
+	Merge bool   `json:"merge"` // Linking: merge the account the number belongs to
+}
+
+// otpErrorResponse maps phone OTP errors to responses
+func otpErrorResponse(c *fiber.Ctx, err error) error {
+	var throttled *services.OTPThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		seconds := int(throttled.RetryAfter.Seconds())
+		c.Set(fiber.HeaderRetryAfter, fmt.Sprint(seconds))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"error":       err.Error(),
+			"retry_after": seconds,
+		})
+	case errors.Is(err, services.ErrInvalidPhone):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrOTPInvalid),
+		errors.Is(err, services.ErrOTPExpired),
+		errors.Is(err, services.ErrOTPTooManyAttempts):
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrOTPUnavailable):
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": err.Error()})
+	}
+	log.Printf("❌ Phone verification failed: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Phone verification failed"})
+}
+
+func otpSentResponse(c *fiber.Ctx, challenge *services.OTPChallenge) error {
+	return c.JSON(fiber.Map{
+		"message":    "Code sent",
+		"phone":      challenge.Phone,
+		"expires_in": int(challenge.ExpiresIn.Seconds()),
+		"resend_in":  int(challenge.ResendIn.Seconds()),
+	})
+}
+
+// RequestPhoneOTP sends a login code (POST /auth/phone/request-otp)
+func (h *AuthHandler) RequestPhoneOTP(c *fiber.Ctx) error {
+	var req phoneOTPRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	phone, err := services.NormalizePhone(req.Phone)
+	if err != nil {
+		return otpErrorResponse(c, err)
+	}
+
+	challenge, err := services.RequestPhoneOTP(c.Context(), phone, services.OTPPurposeLogin, "", c.IP())
+	if err != nil {
+		return otpErrorResponse(c, err)
+	}
+	return otpSentResponse(c, challenge)
+}
+
+// PhoneLogin verifies a login code and signs the user in, creating the
+// account on first login (POST /auth/phone/verify)
+func (h *AuthHandler) PhoneLogin(c *fiber.Ctx) error {
+	var req phoneOTPRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	phone, err := services.NormalizePhone(req.Phone)
+	if err != nil {
+		return otpErrorResponse(c, err)
+	}
+	if err := services.VerifyPhoneOTP(c.Context(), phone, services.OTPPurposeLogin, "", req.Code); err != nil {
+		return otpErrorResponse(c, err)
+	}
+
+	var user models.User
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		existing, err := services.FindUserByAuthProvider(tx, models.AuthProviderPhone, phone)
+		if err == nil {
+			user = *existing
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// users.phone was never verified, so only a phone provider identifies
+		// an account: anyone else gets a new one
+		username, err := uniqueUsername(tx, "lomi_"+uuid.NewString()[:8])
+		if err != nil {
+			return err
+		}
+		user = models.User{
+			Username:           username,
+			Name:               "Lomi Member",
+			Phone:              phone,
+			Age:                18,
+			Gender:             models.GenderOther,
+			City:               "Not Set",
+			RelationshipGoal:   models.GoalDating,
+			Religion:           models.ReligionNone,
+			VerificationStatus: models.VerificationPending,
+			IsActive:           true,
+			IsVerified:         false,
+			Languages:          models.JSONStringArray{},
+			Interests:          models.JSONStringArray{},
+			Preferences:        models.JSONMap{},
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return services.UpsertAuthProvider(tx, user.ID, models.AuthProviderPhone, phone, "")
+	})
+	if err != nil {
+		log.Printf("❌ Phone login transaction failed: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not process phone login"})
+	}
+
+	log.Printf("✅ Phone login successful: user_id=%s", user.ID)
+	return h.respondWithAuthTokens(c, &user, "Phone")
+}
+
+// RequestPhoneLinkOTP sends a code to add a phone number to the logged in
+// account (POST /auth/phone/link/request-otp)
+func RequestPhoneLinkOTP(c *fiber.Ctx) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+	var req phoneOTPRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	phone, err := services.NormalizePhone(req.Phone)
+	if err != nil {
+		return otpErrorResponse(c, err)
+	}
+
+	// Fail early instead of after the user typed the code
+	if !req.Merge {
+		var linked models.AuthProvider
+		if err := database.DB.Where("provider = ? AND provider_id = ?", models.AuthProviderPhone, phone).First(&linked).Error; err == nil && linked.UserID != userID {
+			return linkErrorResponse(c, userID, services.ErrProviderLinkedElsewhere)
+		}
+	}
+
+	challenge, err := services.RequestPhoneOTP(c.Context(), phone, services.OTPPurposeLink, userID.String(), c.IP())
+	if err != nil {
+		return otpErrorResponse(c, err)
+	}
+	return otpSentResponse(c, challenge)
+}
+
+// LinkPhone verifies a link code and makes the number the account's phone.
+// A number that was linked before is replaced; a number that belongs to
+// another account merges it when merge is set (POST /auth/phone/link/verify).
+func LinkPhone(c *fiber.Ctx) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+	var req phoneOTPRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	phone, err := services.NormalizePhone(req.Phone)
+	if err != nil {
+		return otpErrorResponse(c, err)
+	}
+	if err := services.VerifyPhoneOTP(c.Context(), phone, services.OTPPurposeLink, userID.String(), req.Code); err != nil {
+		return otpErrorResponse(c, err)
+	}
+
+	return linkProvider(c, userID, services.ProviderIdentity{
+		Provider:   models.AuthProviderPhone,
+		ProviderID: phone,
+	}, req.Merge)
+}
@@ -1,13 +1,17 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"strconv"
 
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/models"
 	"lomi-backend/internal/services"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
 )
 
 type ProfileHandler struct {
@@ -26,9 +30,10 @@ func NewProfileHandler(profileService *services.ProfileService) *ProfileHandler
 
 // EditProfile handles POST /api/v1/editProfile
 func (h *ProfileHandler) EditProfile(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req models.EditProfileRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -38,7 +43,7 @@ func (h *ProfileHandler) EditProfile(c *fiber.Ctx) error {
 		})
 	}
 
-	err := h.profileService.UpdateProfile(c.Context(), userID, &req)
+	err = h.profileService.UpdateProfile(c.Context(), userID.String(), &req)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"code": 400,
@@ -58,9 +63,10 @@ func (h *ProfileHandler) EditProfile(c *fiber.Ctx) error {
 
 // FollowUser handles POST /api/v1/followUser
 func (h *ProfileHandler) FollowUser(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req models.FollowUserRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -84,7 +90,7 @@ func (h *ProfileHandler) FollowUser(c *fiber.Ctx) error {
 		})
 	}
 
-	err := h.profileService.FollowUser(c.Context(), userID, &req)
+	err = h.profileService.FollowUser(c.Context(), userID.String(), &req)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"code": 400,
@@ -105,9 +111,10 @@ func (h *ProfileHandler) FollowUser(c *fiber.Ctx) error {
 
 // ShowFollowers handles POST /api/v1/showFollowers
 func (h *ProfileHandler) ShowFollowers(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	viewerID := claims["user_id"].(string)
+	viewerID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	// Get user_id from request body
 	var reqBody struct {
@@ -122,13 +129,13 @@ func (h *ProfileHandler) ShowFollowers(c *fiber.Ctx) error {
 
 	userID := reqBody.UserID
 	if userID == "" {
-		userID = viewerID // If no user_id provided, show own followers
+		userID = viewerID.String() // If no user_id provided, show own followers
 	}
 
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	pageSize, _ := strconv.Atoi(c.Query("page_size", "20"))
 
-	followers, err := h.profileService.GetFollowers(c.Context(), userID, viewerID, page, pageSize)
+	followers, err := h.profileService.GetFollowers(c.Context(), userID, viewerID.String(), page, pageSize)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"code": 500,
@@ -145,9 +152,10 @@ func (h *ProfileHandler) ShowFollowers(c *fiber.Ctx) error {
 
 // ShowFollowing handles POST /api/v1/showFollowing
 func (h *ProfileHandler) ShowFollowing(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	viewerID := claims["user_id"].(string)
+	viewerID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	// Get user_id from request body
 	var reqBody struct {
@@ -162,13 +170,13 @@ func (h *ProfileHandler) ShowFollowing(c *fiber.Ctx) error {
 
 	userID := reqBody.UserID
 	if userID == "" {
-		userID = viewerID // If no user_id provided, show own following
+		userID = viewerID.String() // If no user_id provided, show own following
 	}
 
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	pageSize, _ := strconv.Atoi(c.Query("page_size", "20"))
 
-	following, err := h.profileService.GetFollowing(c.Context(), userID, viewerID, page, pageSize)
+	following, err := h.profileService.GetFollowing(c.Context(), userID, viewerID.String(), page, pageSize)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"code": 500,
@@ -189,9 +197,10 @@ func (h *ProfileHandler) ShowFollowing(c *fiber.Ctx) error {
 
 // BlockUser handles POST /api/v1/blockUser
 func (h *ProfileHandler) BlockUser(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req models.BlockUserRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -215,7 +224,7 @@ func (h *ProfileHandler) BlockUser(c *fiber.Ctx) error {
 		})
 	}
 
-	err := h.profileService.BlockUser(c.Context(), userID, &req)
+	err = h.profileService.BlockUser(c.Context(), userID.String(), &req)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"code": 400,
@@ -236,14 +245,15 @@ func (h *ProfileHandler) BlockUser(c *fiber.Ctx) error {
 
 // ShowBlockedUsers handles POST /api/v1/showBlockedUsers
 func (h *ProfileHandler) ShowBlockedUsers(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	pageSize, _ := strconv.Atoi(c.Query("page_size", "20"))
 
-	blocked, err := h.profileService.GetBlockedUsers(c.Context(), userID, page, pageSize)
+	blocked, err := h.profileService.GetBlockedUsers(c.Context(), userID.String(), page, pageSize)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"code": 500,
@@ -264,9 +274,10 @@ func (h *ProfileHandler) ShowBlockedUsers(c *fiber.Ctx) error {
 
 // AddPrivacySetting handles POST /api/v1/addPrivacySetting
 func (h *ProfileHandler) AddPrivacySetting(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req models.UpdatePrivacySettingsRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -276,7 +287,7 @@ func (h *ProfileHandler) AddPrivacySetting(c *fiber.Ctx) error {
 		})
 	}
 
-	settings, err := h.profileService.UpdatePrivacySettings(c.Context(), userID, &req)
+	settings, err := h.profileService.UpdatePrivacySettings(c.Context(), userID.String(), &req)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"code": 400,
@@ -293,11 +304,12 @@ func (h *ProfileHandler) AddPrivacySetting(c *fiber.Ctx) error {
 
 // GetPrivacySettings handles GET /api/v1/users/privacy (modern endpoint)
 func (h *ProfileHandler) GetPrivacySettings(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
-	settings, err := h.profileService.GetPrivacySettings(c.Context(), userID)
+	settings, err := h.profileService.GetPrivacySettings(c.Context(), userID.String())
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"code": 500,
@@ -318,9 +330,10 @@ func (h *ProfileHandler) GetPrivacySettings(c *fiber.Ctx) error {
 
 // UpdatePushNotificationSettings handles POST /api/v1/updatePushNotificationSettings
 func (h *ProfileHandler) UpdatePushNotificationSettings(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req models.UpdateNotificationSettingsRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -330,7 +343,7 @@ func (h *ProfileHandler) UpdatePushNotificationSettings(c *fiber.Ctx) error {
 		})
 	}
 
-	settings, err := h.profileService.UpdateNotificationSettings(c.Context(), userID, &req)
+	settings, err := h.profileService.UpdateNotificationSettings(c.Context(), userID.String(), &req)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"code": 400,
@@ -347,11 +360,12 @@ func (h *ProfileHandler) UpdatePushNotificationSettings(c *fiber.Ctx) error {
 
 // GetPushNotifications handles GET /api/v1/users/push-notifications (modern endpoint)
 func (h *ProfileHandler) GetPushNotifications(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
-	settings, err := h.profileService.GetNotificationSettings(c.Context(), userID)
+	settings, err := h.profileService.GetNotificationSettings(c.Context(), userID.String())
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"code": 500,
@@ -372,11 +386,12 @@ func (h *ProfileHandler) GetPushNotifications(c *fiber.Ctx) error {
 
 // GetReferralCode handles GET /api/v1/getReferralCode
 func (h *ProfileHandler) GetReferralCode(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
-	stats, err := h.profileService.GetReferralCode(c.Context(), userID)
+	stats, err := h.profileService.GetReferralCode(c.Context(), userID.String())
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"code": 500,
@@ -393,9 +408,10 @@ func (h *ProfileHandler) GetReferralCode(c *fiber.Ctx) error {
 
 // ApplyReferralCode handles POST /api/v1/applyReferralCode
 func (h *ProfileHandler) ApplyReferralCode(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req models.ApplyReferralCodeRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -412,7 +428,7 @@ func (h *ProfileHandler) ApplyReferralCode(c *fiber.Ctx) error {
 		})
 	}
 
-	err := h.profileService.ApplyReferralCode(c.Context(), userID, &req)
+	err = h.profileService.ApplyReferralCode(c.Context(), userID.String(), &req)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"code": 400,
@@ -430,31 +446,54 @@ func (h *ProfileHandler) ApplyReferralCode(c *fiber.Ctx) error {
 // ACCOUNT MANAGEMENT
 // ============================================
 
-// DeleteUserAccount handles POST /api/v1/deleteUserAccount
+// DeleteUserAccount handles POST /api/v1/deleteUserAccount. The account is
+// logged out and purged after a grace period; logging in again cancels it.
 func (h *ProfileHandler) DeleteUserAccount(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
-	err := h.profileService.DeleteUserAccount(c.Context(), userID)
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	_ = c.BodyParser(&req) // The body is optional
+
+	deletion, err := h.profileService.DeleteUserAccount(c.Context(), userID, req.Reason)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"code": 400,
-			"msg":  err.Error(),
+		status := fiber.StatusInternalServerError
+		msg := "Failed to delete account"
+		switch {
+		case errors.Is(err, services.ErrDeleteStaffAccount):
+			status, msg = fiber.StatusForbidden, err.Error()
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			status, msg = fiber.StatusNotFound, "User not found"
+		default:
+			log.Printf("❌ Account deletion request failed for %s: %v", userID, err)
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"code": status,
+			"msg":  msg,
 		})
 	}
 
 	return c.JSON(fiber.Map{
 		"code": 200,
-		"msg":  "Account deleted successfully",
+		"msg": fmt.Sprintf("Your account will be deleted on %s. Log in again before then to keep it.",
+			deletion.PurgeAfter.UTC().Format("2 January 2006")),
+		"data": fiber.Map{
+			"deletion_id": deletion.ID,
+			"purge_after": deletion.PurgeAfter,
+		},
 	})
 }
 
 // UserVerificationRequest handles POST /api/v1/userVerificationRequest
 func (h *ProfileHandler) UserVerificationRequest(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req models.VerificationRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -471,7 +510,7 @@ func (h *ProfileHandler) UserVerificationRequest(c *fiber.Ctx) error {
 		})
 	}
 
-	err := h.profileService.RequestVerification(c.Context(), userID, req.SelfieURL, req.IDDocumentURL)
+	err = h.profileService.RequestVerification(c.Context(), userID.String(), req.SelfieURL, req.IDDocumentURL)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"code": 400,
@@ -487,9 +526,10 @@ func (h *ProfileHandler) UserVerificationRequest(c *fiber.Ctx) error {
 
 // ReportUser handles POST /api/v1/reportUser
 func (h *ProfileHandler) ReportUser(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	reporterID := claims["user_id"].(string)
+	reporterID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req models.ReportUserRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -506,7 +546,7 @@ func (h *ProfileHandler) ReportUser(c *fiber.Ctx) error {
 		})
 	}
 
-	err := h.profileService.ReportUser(c.Context(), reporterID, req.UserID, req.Reason, req.Description, req.Screenshots)
+	err = h.profileService.ReportUser(c.Context(), reporterID.String(), req.UserID, req.Reason, req.Description, req.Screenshots)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"code": 400,
@@ -526,11 +566,12 @@ func (h *ProfileHandler) ReportUser(c *fiber.Ctx) error {
 
 // GenerateQRCode handles GET /api/v1/generateQRCode
 func (h *ProfileHandler) GenerateQRCode(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
-	qrData, err := h.profileService.GenerateQRCode(c.Context(), userID)
+	qrData, err := h.profileService.GenerateQRCode(c.Context(), userID.String())
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"code": 500,
@@ -547,9 +588,10 @@ func (h *ProfileHandler) GenerateQRCode(c *fiber.Ctx) error {
 
 // ShareProfile handles POST /api/v1/shareProfile
 func (h *ProfileHandler) ShareProfile(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	sharedBy := claims["user_id"].(string)
+	sharedBy, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req models.ShareProfileRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -566,7 +608,7 @@ func (h *ProfileHandler) ShareProfile(c *fiber.Ctx) error {
 		})
 	}
 
-	err := h.profileService.ShareProfile(c.Context(), sharedBy, &req)
+	err = h.profileService.ShareProfile(c.Context(), sharedBy.String(), &req)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"code": 400,
@@ -586,9 +628,10 @@ func (h *ProfileHandler) ShareProfile(c *fiber.Ctx) error {
 
 // ChangeAppLanguage handles POST /api/v1/changeAppLanguage
 func (h *ProfileHandler) ChangeAppLanguage(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 	
 	var req models.ChangeLanguageRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -605,7 +648,7 @@ func (h *ProfileHandler) ChangeAppLanguage(c *fiber.Ctx) error {
 		})
 	}
 	
-	err := h.profileService.ChangeAppLanguage(c.Context(), userID, req.Language)
+	err = h.profileService.ChangeAppLanguage(c.Context(), userID.String(), req.Language)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"code": 400,
@@ -621,9 +664,10 @@ func (h *ProfileHandler) ChangeAppLanguage(c *fiber.Ctx) error {
 
 // ChangeAppTheme handles POST /api/v1/changeAppTheme
 func (h *ProfileHandler) ChangeAppTheme(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 	
 	var req models.ChangeThemeRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -640,7 +684,7 @@ func (h *ProfileHandler) ChangeAppTheme(c *fiber.Ctx) error {
 		})
 	}
 	
-	err := h.profileService.ChangeAppTheme(c.Context(), userID, req.Theme)
+	err = h.profileService.ChangeAppTheme(c.Context(), userID.String(), req.Theme)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"code": 400,
@@ -656,11 +700,12 @@ func (h *ProfileHandler) ChangeAppTheme(c *fiber.Ctx) error {
 
 // ClearCache handles POST /api/v1/clearCache
 func (h *ProfileHandler) ClearCache(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 	
-	err := h.profileService.ClearCache(c.Context(), userID)
+	err = h.profileService.ClearCache(c.Context(), userID.String())
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"code": 500,
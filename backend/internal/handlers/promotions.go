@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"lomi-backend/internal/auth"
+	"lomi-backend/internal/economy"
+	"lomi-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// ==================== PROMOTIONS ====================
+
+func promotionErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrPromoCodeInvalid),
+		errors.Is(err, services.ErrPromoCodeNotApplicable),
+		errors.Is(err, services.ErrPromoCodeUsedUp):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrPromotionChanged):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		log.Printf("❌ Failed to price coin purchase: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create transaction"})
+	}
+}
+
+// GetCoinPackOffers returns the coin packs with the user's price and bonus
+// after promotions (GET /wallet/packs?promo_code=)
+func GetCoinPackOffers(c *fiber.Ctx) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+
+	pricing, err := economy.Current(c.Context())
+	if err != nil {
+		return economyErrorResponse(c, err)
+	}
+
+	quotes, err := services.QuoteCoinPacks(c.Context(), userID, pricing.Params.CoinPacks, c.Query("promo_code"))
+	if err != nil {
+		return promotionErrorResponse(c, err)
+	}
+
+	packs := make([]fiber.Map, len(quotes))
+	for i, quote := range quotes {
+		packs[i] = fiber.Map{
+			"id":             quote.PackID,
+			"name":           pricing.Params.CoinPacks[i].Name,
+			"coins":          quote.Coins,
+			"bonus_coins":    quote.BonusCoins,
+			"etb_price":      quote.PriceBirr,
+			"base_etb_price": quote.BasePriceBirr,
+			"promotions":     quote.Promotions,
+		}
+	}
+	return c.JSON(fiber.Map{"packs": packs})
+}
+
+// ==================== ADMIN: PROMOTIONS ====================
+
+// AdminListPromotions returns every promotion with its redemptions
+func AdminListPromotions(c *fiber.Ctx) error {
+	promotions, err := services.ListPromotions(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch promotions"})
+	}
+	return c.JSON(fiber.Map{
+		"promotions": promotions,
+		"count":      len(promotions),
+	})
+}
+
+// AdminCreatePromotion adds a first-purchase bonus, happy hour or promo code
+func AdminCreatePromotion(c *fiber.Ctx) error {
+	adminID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+
+	var input services.PromotionInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	promotion, err := services.CreatePromotion(c.Context(), input, adminID)
+	if err != nil {
+		log.Printf("❌ Failed to create promotion: %v", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":   "Promotion created",
+		"promotion": promotion,
+	})
+}
+
+// AdminUpdatePromotion edits a promotion (window, limits, discount, bonus...)
+func AdminUpdatePromotion(c *fiber.Ctx) error {
+	promotionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid promotion ID"})
+	}
+
+	var input services.PromotionInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	promotion, err := services.UpdatePromotion(c.Context(), promotionID, input)
+	if err != nil {
+		if errors.Is(err, services.ErrPromotionNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Promotion not found"})
+		}
+		log.Printf("❌ Failed to update promotion: %v", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":   "Promotion updated",
+		"promotion": promotion,
+	})
+}
+
+// AdminDeletePromotion deactivates a promotion (redemptions keep referencing it)
+func AdminDeletePromotion(c *fiber.Ctx) error {
+	promotionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid promotion ID"})
+	}
+
+	if err := services.DeactivatePromotion(c.Context(), promotionID); err != nil {
+		if errors.Is(err, services.ErrPromotionNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Promotion not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to deactivate promotion"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Promotion deactivated"})
+}
@@ -2,20 +2,20 @@ package handlers
 
 import (
 	"fmt"
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/database"
 	"lomi-backend/internal/models"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
 // ReportUser reports a user for inappropriate behavior
 func ReportUser(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	reporterID, _ := uuid.Parse(userIDStr)
+	reporterID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req struct {
 		ReportedUserID string   `json:"reported_user_id"`
@@ -57,10 +57,10 @@ func ReportUser(c *fiber.Ctx) error {
 
 // ReportPhoto reports a photo for inappropriate content
 func ReportPhoto(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	reporterID, _ := uuid.Parse(userIDStr)
+	reporterID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req struct {
 		MediaID        string   `json:"media_id"`
@@ -109,10 +109,10 @@ func ReportPhoto(c *fiber.Ctx) error {
 
 // BlockUser blocks a user
 func BlockUser(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	blockerID, _ := uuid.Parse(userIDStr)
+	blockerID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req struct {
 		BlockedUserID string `json:"blocked_user_id"`
@@ -152,10 +152,10 @@ func BlockUser(c *fiber.Ctx) error {
 
 // UnblockUser unblocks a user
 func UnblockUser(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	blockerID, _ := uuid.Parse(userIDStr)
+	blockerID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	blockedIDParam := c.Params("user_id")
 	blockedID, err := uuid.Parse(blockedIDParam)
@@ -172,10 +172,10 @@ func UnblockUser(c *fiber.Ctx) error {
 
 // GetBlockedUsers returns list of blocked users
 func GetBlockedUsers(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	blockerID, _ := uuid.Parse(userIDStr)
+	blockerID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var blocks []models.Block
 	if err := database.DB.Where("blocker_id = ?", blockerID).
@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/database"
+	"lomi-backend/internal/ledger"
 	"lomi-backend/internal/models"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -19,10 +22,10 @@ func GetRewardChannels(c *fiber.Ctx) error {
 	}
 
 	// Check which channels user has already claimed
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var claimedChannelIDs []uuid.UUID
 	database.DB.Model(&models.UserChannelReward{}).
@@ -57,10 +60,10 @@ func GetRewardChannels(c *fiber.Ctx) error {
 
 // ClaimChannelReward claims coins for subscribing to a Telegram channel
 func ClaimChannelReward(c *fiber.Ctx) error {
-	token := c.Locals("user").(*jwt.Token)
-	claims := token.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req struct {
 		ChannelID string `json:"channel_id"`
@@ -105,24 +108,30 @@ func ClaimChannelReward(c *fiber.Ctx) error {
 	}
 
 	// Add coins to user
-	var user models.User
-	if err := tx.First(&user, "id = ?", userID).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
-	}
-
-	user.CoinBalance += channel.CoinReward
-	if err := tx.Save(&user).Error; err != nil {
+	entry, err := ledger.Post(c.Context(), ledger.Gorm(tx), ledger.Entry{
+		Type:           ledger.EntryChannelReward,
+		ReferenceType:  "user_channel_reward",
+		ReferenceID:    reward.ID.String(),
+		IdempotencyKey: fmt.Sprintf("channel_reward:%s:%s", userID, channelID),
+		Description:    fmt.Sprintf("Channel subscription reward (%s)", channel.ChannelName),
+		Lines:          ledger.Transfer(ledger.System(ledger.SystemRewards), ledger.UserCoins(userID), int64(channel.CoinReward)),
+	})
+	if err != nil {
 		tx.Rollback()
+		if errors.Is(err, ledger.ErrDuplicateEntry) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Reward already claimed"})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update balance"})
 	}
+	newBalance := int(entry.BalanceAfter(ledger.UserCoins(userID)))
 
 	// Create coin transaction
 	coinTx := models.CoinTransaction{
 		UserID:          userID,
 		TransactionType: models.TransactionTypeChannelSubscriptionReward,
 		CoinAmount:      channel.CoinReward,
-		BalanceAfter:    user.CoinBalance,
+		BalanceAfter:    newBalance,
+		JournalEntryID:  &entry.ID,
 		Metadata:        models.JSONMap{"channel_id": channelID.String()},
 	}
 	if err := tx.Create(&coinTx).Error; err != nil {
@@ -135,7 +144,6 @@ func ClaimChannelReward(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message":      "Reward claimed successfully",
 		"coins_earned": channel.CoinReward,
-		"new_balance":  user.CoinBalance,
+		"new_balance":  newBalance,
 	})
 }
-
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"lomi-backend/internal/auth"
+	"lomi-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// ListSessions returns the devices the user is logged in on
+// (GET /auth/sessions). The session of this request is marked current.
+func ListSessions(c *fiber.Ctx) error {
+	claims, err := auth.ClaimsFrom(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+
+	sessions, err := services.ListSessions(c.Context(), claims.UserID)
+	if err != nil {
+		log.Printf("❌ Failed to list sessions of user %s: %v", claims.UserID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load devices"})
+	}
+
+	devices := make([]fiber.Map, 0, len(sessions))
+	for _, session := range sessions {
+		devices = append(devices, fiber.Map{
+			"session": session,
+			"current": session.ID == claims.SessionID,
+		})
+	}
+	return c.JSON(fiber.Map{
+		"sessions": devices,
+		"count":    len(devices),
+	})
+}
+
+// RevokeSession logs a device out (DELETE /auth/sessions/:id)
+func RevokeSession(c *fiber.Ctx) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid session ID"})
+	}
+
+	if err := services.RevokeSession(c.Context(), userID, sessionID); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Printf("❌ Failed to revoke session %s of user %s: %v", sessionID, userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to log out device"})
+	}
+
+	log.Printf("✅ Session revoked: user_id=%s session_id=%s", userID, sessionID)
+	return c.JSON(fiber.Map{"message": "Device logged out"})
+}
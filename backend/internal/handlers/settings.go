@@ -1,25 +1,25 @@
 package handlers
 
 import (
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/database"
 	"lomi-backend/internal/models"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
 )
 
 // GetPrivacySettings retrieves the user's privacy settings
 func GetPrivacySettings(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var settings models.PrivacySetting
 	if err := database.DB.Where("user_id = ?", userID).First(&settings).Error; err != nil {
 		// If not found, create default settings
 		settings = models.PrivacySetting{
-			UserID: uuid.MustParse(userID),
+			UserID: userID,
 		}
 		database.DB.Create(&settings)
 	}
@@ -29,9 +29,10 @@ func GetPrivacySettings(c *fiber.Ctx) error {
 
 // UpdatePrivacySettings updates the user's privacy settings
 func UpdatePrivacySettings(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req models.PrivacySetting
 	if err := c.BodyParser(&req); err != nil {
@@ -42,7 +43,7 @@ func UpdatePrivacySettings(c *fiber.Ctx) error {
 	if err := database.DB.Where("user_id = ?", userID).First(&settings).Error; err != nil {
 		// If not found, create new
 		settings = models.PrivacySetting{
-			UserID: uuid.MustParse(userID),
+			UserID: userID,
 		}
 	}
 
@@ -63,15 +64,16 @@ func UpdatePrivacySettings(c *fiber.Ctx) error {
 
 // GetPushNotifications retrieves the user's push notification settings
 func GetPushNotifications(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var settings models.PushNotification
 	if err := database.DB.Where("user_id = ?", userID).First(&settings).Error; err != nil {
 		// If not found, create default settings
 		settings = models.PushNotification{
-			UserID: uuid.MustParse(userID),
+			UserID: userID,
 		}
 		database.DB.Create(&settings)
 	}
@@ -81,9 +83,10 @@ func GetPushNotifications(c *fiber.Ctx) error {
 
 // UpdatePushNotifications updates the user's push notification settings
 func UpdatePushNotifications(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req models.PushNotification
 	if err := c.BodyParser(&req); err != nil {
@@ -94,7 +97,7 @@ func UpdatePushNotifications(c *fiber.Ctx) error {
 	if err := database.DB.Where("user_id = ?", userID).First(&settings).Error; err != nil {
 		// If not found, create new
 		settings = models.PushNotification{
-			UserID: uuid.MustParse(userID),
+			UserID: userID,
 		}
 	}
 
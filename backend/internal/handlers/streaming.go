@@ -6,12 +6,16 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"strings"
 	"time"
 
 	"lomi-backend/config"
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/database"
+	"lomi-backend/internal/ledger"
 	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -260,21 +264,13 @@ func (h *StreamingHandler) ShowUserDetail(c *fiber.Ctx) error {
 		return c.JSON(tikTokError(400, "Invalid request body"))
 	}
 
-	// Get user ID from context (set by auth middleware) or from request
-	userID := c.Locals("user_id")
-	if userID == nil && req.UserID != "" {
-		parsedID, err := uuid.Parse(req.UserID)
-		if err == nil {
-			userID = parsedID
-		}
-	}
-
-	if userID == nil {
+	userID, err := auth.UserID(c)
+	if err != nil {
 		return c.JSON(tikTokError(401, "Unauthorized"))
 	}
 
 	var user models.User
-	targetUserID := userID.(uuid.UUID)
+	targetUserID := userID
 
 	// If requesting other user's profile
 	if req.OtherUserID != "" {
@@ -285,7 +281,7 @@ func (h *StreamingHandler) ShowUserDetail(c *fiber.Ctx) error {
 		targetUserID = otherID
 	}
 
-	if err := database.DB.Where("id = ?", targetUserID).First(&user).Error; err != nil {
+	if err := database.DB.Scopes(services.PreloadActiveEntitlements).Where("id = ?", targetUserID).First(&user).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.JSON(tikTokError(404, "User not found"))
 		}
@@ -294,19 +290,26 @@ func (h *StreamingHandler) ShowUserDetail(c *fiber.Ctx) error {
 
 	// Determine relationship status (following/friends/follow back)
 	buttonStatus := "follow"
-	if targetUserID != userID.(uuid.UUID) {
+	self := targetUserID == userID
+	if !self {
 		// TODO: Check if users follow each other
 		// For now, default to "follow"
 		buttonStatus = "follow"
 	}
 
+	// Coin balances are only shown to their owner
+	wallet, totalCoins := 0, 0
+	if self {
+		wallet, totalCoins = user.CoinBalance, user.TotalEarned
+	}
+
 	response := tikTokSuccess(fiber.Map{
 		"User": fiber.Map{
 			"id":                   user.ID,
 			"username":             user.Name,
 			"first_name":           user.Name,
-			"wallet":               user.CoinBalance,
-			"total_all_time_coins": user.TotalEarned,
+			"wallet":               wallet,
+			"total_all_time_coins": totalCoins,
 			"verified":             boolToInt(user.IsVerified),
 			"button":               buttonStatus,
 			"profile_pic":          "",
@@ -315,6 +318,7 @@ func (h *StreamingHandler) ShowUserDetail(c *fiber.Ctx) error {
 			"age":                  user.Age,
 			"gender":               user.Gender,
 			"online":               boolToInt(user.IsOnline),
+			"entitlements":         user.Entitlements,
 		},
 	})
 
@@ -514,16 +518,8 @@ func (h *StreamingHandler) LiveStream(c *fiber.Ctx) error {
 		return c.JSON(tikTokError(400, "Invalid request body"))
 	}
 
-	// Get user ID from context or request
-	userID := c.Locals("user_id")
-	if userID == nil && req.UserID != "" {
-		parsedID, err := uuid.Parse(req.UserID)
-		if err == nil {
-			userID = parsedID
-		}
-	}
-
-	if userID == nil {
+	userID, err := auth.UserID(c)
+	if err != nil {
 		return c.JSON(tikTokError(401, "Unauthorized"))
 	}
 
@@ -568,9 +564,8 @@ func (h *StreamingHandler) SendGift(c *fiber.Ctx) error {
 		return c.JSON(tikTokError(400, "Invalid request body"))
 	}
 
-	// Get sender ID from context
-	senderID := c.Locals("user_id")
-	if senderID == nil {
+	senderID, err := auth.UserID(c)
+	if err != nil {
 		return c.JSON(tikTokError(401, "Unauthorized"))
 	}
 
@@ -580,19 +575,46 @@ func (h *StreamingHandler) SendGift(c *fiber.Ctx) error {
 		return c.JSON(tikTokError(400, "Invalid receiver_id"))
 	}
 
-	// Parse gift ID
+	// Resolve gift from the catalog
 	giftID, err := uuid.Parse(req.GiftID)
 	if err != nil {
 		return c.JSON(tikTokError(400, "Invalid gift_id"))
 	}
+	catalogGift, err := services.GiftCatalogSvc.GetByID(c.Context(), giftID)
+	if err != nil {
+		switch err {
+		case services.ErrGiftNotFound:
+			return c.JSON(tikTokError(400, "Invalid gift_id"))
+		case services.ErrGiftUnavailable:
+			return c.JSON(tikTokError(400, "This gift is not available right now"))
+		}
+		log.Printf("❌ Failed to resolve gift: %v", err)
+		return c.JSON(tikTokError(500, "Could not send gift"))
+	}
+	gift := *catalogGift
 
 	if req.GiftCount <= 0 {
 		req.GiftCount = 1
 	}
+	if req.GiftCount > services.MaxGiftCount {
+		return c.JSON(tikTokError(400, fmt.Sprintf("gift_count can be at most %d", services.MaxGiftCount)))
+	}
+	// The count is capped, so the cost fits in an int64; it must fit the int columns too
+	cost := int64(gift.CoinPrice) * int64(req.GiftCount)
+	if cost > math.MaxInt32 {
+		return c.JSON(tikTokError(400, "Gift total is too large"))
+	}
+	totalCost := int(cost)
+
+	var liveStreamID *uuid.UUID
+	if req.LiveStreamingID != "" {
+		if id, err := uuid.Parse(req.LiveStreamingID); err == nil {
+			liveStreamID = &id
+		}
+	}
 
 	var sender models.User
 	var receiver models.User
-	var gift models.Gift
 
 	err = database.DB.Transaction(func(tx *gorm.DB) error {
 		// Get sender
@@ -605,33 +627,11 @@ func (h *StreamingHandler) SendGift(c *fiber.Ctx) error {
 			return err
 		}
 
-		// Get gift
-		if err := tx.Where("id = ?", giftID).First(&gift).Error; err != nil {
-			return err
-		}
-
-		// Calculate total cost
-		totalCost := gift.CoinPrice * req.GiftCount
-
 		// Check if sender has enough coins
 		if sender.CoinBalance < totalCost {
 			return fmt.Errorf("insufficient coins")
 		}
 
-		// Deduct coins from sender
-		sender.CoinBalance -= totalCost
-		sender.TotalSpent += totalCost
-		if err := tx.Save(&sender).Error; err != nil {
-			return err
-		}
-
-		// Add coins to receiver
-		receiver.CoinBalance += totalCost
-		receiver.TotalEarned += totalCost
-		if err := tx.Save(&receiver).Error; err != nil {
-			return err
-		}
-
 		// Create gift transaction
 		giftTx := models.GiftTransaction{
 			SenderID:   sender.ID,
@@ -640,11 +640,44 @@ func (h *StreamingHandler) SendGift(c *fiber.Ctx) error {
 			CoinAmount: totalCost,
 			BirrValue:  gift.BirrValue * float64(req.GiftCount),
 			GiftType:   gift.NameEn,
+
+			LiveStreamID: liveStreamID,
 		}
 		if err := tx.Create(&giftTx).Error; err != nil {
 			return err
 		}
 
+		// Move coins from sender to receiver
+		entry, err := ledger.Post(c.Context(), ledger.Gorm(tx), ledger.Entry{
+			Type:          ledger.EntryGift,
+			ReferenceType: "gift_transaction",
+			ReferenceID:   giftTx.ID.String(),
+			Description:   fmt.Sprintf("Gift %s x%d", gift.Slug, req.GiftCount),
+			Lines:         ledger.Transfer(ledger.UserCoins(sender.ID), ledger.UserCoins(receiver.ID), int64(totalCost)),
+		})
+		if errors.Is(err, ledger.ErrInsufficientFunds) {
+			return fmt.Errorf("insufficient coins")
+		}
+		if err != nil {
+			return err
+		}
+		sender.CoinBalance = int(entry.BalanceAfter(ledger.UserCoins(sender.ID)))
+		receiver.CoinBalance = int(entry.BalanceAfter(ledger.UserCoins(receiver.ID)))
+
+		if err := tx.Model(&models.User{}).Where("id = ?", sender.ID).
+			UpdateColumn("total_spent", gorm.Expr("total_spent + ?", totalCost)).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", receiver.ID).
+			UpdateColumn("total_earned", gorm.Expr("total_earned + ?", totalCost)).Error; err != nil {
+			return err
+		}
+
+		// Grant the receiver the gift's special effect, if any
+		if _, err := services.GrantGiftEntitlement(tx, &gift, &giftTx, req.GiftCount); err != nil {
+			return err
+		}
+
 		// Create coin transactions for both users
 		senderCoinTx := models.CoinTransaction{
 			UserID:            sender.ID,
@@ -653,6 +686,7 @@ func (h *StreamingHandler) SendGift(c *fiber.Ctx) error {
 			GiftTransactionID: &giftTx.ID,
 			BalanceAfter:      sender.CoinBalance,
 			PaymentStatus:     models.PaymentStatusCompleted,
+			JournalEntryID:    &entry.ID,
 		}
 		if err := tx.Create(&senderCoinTx).Error; err != nil {
 			return err
@@ -665,6 +699,7 @@ func (h *StreamingHandler) SendGift(c *fiber.Ctx) error {
 			GiftTransactionID: &giftTx.ID,
 			BalanceAfter:      receiver.CoinBalance,
 			PaymentStatus:     models.PaymentStatusCompleted,
+			JournalEntryID:    &entry.ID,
 		}
 		if err := tx.Create(&receiverCoinTx).Error; err != nil {
 			return err
@@ -683,6 +718,11 @@ func (h *StreamingHandler) SendGift(c *fiber.Ctx) error {
 
 	log.Printf("✅ Gift sent: sender=%s, receiver=%s, gift=%s, count=%d", sender.ID, receiver.ID, gift.NameEn, req.GiftCount)
 
+	// Count towards a PK battle if the receiver is battling on this stream
+	if req.LiveStreamingID != "" {
+		go recordBattleGift(req.LiveStreamingID, sender.ID, receiver.ID, totalCost)
+	}
+
 	response := tikTokSuccess(fiber.Map{
 		"User": fiber.Map{
 			"id":     sender.ID,
@@ -698,100 +738,41 @@ func (h *StreamingHandler) SendGift(c *fiber.Ctx) error {
 	return c.JSON(response)
 }
 
-// ==================== 6. POST /api/purchaseCoin ====================
-// Purchase coins (in-app purchase)
-func (h *StreamingHandler) PurchaseCoin(c *fiber.Ctx) error {
-	var req struct {
-		UserID        string `json:"user_id"`
-		Coin          string `json:"coin"`
-		Title         string `json:"title"`
-		Price         string `json:"price"`
-		TransactionID string `json:"transaction_id"`
-		Device        string `json:"device"` // "ios" or "android"
-	}
-
-	if err := c.BodyParser(&req); err != nil {
-		return c.JSON(tikTokError(400, "Invalid request body"))
-	}
-
-	// Get user ID from context
-	userID := c.Locals("user_id")
-	if userID == nil {
-		return c.JSON(tikTokError(401, "Unauthorized"))
-	}
-
-	// Parse coin amount
-	var coinAmount int
-	fmt.Sscanf(req.Coin, "%d", &coinAmount)
-	if coinAmount <= 0 {
-		return c.JSON(tikTokError(400, "Invalid coin amount"))
-	}
-
-	// Parse price
-	var price float64
-	fmt.Sscanf(req.Price, "%f", &price)
-	if price <= 0 {
-		return c.JSON(tikTokError(400, "Invalid price"))
+// ==================== POST /api/showGifts ====================
+// Gift catalog in the TikTok app format
+func (h *StreamingHandler) ShowGifts(c *fiber.Ctx) error {
+	catalog, err := services.GiftCatalogSvc.ListAvailable(c.Context())
+	if err != nil {
+		log.Printf("❌ ShowGifts error: %v", err)
+		return c.JSON(tikTokError(500, "Could not load gifts"))
 	}
 
-	var user models.User
-
-	err := database.DB.Transaction(func(tx *gorm.DB) error {
-		// Get user
-		if err := tx.Where("id = ?", userID).First(&user).Error; err != nil {
-			return err
+	gifts := make([]fiber.Map, 0, len(catalog))
+	for _, gift := range catalog {
+		giftTier := "normal"
+		if gift.CoinPrice >= 29999 {
+			giftTier = "luxury"
+		} else if gift.CoinPrice >= 999 {
+			giftTier = "premium"
 		}
 
-		// Add coins to user
-		user.CoinBalance += coinAmount
-		if err := tx.Save(&user).Error; err != nil {
-			return err
-		}
-
-		// Create coin transaction
-		coinTx := models.CoinTransaction{
-			UserID:           user.ID,
-			TransactionType:  models.TransactionTypePurchase,
-			CoinAmount:       coinAmount,
-			BirrAmount:       price,
-			PaymentMethod:    models.PaymentMethodTelebirr, // Default
-			PaymentReference: req.TransactionID,
-			PaymentStatus:    models.PaymentStatusCompleted,
-			BalanceAfter:     user.CoinBalance,
-			Metadata: models.JSONMap{
-				"title":  req.Title,
-				"device": req.Device,
+		gifts = append(gifts, fiber.Map{
+			"Gift": fiber.Map{
+				"id":        gift.ID,
+				"slug":      gift.Slug,
+				"title":     gift.NameEn,
+				"title_am":  gift.NameAm,
+				"image":     gift.IconURL,
+				"animation": gift.AnimationURL,
+				"sound":     gift.SoundURL,
+				"coin":      gift.CoinPrice,
+				"type":      giftTier,
+				"featured":  boolToInt(gift.IsFeatured),
 			},
-		}
-		if err := tx.Create(&coinTx).Error; err != nil {
-			return err
-		}
-
-		return nil
-	})
-
-	if err != nil {
-		log.Printf("❌ PurchaseCoin error: %v", err)
-		return c.JSON(tikTokError(500, "Could not process purchase"))
+		})
 	}
 
-	log.Printf("✅ Coins purchased: user=%s, coins=%d, price=%.2f", user.ID, coinAmount, price)
-
-	response := tikTokSuccess(fiber.Map{
-		"User": fiber.Map{
-			"id":                   user.ID,
-			"wallet":               user.CoinBalance,
-			"total_all_time_coins": user.TotalEarned + coinAmount,
-		},
-		"Transaction": fiber.Map{
-			"id":      999, // Dummy ID
-			"coin":    coinAmount,
-			"price":   price,
-			"created": time.Now().Format("2006-01-02 15:04:05"),
-		},
-	})
-
-	return c.JSON(response)
+	return c.JSON(tikTokSuccess(gifts))
 }
 
 // ==================== Helper Functions ====================
@@ -4,13 +4,14 @@ import (
 	"context"
 	"fmt"
 	"lomi-backend/config"
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/database"
 	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
 	"lomi-backend/internal/utils"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -89,10 +90,10 @@ func TestS3Connection(c *fiber.Ctx) error {
 
 // TestMediaUpload tests the complete media upload flow
 func TestMediaUpload(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, _ := uuid.Parse(userIDStr)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	// Step 1: Get upload URL
 	mediaType := c.Query("media_type", "photo")
@@ -150,8 +151,8 @@ func TestMediaUpload(c *fiber.Ctx) error {
 	})
 }
 
-// TestGetJWT generates a JWT token for a user by user ID (for testing only)
-// WARNING: This endpoint should be disabled in production or protected with admin auth
+// TestGetJWT generates a JWT token for a user by user ID (for testing only).
+// Mounted under /debug in development only, behind an admin token.
 func TestGetJWT(c *fiber.Ctx) error {
 	// Get user_id from query parameter or body
 	var userIDStr string
@@ -168,7 +169,7 @@ func TestGetJWT(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "user_id is required",
 			"usage": fiber.Map{
-				"query": "/test/jwt?user_id=USER_UUID",
+				"query": "/debug/jwt?user_id=USER_UUID",
 				"body":  "POST with {\"user_id\": \"USER_UUID\"}",
 				"note":  "Get user_id from database: SELECT id FROM users LIMIT 1;",
 			},
@@ -201,7 +202,7 @@ func TestGetJWT(c *fiber.Ctx) error {
 		})
 	}
 
-	tokens, err := utils.CreateToken(userID, cfg.JWTSecret)
+	tokens, err := services.IssueTokens(c.Context(), userID, deviceFromRequest(c))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   "Failed to generate tokens",
@@ -1,12 +1,15 @@
 package handlers
 
 import (
+	"log"
+
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/database"
 	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
 	"lomi-backend/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 type UpdateProfileRequest struct {
@@ -21,25 +24,31 @@ type UpdateProfileRequest struct {
 }
 
 func GetMe(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var dbUser models.User
-	// Preload related settings
-	if err := database.DB.Preload("PrivacySetting").Preload("PushNotification").First(&dbUser, "id = ?", userID).Error; err != nil {
+	// Preload related settings and active gift effects
+	if err := database.DB.Preload("PrivacySetting").Preload("PushNotification").
+		Scopes(services.PreloadActiveEntitlements).
+		First(&dbUser, "id = ?", userID).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
 	}
 
 	return c.JSON(dbUser)
 }
 
-// GetAllUsers lists all users (for testing/admin purposes)
+// GetAllUsers lists full user rows, newest first (debug router only)
 func GetAllUsers(c *fiber.Ctx) error {
 	var users []models.User
 
 	// Get query parameters
 	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 100 {
+		limit = 100
+	}
 	offset := c.QueryInt("offset", 0)
 	telegramOnly := c.QueryBool("telegram_only", false)
 
@@ -54,18 +63,14 @@ func GetAllUsers(c *fiber.Ctx) error {
 	query = query.Order("created_at DESC")
 
 	// Apply limit and offset
-	if limit > 0 {
-		query = query.Limit(limit)
-	}
+	query = query.Limit(limit)
 	if offset > 0 {
 		query = query.Offset(offset)
 	}
 
 	if err := query.Find(&users).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   "Failed to fetch users",
-			"details": err.Error(),
-		})
+		log.Printf("❌ Failed to fetch users: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch users"})
 	}
 
 	// Count total users
@@ -119,9 +124,10 @@ func GetAllUsers(c *fiber.Ctx) error {
 }
 
 func UpdateProfile(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	var req UpdateProfileRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -167,7 +173,11 @@ func UpdateProfile(c *fiber.Ctx) error {
 
 	// Profile is considered complete if basic info is present (City check is done elsewhere)
 
-	if err := database.DB.Save(&dbUser).Error; err != nil {
+	// Only write the profile columns: balances and totals are owned by the
+	// ledger, and saving the whole row would put back the copy read above
+	if err := database.DB.Model(&dbUser).
+		Select("name", "age", "gender", "bio", "city", "interests", "relationship_goal", "preferences", "updated_at").
+		Updates(&dbUser).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update profile"})
 	}
 
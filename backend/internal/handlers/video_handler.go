@@ -3,10 +3,10 @@ package handlers
 import (
 	"strconv"
 
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/services"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 type VideoHandler struct {
@@ -25,9 +25,10 @@ func NewVideoHandler(videoService *services.VideoService) *VideoHandler {
 
 // ShowVideosAgainstUserID handles POST /api/v1/showVideosAgainstUserID
 func (h *VideoHandler) ShowVideosAgainstUserID(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	viewerID := claims["user_id"].(string)
+	viewerID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	// Get user_id from request body
 	var reqBody struct {
@@ -42,13 +43,13 @@ func (h *VideoHandler) ShowVideosAgainstUserID(c *fiber.Ctx) error {
 
 	userID := reqBody.UserID
 	if userID == "" {
-		userID = viewerID // If no user_id provided, show own videos
+		userID = viewerID.String() // If no user_id provided, show own videos
 	}
 
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "20"))
 
-	result, err := h.videoService.GetUserVideos(c.Context(), userID, viewerID, page, limit)
+	result, err := h.videoService.GetUserVideos(c.Context(), userID, viewerID.String(), page, limit)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"code": 500,
@@ -65,9 +66,10 @@ func (h *VideoHandler) ShowVideosAgainstUserID(c *fiber.Ctx) error {
 
 // ShowUserLikedVideos handles POST /api/v1/showUserLikedVideos
 func (h *VideoHandler) ShowUserLikedVideos(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	viewerID := claims["user_id"].(string)
+	viewerID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	// Get user_id from request body
 	var reqBody struct {
@@ -82,13 +84,13 @@ func (h *VideoHandler) ShowUserLikedVideos(c *fiber.Ctx) error {
 
 	userID := reqBody.UserID
 	if userID == "" {
-		userID = viewerID // If no user_id provided, show own liked videos
+		userID = viewerID.String() // If no user_id provided, show own liked videos
 	}
 
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "20"))
 
-	result, err := h.videoService.GetUserLikedVideos(c.Context(), userID, viewerID, page, limit)
+	result, err := h.videoService.GetUserLikedVideos(c.Context(), userID, viewerID.String(), page, limit)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"code": 500,
@@ -105,9 +107,10 @@ func (h *VideoHandler) ShowUserLikedVideos(c *fiber.Ctx) error {
 
 // ShowUserRepostedVideos handles POST /api/v1/showUserRepostedVideos
 func (h *VideoHandler) ShowUserRepostedVideos(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	viewerID := claims["user_id"].(string)
+	viewerID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	// Get user_id from request body
 	var reqBody struct {
@@ -122,13 +125,13 @@ func (h *VideoHandler) ShowUserRepostedVideos(c *fiber.Ctx) error {
 
 	userID := reqBody.UserID
 	if userID == "" {
-		userID = viewerID // If no user_id provided, show own reposts
+		userID = viewerID.String() // If no user_id provided, show own reposts
 	}
 
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "20"))
 
-	result, err := h.videoService.GetUserRepostedVideos(c.Context(), userID, viewerID, page, limit)
+	result, err := h.videoService.GetUserRepostedVideos(c.Context(), userID, viewerID.String(), page, limit)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"code": 500,
@@ -145,9 +148,10 @@ func (h *VideoHandler) ShowUserRepostedVideos(c *fiber.Ctx) error {
 
 // ShowFavouriteVideos handles POST /api/v1/showFavouriteVideos
 func (h *VideoHandler) ShowFavouriteVideos(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	viewerID := claims["user_id"].(string)
+	viewerID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	// Get user_id from request body
 	var reqBody struct {
@@ -162,13 +166,13 @@ func (h *VideoHandler) ShowFavouriteVideos(c *fiber.Ctx) error {
 
 	userID := reqBody.UserID
 	if userID == "" {
-		userID = viewerID // If no user_id provided, show own favorites
+		userID = viewerID.String() // If no user_id provided, show own favorites
 	}
 
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "20"))
 
-	result, err := h.videoService.GetUserFavoriteVideos(c.Context(), userID, viewerID, page, limit)
+	result, err := h.videoService.GetUserFavoriteVideos(c.Context(), userID, viewerID.String(), page, limit)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"code": 500,
@@ -189,14 +193,15 @@ func (h *VideoHandler) ShowFavouriteVideos(c *fiber.Ctx) error {
 
 // ShowDraftVideos handles POST /api/v1/showDraftVideos
 func (h *VideoHandler) ShowDraftVideos(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 	
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "20"))
 	
-	result, err := h.videoService.GetUserDraftVideos(c.Context(), userID, page, limit)
+	result, err := h.videoService.GetUserDraftVideos(c.Context(), userID.String(), page, limit)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"code": 500,
@@ -213,9 +218,10 @@ func (h *VideoHandler) ShowDraftVideos(c *fiber.Ctx) error {
 
 // DeleteDraftVideo handles POST /api/v1/deleteDraftVideo
 func (h *VideoHandler) DeleteDraftVideo(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 	
 	var reqBody struct {
 		VideoID string `json:"video_id"`
@@ -234,7 +240,7 @@ func (h *VideoHandler) DeleteDraftVideo(c *fiber.Ctx) error {
 		})
 	}
 	
-	err := h.videoService.DeleteDraftVideo(c.Context(), reqBody.VideoID, userID)
+	err = h.videoService.DeleteDraftVideo(c.Context(), reqBody.VideoID, userID.String())
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"code": 400,
@@ -3,11 +3,11 @@ package handlers
 import (
 	"strconv"
 
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/models"
 	"lomi-backend/internal/services"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 // WalletHandler handles wallet-related HTTP requests
@@ -28,16 +28,12 @@ func NewWalletHandler(walletService *services.WalletService) *WalletHandler {
 
 // GetWalletBalance handles GET /api/v1/wallet/balance
 func (h *WalletHandler) GetWalletBalance(c *fiber.Ctx) error {
-	// Get user ID from context (set by auth middleware)
-	userID, ok := c.Locals("user_id").(string)
-	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"code": 401,
-			"msg":  "Unauthorized",
-		})
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
 	}
 
-	balance, err := h.walletService.GetWalletBalance(c.Context(), userID)
+	balance, err := h.walletService.GetWalletBalance(c.Context(), userID.String())
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"code": 500,
@@ -73,73 +69,15 @@ func (h *WalletHandler) GetCoinPackages(c *fiber.Ctx) error {
 	})
 }
 
-// PurchaseCoins handles POST /api/v1/wallet/purchase-coins
-func (h *WalletHandler) PurchaseCoins(c *fiber.Ctx) error {
-	userID, ok := c.Locals("user_id").(string)
-	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"code": 401,
-			"msg":  "Unauthorized",
-		})
-	}
-
-	var req models.PurchaseCoinsRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"code": 400,
-			"msg":  "Invalid request body",
-		})
-	}
-
-	// Validate request
-	if req.Coins <= 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"code": 400,
-			"msg":  "Coins must be greater than 0",
-		})
-	}
-
-	if req.Amount <= 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"code": 400,
-			"msg":  "Amount must be greater than 0",
-		})
-	}
-
-	if req.PaymentMethod == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"code": 400,
-			"msg":  "Payment method is required",
-		})
-	}
-
-	transaction, err := h.walletService.PurchaseCoins(c.Context(), userID, &req)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"code": 500,
-			"msg":  err.Error(),
-		})
-	}
-
-	return c.JSON(fiber.Map{
-		"code": 200,
-		"msg":  "Coins purchased successfully",
-		"data": transaction,
-	})
-}
-
 // ============================================
 // WITHDRAWAL ENDPOINTS
 // ============================================
 
 // RequestWithdrawal handles POST /api/v1/wallet/withdraw
 func (h *WalletHandler) RequestWithdrawal(c *fiber.Ctx) error {
-	userID, ok := c.Locals("user_id").(string)
-	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"code": 401,
-			"msg":  "Unauthorized",
-		})
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
 	}
 
 	var req models.WithdrawRequest
@@ -172,7 +110,7 @@ func (h *WalletHandler) RequestWithdrawal(c *fiber.Ctx) error {
 		})
 	}
 
-	withdrawalReq, err := h.walletService.RequestWithdrawal(c.Context(), userID, &req)
+	withdrawalReq, err := h.walletService.RequestWithdrawal(c.Context(), userID.String(), &req)
 	if err != nil {
 		statusCode := fiber.StatusInternalServerError
 		if err.Error() == "insufficient balance" {
@@ -193,19 +131,16 @@ func (h *WalletHandler) RequestWithdrawal(c *fiber.Ctx) error {
 
 // GetWithdrawalHistory handles GET /api/v1/wallet/withdrawal-history
 func (h *WalletHandler) GetWithdrawalHistory(c *fiber.Ctx) error {
-	userID, ok := c.Locals("user_id").(string)
-	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"code": 401,
-			"msg":  "Unauthorized",
-		})
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
 	}
 
 	// Get pagination parameters
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	pageSize, _ := strconv.Atoi(c.Query("page_size", "20"))
 
-	history, err := h.walletService.GetWithdrawalHistory(c.Context(), userID, page, pageSize)
+	history, err := h.walletService.GetWithdrawalHistory(c.Context(), userID.String(), page, pageSize)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"code": 500,
@@ -226,19 +161,16 @@ func (h *WalletHandler) GetWithdrawalHistory(c *fiber.Ctx) error {
 
 // GetTransactionHistory handles GET /api/v1/wallet/transactions
 func (h *WalletHandler) GetTransactionHistory(c *fiber.Ctx) error {
-	userID, ok := c.Locals("user_id").(string)
-	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"code": 401,
-			"msg":  "Unauthorized",
-		})
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
 	}
 
 	// Get pagination parameters
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	pageSize, _ := strconv.Atoi(c.Query("page_size", "20"))
 
-	transactions, err := h.walletService.GetTransactionHistory(c.Context(), userID, page, pageSize)
+	transactions, err := h.walletService.GetTransactionHistory(c.Context(), userID.String(), page, pageSize)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"code": 500,
@@ -259,12 +191,9 @@ func (h *WalletHandler) GetTransactionHistory(c *fiber.Ctx) error {
 
 // AddPayoutMethod handles POST /api/v1/wallet/payout-methods
 func (h *WalletHandler) AddPayoutMethod(c *fiber.Ctx) error {
-	userID, ok := c.Locals("user_id").(string)
-	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"code": 401,
-			"msg":  "Unauthorized",
-		})
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
 	}
 
 	var req models.AddPayoutMethodRequest
@@ -297,7 +226,7 @@ func (h *WalletHandler) AddPayoutMethod(c *fiber.Ctx) error {
 		})
 	}
 
-	method, err := h.walletService.AddPayoutMethod(c.Context(), userID, &req)
+	method, err := h.walletService.AddPayoutMethod(c.Context(), userID.String(), &req)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"code": 500,
@@ -314,15 +243,12 @@ func (h *WalletHandler) AddPayoutMethod(c *fiber.Ctx) error {
 
 // GetPayoutMethods handles GET /api/v1/wallet/payout-methods
 func (h *WalletHandler) GetPayoutMethods(c *fiber.Ctx) error {
-	userID, ok := c.Locals("user_id").(string)
-	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"code": 401,
-			"msg":  "Unauthorized",
-		})
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
 	}
 
-	methods, err := h.walletService.GetPayoutMethods(c.Context(), userID)
+	methods, err := h.walletService.GetPayoutMethods(c.Context(), userID.String())
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"code": 500,
@@ -339,12 +265,9 @@ func (h *WalletHandler) GetPayoutMethods(c *fiber.Ctx) error {
 
 // DeletePayoutMethod handles DELETE /api/v1/wallet/payout-methods/:id
 func (h *WalletHandler) DeletePayoutMethod(c *fiber.Ctx) error {
-	userID, ok := c.Locals("user_id").(string)
-	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"code": 401,
-			"msg":  "Unauthorized",
-		})
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
 	}
 
 	methodID := c.Params("id")
@@ -355,7 +278,7 @@ func (h *WalletHandler) DeletePayoutMethod(c *fiber.Ctx) error {
 		})
 	}
 
-	err := h.walletService.DeletePayoutMethod(c.Context(), methodID, userID)
+	err = h.walletService.DeletePayoutMethod(c.Context(), methodID, userID.String())
 	if err != nil {
 		statusCode := fiber.StatusInternalServerError
 		if err.Error() == "payout method not found" {
@@ -387,11 +310,6 @@ func (h *WalletHandler) AddPayout(c *fiber.Ctx) error {
 	return h.AddPayoutMethod(c)
 }
 
-// PurchaseCoin handles POST /api/v1/purchaseCoin (legacy)
-func (h *WalletHandler) PurchaseCoin(c *fiber.Ctx) error {
-	return h.PurchaseCoins(c)
-}
-
 // WithdrawRequest handles POST /api/v1/withdrawRequest (legacy)
 func (h *WalletHandler) WithdrawRequest(c *fiber.Ctx) error {
 	return h.RequestWithdrawal(c)
@@ -404,9 +322,10 @@ func (h *WalletHandler) ShowWithdrawalHistory(c *fiber.Ctx) error {
 
 // ShowOrderHistory handles POST /api/v1/showOrderHistory
 func (h *WalletHandler) ShowOrderHistory(c *fiber.Ctx) error {
-	user := c.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := claims["user_id"].(string)
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return auth.Unauthorized(c)
+	}
 
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "20"))
@@ -419,7 +338,7 @@ func (h *WalletHandler) ShowOrderHistory(c *fiber.Ctx) error {
 	}
 
 	// Get transaction history (purchases)
-	history, err := h.walletService.GetTransactionHistory(c.Context(), userID, page, limit)
+	history, err := h.walletService.GetTransactionHistory(c.Context(), userID.String(), page, limit)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"code": 500,
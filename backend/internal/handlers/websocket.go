@@ -3,13 +3,13 @@ package handlers
 import (
 	"encoding/json"
 	"log"
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/database"
 	"lomi-backend/internal/models"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -140,33 +140,14 @@ func init() {
 
 // HandleWebSocket handles WebSocket connections
 func HandleWebSocket(c *websocket.Conn) {
-	// Extract user from query params (token should be in query string)
-	tokenString := c.Query("token")
-	if tokenString == "" {
-		c.WriteJSON(fiber.Map{"error": "Token required"})
-		c.Close()
-		return
-	}
-
-	// Parse JWT token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte("your-super-secret-jwt-key-change-in-production"), nil // TODO: Get from config
-	})
-
-	if err != nil || !token.Valid {
-		c.WriteJSON(fiber.Map{"error": "Invalid token"})
-		c.Close()
-		return
-	}
-
-	claims := token.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, err := uuid.Parse(userIDStr)
-	if err != nil {
-		c.WriteJSON(fiber.Map{"error": "Invalid user ID"})
+	// Authenticated by middleware.WebSocketAuth before the upgrade
+	claims, ok := c.Locals(auth.LocalsKey).(*auth.Claims)
+	if !ok {
+		c.WriteJSON(fiber.Map{"error": "Unauthorized"})
 		c.Close()
 		return
 	}
+	userID := claims.UserID
 
 	client := &Client{
 		ID:     uuid.New(),
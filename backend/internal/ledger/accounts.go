@@ -0,0 +1,110 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// ==================== ACCOUNTS ====================
+// Every user has one LC account ("user:<id>:LC"). users.coin_balance and
+// wallets.balance are projections of it and are only written by Post.
+// System accounts are the other side of every coin movement and may go
+// negative (e.g. coin sales is negative by the number of coins ever sold).
+// A user account only goes negative through an overdraft entry (a refund of
+// coins already spent) and is flagged in_debt until it is back at zero.
+
+const (
+	CurrencyCoins = "LC" // Lomi Coins, minor unit = 1 coin
+)
+
+type AccountType string
+
+const (
+	AccountTypeUser   AccountType = "user"
+	AccountTypeSystem AccountType = "system"
+)
+
+// System account codes
+const (
+	SystemCoinSales       = "system:coin_sales"       // Coins issued against payments
+	SystemGiftRevenue     = "system:gift_revenue"     // Gifts whose value is paid out in ETB gift balance
+	SystemSpend           = "system:spend"            // Coins burned on reveals, boosts and other features
+	SystemRewards         = "system:rewards"          // Channel subscription rewards
+	SystemReferrals       = "system:referrals"        // Referral bonuses
+	SystemPromotions      = "system:promotions"       // Bonus coins of purchase promotions
+	SystemPayoutsHeld     = "system:payouts_held"     // Coins held for cashout / withdrawal requests
+	SystemPayoutsPaid     = "system:payouts_paid"     // Coins of cashouts the provider confirmed as paid
+	SystemPlatformCredits = "system:platform_credits" // Manual credits (earnings, adjustments)
+	SystemOpeningBalance  = "system:opening_balance"  // Balances carried over from the old ledgers
+)
+
+// Account identifies a ledger account. Accounts are created on first use.
+type Account struct {
+	Code     string
+	Type     AccountType
+	UserID   *uuid.UUID
+	Currency string
+}
+
+// UserCoins is the coin account of a user
+func UserCoins(userID uuid.UUID) Account {
+	return Account{
+		Code:     fmt.Sprintf("user:%s:%s", userID, CurrencyCoins),
+		Type:     AccountTypeUser,
+		UserID:   &userID,
+		Currency: CurrencyCoins,
+	}
+}
+
+// System is a platform-side LC account
+func System(code string) Account {
+	return Account{
+		Code:     code,
+		Type:     AccountTypeSystem,
+		Currency: CurrencyCoins,
+	}
+}
+
+// allowNegative: only system accounts may be overdrawn
+func (a Account) allowNegative() bool {
+	return a.Type == AccountTypeSystem
+}
+
+// ensureAccount returns the account ID, creating the account if needed
+func ensureAccount(ctx context.Context, db DBTX, account Account) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := db.QueryRowContext(ctx, `SELECT id FROM ledger_accounts WHERE code = $1`, account.Code).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return uuid.Nil, fmt.Errorf("failed to load account %s: %w", account.Code, err)
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO ledger_accounts (code, account_type, user_id, currency, allow_negative)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (code) DO NOTHING
+	`, account.Code, string(account.Type), account.UserID, account.Currency, account.allowNegative())
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to create account %s: %w", account.Code, err)
+	}
+
+	if err := db.QueryRowContext(ctx, `SELECT id FROM ledger_accounts WHERE code = $1`, account.Code).Scan(&id); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to load account %s: %w", account.Code, err)
+	}
+	return id, nil
+}
+
+// Balance returns the ledger balance of an account (0 if it does not exist yet)
+func Balance(ctx context.Context, db DBTX, account Account) (int64, error) {
+	var balance int64
+	err := db.QueryRowContext(ctx, `SELECT balance FROM ledger_accounts WHERE code = $1`, account.Code).Scan(&balance)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return balance, err
+}
@@ -0,0 +1,303 @@
+// Package ledger is the double-entry coin ledger. Every coin movement is a
+// journal entry whose postings sum to zero per currency; account balances
+// and the users.coin_balance / wallets.balance projections are updated in
+// the caller's transaction.
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DBTX is satisfied by *sql.Tx, *sqlx.Tx and the connection of a GORM transaction.
+// Post must always run inside a transaction.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Gorm returns the underlying connection of a GORM transaction
+func Gorm(tx *gorm.DB) DBTX {
+	return tx.Statement.ConnPool
+}
+
+var (
+	ErrInsufficientFunds = errors.New("insufficient balance")
+	ErrUnbalanced        = errors.New("journal entry does not balance")
+	ErrDuplicateEntry    = errors.New("journal entry already posted")
+	ErrInvalidAmount     = errors.New("amount must be greater than 0")
+)
+
+type EntryType string
+
+const (
+	EntryCoinPurchase   EntryType = "coin_purchase"
+	EntryGift           EntryType = "gift"
+	EntryReveal         EntryType = "reveal"
+	EntryChannelReward  EntryType = "channel_reward"
+	EntryReferralBonus  EntryType = "referral_bonus"
+	EntryCashout        EntryType = "cashout"
+	EntryWithdrawal     EntryType = "withdrawal"
+	EntryDebit          EntryType = "debit"
+	EntryCredit         EntryType = "credit"
+	EntryOpeningBalance EntryType = "opening_balance"
+	EntryRefund         EntryType = "refund"
+	EntryChargeback     EntryType = "chargeback"
+	EntryPayoutSettled  EntryType = "payout_settled"
+	EntryPayoutRefund   EntryType = "payout_refund"
+	EntryAccountMerge   EntryType = "account_merge"
+)
+
+// Line is one side of an entry: positive amounts credit the account balance,
+// negative amounts debit it
+type Line struct {
+	Account Account
+	Amount  int64
+
+	invalid bool // Built by Transfer from an amount <= 0
+}
+
+// Entry is a journal entry to post
+type Entry struct {
+	Type           EntryType
+	ReferenceType  string // e.g. "gift_transaction", "coin_transaction", "payout"
+	ReferenceID    string
+	IdempotencyKey string // Optional: a second Post with the same key returns ErrDuplicateEntry
+	Description    string
+	Metadata       map[string]interface{}
+	Lines          []Line
+	// AllowOverdraft lets user accounts go negative (refunds of coins that were
+	// already spent). The account is flagged in_debt until it is back at zero.
+	AllowOverdraft bool
+	// EconomyVersion is the economy config version that priced the entry.
+	// Nil records the version in force when the entry is posted.
+	EconomyVersion *int
+}
+
+// Transfer builds the two lines that move amount from one account to another.
+// amount must be positive: a negative one would move the money the other way,
+// so Post refuses the lines with ErrInvalidAmount. To move money the other
+// way, swap from and to.
+func Transfer(from, to Account, amount int64) []Line {
+	invalid := amount <= 0
+	return []Line{
+		{Account: from, Amount: -amount, invalid: invalid},
+		{Account: to, Amount: amount, invalid: invalid},
+	}
+}
+
+// JournalEntry is a posted entry
+type JournalEntry struct {
+	ID             uuid.UUID `json:"id"`
+	Type           EntryType `json:"entry_type"`
+	EconomyVersion int       `json:"economy_version"`
+	Postings       []Posting `json:"postings"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Posting is a posted line with the account balance right after it
+type Posting struct {
+	AccountCode  string     `json:"account_code"`
+	UserID       *uuid.UUID `json:"user_id,omitempty"`
+	Currency     string     `json:"currency"`
+	Amount       int64      `json:"amount"`
+	BalanceAfter int64      `json:"balance_after"`
+}
+
+// BalanceAfter returns the balance of account after this entry
+func (e *JournalEntry) BalanceAfter(account Account) int64 {
+	for i := len(e.Postings) - 1; i >= 0; i-- {
+		if e.Postings[i].AccountCode == account.Code {
+			return e.Postings[i].BalanceAfter
+		}
+	}
+	return 0
+}
+
+// Post validates and records an entry, updating account balances and the
+// user balance projections. User accounts never go negative unless the entry
+// allows an overdraft.
+//
+// Locking: Post locks every account of the entry (in code order) before
+// changing anything, and the projections are only written while holding the
+// user's account lock. Callers must not lock users/wallets rows themselves
+// before calling Post, or they invert that order.
+func Post(ctx context.Context, db DBTX, entry Entry) (*JournalEntry, error) {
+	if err := validate(entry); err != nil {
+		return nil, err
+	}
+
+	metadata := entry.Metadata
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("invalid entry metadata: %w", err)
+	}
+
+	journal := &JournalEntry{Type: entry.Type}
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO journal_entries (entry_type, reference_type, reference_id, idempotency_key, description, metadata, economy_version)
+		VALUES ($1, $2, $3, $4, $5, $6::jsonb, $7)
+		ON CONFLICT (idempotency_key) DO NOTHING
+		RETURNING id, economy_version, created_at
+	`, string(entry.Type), nullString(entry.ReferenceType), nullString(entry.ReferenceID),
+		nullString(entry.IdempotencyKey), entry.Description, string(metadataJSON), entry.EconomyVersion,
+	).Scan(&journal.ID, &journal.EconomyVersion, &journal.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrDuplicateEntry
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create journal entry: %w", err)
+	}
+
+	// Touch accounts in a stable order so concurrent entries can't deadlock
+	lines := make([]Line, len(entry.Lines))
+	copy(lines, entry.Lines)
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].Account.Code < lines[j].Account.Code })
+
+	accountIDs, err := lockAccounts(ctx, db, lines)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range lines {
+		posting, err := postLine(ctx, db, journal.ID, accountIDs[line.Account.Code], line, entry.AllowOverdraft)
+		if err != nil {
+			return nil, err
+		}
+		journal.Postings = append(journal.Postings, *posting)
+	}
+
+	return journal, nil
+}
+
+// lockAccounts creates missing accounts and takes all their row locks up front,
+// in code order. Two entries touching the same accounts queue on the first
+// shared account instead of each holding one lock the other needs.
+// lines must already be sorted by account code.
+func lockAccounts(ctx context.Context, db DBTX, lines []Line) (map[string]uuid.UUID, error) {
+	ids := make(map[string]uuid.UUID, len(lines))
+	args := make([]interface{}, 0, len(lines))
+	placeholders := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if _, seen := ids[line.Account.Code]; seen {
+			continue
+		}
+		id, err := ensureAccount(ctx, db, line.Account)
+		if err != nil {
+			return nil, err
+		}
+		ids[line.Account.Code] = id
+		args = append(args, id)
+		placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+	}
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT id FROM ledger_accounts WHERE id IN (`+strings.Join(placeholders, ", ")+`) ORDER BY code FOR UPDATE`,
+		args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock accounts: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		// Locks are taken as rows are read; nothing to scan
+	}
+	return ids, rows.Err()
+}
+
+func postLine(ctx context.Context, db DBTX, entryID, accountID uuid.UUID, line Line, overdraft bool) (*Posting, error) {
+	// Conditional update: the balance check and the write are one statement.
+	// A user account below zero is in debt; paying it back clears the flag.
+	var balanceAfter int64
+	err := db.QueryRowContext(ctx, `
+		UPDATE ledger_accounts
+		SET balance = balance + $1,
+		    in_debt = NOT allow_negative AND balance + $1 < 0,
+		    updated_at = NOW()
+		WHERE id = $2 AND (allow_negative OR $3 OR balance + $1 >= 0)
+		RETURNING balance
+	`, line.Amount, accountID, overdraft).Scan(&balanceAfter)
+	if err == sql.ErrNoRows {
+		return nil, ErrInsufficientFunds
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update account %s: %w", line.Account.Code, err)
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO postings (journal_entry_id, account_id, currency, amount, balance_after)
+		VALUES ($1, $2, $3, $4, $5)
+	`, entryID, accountID, line.Account.Currency, line.Amount, balanceAfter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create posting: %w", err)
+	}
+
+	if line.Account.Type == AccountTypeUser && line.Account.UserID != nil {
+		if err := updateProjections(ctx, db, *line.Account.UserID, balanceAfter); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Posting{
+		AccountCode:  line.Account.Code,
+		UserID:       line.Account.UserID,
+		Currency:     line.Account.Currency,
+		Amount:       line.Amount,
+		BalanceAfter: balanceAfter,
+	}, nil
+}
+
+// updateProjections keeps the legacy balance columns in sync with the ledger
+func updateProjections(ctx context.Context, db DBTX, userID uuid.UUID, balance int64) error {
+	if _, err := db.ExecContext(ctx,
+		`UPDATE users SET coin_balance = $1, updated_at = NOW() WHERE id = $2`, balance, userID); err != nil {
+		return fmt.Errorf("failed to update users.coin_balance: %w", err)
+	}
+	if _, err := db.ExecContext(ctx,
+		`UPDATE wallets SET balance = $1, updated_at = NOW() WHERE user_id = $2`, balance, userID); err != nil {
+		return fmt.Errorf("failed to update wallets.balance: %w", err)
+	}
+	return nil
+}
+
+func validate(entry Entry) error {
+	if entry.Type == "" {
+		return errors.New("journal entry type is required")
+	}
+	if len(entry.Lines) < 2 {
+		return fmt.Errorf("%w: at least two lines are required", ErrUnbalanced)
+	}
+
+	sums := make(map[string]int64)
+	for _, line := range entry.Lines {
+		if line.Amount == 0 || line.invalid {
+			return ErrInvalidAmount
+		}
+		if line.Account.Code == "" || line.Account.Currency == "" {
+			return errors.New("journal entry line has no account")
+		}
+		sums[line.Account.Currency] += line.Amount
+	}
+	for currency, sum := range sums {
+		if sum != 0 {
+			return fmt.Errorf("%w: %s is off by %d", ErrUnbalanced, currency, sum)
+		}
+	}
+	return nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
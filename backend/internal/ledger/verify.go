@@ -0,0 +1,102 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// ==================== VERIFICATION ====================
+
+// Drift is a user whose balance projection disagrees with the ledger
+type Drift struct {
+	UserID        uuid.UUID `json:"user_id"`
+	LedgerBalance int64     `json:"ledger_balance"`
+	CoinBalance   int64     `json:"coin_balance"`
+	WalletBalance *float64  `json:"wallet_balance,omitempty"`
+}
+
+// Report is the result of Verify
+type Report struct {
+	// Sum of all account balances per currency; must be 0
+	TrialBalance map[string]int64 `json:"trial_balance"`
+	// Accounts whose stored balance differs from the sum of their postings
+	AccountMismatches []string `json:"account_mismatches"`
+	// Users whose users.coin_balance / wallets.balance differ from the ledger
+	ProjectionDrift []Drift `json:"projection_drift"`
+	Balanced        bool    `json:"balanced"`
+}
+
+// Verify checks the ledger invariants. Read-only; safe to run on a live database.
+func Verify(ctx context.Context, db DBTX) (*Report, error) {
+	report := &Report{
+		TrialBalance:      make(map[string]int64),
+		AccountMismatches: []string{},
+		ProjectionDrift:   []Drift{},
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT currency, COALESCE(SUM(balance), 0) FROM ledger_accounts GROUP BY currency`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute trial balance: %w", err)
+	}
+	for rows.Next() {
+		var currency string
+		var sum int64
+		if err := rows.Scan(&currency, &sum); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		report.TrialBalance[currency] = sum
+	}
+	rows.Close()
+
+	rows, err = db.QueryContext(ctx, `
+		SELECT a.code
+		FROM ledger_accounts a
+		LEFT JOIN postings p ON p.account_id = a.id
+		GROUP BY a.id, a.code, a.balance
+		HAVING a.balance <> COALESCE(SUM(p.amount), 0)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check account balances: %w", err)
+	}
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		report.AccountMismatches = append(report.AccountMismatches, code)
+	}
+	rows.Close()
+
+	rows, err = db.QueryContext(ctx, `
+		SELECT u.id, COALESCE(a.balance, 0), u.coin_balance, w.balance
+		FROM users u
+		LEFT JOIN ledger_accounts a ON a.user_id = u.id AND a.currency = $1
+		LEFT JOIN wallets w ON w.user_id = u.id
+		WHERE u.coin_balance <> COALESCE(a.balance, 0)
+		   OR (w.id IS NOT NULL AND w.balance <> COALESCE(a.balance, 0))
+		LIMIT 1000
+	`, CurrencyCoins)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check balance projections: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d Drift
+		if err := rows.Scan(&d.UserID, &d.LedgerBalance, &d.CoinBalance, &d.WalletBalance); err != nil {
+			return nil, err
+		}
+		report.ProjectionDrift = append(report.ProjectionDrift, d)
+	}
+
+	report.Balanced = len(report.AccountMismatches) == 0 && len(report.ProjectionDrift) == 0
+	for _, sum := range report.TrialBalance {
+		if sum != 0 {
+			report.Balanced = false
+		}
+	}
+	return report, rows.Err()
+}
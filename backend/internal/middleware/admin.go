@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"encoding/json"
+	"strings"
+
+	"lomi-backend/internal/auth"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// adminLocalsKey holds the *models.AdminUser of an admin request
+const adminLocalsKey = "admin"
+
+// auditLocalsKey holds the *auditEntity of an admin request
+const auditLocalsKey = "admin_audit"
+
+// AdminFromContext returns the admin making the request (set by AdminAuth)
+func AdminFromContext(c *fiber.Ctx) (*models.AdminUser, bool) {
+	admin, ok := c.Locals(adminLocalsKey).(*models.AdminUser)
+	return admin, ok && admin != nil
+}
+
+// AdminAuth authenticates an admin token. The account is loaded on every
+// request, so deactivating an admin or changing their role applies at once.
+// The claims are stored like AuthMiddleware's: auth.UserID(c) is the admin's
+// linked user account.
+func AdminAuth(c *fiber.Ctx) error {
+	tokenString := strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+	if tokenString == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authorization header required",
+		})
+	}
+
+	claims, err := auth.ParseAdminToken(tokenString)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid or expired admin token",
+		})
+	}
+
+	var admin models.AdminUser
+	if err := database.DB.WithContext(c.Context()).First(&admin, "id = ?", *claims.AdminID).Error; err != nil || !admin.IsActive {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Admin account is disabled",
+		})
+	}
+	if admin.UserID == nil || *admin.UserID != claims.UserID {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid or expired admin token",
+		})
+	}
+
+	claims.Role = string(admin.Role)
+	auth.SetClaims(c, claims)
+	c.Locals(adminLocalsKey, &admin)
+
+	return c.Next()
+}
+
+// RequirePermission lets the request through when the admin's role has the permission
+func RequirePermission(perm models.AdminPermission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		admin, ok := AdminFromContext(c)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+		}
+		if !admin.Role.Can(perm) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":      "Your role can't do this",
+				"permission": perm,
+			})
+		}
+		return c.Next()
+	}
+}
+
+// auditEntity is the row an admin route changes
+type auditEntity struct {
+	table  string
+	column string
+	id     string
+	before models.JSONB
+}
+
+// AuditEntity snapshots the row the route changes (table.column = :param)
+// before the handler runs; AdminAuditLog snapshots it again afterwards.
+// table and column come from route definitions, never from the request.
+func AuditEntity(table, column, param string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		entity := &auditEntity{table: table, column: column, id: c.Params(param)}
+		entity.before = snapshotRow(c, entity)
+		c.Locals(auditLocalsKey, entity)
+		return c.Next()
+	}
+}
+
+// snapshotRow reads a row as JSON, minus secrets
+func snapshotRow(c *fiber.Ctx, e *auditEntity) models.JSONB {
+	if e.id == "" {
+		return nil
+	}
+	var raw []byte
+	err := database.DB.WithContext(c.Context()).Raw(
+		`SELECT to_jsonb(t) - 'password_hash' - 'totp_secret' FROM `+e.table+` t WHERE `+e.column+`::text = ?`,
+		e.id,
+	).Row().Scan(&raw)
+	if err != nil {
+		return nil
+	}
+	var row models.JSONB
+	if json.Unmarshal(raw, &row) != nil {
+		return nil
+	}
+	return row
+}
+
+// Request and response fields never written to the audit log
+var auditRedactedFields = []string{"password", "totp_code", "code", "secret", "access_token", "refresh_token", "token"}
+
+// AdminAuditLog writes every admin request that changes something (any method
+// but GET/HEAD) to admin_audit_logs, allowed or not: who, what route, the
+// status, the request body and the before/after state of the entity.
+func AdminAuditLog(c *fiber.Ctx) error {
+	if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+		return c.Next()
+	}
+
+	request := auditRequestBody(c.Body())
+	handlerErr := c.Next()
+
+	entry := &models.AdminAuditLog{
+		Action:     c.Method() + " " + c.Route().Path,
+		Path:       c.OriginalURL(),
+		StatusCode: c.Response().StatusCode(),
+		Request:    request,
+		IPAddress:  c.IP(),
+		UserAgent:  c.Get("User-Agent"),
+	}
+	if admin, ok := AdminFromContext(c); ok {
+		entry.AdminUserID = &admin.ID
+		entry.AdminRole = admin.Role
+	}
+	if entity, ok := c.Locals(auditLocalsKey).(*auditEntity); ok {
+		entry.EntityType = entity.table
+		entry.EntityID = entity.id
+		entry.Before = entity.before
+		entry.After = snapshotRow(c, entity)
+	} else if entry.StatusCode < 300 {
+		// No row to read back (creates, exports...): keep the response
+		var after models.JSONB
+		if json.Unmarshal(c.Response().Body(), &after) == nil {
+			entry.After = redactAuditFields(after)
+		}
+	}
+
+	services.RecordAdminAudit(c.Context(), entry)
+	return handlerErr
+}
+
+func auditRequestBody(body []byte) models.JSONB {
+	if len(body) == 0 {
+		return nil
+	}
+	var request models.JSONB
+	if err := json.Unmarshal(body, &request); err != nil {
+		return models.JSONB{"_raw_bytes": len(body)}
+	}
+	return redactAuditFields(request)
+}
+
+func redactAuditFields(fields models.JSONB) models.JSONB {
+	for _, field := range auditRedactedFields {
+		if _, ok := fields[field]; ok {
+			fields[field] = "[redacted]"
+		}
+	}
+	return fields
+}
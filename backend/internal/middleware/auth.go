@@ -1,11 +1,12 @@
 package middleware
 
 import (
-	"lomi-backend/config"
+	"errors"
+	"log"
+	"lomi-backend/internal/auth"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 func AuthMiddleware(c *fiber.Ctx) error {
@@ -24,21 +25,51 @@ func AuthMiddleware(c *fiber.Ctx) error {
 		})
 	}
 
-	tokenString := parts[1]
+	return authenticate(c, parts[1])
+}
 
-	// Parse and validate token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(config.Cfg.JWTSecret), nil
-	})
+// WebSocketAuth authenticates a WebSocket upgrade before it happens. Browsers
+// can't set headers on WebSocket requests, so the token may also come in the
+// ?token= query parameter. Handlers read the claims from conn.Locals(auth.LocalsKey).
+func WebSocketAuth(c *fiber.Ctx) error {
+	tokenString := c.Query("token")
+	if tokenString == "" {
+		tokenString = strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+	}
+	if tokenString == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Token required",
+		})
+	}
 
-	if err != nil || !token.Valid {
+	return authenticate(c, tokenString)
+}
+
+// authenticate validates an access token (signature, claims, revocation) and
+// stores its claims for the handlers
+func authenticate(c *fiber.Ctx, tokenString string) error {
+	claims, err := auth.ValidateAccessToken(c.Context(), tokenString)
+	switch {
+	case errors.Is(err, auth.ErrTokenRevoked):
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Session has been logged out",
+		})
+	case errors.Is(err, auth.ErrInvalidToken):
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Invalid or expired token",
 		})
+	case err != nil:
+		log.Printf("❌ Failed to validate access token: %v", err)
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Could not verify session, try again shortly",
+		})
 	}
 
-	// Store token in context for handlers to use
-	c.Locals("user", token)
+	// Store claims in context for handlers to use
+	auth.SetClaims(c, claims)
 
+	if claims.Impersonated() {
+		return impersonatedRequest(c, claims)
+	}
 	return c.Next()
 }
@@ -1,13 +1,12 @@
 package middleware
 
 import (
+	"lomi-backend/internal/auth"
 	"lomi-backend/internal/database"
 	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
 )
 
 // RateLimitConfig configures rate limiting
@@ -21,23 +20,7 @@ type RateLimitConfig struct {
 func RateLimit(config RateLimitConfig) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// Get user ID from JWT token
-		user := c.Locals("user")
-		if user == nil {
-			return c.Next()
-		}
-
-		token, ok := user.(*jwt.Token)
-		if !ok {
-			return c.Next()
-		}
-
-		claims := token.Claims.(jwt.MapClaims)
-		userIDStr, ok := claims["user_id"].(string)
-		if !ok {
-			return c.Next()
-		}
-
-		userID, err := uuid.Parse(userIDStr)
+		userID, err := auth.UserID(c)
 		if err != nil {
 			return c.Next()
 		}
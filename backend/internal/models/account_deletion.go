@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AccountDeletionStatus string
+
+const (
+	AccountDeletionScheduled AccountDeletionStatus = "scheduled"
+	AccountDeletionCanceled  AccountDeletionStatus = "canceled" // The user logged in during the grace period
+	AccountDeletionPurged    AccountDeletionStatus = "purged"
+)
+
+// AccountDeletion is a requested account deletion and, once purged, its receipt
+type AccountDeletion struct {
+	ID        uuid.UUID             `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	UserID    uuid.UUID             `gorm:"type:uuid;not null;index" json:"user_id"`
+	Status    AccountDeletionStatus `gorm:"size:20;not null;default:'scheduled'" json:"status"`
+	Reason    string                `gorm:"type:text" json:"reason,omitempty"`
+	WasActive bool                  `gorm:"not null;default:true" json:"-"`
+
+	RequestedAt time.Time  `gorm:"type:timestamptz;not null;default:now()" json:"requested_at"`
+	PurgeAfter  time.Time  `gorm:"type:timestamptz;not null" json:"purge_after"`
+	CanceledAt  *time.Time `gorm:"type:timestamptz" json:"canceled_at,omitempty"`
+	PurgedAt    *time.Time `gorm:"type:timestamptz" json:"purged_at,omitempty"`
+
+	ObjectsDeleted int    `gorm:"not null;default:0" json:"objects_deleted"`
+	DeletedRows    JSONB  `gorm:"type:jsonb;not null;default:'{}'" json:"deleted_rows"`    // "table.column" -> rows deleted
+	AnonymizedRows JSONB  `gorm:"type:jsonb;not null;default:'{}'" json:"anonymized_rows"` // "table" -> rows scrubbed but kept
+	RetainedRows   JSONB  `gorm:"type:jsonb;not null;default:'{}'" json:"retained_rows"`   // "table.column" -> rows kept on the tombstone
+	LastError      string `gorm:"type:text" json:"last_error,omitempty"`
+	Attempts       int    `gorm:"not null;default:0" json:"attempts"`
+
+	CreatedAt time.Time `gorm:"type:timestamptz;default:now()" json:"created_at"`
+	UpdatedAt time.Time `gorm:"type:timestamptz;default:now()" json:"updated_at"`
+}
+
+func (d *AccountDeletion) BeforeCreate(tx *gorm.DB) (err error) {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return
+}
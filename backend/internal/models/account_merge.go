@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AccountMerge records one account (source) merged into another (target)
+type AccountMerge struct {
+	ID           uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	TargetUserID uuid.UUID `gorm:"type:uuid;not null;index" json:"target_user_id"`
+	SourceUserID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex" json:"source_user_id"`
+
+	JournalEntryID   *uuid.UUID `gorm:"type:uuid" json:"journal_entry_id,omitempty"`
+	CoinsMoved       int64      `gorm:"not null;default:0" json:"coins_moved"`
+	GiftBalanceMoved float64    `gorm:"type:decimal(10,2);not null;default:0" json:"gift_balance_moved"`
+
+	MovedRows   JSONB `gorm:"type:jsonb;not null;default:'{}'" json:"moved_rows"`   // "table.column" -> rows moved
+	SkippedRows JSONB `gorm:"type:jsonb;not null;default:'{}'" json:"skipped_rows"` // Left on the source: the target already had one
+
+	CreatedAt time.Time `gorm:"type:timestamptz;default:now()" json:"created_at"`
+}
+
+func (m *AccountMerge) BeforeCreate(tx *gorm.DB) (err error) {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return
+}
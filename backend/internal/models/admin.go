@@ -0,0 +1,134 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AdminRole string
+
+const (
+	AdminRoleModerator  AdminRole = "moderator"   // Reports and photo moderation
+	AdminRoleFinance    AdminRole = "finance"     // Payouts, payments, refunds
+	AdminRoleAdmin      AdminRole = "admin"       // Everything but admin accounts
+	AdminRoleSuperAdmin AdminRole = "super_admin" // Everything
+)
+
+func (r AdminRole) IsValid() bool {
+	switch r {
+	case AdminRoleModerator, AdminRoleFinance, AdminRoleAdmin, AdminRoleSuperAdmin:
+		return true
+	}
+	return false
+}
+
+type AdminPermission string
+
+const (
+	AdminPermReports    AdminPermission = "reports"    // Review user reports
+	AdminPermModeration AdminPermission = "moderation" // Photo moderation, queue and live chat stats
+	AdminPermPayouts    AdminPermission = "payouts"    // Approve, reject, export, settle payouts
+	AdminPermPayments   AdminPermission = "payments"   // Reconciliation, refunds, ledger checks
+	AdminPermEconomy    AdminPermission = "economy"    // Prices, promotions, gift catalog
+	AdminPermAdmins     AdminPermission = "admins"     // Admin accounts and the audit log
+	AdminPermSupport    AdminPermission = "support"    // Impersonate users to reproduce their issues
+	AdminPermDebug      AdminPermission = "debug"      // Debug tools (development only)
+)
+
+// adminRolePermissions is the permission matrix. super_admin has every permission.
+var adminRolePermissions = map[AdminRole][]AdminPermission{
+	AdminRoleModerator: {AdminPermReports, AdminPermModeration},
+	AdminRoleFinance:   {AdminPermPayouts, AdminPermPayments},
+	AdminRoleAdmin: {
+		AdminPermReports, AdminPermModeration,
+		AdminPermPayouts, AdminPermPayments,
+		AdminPermEconomy, AdminPermSupport,
+	},
+}
+
+// Can reports whether the role has a permission
+func (r AdminRole) Can(perm AdminPermission) bool {
+	if r == AdminRoleSuperAdmin {
+		return true
+	}
+	for _, p := range adminRolePermissions[r] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminUser is a staff account for the admin API. Admins act on the app
+// through their linked user account (UserID), which existing *_by columns
+// reference.
+type AdminUser struct {
+	ID           uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	UserID       *uuid.UUID `gorm:"type:uuid;uniqueIndex" json:"user_id,omitempty"`
+	Email        string     `gorm:"size:255;uniqueIndex;not null" json:"email"`
+	PasswordHash string     `gorm:"size:255;not null" json:"-"` // bcrypt
+	FullName     string     `gorm:"size:255;not null" json:"full_name"`
+	Role         AdminRole  `gorm:"size:50;default:'moderator'" json:"role"`
+	IsActive     bool       `gorm:"default:true" json:"is_active"`
+
+	TOTPSecret   *string `gorm:"column:totp_secret;size:64" json:"-"` // Base32, set once setup starts
+	TOTPEnabled  bool    `gorm:"column:totp_enabled;not null;default:false" json:"totp_enabled"`
+	TOTPLastStep int64   `gorm:"column:totp_last_step;not null;default:0" json:"-"`
+
+	FailedLogins int        `gorm:"not null;default:0" json:"-"`
+	LockedUntil  *time.Time `gorm:"type:timestamptz" json:"locked_until,omitempty"`
+	LastLoginAt  *time.Time `gorm:"type:timestamptz" json:"last_login_at,omitempty"`
+	CreatedAt    time.Time  `gorm:"type:timestamptz;default:now()" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"type:timestamptz;default:now()" json:"updated_at"`
+}
+
+func (a *AdminUser) BeforeCreate(tx *gorm.DB) (err error) {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return
+}
+
+// AdminAuditLog records one admin action (append-only)
+type AdminAuditLog struct {
+	ID          uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	AdminUserID *uuid.UUID `gorm:"type:uuid;index" json:"admin_user_id,omitempty"`
+	AdminRole   AdminRole  `gorm:"size:50" json:"admin_role,omitempty"`
+
+	Action     string `gorm:"size:255;not null" json:"action"`
+	Path       string `gorm:"type:text;not null" json:"path"`
+	StatusCode int    `gorm:"not null" json:"status_code"`
+
+	EntityType string `gorm:"size:50" json:"entity_type,omitempty"`
+	EntityID   string `gorm:"size:100" json:"entity_id,omitempty"`
+	Request    JSONB  `gorm:"type:jsonb" json:"request,omitempty"`
+	Before     JSONB  `gorm:"type:jsonb" json:"before,omitempty"`
+	After      JSONB  `gorm:"type:jsonb" json:"after,omitempty"`
+
+	IPAddress string    `gorm:"size:45" json:"ip_address,omitempty"`
+	UserAgent string    `gorm:"type:text" json:"user_agent,omitempty"`
+	CreatedAt time.Time `gorm:"type:timestamptz;default:now()" json:"created_at"`
+}
+
+func (l *AdminAuditLog) BeforeCreate(tx *gorm.DB) (err error) {
+	if l.ID == uuid.Nil {
+		l.ID = uuid.New()
+	}
+	return
+}
+
+// AdminImpersonation is a support admin acting as a user. The short-lived
+// access token it issued carries impersonator_id; ending it revokes the token.
+type AdminImpersonation struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"` // sid claim of the token
+	AdminUserID uuid.UUID  `gorm:"type:uuid;not null;index" json:"admin_user_id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Reason      string     `gorm:"type:text;not null" json:"reason"`
+	AccessUUID  uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex" json:"-"`
+	ExpiresAt   time.Time  `gorm:"type:timestamptz;not null" json:"expires_at"`
+	EndedAt     *time.Time `gorm:"type:timestamptz" json:"ended_at,omitempty"`
+	IPAddress   string     `gorm:"size:45" json:"ip_address,omitempty"`
+	CreatedAt   time.Time  `gorm:"type:timestamptz;default:now()" json:"created_at"`
+}
@@ -7,6 +7,13 @@ import (
 	"gorm.io/gorm"
 )
 
+// Login methods (auth_providers.provider)
+const (
+	AuthProviderTelegram = "telegram" // provider_id: Telegram user ID
+	AuthProviderGoogle   = "google"   // provider_id: Google "sub"
+	AuthProviderPhone    = "phone"    // provider_id: E.164 number
+)
+
 type AuthProvider struct {
 	ID         uuid.UUID      `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
 	UserID     uuid.UUID      `gorm:"type:uuid;not null;index"`
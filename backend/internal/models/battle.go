@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type LiveBattleStatus string
+
+const (
+	LiveBattleStatusPending   LiveBattleStatus = "pending"
+	LiveBattleStatusActive    LiveBattleStatus = "active"
+	LiveBattleStatusFinished  LiveBattleStatus = "finished"
+	LiveBattleStatusCancelled LiveBattleStatus = "cancelled"
+)
+
+// LiveBattle is a timed PK round between two live broadcasters.
+// Side A is the broadcaster who sent the invite, side B accepted it.
+type LiveBattle struct {
+	ID uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+
+	StreamAID      uuid.UUID `gorm:"type:uuid;not null;index"`
+	BroadcasterAID uuid.UUID `gorm:"type:uuid;not null;index"`
+	BroadcasterA   User      `gorm:"foreignKey:BroadcasterAID"`
+	StreamBID      uuid.UUID `gorm:"type:uuid;not null;index"`
+	BroadcasterBID uuid.UUID `gorm:"type:uuid;not null;index"`
+	BroadcasterB   User      `gorm:"foreignKey:BroadcasterBID"`
+
+	DurationSeconds int              `gorm:"not null;default:300"`
+	Status          LiveBattleStatus `gorm:"size:20;not null;default:'pending';index"`
+
+	ScoreA       int        `gorm:"not null;default:0"`
+	ScoreB       int        `gorm:"not null;default:0"`
+	GiftCountA   int        `gorm:"not null;default:0"`
+	GiftCountB   int        `gorm:"not null;default:0"`
+	WinnerID     *uuid.UUID `gorm:"type:uuid"`
+	IsDraw       bool       `gorm:"default:false"`
+	TopSupporter *uuid.UUID `gorm:"type:uuid"`
+
+	StartedAt *time.Time `gorm:"type:timestamptz"`
+	EndsAt    *time.Time `gorm:"type:timestamptz"`
+	EndedAt   *time.Time `gorm:"type:timestamptz"`
+
+	CreatedAt time.Time `gorm:"type:timestamptz;default:now()"`
+	UpdatedAt time.Time `gorm:"type:timestamptz;default:now()"`
+}
+
+func (b *LiveBattle) BeforeCreate(tx *gorm.DB) (err error) {
+	if b.ID == uuid.Nil {
+		b.ID = uuid.New()
+	}
+	return
+}
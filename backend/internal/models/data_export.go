@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type DataExportStatus string
+
+const (
+	DataExportPending    DataExportStatus = "pending"
+	DataExportProcessing DataExportStatus = "processing"
+	DataExportReady      DataExportStatus = "ready"
+	DataExportFailed     DataExportStatus = "failed"
+	DataExportExpired    DataExportStatus = "expired" // The ZIP was deleted
+)
+
+// DataExport is a user's request for a copy of their data and the ZIP it produced
+type DataExport struct {
+	ID     uuid.UUID        `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	UserID uuid.UUID        `gorm:"type:uuid;not null;index" json:"-"`
+	Status DataExportStatus `gorm:"size:20;not null;default:'pending'" json:"status"`
+
+	ObjectKey string `gorm:"size:255" json:"-"` // In the gifts bucket
+	SizeBytes int64  `gorm:"not null;default:0" json:"size_bytes"`
+	Files     JSONB  `gorm:"type:jsonb;not null;default:'{}'" json:"files"` // File name -> records in it
+	Attempts  int    `gorm:"not null;default:0" json:"-"`
+	LastError string `gorm:"type:text" json:"-"`
+
+	RequestedAt time.Time  `gorm:"type:timestamptz;not null;default:now()" json:"requested_at"`
+	StartedAt   *time.Time `gorm:"type:timestamptz" json:"started_at,omitempty"`
+	CompletedAt *time.Time `gorm:"type:timestamptz" json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `gorm:"type:timestamptz" json:"expires_at,omitempty"`
+
+	CreatedAt time.Time `gorm:"type:timestamptz;default:now()" json:"created_at"`
+	UpdatedAt time.Time `gorm:"type:timestamptz;default:now()" json:"updated_at"`
+}
+
+func (e *DataExport) BeforeCreate(tx *gorm.DB) (err error) {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// EarningsStatement is a creator's monthly earnings summary. The CSV and PDF
+// are archived in the gifts bucket under CSVKey / PDFKey.
+type EarningsStatement struct {
+	ID     uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	UserID uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	Period time.Time `gorm:"type:date;not null" json:"period"` // First day of the month
+
+	GiftCount   int     `gorm:"not null" json:"gift_count"`
+	SenderCount int     `gorm:"not null" json:"sender_count"`
+	GiftCoins   int64   `gorm:"not null" json:"gift_coins"`
+	GrossBirr   float64 `gorm:"type:decimal(12,2);not null" json:"gross_birr"`
+
+	PlatformFeePercentage int     `gorm:"not null" json:"platform_fee_percentage"`
+	PlatformFeeBirr       float64 `gorm:"type:decimal(12,2);not null" json:"platform_fee_birr"`
+	NetBirr               float64 `gorm:"type:decimal(12,2);not null" json:"net_birr"`
+
+	PayoutCount int     `gorm:"not null" json:"payout_count"`
+	PayoutsBirr float64 `gorm:"type:decimal(12,2);not null" json:"payouts_birr"`
+
+	OpeningBalanceBirr float64 `gorm:"type:decimal(12,2);not null" json:"opening_balance_birr"`
+	ClosingBalanceBirr float64 `gorm:"type:decimal(12,2);not null" json:"closing_balance_birr"`
+
+	CSVKey string `gorm:"size:255" json:"-"`
+	PDFKey string `gorm:"size:255" json:"-"`
+
+	GeneratedAt time.Time `gorm:"type:timestamptz;default:now()" json:"generated_at"`
+}
+
+func (s *EarningsStatement) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CoinPack is a coin bundle sold in the shop
+type CoinPack struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	ETBPrice float64 `json:"etb_price"`
+	Coins    int     `json:"coins"`
+}
+
+// EconomyParams are the prices and rates of the coin economy
+type EconomyParams struct {
+	CoinBirrRate          float64    `json:"coin_birr_rate"`          // ETB value of 1 LC
+	PlatformFeePercentage int        `json:"platform_fee_percentage"` // Taken off cashouts and payouts
+	MinCashoutCoins       int        `json:"min_cashout_coins"`
+	MinPayoutBirr         float64    `json:"min_payout_birr"` // Gift-balance payouts
+	MinWithdrawal         float64    `json:"min_withdrawal"`  // Wallet withdrawals
+	RevealOneCoins        int        `json:"reveal_one_coins"`
+	RevealAllCoins        int        `json:"reveal_all_coins"`
+	ReferralRewardCoins   int        `json:"referral_reward_coins"`
+	CoinPacks             []CoinPack `json:"coin_packs"`
+}
+
+func (p *EconomyParams) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, p)
+}
+
+func (p EconomyParams) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+// EconomyConfig is one immutable version of the economy parameters. The
+// version with the latest EffectiveFrom that has passed is the one in force.
+type EconomyConfig struct {
+	Version       int           `gorm:"primaryKey;autoIncrement" json:"version"`
+	Params        EconomyParams `gorm:"type:jsonb;not null" json:"params"`
+	EffectiveFrom time.Time     `gorm:"type:timestamptz;not null;index" json:"effective_from"`
+	Note          string        `gorm:"type:text" json:"note,omitempty"`
+	CreatedBy     *uuid.UUID    `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt     time.Time     `gorm:"type:timestamptz;default:now()" json:"created_at"`
+}
+
+// CoinPack returns the pack with the given ID
+func (c *EconomyConfig) CoinPack(id string) (*CoinPack, bool) {
+	for i := range c.Params.CoinPacks {
+		if c.Params.CoinPacks[i].ID == id {
+			pack := c.Params.CoinPacks[i]
+			return &pack, true
+		}
+	}
+	return nil, false
+}
+
+// CoinsToBirr converts coins to ETB at this version's rate
+func (c *EconomyConfig) CoinsToBirr(coins int) float64 {
+	return float64(coins) * c.Params.CoinBirrRate
+}
+
+// PlatformFee returns the platform fee on an ETB amount
+func (c *EconomyConfig) PlatformFee(amount float64) float64 {
+	return amount * float64(c.Params.PlatformFeePercentage) / 100.0
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type EntitlementType string
+
+const (
+	EntitlementTypeProfileFrame    EntitlementType = "profile_frame"
+	EntitlementTypeCrownBadge      EntitlementType = "crown_badge"
+	EntitlementTypeHighlightedCard EntitlementType = "highlighted_card"
+)
+
+// IsValid reports whether t is a known cosmetic entitlement
+func (t EntitlementType) IsValid() bool {
+	switch t {
+	case EntitlementTypeProfileFrame, EntitlementTypeCrownBadge, EntitlementTypeHighlightedCard:
+		return true
+	}
+	return false
+}
+
+// UserEntitlement is a time-limited cosmetic granted by receiving a gift with a special effect.
+// There is at most one active row per user and type; receiving the effect again extends it.
+type UserEntitlement struct {
+	ID     uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	UserID uuid.UUID `gorm:"type:uuid;not null;index"`
+
+	EntitlementType EntitlementType `gorm:"size:30;not null;index"`
+
+	// Where it came from (the latest gift that granted or extended it)
+	SourceGiftID            *uuid.UUID `gorm:"type:uuid"`
+	SourceGiftTransactionID *uuid.UUID `gorm:"type:uuid"`
+	GrantedByID             *uuid.UUID `gorm:"type:uuid"`
+
+	StartsAt  time.Time `gorm:"type:timestamptz;not null"`
+	ExpiresAt time.Time `gorm:"type:timestamptz;not null;index"`
+	IsActive  bool      `gorm:"default:true;index"`
+
+	CreatedAt time.Time `gorm:"type:timestamptz;default:now()"`
+	UpdatedAt time.Time `gorm:"type:timestamptz;default:now()"`
+}
+
+func (e *UserEntitlement) BeforeCreate(tx *gorm.DB) (err error) {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return
+}
@@ -8,11 +8,12 @@ import (
 )
 
 type Gift struct {
-	ID          uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
-	NameEn      string    `gorm:"size:255;not null"`
-	NameAm      string    `gorm:"size:255;not null"`
-	DescriptionEn string  `gorm:"type:text"`
-	DescriptionAm string  `gorm:"type:text"`
+	ID            uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	Slug          string    `gorm:"size:50;not null;uniqueIndex"` // e.g., "rose", "universe", "lomi_crown"
+	NameEn        string    `gorm:"size:255;not null"`
+	NameAm        string    `gorm:"size:255;not null"`
+	DescriptionEn string    `gorm:"type:text"`
+	DescriptionAm string    `gorm:"type:text"`
 
 	CoinPrice int     `gorm:"not null;check:coin_price > 0"`
 	BirrValue float64 `gorm:"type:decimal(10,2);not null;check:birr_value > 0"`
@@ -21,12 +22,17 @@ type Gift struct {
 	AnimationURL string `gorm:"type:text;not null"`
 	SoundURL     string `gorm:"type:text"`
 
-	HasSpecialEffect        bool `gorm:"default:false"`
-	SpecialEffectDurationDays int `gorm:"type:integer"`
+	HasSpecialEffect          bool            `gorm:"default:false"`
+	SpecialEffectDurationDays int             `gorm:"type:integer"`
+	EffectType                EntitlementType `gorm:"size:30"` // What the receiver gets, see UserEntitlement
 
-	IsActive  bool `gorm:"default:true;index"`
-	IsFeatured bool `gorm:"default:false;index"`
-	DisplayOrder int `gorm:"default:0;index"`
+	IsActive     bool `gorm:"default:true;index"`
+	IsFeatured   bool `gorm:"default:false;index"`
+	DisplayOrder int  `gorm:"default:0;index"`
+
+	// Seasonal / limited gifts (NULL = no bound)
+	AvailableFrom  *time.Time `gorm:"type:timestamptz"`
+	AvailableUntil *time.Time `gorm:"type:timestamptz"`
 
 	CreatedAt time.Time `gorm:"type:timestamptz;default:now()"`
 	UpdatedAt time.Time `gorm:"type:timestamptz;default:now()"`
@@ -39,3 +45,16 @@ func (g *Gift) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+// IsAvailableAt reports whether the gift can be sent at t
+func (g *Gift) IsAvailableAt(t time.Time) bool {
+	if !g.IsActive {
+		return false
+	}
+	if g.AvailableFrom != nil && t.Before(*g.AvailableFrom) {
+		return false
+	}
+	if g.AvailableUntil != nil && !t.Before(*g.AvailableUntil) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type LiveStreamStatus string
+
+const (
+	LiveStreamStatusPending LiveStreamStatus = "pending"
+	LiveStreamStatusLive    LiveStreamStatus = "live"
+	LiveStreamStatusEnded   LiveStreamStatus = "ended"
+	LiveStreamStatusBanned  LiveStreamStatus = "banned"
+)
+
+type LiveStream struct {
+	ID     uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	UserID uuid.UUID `gorm:"type:uuid;not null;index"`
+	User   User      `gorm:"foreignKey:UserID"`
+
+	Title        string `gorm:"size:255;not null;default:'Live Stream'"`
+	Description  string `gorm:"type:text"`
+	ThumbnailURL string `gorm:"type:text"`
+
+	// Streaming details
+	StreamKey   string `gorm:"size:255;uniqueIndex;not null" json:"-"`
+	RTMPURL     string `gorm:"column:rtmp_url;type:text" json:"-"`
+	PlaybackURL string `gorm:"type:text"`
+
+	// Recording (set by the broadcaster once the VOD is processed)
+	VODURL             string `gorm:"column:vod_url;type:text"`
+	VODDurationSeconds int    `gorm:"column:vod_duration_seconds;default:0"`
+
+	Status    LiveStreamStatus `gorm:"size:50;default:'pending';index"`
+	StartedAt *time.Time       `gorm:"type:timestamptz"`
+	EndedAt   *time.Time       `gorm:"type:timestamptz"`
+
+	// Stats
+	PeakViewers        int `gorm:"default:0"`
+	TotalViews         int `gorm:"default:0"`
+	TotalMessages      int `gorm:"default:0"`
+	TotalGiftsReceived int `gorm:"default:0"`
+	TotalCoinsEarned   int `gorm:"default:0"`
+
+	// Settings
+	AllowChat  bool `gorm:"default:true"`
+	AllowGifts bool `gorm:"default:true"`
+	IsPrivate  bool `gorm:"default:false"`
+
+	Metadata JSONMap `gorm:"type:jsonb;default:'{}'"`
+
+	CreatedAt time.Time `gorm:"type:timestamptz;default:now()"`
+	UpdatedAt time.Time `gorm:"type:timestamptz;default:now()"`
+}
+
+func (ls *LiveStream) BeforeCreate(tx *gorm.DB) (err error) {
+	if ls.ID == uuid.Nil {
+		ls.ID = uuid.New()
+	}
+	return
+}
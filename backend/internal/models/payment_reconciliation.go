@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PaymentReconciliationReport is one provider's coin purchases for one day
+type PaymentReconciliationReport struct {
+	ID         uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	ReportDate time.Time `gorm:"type:date;not null" json:"report_date"`
+	Provider   string    `gorm:"size:30;not null" json:"provider"`
+
+	InitiatedCount  int     `gorm:"not null" json:"initiated_count"`
+	ExpectedBirr    float64 `gorm:"type:decimal(12,2);not null" json:"expected_birr"`
+	SettledCount    int     `gorm:"not null" json:"settled_count"`
+	SettledBirr     float64 `gorm:"type:decimal(12,2);not null" json:"settled_birr"`
+	ReconciledCount int     `gorm:"not null" json:"reconciled_count"`
+	FailedCount     int     `gorm:"not null" json:"failed_count"`
+	FailedBirr      float64 `gorm:"type:decimal(12,2);not null" json:"failed_birr"`
+	ExpiredCount    int     `gorm:"not null" json:"expired_count"`
+	PendingCount    int     `gorm:"not null" json:"pending_count"`
+	PendingBirr     float64 `gorm:"type:decimal(12,2);not null" json:"pending_birr"`
+	RefundedCount   int     `gorm:"not null" json:"refunded_count"`
+	RefundedBirr    float64 `gorm:"type:decimal(12,2);not null" json:"refunded_birr"`
+
+	CoinsCredited  int64 `gorm:"not null" json:"coins_credited"`
+	LedgerCoins    int64 `gorm:"not null" json:"ledger_coins"`
+	HasDiscrepancy bool  `gorm:"not null" json:"has_discrepancy"`
+
+	GeneratedAt time.Time `gorm:"type:timestamptz;default:now()" json:"generated_at"`
+}
+
+func (r *PaymentReconciliationReport) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return
+}
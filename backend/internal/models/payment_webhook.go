@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type WebhookEventStatus string
+
+const (
+	WebhookEventReceived  WebhookEventStatus = "received"
+	WebhookEventProcessed WebhookEventStatus = "processed"
+	WebhookEventDuplicate WebhookEventStatus = "duplicate"
+	WebhookEventRejected  WebhookEventStatus = "rejected"
+	WebhookEventIgnored   WebhookEventStatus = "ignored" // Valid but nothing to do (e.g. pending status)
+)
+
+// PaymentWebhookEvent is the raw audit record of one payment provider callback
+type PaymentWebhookEvent struct {
+	ID                uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	Provider          string     `gorm:"size:30;not null"`
+	EventID           *string    `gorm:"size:150"`
+	CoinTransactionID *uuid.UUID `gorm:"type:uuid;index"`
+
+	SignatureValid bool               `gorm:"not null;default:false"`
+	Status         WebhookEventStatus `gorm:"size:20;not null;default:'received'"`
+	Error          string             `gorm:"type:text"`
+
+	EventStatus string `gorm:"size:20"`
+	Amount      *int64
+	Currency    string `gorm:"size:3"`
+
+	Headers  JSONMap `gorm:"type:jsonb;default:'{}'"`
+	Payload  string  `gorm:"type:text;not null"`
+	RemoteIP string  `gorm:"size:45"`
+
+	ReceivedAt  time.Time  `gorm:"type:timestamptz;default:now()"`
+	ProcessedAt *time.Time `gorm:"type:timestamptz"`
+}
+
+func (e *PaymentWebhookEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return
+}
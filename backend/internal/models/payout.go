@@ -9,32 +9,70 @@ import (
 
 type PayoutStatus string
 
+// Payout lifecycle:
+//
+//	requested → held → approved → sent → settled
+//	held / approved / sent → rejected → refunded
 const (
+	PayoutStatusRequested PayoutStatus = "requested" // Created, funds not held yet
+	PayoutStatusHeld      PayoutStatus = "held"      // Funds held, waiting for approval
+	PayoutStatusApproved  PayoutStatus = "approved"  // Approved, waiting for the next disbursement batch
+	PayoutStatusSent      PayoutStatus = "sent"      // Exported in a disbursement batch
+	PayoutStatusSettled   PayoutStatus = "settled"   // Provider confirmed the money arrived
+	PayoutStatusRejected  PayoutStatus = "rejected"  // Rejected or disbursement failed
+	PayoutStatusRefunded  PayoutStatus = "refunded"  // Held funds returned to the user
+
+	// Legacy statuses, migrated to the ones above
 	PayoutStatusPending    PayoutStatus = "pending"
 	PayoutStatusProcessing PayoutStatus = "processing"
 	PayoutStatusCompleted  PayoutStatus = "completed"
-	PayoutStatusRejected   PayoutStatus = "rejected"
 )
 
+// PayoutStatusesInFlight are payouts whose funds are held but not paid yet
+var PayoutStatusesInFlight = []PayoutStatus{
+	PayoutStatusRequested, PayoutStatusHeld, PayoutStatusApproved, PayoutStatusSent,
+}
+
 type Payout struct {
 	ID     uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
 	UserID uuid.UUID `gorm:"type:uuid;not null;index"`
 	User   User      `gorm:"foreignKey:UserID"`
 
-	Coins                int     `gorm:"type:integer"` // Coin-based cashout
-	GiftBalanceAmount    float64 `gorm:"type:decimal(10,2);not null"`
-	PlatformFeePercentage int    `gorm:"not null;default:25"`
-	PlatformFeeAmount    float64 `gorm:"type:decimal(10,2);not null"`
-	NetAmount            float64 `gorm:"type:decimal(10,2);not null"`
+	Coins                 int     `gorm:"type:integer"` // Coin-based cashout
+	GiftBalanceAmount     float64 `gorm:"type:decimal(10,2);not null"`
+	PlatformFeePercentage int     `gorm:"not null;default:25"`
+	PlatformFeeAmount     float64 `gorm:"type:decimal(10,2);not null"`
+	NetAmount             float64 `gorm:"type:decimal(10,2);not null"`
 
 	PaymentMethod      PaymentMethod `gorm:"type:payment_method;not null"`
 	PaymentAccount     string        `gorm:"size:255;not null"`
 	PaymentAccountName string        `gorm:"size:255"`
 
-	Status        PayoutStatus `gorm:"type:payout_status;default:'pending';index"`
-	ProcessedBy   *uuid.UUID   `gorm:"type:uuid"`
-	ProcessedAt   *time.Time   `gorm:"type:timestamptz"`
-	PaymentReference string     `gorm:"size:255"`
+	Status           PayoutStatus `gorm:"type:payout_status;default:'requested';index"`
+	ProcessedBy      *uuid.UUID   `gorm:"type:uuid"`
+	ProcessedAt      *time.Time   `gorm:"type:timestamptz"`
+	PaymentReference string       `gorm:"size:255"`
+
+	// Ledger entry that moved the coins to payouts_held (coin cashouts only)
+	HoldJournalEntryID *uuid.UUID `gorm:"type:uuid"`
+
+	// Economy config version the amounts and fee were computed with
+	EconomyVersion *int `gorm:"type:integer"`
+
+	// Maker-checker: above the threshold two different admins must approve
+	RequiresDualApproval bool       `gorm:"not null;default:false"`
+	FirstApprovedBy      *uuid.UUID `gorm:"type:uuid"`
+	FirstApprovedAt      *time.Time `gorm:"type:timestamptz"`
+	ApprovedBy           *uuid.UUID `gorm:"type:uuid"`
+	ApprovedAt           *time.Time `gorm:"type:timestamptz"`
+
+	BatchID   *uuid.UUID `gorm:"type:uuid;index"`
+	SentAt    *time.Time `gorm:"type:timestamptz"`
+	SettledAt *time.Time `gorm:"type:timestamptz"`
+
+	RejectedBy *uuid.UUID `gorm:"type:uuid"`
+	RejectedAt *time.Time `gorm:"type:timestamptz"`
+	RefundedAt *time.Time `gorm:"type:timestamptz"`
 
 	AdminNotes      string `gorm:"type:text"`
 	RejectionReason string `gorm:"type:text"`
@@ -50,3 +88,37 @@ func (p *Payout) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+// PayoutEvent is one status change of a payout (audit trail)
+type PayoutEvent struct {
+	ID         uuid.UUID    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	PayoutID   uuid.UUID    `gorm:"type:uuid;not null;index" json:"payout_id"`
+	FromStatus PayoutStatus `gorm:"type:payout_status" json:"from_status"`
+	ToStatus   PayoutStatus `gorm:"type:payout_status;not null" json:"to_status"`
+	ActorID    *uuid.UUID   `gorm:"type:uuid" json:"actor_id,omitempty"` // nil for the system
+	Note       string       `gorm:"type:text" json:"note,omitempty"`
+	CreatedAt  time.Time    `gorm:"type:timestamptz;default:now()" json:"created_at"`
+}
+
+func (e *PayoutEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return
+}
+
+// PayoutBatch is a set of approved payouts exported for bulk disbursement
+type PayoutBatch struct {
+	ID             uuid.UUID     `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	PaymentMethod  PaymentMethod `gorm:"type:payment_method;not null" json:"payment_method"`
+	PayoutCount    int           `gorm:"not null" json:"payout_count"`
+	TotalNetAmount float64       `gorm:"type:decimal(12,2);not null" json:"total_net_amount"`
+	CreatedBy      uuid.UUID     `gorm:"type:uuid;not null" json:"created_by"`
+	CreatedAt      time.Time     `gorm:"type:timestamptz;default:now()" json:"created_at"`
+}
+
+func (b *PayoutBatch) BeforeCreate(tx *gorm.DB) (err error) {
+	if b.ID == uuid.Nil {
+		b.ID = uuid.New()
+	}
+	return
+}
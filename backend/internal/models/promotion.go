@@ -0,0 +1,105 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PromotionKind string
+
+const (
+	PromotionKindFirstPurchase PromotionKind = "first_purchase" // Bonus on a user's first paid purchase
+	PromotionKindHappyHour     PromotionKind = "happy_hour"     // Applies to everyone between StartsAt and EndsAt
+	PromotionKindPromoCode     PromotionKind = "promo_code"     // Applies when the user enters Code
+)
+
+func (k PromotionKind) IsValid() bool {
+	switch k {
+	case PromotionKindFirstPurchase, PromotionKindHappyHour, PromotionKindPromoCode:
+		return true
+	}
+	return false
+}
+
+// Promotion changes the price or the coins of a coin purchase. Discounts lower
+// the ETB price; bonus coins are credited on top of the pack from
+// system:promotions, so coin sales only ever counts paid coins.
+type Promotion struct {
+	ID   uuid.UUID     `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	Kind PromotionKind `gorm:"size:20;not null;index" json:"kind"`
+	Name string        `gorm:"size:255;not null" json:"name"`
+	Code *string       `gorm:"size:50;uniqueIndex" json:"code,omitempty"` // Upper case, promo codes only
+
+	DiscountPercentage int `gorm:"not null;default:0" json:"discount_percentage"` // Off the ETB price
+	BonusPercentage    int `gorm:"not null;default:0" json:"bonus_percentage"`    // Extra coins, % of the pack
+	BonusCoins         int `gorm:"not null;default:0" json:"bonus_coins"`         // Extra coins, flat
+
+	PackIDs JSONStringArray `gorm:"type:jsonb;default:'[]'" json:"pack_ids"` // Empty = every purchase
+
+	// Window (NULL = no bound)
+	StartsAt *time.Time `gorm:"type:timestamptz" json:"starts_at,omitempty"`
+	EndsAt   *time.Time `gorm:"type:timestamptz" json:"ends_at,omitempty"`
+
+	// Usage limits (NULL = unlimited). Purchases that failed don't count.
+	MaxRedemptions *int `gorm:"type:integer" json:"max_redemptions,omitempty"`
+	MaxPerUser     *int `gorm:"type:integer" json:"max_per_user,omitempty"`
+
+	IsActive  bool       `gorm:"default:true;index" json:"is_active"`
+	CreatedBy *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt time.Time  `gorm:"type:timestamptz;default:now()" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"type:timestamptz;default:now()" json:"updated_at"`
+}
+
+func (p *Promotion) BeforeCreate(tx *gorm.DB) (err error) {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return
+}
+
+// IsRunningAt reports whether the promotion is active and inside its window at t
+func (p *Promotion) IsRunningAt(t time.Time) bool {
+	if !p.IsActive {
+		return false
+	}
+	if p.StartsAt != nil && t.Before(*p.StartsAt) {
+		return false
+	}
+	if p.EndsAt != nil && !t.Before(*p.EndsAt) {
+		return false
+	}
+	return true
+}
+
+// AppliesToPack reports whether the promotion covers a pack ("" = a custom coin amount)
+func (p *Promotion) AppliesToPack(packID string) bool {
+	if len(p.PackIDs) == 0 {
+		return true
+	}
+	for _, id := range p.PackIDs {
+		if id == packID {
+			return true
+		}
+	}
+	return false
+}
+
+// PromotionRedemption is a promotion applied to a purchase
+type PromotionRedemption struct {
+	ID                uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	PromotionID       uuid.UUID `gorm:"type:uuid;not null;index" json:"promotion_id"`
+	UserID            uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	CoinTransactionID uuid.UUID `gorm:"type:uuid;not null;index" json:"coin_transaction_id"`
+	DiscountBirr      float64   `gorm:"type:decimal(10,2);not null;default:0" json:"discount_birr"`
+	BonusCoins        int       `gorm:"not null;default:0" json:"bonus_coins"`
+	CreatedAt         time.Time `gorm:"type:timestamptz;default:now()" json:"created_at"`
+}
+
+func (r *PromotionRedemption) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return
+}
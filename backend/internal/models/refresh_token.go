@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reasons a refresh token family was revoked
+const (
+	RefreshRevokeLogout      = "logout"
+	RefreshRevokeLogoutAll   = "logout_all"
+	RefreshRevokeReuse       = "reuse_detected"
+	RefreshRevokeNewLogin    = "new_login" // The same device logged in again
+	RefreshRevokeUserRemoved = "user_removed"
+	RefreshRevokeMerged      = "account_merged"   // The user was merged into another account
+	RefreshRevokeRemote      = "revoked_remotely" // Logged out from the user's device list
+	RefreshRevokeDeletion    = "account_deletion" // The user asked to delete the account
+)
+
+// RefreshToken is one issued refresh token. Only its hash is stored. A token
+// is single use: refreshing sets UsedAt and ReplacedBy on it.
+type RefreshToken struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"` // refresh_uuid claim
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	FamilyID  uuid.UUID `gorm:"type:uuid;not null;index" json:"family_id"`
+	TokenHash string    `gorm:"type:char(64);not null;uniqueIndex" json:"-"`
+
+	// Access token issued with this refresh token
+	AccessUUID      uuid.UUID `gorm:"type:uuid;not null;index" json:"-"`
+	AccessExpiresAt time.Time `gorm:"type:timestamptz;not null" json:"-"`
+
+	DeviceID  *string `gorm:"size:255" json:"device_id,omitempty"`
+	UserAgent string  `gorm:"type:text" json:"user_agent,omitempty"`
+	IPAddress string  `gorm:"size:45" json:"ip_address,omitempty"`
+
+	ExpiresAt    time.Time  `gorm:"type:timestamptz;not null" json:"expires_at"`
+	UsedAt       *time.Time `gorm:"type:timestamptz" json:"used_at,omitempty"`
+	ReplacedBy   *uuid.UUID `gorm:"type:uuid" json:"replaced_by,omitempty"`
+	RevokedAt    *time.Time `gorm:"type:timestamptz" json:"revoked_at,omitempty"`
+	RevokeReason *string    `gorm:"size:50" json:"revoke_reason,omitempty"`
+	CreatedAt    time.Time  `gorm:"type:timestamptz;default:now()" json:"created_at"`
+}
@@ -36,6 +36,7 @@ type CoinTransaction struct {
 	TransactionType TransactionType `gorm:"type:transaction_type;not null;index"`
 
 	CoinAmount int `gorm:"not null"`
+	BonusCoins int `gorm:"not null;default:0"` // Promotional coins credited with a purchase
 
 	// For purchases
 	BirrAmount       float64       `gorm:"type:decimal(10,2)"`
@@ -49,6 +50,12 @@ type CoinTransaction struct {
 
 	BalanceAfter int `gorm:"not null"`
 
+	// Ledger entry that moved the coins (nil while a purchase is pending)
+	JournalEntryID *uuid.UUID `gorm:"type:uuid;index"`
+
+	// Economy config version a purchase was priced with
+	EconomyVersion *int `gorm:"type:integer"`
+
 	Metadata JSONMap `gorm:"type:jsonb;default:'{}'"`
 
 	CreatedAt time.Time `gorm:"type:timestamptz;default:now()"`
@@ -78,6 +85,8 @@ type GiftTransaction struct {
 	MessageID *uuid.UUID `gorm:"type:uuid"`
 	Message   *Message   `gorm:"foreignKey:MessageID"`
 
+	LiveStreamID *uuid.UUID `gorm:"type:uuid;index"` // Set when sent during a live stream
+
 	CreatedAt time.Time `gorm:"type:timestamptz;default:now()"`
 }
 
@@ -118,7 +118,7 @@ type User struct {
 	AuthToken           string    `gorm:"size:255"`
 	Social              string    `gorm:"size:20"`
 	Version             string    `gorm:"size:20"`
-	Device              string    `gorm:"size:50"`
+	Device              string    `gorm:"size:50"` // Legacy single device: logins are tracked in user_sessions
 	IP                  string    `gorm:"size:50"`
 	CountryID           int       `gorm:""`
 	Wallet              float64   `gorm:"type:decimal(10,2);default:0.00"`
@@ -144,6 +144,7 @@ type User struct {
 	// Relationships
 	PrivacySetting   *PrivacySetting   `gorm:"foreignKey:UserID"`
 	PushNotification *PushNotification `gorm:"foreignKey:UserID"`
+	Entitlements     []UserEntitlement `gorm:"foreignKey:UserID"` // Preload active ones only (see services.PreloadActiveEntitlements)
 
 	// Preferences
 	Preferences JSONMap `gorm:"type:jsonb;default:'{}'"`
@@ -176,6 +177,7 @@ type User struct {
 
 	// Auth Providers
 	AuthProviders []AuthProvider `gorm:"foreignKey:UserID"`
+	MergedIntoID  *uuid.UUID     `gorm:"type:uuid"` // Set (and the user soft-deleted) once merged into another account
 
 	// Timestamps
 	CreatedAt time.Time      `gorm:"type:timestamptz;default:now()"`
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserSession is one login of a user on a device: a refresh token family.
+// Refreshing updates LastSeenAt, the IP and its location.
+type UserSession struct {
+	ID     uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"` // family_id, sid claim
+	UserID uuid.UUID `gorm:"type:uuid;not null;index" json:"-"`
+
+	DeviceID   *string `gorm:"size:255" json:"device_id,omitempty"`
+	DeviceName string  `gorm:"size:100" json:"device_name"` // X-Device-Name, or from the user agent
+	Platform   string  `gorm:"size:20" json:"platform"`     // ios, android, web, ...
+	AppVersion string  `gorm:"size:50" json:"app_version,omitempty"`
+	UserAgent  string  `gorm:"type:text" json:"-"`
+
+	IPAddress   string `gorm:"size:45" json:"ip_address,omitempty"`
+	CountryCode string `gorm:"size:2" json:"country_code,omitempty"`
+	Region      string `gorm:"size:100" json:"region,omitempty"`
+	City        string `gorm:"size:100" json:"city,omitempty"`
+
+	FirstSeenAt  time.Time  `gorm:"type:timestamptz;not null;default:now()" json:"first_seen_at"`
+	LastSeenAt   time.Time  `gorm:"type:timestamptz;not null;default:now()" json:"last_seen_at"`
+	ExpiresAt    time.Time  `gorm:"type:timestamptz;not null" json:"expires_at"`
+	RevokedAt    *time.Time `gorm:"type:timestamptz" json:"-"`
+	RevokeReason *string    `gorm:"size:50" json:"-"`
+}
@@ -58,6 +58,7 @@ type WalletTransaction struct {
 	ReferenceID     *string   `json:"reference_id,omitempty" db:"reference_id"`
 	ReferenceType   *string   `json:"reference_type,omitempty" db:"reference_type"`
 	Status          string    `json:"status" db:"status"`
+	JournalEntryID  *string   `json:"journal_entry_id,omitempty" db:"journal_entry_id"`
 	Metadata        JSONB     `json:"metadata,omitempty" db:"metadata"`
 	CreatedAt       time.Time `json:"created_at" db:"created_at"`
 }
@@ -177,15 +178,6 @@ type GetWalletBalanceResponse struct {
 	Currency       string  `json:"currency"`
 }
 
-// PurchaseCoinsRequest represents a coin purchase request
-type PurchaseCoinsRequest struct {
-	PackageID        *string `json:"package_id,omitempty"`
-	Coins            int     `json:"coins" validate:"required,min=1"`
-	Amount           float64 `json:"amount" validate:"required,min=0.01"`
-	PaymentMethod    string  `json:"payment_method" validate:"required"`
-	PaymentReference string  `json:"payment_reference,omitempty"`
-}
-
 // WithdrawRequest represents a withdrawal request
 type WithdrawRequest struct {
 	Amount           float64 `json:"amount" validate:"required,min=1"`
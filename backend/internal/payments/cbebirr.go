@@ -0,0 +1,56 @@
+package payments
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// cbeBirrNotification is the CBE Birr merchant payment callback.
+// bill_reference_number is the CoinTransaction ID we sent when creating the bill.
+type cbeBirrNotification struct {
+	EventID       string          `json:"event_id"`
+	BillReference string          `json:"bill_reference_number"`
+	TransactionID string          `json:"transaction_id"`
+	Amount        json.RawMessage `json:"amount"`
+	Currency      string          `json:"currency"`
+	Status        string          `json:"status"` // SUCCESS, FAILED, PENDING, REFUNDED, CHARGEBACK
+}
+
+func parseCBEBirr(body []byte) (*Event, error) {
+	var n cbeBirrNotification
+	if err := json.Unmarshal(body, &n); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedEvent, err)
+	}
+
+	transactionID, err := uuid.Parse(n.BillReference)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid bill_reference_number", ErrMalformedEvent)
+	}
+	amount, err := parseAmount(n.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &Event{
+		EventID:          n.EventID,
+		TransactionID:    transactionID,
+		PaymentReference: n.TransactionID,
+		Amount:           amount,
+		Currency:         strings.ToUpper(n.Currency),
+	}
+
+	switch strings.ToUpper(n.Status) {
+	case "SUCCESS", "COMPLETED":
+		event.Status = EventStatusSuccess
+	case "FAILED", "CANCELLED", "EXPIRED":
+		event.Status = EventStatusFailed
+	case "REFUNDED", "CHARGEBACK", "REVERSED":
+		event.Status = EventStatusRefunded
+	default:
+		event.Status = EventStatusPending
+	}
+	return event, nil
+}
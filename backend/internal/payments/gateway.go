@@ -0,0 +1,123 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"lomi-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// hostedGateway is a provider with a hosted checkout page that reports back
+// through signed callbacks. Order status is queried with the merchant API key;
+// the provider answers with a callback-shaped body signed like a callback.
+// Refunds go through the provider's merchant portal until that API is integrated.
+type hostedGateway struct {
+	method          models.PaymentMethod
+	checkoutURL     string
+	notifyURL       string
+	signatureHeader string
+	verifier        Verifier
+	parse           func(body []byte) (*Event, error)
+
+	queryURL   string // Empty: the provider has no status query
+	queryField string // Request field carrying our CoinTransaction ID
+	apiKey     string
+	client     *http.Client
+}
+
+func (g *hostedGateway) Method() models.PaymentMethod {
+	return g.method
+}
+
+func (g *hostedGateway) CreateCheckout(ctx context.Context, req CheckoutRequest) (*Checkout, error) {
+	query := url.Values{}
+	query.Set("transaction_id", req.TransactionID.String())
+	query.Set("amount", fmt.Sprintf("%.2f", float64(req.Amount)/100))
+	query.Set("currency", req.Currency)
+	query.Set("user_id", req.UserID.String())
+	query.Set("notify_url", g.notifyURL)
+	return &Checkout{PaymentURL: g.checkoutURL + "?" + query.Encode()}, nil
+}
+
+// canQuery reports whether status queries are configured: the request needs
+// the API key and the answer is only trusted with the webhook key
+func (g *hostedGateway) canQuery() bool {
+	return g.queryURL != "" && g.apiKey != "" && g.verifier != nil && g.parse != nil
+}
+
+func (g *hostedGateway) QueryStatus(ctx context.Context, transactionID uuid.UUID, paymentReference string) (*StatusResult, error) {
+	if !g.canQuery() {
+		return nil, fmt.Errorf("%w: %s status query", ErrNotSupported, g.method)
+	}
+
+	payload, err := json.Marshal(map[string]string{g.queryField: transactionID.String()})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.queryURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+g.apiKey)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s status query: %w", g.method, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("%s status query: %w", g.method, err)
+	}
+
+	// The user never reached the checkout page: nothing to settle, let it expire
+	if resp.StatusCode == http.StatusNotFound {
+		return &StatusResult{Status: EventStatusPending}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s status query: HTTP %d", g.method, resp.StatusCode)
+	}
+
+	// Same trust rule as callbacks: an unsigned answer never credits coins
+	signature := resp.Header.Get(g.signatureHeader)
+	if signature == "" {
+		return nil, ErrInvalidSignature
+	}
+	if err := g.verifier.Verify(body, signature); err != nil {
+		return nil, err
+	}
+	event, err := g.parse(body)
+	if err != nil {
+		return nil, err
+	}
+	if event.TransactionID != transactionID {
+		return nil, fmt.Errorf("%w: status query for %s answered for %s", ErrMalformedEvent, transactionID, event.TransactionID)
+	}
+
+	return &StatusResult{
+		Status:           event.Status,
+		PaymentReference: event.PaymentReference,
+		Amount:           event.Amount,
+		Currency:         event.Currency,
+	}, nil
+}
+
+func (g *hostedGateway) VerifyCallback(header func(string) string, body []byte) (*Event, error) {
+	return verifyCallback(g.method, g.verifier, g.signatureHeader, g.parse, header, body)
+}
+
+func (g *hostedGateway) SupportsRefunds() bool {
+	return false
+}
+
+func (g *hostedGateway) Refund(ctx context.Context, req RefundRequest) (*RefundResult, error) {
+	return nil, fmt.Errorf("%w: %s refund", ErrNotSupported, g.method)
+}
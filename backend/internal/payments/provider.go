@@ -0,0 +1,189 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"lomi-backend/config"
+	"lomi-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ==================== PROVIDERS ====================
+
+var ErrNotSupported = errors.New("operation not supported by payment provider")
+
+// CheckoutRequest starts a payment for a pending CoinTransaction
+type CheckoutRequest struct {
+	TransactionID uuid.UUID
+	UserID        uuid.UUID
+	Amount        int64 // Minor units (santim)
+	Currency      string
+	Description   string
+}
+
+// Checkout is where the user is sent to pay
+type Checkout struct {
+	PaymentURL string
+	ExpiresAt  *time.Time
+}
+
+// StatusResult is the provider's view of a payment
+type StatusResult struct {
+	Status           EventStatus
+	PaymentReference string
+	Amount           int64
+	Currency         string
+}
+
+// RefundRequest refunds (part of) a completed payment
+type RefundRequest struct {
+	TransactionID    uuid.UUID
+	PaymentReference string
+	Amount           int64 // Minor units (santim)
+	Currency         string
+	Reason           string
+	// IdempotencyKey is the same on every retry of a refund; the provider
+	// must not refund the payment twice for it
+	IdempotencyKey string
+}
+
+// RefundResult is the provider's acknowledgement of a refund
+type RefundResult struct {
+	RefundID string
+	Status   EventStatus
+}
+
+// PaymentProvider is one payment gateway (Telebirr, CBE Birr, ...)
+type PaymentProvider interface {
+	Method() models.PaymentMethod
+	CreateCheckout(ctx context.Context, req CheckoutRequest) (*Checkout, error)
+	QueryStatus(ctx context.Context, transactionID uuid.UUID, paymentReference string) (*StatusResult, error)
+	// VerifyCallback checks the signature of a raw callback and parses it.
+	// header looks up a request header (e.g. fiber's c.Get).
+	VerifyCallback(header func(string) string, body []byte) (*Event, error)
+	// SupportsRefunds reports whether Refund is implemented; otherwise refunds
+	// are made in the merchant portal
+	SupportsRefunds() bool
+	Refund(ctx context.Context, req RefundRequest) (*RefundResult, error)
+}
+
+var (
+	providers      = map[models.PaymentMethod]PaymentProvider{}
+	sandboxEnabled bool
+)
+
+// Init builds every provider from config. With PAYMENT_SANDBOX on, all of them
+// are replaced by the built-in sandbox gateway. Like the debug routes, the
+// sandbox is only allowed in development: anyone can "pay" on its checkout page.
+func Init(cfg *config.Config) error {
+	registry := map[models.PaymentMethod]PaymentProvider{}
+
+	if cfg.PaymentSandbox {
+		if cfg.AppEnv != "development" {
+			return fmt.Errorf("the payment sandbox can only be enabled with APP_ENV=development (got %q)", cfg.AppEnv)
+		}
+		secret := cfg.PaymentSandboxSecret
+		if secret == "" {
+			// Callbacks are signed and verified by this same process
+			secret = uuid.NewString()
+		}
+		store := newSandboxStore()
+		for _, method := range []models.PaymentMethod{
+			models.PaymentMethodTelebirr, models.PaymentMethodCbeBirr,
+			models.PaymentMethodHelloCash, models.PaymentMethodAmole,
+		} {
+			registry[method] = newSandbox(method, secret, cfg.APIPublicURL, store)
+		}
+		providers = registry
+		sandboxEnabled = true
+		log.Printf("⚠️ Payment sandbox enabled: no real money moves")
+		return nil
+	}
+
+	telebirr, err := NewVerifier(cfg.TelebirrWebhookSecret, cfg.TelebirrWebhookPublicKey)
+	if err != nil {
+		return fmt.Errorf("telebirr webhook key: %w", err)
+	}
+	cbeBirr, err := NewVerifier(cfg.CBEBirrWebhookSecret, cfg.CBEBirrWebhookPublicKey)
+	if err != nil {
+		return fmt.Errorf("cbe birr webhook key: %w", err)
+	}
+
+	registry[models.PaymentMethodTelebirr] = &hostedGateway{
+		method:          models.PaymentMethodTelebirr,
+		checkoutURL:     cfg.PaymentGatewayURL + "/telebirr/pay",
+		notifyURL:       webhookURL(cfg.APIPublicURL, models.PaymentMethodTelebirr),
+		signatureHeader: "X-Telebirr-Signature",
+		verifier:        telebirr,
+		parse:           parseTelebirr,
+		queryURL:        cfg.PaymentGatewayURL + "/telebirr/query",
+		queryField:      "merch_order_id",
+		apiKey:          cfg.TelebirrAPIKey,
+		client:          &http.Client{Timeout: 10 * time.Second},
+	}
+	registry[models.PaymentMethodCbeBirr] = &hostedGateway{
+		method:          models.PaymentMethodCbeBirr,
+		checkoutURL:     cfg.PaymentGatewayURL + "/cbe-birr/pay",
+		notifyURL:       webhookURL(cfg.APIPublicURL, models.PaymentMethodCbeBirr),
+		signatureHeader: "X-CBE-Signature",
+		verifier:        cbeBirr,
+		parse:           parseCBEBirr,
+		queryURL:        cfg.PaymentGatewayURL + "/cbe-birr/query",
+		queryField:      "bill_reference_number",
+		apiKey:          cfg.CBEBirrAPIKey,
+		client:          &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, method := range []models.PaymentMethod{models.PaymentMethodTelebirr, models.PaymentMethodCbeBirr} {
+		if !registry[method].(*hostedGateway).canQuery() {
+			log.Printf("⚠️ %s status queries disabled (API key or webhook key not set): lost callbacks only expire", method)
+		}
+	}
+	// No callback integration yet: checkouts work, callbacks are refused
+	registry[models.PaymentMethodHelloCash] = &hostedGateway{
+		method:      models.PaymentMethodHelloCash,
+		checkoutURL: cfg.PaymentGatewayURL + "/hellocash/pay",
+		notifyURL:   webhookURL(cfg.APIPublicURL, models.PaymentMethodHelloCash),
+	}
+	registry[models.PaymentMethodAmole] = &hostedGateway{
+		method:      models.PaymentMethodAmole,
+		checkoutURL: cfg.PaymentGatewayURL + "/amole/pay",
+		notifyURL:   webhookURL(cfg.APIPublicURL, models.PaymentMethodAmole),
+	}
+
+	providers = registry
+	sandboxEnabled = false
+	return nil
+}
+
+// Get returns the provider for a payment method
+func Get(method models.PaymentMethod) (PaymentProvider, error) {
+	p, ok := providers[method]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+// VerifyWebhook checks and parses a raw callback for the given provider
+func VerifyWebhook(method models.PaymentMethod, header func(string) string, body []byte) (*Event, error) {
+	p, err := Get(method)
+	if err != nil {
+		return nil, err
+	}
+	return p.VerifyCallback(header, body)
+}
+
+// SandboxEnabled reports whether providers are served by the sandbox gateway
+func SandboxEnabled() bool {
+	return sandboxEnabled
+}
+
+func webhookURL(apiPublicURL string, method models.PaymentMethod) string {
+	return fmt.Sprintf("%s/api/v1/payments/webhooks/%s", apiPublicURL, method)
+}
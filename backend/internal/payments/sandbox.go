@@ -0,0 +1,279 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"lomi-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ==================== SANDBOX ====================
+// Local stand-in for every provider (PAYMENT_SANDBOX=true). Checkouts point at
+// our own /payments/sandbox/checkout page; paying or failing there fires a
+// signed callback at the regular webhook endpoint, so the whole buy-coins
+// flow runs exactly as in production minus the real gateway.
+
+const sandboxSignatureHeader = "X-Sandbox-Signature"
+
+var ErrSandboxCheckout = errors.New("invalid sandbox checkout link")
+
+// sandboxStore remembers settled payments and refunds so QueryStatus and
+// Refund behave
+type sandboxStore struct {
+	mu       sync.Mutex
+	payments map[uuid.UUID]*StatusResult
+	refunds  map[string]*RefundResult // By idempotency key
+}
+
+func newSandboxStore() *sandboxStore {
+	return &sandboxStore{
+		payments: make(map[uuid.UUID]*StatusResult),
+		refunds:  make(map[string]*RefundResult),
+	}
+}
+
+// Sandbox is the sandbox gateway for one payment method
+type Sandbox struct {
+	method       models.PaymentMethod
+	secret       []byte
+	apiPublicURL string
+	store        *sandboxStore
+	client       *http.Client
+}
+
+func newSandbox(method models.PaymentMethod, secret, apiPublicURL string, store *sandboxStore) *Sandbox {
+	return &Sandbox{
+		method:       method,
+		secret:       []byte(secret),
+		apiPublicURL: apiPublicURL,
+		store:        store,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// SandboxSession is a checkout opened on the sandbox page
+type SandboxSession struct {
+	Provider      models.PaymentMethod
+	TransactionID uuid.UUID
+	Amount        int64 // Minor units (santim)
+	Currency      string
+	Description   string
+}
+
+// SandboxCallback is what happened when the sandbox fired a callback
+type SandboxCallback struct {
+	EventID    string `json:"event_id"`
+	StatusCode int    `json:"status_code"`
+	Response   string `json:"response"`
+}
+
+func (s *Sandbox) Method() models.PaymentMethod {
+	return s.method
+}
+
+func (s *Sandbox) CreateCheckout(ctx context.Context, req CheckoutRequest) (*Checkout, error) {
+	query := url.Values{}
+	query.Set("provider", string(s.method))
+	query.Set("transaction_id", req.TransactionID.String())
+	query.Set("amount", strconv.FormatInt(req.Amount, 10))
+	query.Set("currency", req.Currency)
+	query.Set("description", req.Description)
+	query.Set("sig", s.sign(s.method, req.TransactionID, req.Amount, req.Currency))
+
+	expiresAt := time.Now().Add(30 * time.Minute)
+	return &Checkout{
+		PaymentURL: s.apiPublicURL + "/api/v1/payments/sandbox/checkout?" + query.Encode(),
+		ExpiresAt:  &expiresAt,
+	}, nil
+}
+
+func (s *Sandbox) QueryStatus(ctx context.Context, transactionID uuid.UUID, paymentReference string) (*StatusResult, error) {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	if result, ok := s.store.payments[transactionID]; ok {
+		copied := *result
+		return &copied, nil
+	}
+	return &StatusResult{Status: EventStatusPending}, nil
+}
+
+func (s *Sandbox) VerifyCallback(header func(string) string, body []byte) (*Event, error) {
+	return verifyCallback(s.method, HMACVerifier{Secret: s.secret}, sandboxSignatureHeader, parseSandbox, header, body)
+}
+
+func (s *Sandbox) SupportsRefunds() bool {
+	return true
+}
+
+func (s *Sandbox) Refund(ctx context.Context, req RefundRequest) (*RefundResult, error) {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	if refund, ok := s.store.refunds[req.IdempotencyKey]; ok && req.IdempotencyKey != "" {
+		return refund, nil
+	}
+	result, ok := s.store.payments[req.TransactionID]
+	if !ok || result.Status != EventStatusSuccess {
+		return nil, fmt.Errorf("sandbox: no successful payment for %s", req.TransactionID)
+	}
+	if req.Amount <= 0 || req.Amount > result.Amount {
+		return nil, fmt.Errorf("sandbox: refund amount must be between 1 and %d", result.Amount)
+	}
+	result.Amount -= req.Amount
+	if result.Amount == 0 {
+		result.Status = EventStatusRefunded
+	}
+	refund := &RefundResult{
+		RefundID: "SBX-RF-" + strings.ToUpper(uuid.NewString()[:8]),
+		Status:   EventStatusSuccess,
+	}
+	if req.IdempotencyKey != "" {
+		s.store.refunds[req.IdempotencyKey] = refund
+	}
+	return refund, nil
+}
+
+// OpenSandboxCheckout validates the query string of a sandbox checkout link.
+// query looks up a query parameter (e.g. fiber's c.Query).
+func OpenSandboxCheckout(query func(string) string) (*Sandbox, *SandboxSession, error) {
+	p, err := Get(models.PaymentMethod(query("provider")))
+	if err != nil {
+		return nil, nil, ErrSandboxCheckout
+	}
+	sandbox, ok := p.(*Sandbox)
+	if !ok {
+		return nil, nil, ErrSandboxCheckout
+	}
+
+	transactionID, err := uuid.Parse(query("transaction_id"))
+	if err != nil {
+		return nil, nil, ErrSandboxCheckout
+	}
+	amount, err := strconv.ParseInt(query("amount"), 10, 64)
+	if err != nil || amount <= 0 {
+		return nil, nil, ErrSandboxCheckout
+	}
+	session := &SandboxSession{
+		Provider:      sandbox.method,
+		TransactionID: transactionID,
+		Amount:        amount,
+		Currency:      query("currency"),
+		Description:   query("description"),
+	}
+
+	expected := sandbox.sign(session.Provider, session.TransactionID, session.Amount, session.Currency)
+	if !hmac.Equal([]byte(expected), []byte(query("sig"))) {
+		return nil, nil, ErrSandboxCheckout
+	}
+	return sandbox, session, nil
+}
+
+// Settle completes a sandbox checkout with the given outcome and fires the
+// signed callback. Passing the eventID of an earlier callback replays it.
+func (s *Sandbox) Settle(ctx context.Context, session *SandboxSession, status EventStatus, eventID string) (*SandboxCallback, error) {
+	if eventID == "" {
+		eventID = "SBX-EVT-" + uuid.NewString()
+	}
+	reference := "SBX-" + strings.ToUpper(strings.ReplaceAll(session.TransactionID.String(), "-", "")[:12])
+
+	body, err := json.Marshal(sandboxNotification{
+		EventID:       eventID,
+		TransactionID: session.TransactionID.String(),
+		Reference:     reference,
+		Amount:        json.RawMessage(strconv.Quote(fmt.Sprintf("%.2f", float64(session.Amount)/100))),
+		Currency:      session.Currency,
+		Status:        string(status),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.store.mu.Lock()
+	s.store.payments[session.TransactionID] = &StatusResult{
+		Status:           status,
+		PaymentReference: reference,
+		Amount:           session.Amount,
+		Currency:         session.Currency,
+	}
+	s.store.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL(s.apiPublicURL, s.method), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(sandboxSignatureHeader, s.hmacHex(body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sandbox callback failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	return &SandboxCallback{EventID: eventID, StatusCode: resp.StatusCode, Response: string(respBody)}, nil
+}
+
+// sign protects the checkout link so the amount can't be edited in the browser
+func (s *Sandbox) sign(method models.PaymentMethod, transactionID uuid.UUID, amount int64, currency string) string {
+	return s.hmacHex([]byte(fmt.Sprintf("%s|%s|%d|%s", method, transactionID, amount, currency)))
+}
+
+func (s *Sandbox) hmacHex(data []byte) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type sandboxNotification struct {
+	EventID       string          `json:"event_id"`
+	TransactionID string          `json:"transaction_id"`
+	Reference     string          `json:"reference"`
+	Amount        json.RawMessage `json:"amount"`
+	Currency      string          `json:"currency"`
+	Status        string          `json:"status"` // success, failed, pending, refunded
+}
+
+func parseSandbox(body []byte) (*Event, error) {
+	var n sandboxNotification
+	if err := json.Unmarshal(body, &n); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedEvent, err)
+	}
+	transactionID, err := uuid.Parse(n.TransactionID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid transaction_id", ErrMalformedEvent)
+	}
+	amount, err := parseAmount(n.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	status := EventStatus(n.Status)
+	switch status {
+	case EventStatusSuccess, EventStatusFailed, EventStatusRefunded:
+	default:
+		status = EventStatusPending
+	}
+	return &Event{
+		EventID:          n.EventID,
+		TransactionID:    transactionID,
+		PaymentReference: n.Reference,
+		Amount:           amount,
+		Currency:         strings.ToUpper(n.Currency),
+		Status:           status,
+	}, nil
+}
@@ -0,0 +1,66 @@
+package payments
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// telebirrNotification is the Telebirr C2B payment notification.
+// merch_order_id is the CoinTransaction ID we sent when creating the order.
+type telebirrNotification struct {
+	NotifyID       string          `json:"notify_id"`
+	MerchOrderID   string          `json:"merch_order_id"`
+	PaymentOrderID string          `json:"payment_order_id"`
+	TransID        string          `json:"trans_id"`
+	TotalAmount    json.RawMessage `json:"total_amount"`
+	TransCurrency  string          `json:"trans_currency"`
+	TradeStatus    string          `json:"trade_status"` // Completed, Failure, Pending, Expired, Refunded
+}
+
+func parseTelebirr(body []byte) (*Event, error) {
+	var n telebirrNotification
+	if err := json.Unmarshal(body, &n); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedEvent, err)
+	}
+
+	transactionID, err := uuid.Parse(n.MerchOrderID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid merch_order_id", ErrMalformedEvent)
+	}
+	amount, err := parseAmount(n.TotalAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	// A notification is identified by notify_id; older payloads only carry trans_id
+	eventID := n.NotifyID
+	if eventID == "" {
+		eventID = n.TransID
+	}
+
+	event := &Event{
+		EventID:          eventID,
+		TransactionID:    transactionID,
+		PaymentReference: n.PaymentOrderID,
+		Amount:           amount,
+		Currency:         strings.ToUpper(n.TransCurrency),
+	}
+	if n.TransID != "" {
+		event.PaymentReference = n.TransID
+	}
+
+	switch strings.ToLower(n.TradeStatus) {
+	case "completed", "success":
+		event.Status = EventStatusSuccess
+	case "failure", "failed", "expired", "cancelled":
+		event.Status = EventStatusFailed
+	case "refunded", "reversed":
+		event.Status = EventStatusRefunded
+	default:
+		event.Status = EventStatusPending
+	}
+	return event, nil
+}
@@ -0,0 +1,179 @@
+// Package payments talks to payment providers: checkouts, status queries,
+// refunds and signed callbacks. Providers sign every webhook; nothing here
+// trusts a callback whose signature does not check out against the configured key.
+package payments
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"lomi-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Currency is the only currency coin packs are sold in
+const Currency = "ETB"
+
+var (
+	ErrUnknownProvider       = errors.New("unknown payment provider")
+	ErrProviderNotConfigured = errors.New("payment provider webhook key is not configured")
+	ErrInvalidSignature      = errors.New("invalid webhook signature")
+	ErrMalformedEvent        = errors.New("malformed webhook event")
+)
+
+type EventStatus string
+
+const (
+	EventStatusSuccess  EventStatus = "success"
+	EventStatusFailed   EventStatus = "failed"
+	EventStatusPending  EventStatus = "pending"
+	EventStatusRefunded EventStatus = "refunded" // Refund or chargeback initiated at the provider
+)
+
+// Event is a verified provider callback in provider-neutral form
+type Event struct {
+	Provider         models.PaymentMethod
+	EventID          string    // Provider's unique ID for this notification
+	TransactionID    uuid.UUID // Our CoinTransaction ID, echoed back by the provider
+	PaymentReference string    // Provider's payment / order reference
+	Amount           int64     // Minor units (santim)
+	Currency         string
+	Status           EventStatus
+}
+
+// ==================== SIGNATURES ====================
+
+// Verifier checks a signature over the raw request body
+type Verifier interface {
+	Verify(body []byte, signature string) error
+}
+
+// HMACVerifier expects a hex (or base64) HMAC-SHA256 of the body
+type HMACVerifier struct {
+	Secret []byte
+}
+
+func (v HMACVerifier) Verify(body []byte, signature string) error {
+	mac := hmac.New(sha256.New, v.Secret)
+	mac.Write(body)
+	expected := mac.Sum(nil)
+
+	got, err := decodeSignature(signature)
+	if err != nil || !hmac.Equal(got, expected) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// RSAVerifier expects a base64 RSA PKCS#1 v1.5 SHA-256 signature of the body
+type RSAVerifier struct {
+	PublicKey *rsa.PublicKey
+}
+
+func (v RSAVerifier) Verify(body []byte, signature string) error {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	digest := sha256.Sum256(body)
+	if err := rsa.VerifyPKCS1v15(v.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func decodeSignature(signature string) ([]byte, error) {
+	signature = strings.TrimPrefix(strings.TrimSpace(signature), "sha256=")
+	if b, err := hex.DecodeString(signature); err == nil {
+		return b, nil
+	}
+	return base64.StdEncoding.DecodeString(signature)
+}
+
+// NewVerifier picks RSA when a public key is configured, HMAC otherwise.
+// Returns nil when neither is set.
+func NewVerifier(secret, publicKeyPEM string) (Verifier, error) {
+	if publicKeyPEM != "" {
+		key, err := parseRSAPublicKey(publicKeyPEM)
+		if err != nil {
+			return nil, err
+		}
+		return RSAVerifier{PublicKey: key}, nil
+	}
+	if secret != "" {
+		return HMACVerifier{Secret: []byte(secret)}, nil
+	}
+	return nil, nil
+}
+
+func parseRSAPublicKey(publicKeyPEM string) (*rsa.PublicKey, error) {
+	// Keys usually come from env vars with literal "\n"
+	block, _ := pem.Decode([]byte(strings.ReplaceAll(publicKeyPEM, `\n`, "\n")))
+	if block == nil {
+		return nil, errors.New("invalid PEM public key")
+	}
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		if rsaKey, ok := key.(*rsa.PublicKey); ok {
+			return rsaKey, nil
+		}
+		return nil, errors.New("public key is not RSA")
+	}
+	return x509.ParsePKCS1PublicKey(block.Bytes)
+}
+
+// verifyCallback checks the signature header and parses the payload; shared by
+// every provider that signs the raw body
+func verifyCallback(provider models.PaymentMethod, verifier Verifier, signatureHeader string,
+	parse func([]byte) (*Event, error), header func(string) string, body []byte) (*Event, error) {
+	if verifier == nil || parse == nil {
+		return nil, ErrProviderNotConfigured
+	}
+
+	signature := header(signatureHeader)
+	if signature == "" {
+		return nil, ErrInvalidSignature
+	}
+	if err := verifier.Verify(body, signature); err != nil {
+		return nil, err
+	}
+
+	event, err := parse(body)
+	if err != nil {
+		return nil, err
+	}
+	event.Provider = provider
+	if event.EventID == "" || event.TransactionID == uuid.Nil || event.Currency == "" {
+		return nil, fmt.Errorf("%w: event id, transaction id and currency are required", ErrMalformedEvent)
+	}
+	return event, nil
+}
+
+// ==================== AMOUNTS ====================
+
+// ToMinorUnits converts a birr amount to santim
+func ToMinorUnits(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// parseAmount reads a decimal amount sent either as a JSON string or number
+func parseAmount(raw json.RawMessage) (int64, error) {
+	s := strings.Trim(strings.TrimSpace(string(raw)), `"`)
+	amount, err := strconv.ParseFloat(s, 64)
+	if err != nil || amount < 0 {
+		return 0, fmt.Errorf("%w: invalid amount %q", ErrMalformedEvent, s)
+	}
+	return ToMinorUnits(amount), nil
+}
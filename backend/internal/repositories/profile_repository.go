@@ -6,8 +6,11 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"lomi-backend/internal/economy"
+	"lomi-backend/internal/ledger"
 	"lomi-backend/internal/models"
 
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -472,8 +475,13 @@ func (r *ProfileRepository) ApplyReferralCode(ctx context.Context, userID, refer
 		return fmt.Errorf("referral code already applied")
 	}
 
+	pricing, err := economy.Current(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Create referral record
-	rewardCoins := 50 // Default reward
+	rewardCoins := pricing.Params.ReferralRewardCoins
 	query := `
 		INSERT INTO referrals (referrer_id, referred_id, referral_code, reward_coins, is_rewarded)
 		VALUES ($1::uuid, $2::uuid, $3, $4, true)
@@ -483,10 +491,24 @@ func (r *ProfileRepository) ApplyReferralCode(ctx context.Context, userID, refer
 		return err
 	}
 
-	// Award coins to referrer
-	_, err = tx.ExecContext(ctx, `UPDATE users SET coin_balance = coin_balance + $1 WHERE id = $2::uuid`, rewardCoins, referrerID)
-	if err != nil {
-		return err
+	// Award coins to referrer (the reward can be switched off with 0)
+	if rewardCoins > 0 {
+		referrerUUID, err := uuid.Parse(referrerID)
+		if err != nil {
+			return err
+		}
+		_, err = ledger.Post(ctx, tx, ledger.Entry{
+			Type:           ledger.EntryReferralBonus,
+			ReferenceType:  "referral",
+			ReferenceID:    userID,
+			IdempotencyKey: "referral:" + userID,
+			Description:    "Referral bonus",
+			Lines:          ledger.Transfer(ledger.System(ledger.SystemReferrals), ledger.UserCoins(referrerUUID), int64(rewardCoins)),
+			EconomyVersion: &pricing.Version,
+		})
+		if err != nil {
+			return err
+		}
 	}
 
 	return tx.Commit()
@@ -496,26 +518,6 @@ func (r *ProfileRepository) ApplyReferralCode(ctx context.Context, userID, refer
 // ACCOUNT MANAGEMENT
 // ============================================
 
-// DeleteUserAccount soft deletes a user account
-func (r *ProfileRepository) DeleteUserAccount(ctx context.Context, userID string) error {
-	query := `UPDATE users SET deleted_at = NOW() WHERE id = $1::uuid AND deleted_at IS NULL`
-	result, err := r.db.ExecContext(ctx, query, userID)
-	if err != nil {
-		return err
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-
-	if rowsAffected == 0 {
-		return fmt.Errorf("user not found or already deleted")
-	}
-
-	return nil
-}
-
 // RequestVerification creates a verification request
 func (r *ProfileRepository) RequestVerification(ctx context.Context, userID, selfieURL, idDocumentURL string) error {
 	// Check if user already has a pending or approved verification
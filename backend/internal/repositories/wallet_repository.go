@@ -42,17 +42,26 @@ func (r *WalletRepository) GetOrCreateWallet(ctx context.Context, userID string)
 		return nil, fmt.Errorf("failed to get wallet: %w", err)
 	}
 
-	// Create new wallet
-	err = r.db.GetContext(ctx, &wallet, `
+	// Create new wallet (balance is a projection of the user's ledger account).
+	// A concurrent request may create it first, so re-read instead of failing.
+	_, err = r.db.ExecContext(ctx, `
 		INSERT INTO wallets (user_id, balance, currency)
-		VALUES ($1, 0.00, 'USD')
-		RETURNING *
+		VALUES ($1, COALESCE((SELECT balance FROM ledger_accounts WHERE user_id = $1 AND currency = 'LC'), 0), 'LC')
+		ON CONFLICT (user_id) DO NOTHING
 	`, userID)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create wallet: %w", err)
 	}
 
+	err = r.db.GetContext(ctx, &wallet, `
+		SELECT * FROM wallets WHERE user_id = $1
+	`, userID)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallet: %w", err)
+	}
+
 	return &wallet, nil
 }
 
@@ -74,30 +83,6 @@ func (r *WalletRepository) GetWalletByUserID(ctx context.Context, userID string)
 	return &wallet, nil
 }
 
-// UpdateWalletBalance updates wallet balance (use within transaction)
-func (r *WalletRepository) UpdateWalletBalance(ctx context.Context, tx *sqlx.Tx, walletID string, newBalance float64) error {
-	result, err := tx.ExecContext(ctx, `
-		UPDATE wallets 
-		SET balance = $1, updated_at = NOW()
-		WHERE id = $2
-	`, newBalance, walletID)
-
-	if err != nil {
-		return fmt.Errorf("failed to update wallet balance: %w", err)
-	}
-
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
-	}
-
-	if rows == 0 {
-		return errors.New("wallet not found")
-	}
-
-	return nil
-}
-
 // IncrementTotalEarned increments total earned amount
 func (r *WalletRepository) IncrementTotalEarned(ctx context.Context, tx *sqlx.Tx, walletID string, amount float64) error {
 	_, err := tx.ExecContext(ctx, `
@@ -141,11 +126,11 @@ func (r *WalletRepository) CreateTransaction(ctx context.Context, tx *sqlx.Tx, t
 		INSERT INTO wallet_transactions (
 			wallet_id, user_id, transaction_type, amount, 
 			balance_before, balance_after, description, 
-			reference_id, reference_type, status, metadata
+			reference_id, reference_type, status, journal_entry_id, metadata
 		) VALUES (
 			:wallet_id, :user_id, :transaction_type, :amount,
 			:balance_before, :balance_after, :description,
-			:reference_id, :reference_type, :status, :metadata
+			:reference_id, :reference_type, :status, :journal_entry_id, :metadata
 		) RETURNING id, created_at
 	`
 
@@ -401,34 +386,6 @@ func (r *WalletRepository) GetCoinPackageByID(ctx context.Context, id string) (*
 	return &pkg, nil
 }
 
-// CreateCoinPurchase creates a new coin purchase record
-func (r *WalletRepository) CreateCoinPurchase(ctx context.Context, tx *sqlx.Tx, purchase *models.CoinPurchase) error {
-	query := `
-		INSERT INTO coin_purchases (
-			user_id, package_id, coins_purchased, amount_paid,
-			currency, payment_method, payment_reference, status
-		) VALUES (
-			:user_id, :package_id, :coins_purchased, :amount_paid,
-			:currency, :payment_method, :payment_reference, :status
-		) RETURNING id, created_at
-	`
-
-	rows, err := tx.NamedQuery(query, purchase)
-	if err != nil {
-		return fmt.Errorf("failed to create coin purchase: %w", err)
-	}
-	defer rows.Close()
-
-	if rows.Next() {
-		err = rows.Scan(&purchase.ID, &purchase.CreatedAt)
-		if err != nil {
-			return fmt.Errorf("failed to scan coin purchase: %w", err)
-		}
-	}
-
-	return nil
-}
-
 // BeginTx starts a new database transaction
 func (r *WalletRepository) BeginTx(ctx context.Context) (*sqlx.Tx, error) {
 	return r.db.BeginTxx(ctx, nil)
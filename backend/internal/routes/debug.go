@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"lomi-backend/internal/handlers"
+	"lomi-backend/internal/middleware"
+	"lomi-backend/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// setupDebugRoutes mounts developer tools under /api/v1/debug. They are only
+// mounted in development and need an admin token with the debug permission;
+// using them is audited like any admin action.
+func setupDebugRoutes(api fiber.Router) {
+	debug := api.Group("/debug",
+		middleware.AdminAuth,
+		middleware.RequirePermission(models.AdminPermDebug),
+		middleware.AdminAuditLog,
+	)
+	debug.Get("/s3", handlers.TestS3Connection)
+	debug.Get("/jwt", handlers.TestGetJWT)  // Tokens for any user by ID
+	debug.Post("/jwt", handlers.TestGetJWT) // Tokens for any user by ID
+	debug.Get("/media-upload", handlers.TestMediaUpload)
+	debug.Get("/users", handlers.GetAllUsers) // Full user rows, including contact details
+}
@@ -4,6 +4,8 @@ import (
 	"lomi-backend/config"
 	"lomi-backend/internal/handlers"
 	"lomi-backend/internal/middleware"
+	"lomi-backend/internal/models"
+	"lomi-backend/internal/payments"
 	"lomi-backend/internal/services"
 
 	"github.com/gofiber/fiber/v2"
@@ -28,14 +30,11 @@ func SetupRoutes(app *fiber.App, walletHandler *handlers.WalletHandler, profileH
 	api.Post("/checkPhoneNo", legacyHandler.CheckPhoneNo)
 	api.Post("/showUserDetail", legacyHandler.ShowUserDetail)
 
-	// Test endpoints for debugging
+	// Reachability checks (the debug tools are under /debug, development only)
 	api.Get("/test", handlers.TestEndpoint)
 	api.Post("/test", handlers.TestEndpoint)
 	api.Get("/test/auth", handlers.TestAuthEndpoint)
 	api.Post("/test/auth", handlers.TestAuthEndpoint)
-	api.Get("/test/s3", handlers.TestS3Connection)
-	api.Get("/test/jwt", handlers.TestGetJWT)  // Generate JWT for user by ID (testing only)
-	api.Post("/test/jwt", handlers.TestGetJWT) // Generate JWT for user by ID (testing only)
 
 	// Public routes
 	authHandler := handlers.NewAuthHandler(config.Cfg)
@@ -43,8 +42,10 @@ func SetupRoutes(app *fiber.App, walletHandler *handlers.WalletHandler, profileH
 	// Telegram Mini App login (initData method) - Auto-authenticates on app open
 	api.Post("/auth/telegram", authHandler.TelegramLogin)
 	api.Post("/auth/google", authHandler.GoogleLogin)
+	api.Post("/auth/phone/request-otp", authHandler.RequestPhoneOTP)
+	api.Post("/auth/phone/verify", authHandler.PhoneLogin)
 
-	api.Post("/auth/refresh", handlers.RefreshToken) // TODO: Implement
+	api.Post("/auth/refresh", handlers.RefreshToken)
 
 	// ============================================
 	// PUBLIC WALLET ENDPOINTS (No Auth Required)
@@ -53,16 +54,112 @@ func SetupRoutes(app *fiber.App, walletHandler *handlers.WalletHandler, profileH
 	// Coin Packages (Public - anyone can view packages)
 	api.Get("/wallet/coin-packages", walletHandler.GetCoinPackages)
 
+	// Payment provider webhooks (authenticated by provider signature, not JWT)
+	api.Post("/payments/webhooks/:provider", handlers.PaymentWebhook)
+	api.Post("/wallet/buy/webhook", handlers.CoinPurchaseWebhook) // Legacy Telebirr callback URL
+
+	// Local sandbox gateway (PAYMENT_SANDBOX=true only)
+	if payments.SandboxEnabled() {
+		api.Get("/payments/sandbox/checkout", handlers.SandboxCheckout)
+		api.Post("/payments/sandbox/checkout", handlers.SandboxCheckoutSubmit)
+	}
+
+	// ============================================
+	// ADMIN ROUTES (admin token, see middleware/admin.go)
+	// ============================================
+	// Registered before the protected group so user AuthMiddleware never runs
+	// for them. Every change is written to admin_audit_logs.
+
+	api.Post("/admin/auth/login", handlers.AdminLogin)
+
+	admin := api.Group("/admin", middleware.AdminAuth, middleware.AdminAuditLog)
+	admin.Get("/auth/me", handlers.AdminMe)
+	admin.Post("/auth/totp/setup", handlers.AdminSetupTOTP)
+	admin.Post("/auth/totp/enable", handlers.AdminEnableTOTP)
+
+	// Reports
+	reports := middleware.RequirePermission(models.AdminPermReports)
+	admin.Get("/reports/pending", reports, handlers.GetPendingReports)
+	admin.Put("/reports/:id/review", reports, middleware.AuditEntity("reports", "id", "id"), handlers.ReviewReport)
+
+	// Payouts
+	payouts := middleware.RequirePermission(models.AdminPermPayouts)
+	payoutEntity := middleware.AuditEntity("payouts", "id", "id")
+	admin.Get("/payouts/pending", payouts, handlers.GetPendingPayouts)
+	admin.Put("/payouts/:id/process", payouts, payoutEntity, handlers.ProcessPayout)
+	admin.Post("/payouts/:id/approve", payouts, payoutEntity, handlers.AdminApprovePayout)
+	admin.Post("/payouts/:id/reject", payouts, payoutEntity, handlers.AdminRejectPayout)
+	admin.Post("/payouts/:id/settle", payouts, payoutEntity, handlers.AdminSettlePayout)
+	admin.Post("/payouts/export", payouts, handlers.AdminExportPayouts)
+	admin.Get("/payouts/batches/:id/export", payouts, handlers.AdminExportPayoutBatch)
+
+	// Photo Moderation Monitoring (Phase 3)
+	moderation := middleware.RequirePermission(models.AdminPermModeration)
+	admin.Get("/queue-stats", moderation, handlers.GetQueueStats)
+	admin.Get("/live-chat/stats", moderation, handlers.GetLiveChatStats)
+	admin.Get("/moderation/dashboard", moderation, handlers.GetModerationDashboard)
+	admin.Put("/moderation/rejected/:id/verify", moderation, middleware.AuditEntity("media", "id", "id"), handlers.VerifyRejectedPhoto)
+	admin.Delete("/moderation/rejected/:id", moderation, middleware.AuditEntity("media", "id", "id"), handlers.DeleteRejectedPhoto)
+
+	// Payments and ledger
+	paymentsPerm := middleware.RequirePermission(models.AdminPermPayments)
+	admin.Get("/ledger/verify", paymentsPerm, handlers.VerifyLedger)
+	admin.Post("/payments/reconcile", paymentsPerm, handlers.AdminReconcilePayments)
+	admin.Get("/payments/reconciliation", paymentsPerm, handlers.AdminGetReconciliationReport)
+	admin.Post("/payments/purchases/:id/refund", paymentsPerm, middleware.AuditEntity("coin_transactions", "id", "id"), handlers.AdminRefundPurchase)
+
+	// Economy: prices, promotions and the gift catalog
+	economy := middleware.RequirePermission(models.AdminPermEconomy)
+	admin.Get("/economy", economy, handlers.AdminListEconomyConfigs)
+	admin.Post("/economy", economy, handlers.AdminPublishEconomyConfig)
+	admin.Get("/economy/:version", economy, handlers.AdminGetEconomyConfig)
+	admin.Delete("/economy/:version", economy, middleware.AuditEntity("economy_configs", "version", "version"), handlers.AdminCancelEconomyConfig)
+	admin.Get("/promotions", economy, handlers.AdminListPromotions)
+	admin.Post("/promotions", economy, handlers.AdminCreatePromotion)
+	admin.Put("/promotions/:id", economy, middleware.AuditEntity("promotions", "id", "id"), handlers.AdminUpdatePromotion)
+	admin.Delete("/promotions/:id", economy, middleware.AuditEntity("promotions", "id", "id"), handlers.AdminDeletePromotion)
+	admin.Get("/gifts", economy, handlers.AdminListGifts)
+	admin.Post("/gifts", economy, handlers.AdminCreateGift)
+	admin.Put("/gifts/:id", economy, middleware.AuditEntity("gifts", "id", "id"), handlers.AdminUpdateGift)
+	admin.Delete("/gifts/:id", economy, middleware.AuditEntity("gifts", "id", "id"), handlers.AdminDeleteGift)
+
+	// Admin accounts and the audit log
+	admins := middleware.RequirePermission(models.AdminPermAdmins)
+	admin.Get("/admins", admins, handlers.AdminListAdmins)
+	admin.Post("/admins", admins, handlers.AdminCreateAdmin)
+	admin.Put("/admins/:id", admins, middleware.AuditEntity("admin_users", "id", "id"), handlers.AdminUpdateAdmin)
+	admin.Get("/audit-logs", admins, handlers.AdminListAuditLogs)
+
+	// Support: act as a user (short-lived, flagged and audited tokens)
+	support := middleware.RequirePermission(models.AdminPermSupport)
+	admin.Post("/users/:id/impersonate", support, handlers.AdminImpersonateUser)
+	admin.Get("/impersonations", support, handlers.AdminListImpersonations)
+	admin.Delete("/impersonations/:id", support, middleware.AuditEntity("admin_impersonations", "id", "id"), handlers.AdminEndImpersonation)
+	admin.Get("/account-deletions/:id", support, handlers.AdminGetAccountDeletion)
+
+	// Debug tools (admin token with the debug permission, development only)
+	if config.Cfg.AppEnv == "development" {
+		setupDebugRoutes(api)
+	}
+
 	// Protected routes (require authentication)
 	protected := api.Group("", middleware.AuthMiddleware)
 
-	// Test media upload (protected)
-	protected.Get("/test/media-upload", handlers.TestMediaUpload)
+	// Sessions
+	protected.Post("/auth/logout", handlers.Logout)
+	protected.Post("/auth/logout-all", middleware.NoImpersonation, handlers.LogoutAllDevices)
+	protected.Get("/auth/sessions", handlers.ListSessions)
+	protected.Delete("/auth/sessions/:id", middleware.NoImpersonation, handlers.RevokeSession)
+	protected.Post("/auth/phone/link/request-otp", middleware.NoImpersonation, handlers.RequestPhoneLinkOTP)
+	protected.Post("/auth/phone/link/verify", middleware.NoImpersonation, handlers.LinkPhone)
+	protected.Get("/auth/providers", handlers.ListLinkedProviders)
+	protected.Post("/auth/providers/telegram", middleware.NoImpersonation, authHandler.LinkTelegram)
+	protected.Post("/auth/providers/google", middleware.NoImpersonation, authHandler.LinkGoogle)
+	protected.Delete("/auth/providers/:id", middleware.NoImpersonation, handlers.UnlinkProvider)
 
 	// User Profile
 	protected.Get("/users/me", handlers.GetMe)
 	protected.Put("/users/me", handlers.UpdateProfile)
-	protected.Get("/users", handlers.GetAllUsers) // List all users (for testing)
 
 	// Onboarding
 	protected.Get("/onboarding/status", handlers.GetOnboardingStatus)
@@ -96,7 +193,7 @@ func SetupRoutes(app *fiber.App, walletHandler *handlers.WalletHandler, profileH
 
 	// Referral System
 	protected.Get("/getReferralCode", profileHandler.GetReferralCode)
-	protected.Post("/applyReferralCode", profileHandler.ApplyReferralCode)
+	protected.Post("/applyReferralCode", middleware.NoImpersonation, profileHandler.ApplyReferralCode)
 
 	// ============================================
 	// SOCIAL CONTENT (Phase 1.2)
@@ -116,10 +213,15 @@ func SetupRoutes(app *fiber.App, walletHandler *handlers.WalletHandler, profileH
 	// ACCOUNT MANAGEMENT (Phase 2.3)
 	// ============================================
 
-	protected.Post("/deleteUserAccount", profileHandler.DeleteUserAccount)
+	protected.Post("/deleteUserAccount", middleware.NoImpersonation, profileHandler.DeleteUserAccount)
 	protected.Post("/userVerificationRequest", profileHandler.UserVerificationRequest)
 	protected.Post("/reportUser", profileHandler.ReportUser)
 
+	// Personal data export (ZIP of JSON files, once a day)
+	protected.Post("/exports", middleware.NoImpersonation, handlers.RequestDataExport)
+	protected.Get("/exports", handlers.ListDataExports)
+	protected.Get("/exports/:id/download", middleware.NoImpersonation, handlers.DownloadDataExport)
+
 	// ============================================
 	// SOCIAL FEATURES (Phase 4)
 	// ============================================
@@ -163,7 +265,7 @@ func SetupRoutes(app *fiber.App, walletHandler *handlers.WalletHandler, profileH
 
 	// Gifts (Luxury System)
 	protected.Get("/gifts/shop", handlers.GetGiftShop)
-	protected.Post("/gifts/send", handlers.SendGiftLuxury)
+	protected.Post("/gifts/send", middleware.NoImpersonation, handlers.SendGiftLuxury)
 	protected.Get("/gifts/received", handlers.GetGiftsReceived)
 
 	// Legacy gifts endpoint (keep for backward compatibility)
@@ -171,23 +273,27 @@ func SetupRoutes(app *fiber.App, walletHandler *handlers.WalletHandler, profileH
 
 	// Wallet (Luxury System)
 	protected.Get("/wallet/balance", handlers.GetWalletBalance)
-	protected.Post("/wallet/buy", middleware.PurchaseRateLimit(), handlers.BuyCoins)
-	protected.Post("/wallet/buy/webhook", handlers.CoinPurchaseWebhook) // Telebirr webhook
+	protected.Post("/wallet/buy", middleware.NoImpersonation, middleware.PurchaseRateLimit(), handlers.BuyCoins)
+	protected.Get("/wallet/packs", handlers.GetCoinPackOffers) // Packs with the user's promotions
+	protected.Get("/economy", handlers.GetEconomy)             // Coin packs, reveal prices, minimums in force
 
 	// Legacy coins endpoints (keep for backward compatibility)
 	protected.Get("/coins/balance", handlers.GetCoinBalance)
-	protected.Post("/coins/purchase", middleware.PurchaseRateLimit(), handlers.PurchaseCoins)
-	protected.Post("/coins/purchase/confirm", handlers.ConfirmCoinPurchase) // Webhook endpoint
+	protected.Post("/coins/purchase", middleware.NoImpersonation, middleware.PurchaseRateLimit(), handlers.PurchaseCoins)
 	protected.Get("/coins/transactions", handlers.GetCoinTransactions)
 
 	// Cashout (Luxury System)
-	protected.Post("/cashout/request", handlers.RequestCashout)
+	protected.Post("/cashout/request", middleware.NoImpersonation, handlers.RequestCashout)
 
 	// Legacy payouts endpoints (keep for backward compatibility)
 	protected.Get("/payouts/balance", handlers.GetPayoutBalance)
-	protected.Post("/payouts/request", handlers.RequestPayout)
+	protected.Post("/payouts/request", middleware.NoImpersonation, handlers.RequestPayout)
 	protected.Get("/payouts/history", handlers.GetPayoutHistory)
 
+	// Creator earnings statements (monthly, CSV / PDF)
+	protected.Get("/earnings/statements", handlers.ListEarningsStatements)
+	protected.Get("/earnings/statements/:period", handlers.GetEarningsStatement)
+
 	// ============================================
 	// NEW WALLET MANAGEMENT SYSTEM (Production-Grade)
 	// ============================================
@@ -195,11 +301,8 @@ func SetupRoutes(app *fiber.App, walletHandler *handlers.WalletHandler, profileH
 	// Wallet Balance & Info
 	protected.Get("/wallet/v2/balance", walletHandler.GetWalletBalance)
 
-	// Coin Purchase
-	protected.Post("/wallet/purchase-coins", walletHandler.PurchaseCoins)
-
 	// Withdrawals
-	protected.Post("/wallet/withdraw", walletHandler.RequestWithdrawal)
+	protected.Post("/wallet/withdraw", middleware.NoImpersonation, walletHandler.RequestWithdrawal)
 	protected.Get("/wallet/withdrawal-history", walletHandler.GetWithdrawalHistory)
 
 	// Transactions
@@ -207,15 +310,14 @@ func SetupRoutes(app *fiber.App, walletHandler *handlers.WalletHandler, profileH
 	protected.Post("/showOrderHistory", walletHandler.ShowOrderHistory)
 
 	// Payout Methods
-	protected.Post("/wallet/payout-methods", walletHandler.AddPayoutMethod)
+	protected.Post("/wallet/payout-methods", middleware.NoImpersonation, walletHandler.AddPayoutMethod)
 	protected.Get("/wallet/payout-methods", walletHandler.GetPayoutMethods)
-	protected.Delete("/wallet/payout-methods/:id", walletHandler.DeletePayoutMethod)
+	protected.Delete("/wallet/payout-methods/:id", middleware.NoImpersonation, walletHandler.DeletePayoutMethod)
 
 	// Legacy Android Endpoints (Backward Compatibility)
 	protected.Post("/showPayout", walletHandler.ShowPayout)
-	protected.Post("/addPayout", walletHandler.AddPayout)
-	protected.Post("/purchaseCoin", walletHandler.PurchaseCoin)
-	protected.Post("/withdrawRequest", walletHandler.WithdrawRequest)
+	protected.Post("/addPayout", middleware.NoImpersonation, walletHandler.AddPayout)
+	protected.Post("/withdrawRequest", middleware.NoImpersonation, walletHandler.WithdrawRequest)
 	protected.Post("/showWithdrawalHistory", walletHandler.ShowWithdrawalHistory)
 
 	// Verification
@@ -231,35 +333,31 @@ func SetupRoutes(app *fiber.App, walletHandler *handlers.WalletHandler, profileH
 
 	// Reward Channels
 	protected.Get("/coins/earn/channels", handlers.GetRewardChannels)
-	protected.Post("/coins/earn/claim", handlers.ClaimChannelReward)
+	protected.Post("/coins/earn/claim", middleware.NoImpersonation, handlers.ClaimChannelReward)
 
 	// Leaderboard
 	protected.Get("/leaderboard/top-gifted", handlers.GetTopGiftedUsers)
 
 	// Who Likes You (Likes Reveal)
 	protected.Get("/likes/pending", handlers.GetPendingLikes)
-	protected.Post("/likes/reveal", handlers.RevealLike)
-
-	// Admin routes (should have admin middleware in production)
-	admin := protected.Group("/admin")
-	admin.Get("/reports/pending", handlers.GetPendingReports)
-	admin.Put("/reports/:id/review", handlers.ReviewReport)
-	admin.Get("/payouts/pending", handlers.GetPendingPayouts)
-	admin.Put("/payouts/:id/process", handlers.ProcessPayout)
-
-	// Photo Moderation Monitoring (Phase 3)
-	admin.Get("/queue-stats", handlers.GetQueueStats)
-	admin.Get("/moderation/dashboard", handlers.GetModerationDashboard)
-	admin.Put("/moderation/rejected/:id/verify", handlers.VerifyRejectedPhoto)
-	admin.Delete("/moderation/rejected/:id", handlers.DeleteRejectedPhoto)
+	protected.Post("/likes/reveal", middleware.NoImpersonation, handlers.RevealLike)
 
 	// WebSocket - Legacy (keep for backward compatibility)
-	api.Get("/ws", websocket.New(handlers.HandleWebSocket))
+	api.Get("/ws", middleware.WebSocketAuth, websocket.New(handlers.HandleWebSocket))
 
 	// WebSocket - Unified Chat (handles both private 1-on-1 and live streaming chat)
-	api.Get("/ws/chat", websocket.New(handlers.HandleUnifiedChat))
+	api.Get("/ws/chat", middleware.WebSocketAuth, websocket.New(handlers.HandleUnifiedChat))
 
 	// Live Chat HTTP Endpoints
 	protected.Get("/live/:id/viewers", handlers.GetLiveViewerCount)
 	protected.Get("/live/:id/pinned", handlers.GetPinnedMessage)
+	protected.Get("/live/:id/battle", handlers.GetStreamBattle)
+	protected.Get("/live/:id/replay", handlers.GetLiveReplay)
+	protected.Put("/live/:id/vod", handlers.SetLiveStreamVOD)
+
+	// Live PK Battles
+	protected.Post("/live/battles", middleware.NoImpersonation, handlers.StartBattle)
+	protected.Get("/live/battles/:id", handlers.GetBattle)
+	protected.Post("/live/battles/:id/accept", middleware.NoImpersonation, handlers.AcceptBattle)
+	protected.Post("/live/battles/:id/cancel", middleware.NoImpersonation, handlers.CancelBattle)
 }
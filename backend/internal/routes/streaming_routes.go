@@ -3,6 +3,7 @@ package routes
 import (
 	"lomi-backend/config"
 	"lomi-backend/internal/handlers"
+	"lomi-backend/internal/middleware"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -26,24 +27,20 @@ func SetupStreamingRoutes(app *fiber.App) {
 	api.Post("/registerUser", streamingHandler.RegisterUser)
 
 	// ==================== PROTECTED ENDPOINTS ====================
-	// These endpoints require authentication
-	// Note: The TikTok apps send auth_token in the request body, not headers
-	// So we'll handle auth validation inside each handler for now
+	// These endpoints require a Bearer access token; the user comes from it,
+	// never from user_id / sender_id in the body
 
 	// 2. POST /api/showUserDetail - Get user profile & wallet
-	api.Post("/showUserDetail", streamingHandler.ShowUserDetail)
+	api.Post("/showUserDetail", middleware.AuthMiddleware, streamingHandler.ShowUserDetail)
 
 	// 3. POST /api/showRelatedVideos - Home feed (For You page)
 	api.Post("/showRelatedVideos", streamingHandler.ShowRelatedVideos)
 
 	// 4. POST /api/liveStream - Start live streaming
-	api.Post("/liveStream", streamingHandler.LiveStream)
+	api.Post("/liveStream", middleware.AuthMiddleware, middleware.NoImpersonation, streamingHandler.LiveStream)
 
 	// 5. POST /api/sendGift - Send virtual gift
-	api.Post("/sendGift", streamingHandler.SendGift)
-
-	// 6. POST /api/purchaseCoin - Buy coins
-	api.Post("/purchaseCoin", streamingHandler.PurchaseCoin)
+	api.Post("/sendGift", middleware.AuthMiddleware, middleware.NoImpersonation, streamingHandler.SendGift)
 
 	// ==================== BONUS ENDPOINTS ====================
 	// Additional endpoints that might be needed
@@ -90,47 +87,5 @@ func SetupStreamingRoutes(app *fiber.App) {
 	})
 
 	// Show gifts catalog
-	api.Post("/showGifts", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{
-			"code": 200,
-			"msg": []fiber.Map{
-				{
-					"Gift": fiber.Map{
-						"id":    1,
-						"title": "Rose",
-						"image": "https://cdn.example.com/gifts/rose.png",
-						"coin":  10,
-						"type":  "normal",
-					},
-				},
-				{
-					"Gift": fiber.Map{
-						"id":    2,
-						"title": "Heart",
-						"image": "https://cdn.example.com/gifts/heart.png",
-						"coin":  50,
-						"type":  "normal",
-					},
-				},
-				{
-					"Gift": fiber.Map{
-						"id":    3,
-						"title": "Diamond",
-						"image": "https://cdn.example.com/gifts/diamond.png",
-						"coin":  1000,
-						"type":  "premium",
-					},
-				},
-				{
-					"Gift": fiber.Map{
-						"id":    4,
-						"title": "Universe",
-						"image": "https://cdn.example.com/gifts/universe.png",
-						"coin":  5000,
-						"type":  "luxury",
-					},
-				},
-			},
-		})
-	})
+	api.Post("/showGifts", streamingHandler.ShowGifts)
 }
@@ -0,0 +1,545 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"lomi-backend/config"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==================== ACCOUNT DELETION ====================
+// Deleting an account is a request first: the user is logged out everywhere
+// and hidden from discovery, and logging in within 14 days cancels it. After
+// that the purger deletes the account's files from every bucket, scrubs the
+// messages it sent and the reports about or by it, and deletes every other row
+// pointing at it (swipes, matches and their chats, follows, media, ...) through
+// the foreign keys to users(id), so new tables are covered without changes
+// here. Accounting rows are kept: the users row becomes an anonymous
+// tombstone, so ledger entries, gifts and payouts keep an ID that no longer
+// identifies anyone; the payout destinations (phone or bank number, account
+// holder name) on them are blanked. The account_deletions row is the receipt.
+
+const (
+	accountDeletionGrace = 14 * 24 * time.Hour
+	accountPurgeInterval = 1 * time.Hour
+	accountPurgeBatch    = 20
+)
+
+var ErrDeleteStaffAccount = errors.New("staff accounts can't be deleted, remove the admin access first")
+
+// purgeKeepColumns are user references the purge leaves on the tombstone
+var purgeKeepColumns = map[string]bool{
+	// Accounting
+	"ledger_accounts.user_id":          true,
+	"wallets.user_id":                  true,
+	"wallet_transactions.user_id":      true,
+	"coin_transactions.user_id":        true,
+	"coin_purchases.user_id":           true,
+	"gift_transactions.sender_id":      true,
+	"gift_transactions.receiver_id":    true,
+	"payouts.user_id":                  true, // Destination scrubbed by anonymizeAccountRows
+	"withdrawal_requests.user_id":      true,
+	"earnings_statements.user_id":      true,
+	"promotion_redemptions.user_id":    true,
+	"live_battles.broadcaster_a_id":    true, // Battle gifts and the other broadcaster's history
+	"live_battles.broadcaster_b_id":    true,
+	"live_battles.winner_id":           true,
+	"live_battles.top_supporter":       true,
+	"messages.sender_id":               true, // Scrubbed by anonymizeAccountRows
+	"messages.receiver_id":             true,
+	"messages.live_stream_id":          true,
+	"reports.reporter_id":              true,
+	"reports.reported_user_id":         true,
+	"account_merges.target_user_id":    true,
+	"account_merges.source_user_id":    true,
+	"users.merged_into_id":             true,
+	"admin_impersonations.user_id":     true, // Support history
+	"admin_users.user_id":              true,
+	"matches.initiated_by":             true, // Deleted through user1_id/user2_id
+	"matches.unmatched_by":             true,
+	"verifications.reviewed_by":        true, // What someone else did as staff
+	"reports.reviewed_by":              true,
+	"payouts.processed_by":             true,
+	"payouts.first_approved_by":        true,
+	"payouts.approved_by":              true,
+	"payouts.rejected_by":              true,
+	"payout_batches.created_by":        true,
+	"payout_events.actor_id":           true,
+	"withdrawal_requests.processed_by": true,
+	"economy_configs.created_by":       true,
+	"promotions.created_by":            true,
+	"user_entitlements.granted_by_id":  true,
+}
+
+// RequestAccountDeletion schedules the user's account for purging and logs it
+// out everywhere. Asking again returns the pending request.
+func RequestAccountDeletion(ctx context.Context, userID uuid.UUID, reason string) (*models.AccountDeletion, error) {
+	var deletion models.AccountDeletion
+	var revoked []models.RefreshToken
+
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var staff int64
+		if err := tx.Model(&models.AdminUser{}).Where("user_id = ?", userID).Count(&staff).Error; err != nil {
+			return err
+		}
+		if staff > 0 {
+			return ErrDeleteStaffAccount
+		}
+
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "is_active").
+			First(&user, "id = ?", userID).Error; err != nil {
+			return err
+		}
+		err := tx.Where("user_id = ? AND status = ?", userID, models.AccountDeletionScheduled).First(&deletion).Error
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		now := time.Now()
+		deletion = models.AccountDeletion{
+			ID:             uuid.New(),
+			UserID:         userID,
+			Status:         models.AccountDeletionScheduled,
+			Reason:         truncate(strings.TrimSpace(reason), 1000),
+			WasActive:      user.IsActive,
+			RequestedAt:    now,
+			PurgeAfter:     now.Add(accountDeletionGrace),
+			DeletedRows:    models.JSONB{},
+			AnonymizedRows: models.JSONB{},
+			RetainedRows:   models.JSONB{},
+		}
+		if err := tx.Create(&deletion).Error; err != nil {
+			return err
+		}
+		// Hidden from discovery and likes until it's purged or canceled
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"is_active": false,
+			"is_online": false,
+		}).Error; err != nil {
+			return err
+		}
+
+		var families []uuid.UUID
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Distinct().Pluck("family_id", &families).Error; err != nil {
+			return err
+		}
+		revoked, err = revokeFamilies(tx, families, models.RefreshRevokeDeletion)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	blacklistAccessTokens(ctx, revoked)
+	log.Printf("🗑️ Account deletion requested: user=%s deletion=%s purge_after=%s",
+		userID, deletion.ID, deletion.PurgeAfter.Format(time.RFC3339))
+	return &deletion, nil
+}
+
+// CancelAccountDeletion cancels the user's pending deletion, if any, and
+// returns it. Called on login.
+func CancelAccountDeletion(ctx context.Context, userID uuid.UUID) (*models.AccountDeletion, error) {
+	var deletion models.AccountDeletion
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND status = ?", userID, models.AccountDeletionScheduled).
+			First(&deletion).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		deletion.Status = models.AccountDeletionCanceled
+		deletion.CanceledAt = &now
+		if err := tx.Model(&deletion).Updates(map[string]interface{}{
+			"status":      deletion.Status,
+			"canceled_at": now,
+			"updated_at":  now,
+		}).Error; err != nil {
+			return err
+		}
+		// A banned account stays banned
+		return tx.Model(&models.User{}).Where("id = ?", userID).Update("is_active", deletion.WasActive).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("♻️ Account deletion canceled by login: user=%s deletion=%s", userID, deletion.ID)
+	return &deletion, nil
+}
+
+// GetAccountDeletion returns a deletion request or receipt
+func GetAccountDeletion(ctx context.Context, id uuid.UUID) (*models.AccountDeletion, error) {
+	var deletion models.AccountDeletion
+	if err := database.DB.WithContext(ctx).First(&deletion, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &deletion, nil
+}
+
+// StartAccountPurger purges accounts whose grace period is over
+func StartAccountPurger() {
+	log.Printf("✅ Account purger started (every %s)", accountPurgeInterval)
+
+	ticker := time.NewTicker(accountPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		if purged, err := PurgeDueAccounts(context.Background()); err != nil {
+			log.Printf("❌ Account purge failed: %v", err)
+		} else if purged > 0 {
+			log.Printf("🗑️ Purged %d deleted accounts", purged)
+		}
+		<-ticker.C
+	}
+}
+
+// PurgeDueAccounts purges a batch of accounts past their grace period. A
+// failed purge is recorded on its request and retried next time.
+func PurgeDueAccounts(ctx context.Context) (int, error) {
+	var due []uuid.UUID
+	if err := database.DB.WithContext(ctx).Model(&models.AccountDeletion{}).
+		Where("status = ? AND purge_after <= ?", models.AccountDeletionScheduled, time.Now()).
+		Order("purge_after").Limit(accountPurgeBatch).
+		Pluck("id", &due).Error; err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, id := range due {
+		if err := PurgeAccount(ctx, id); err != nil {
+			log.Printf("❌ Account purge %s failed: %v", id, err)
+			database.DB.WithContext(ctx).Model(&models.AccountDeletion{}).Where("id = ?", id).Updates(map[string]interface{}{
+				"attempts":   gorm.Expr("attempts + 1"),
+				"last_error": truncate(err.Error(), 2000),
+				"updated_at": time.Now(),
+			})
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// PurgeAccount carries out a scheduled deletion. The request row stays locked
+// throughout, so a login can't cancel it halfway.
+func PurgeAccount(ctx context.Context, deletionID uuid.UUID) error {
+	var deletion models.AccountDeletion
+	var telegramID *int64
+
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND status = ?", deletionID, models.AccountDeletionScheduled).
+			First(&deletion).Error; err != nil {
+			return err
+		}
+		deletion.DeletedRows = models.JSONB{}
+		deletion.AnonymizedRows = models.JSONB{}
+		deletion.RetainedRows = models.JSONB{}
+
+		var user models.User
+		if err := tx.Unscoped().Select("id", "telegram_id").First(&user, "id = ?", deletion.UserID).Error; err != nil {
+			return fmt.Errorf("load user %s: %v", deletion.UserID, err) // Not gorm.ErrRecordNotFound: that means canceled
+		}
+		telegramID = user.TelegramID
+
+		// 1. Files, while the rows naming them still exist. Deleting is
+		// idempotent, so a retry after a failure below starts over safely.
+		objects, err := purgeAccountFiles(ctx, tx, deletion.UserID)
+		if err != nil {
+			return err
+		}
+		deletion.ObjectsDeleted = objects
+
+		// 2. Rows kept for the other side of a conversation or for moderation
+		if err := anonymizeAccountRows(tx, &deletion); err != nil {
+			return err
+		}
+
+		// 3. Everything else that points at the account
+		refs, err := userReferences(tx)
+		if err != nil {
+			return err
+		}
+		for _, ref := range refs {
+			key := ref.Table + "." + ref.Column
+			if purgeKeepColumns[key] {
+				var count int64
+				if err := tx.Table(ref.Table).Where(`"`+ref.Column+`" = ?`, deletion.UserID).Count(&count).Error; err != nil {
+					return fmt.Errorf("count %s: %w", key, err)
+				}
+				if count > 0 {
+					deletion.RetainedRows[key] = count
+				}
+				continue
+			}
+			deleted, kept, err := deleteUserReference(tx, ref, deletion.UserID)
+			if err != nil {
+				return fmt.Errorf("delete %s: %w", key, err)
+			}
+			if deleted > 0 {
+				deletion.DeletedRows[key] = deleted
+			}
+			if kept > 0 {
+				deletion.RetainedRows[key] = kept
+			}
+		}
+
+		// 4. The account itself becomes an anonymous tombstone
+		if err := tx.Model(&models.User{}).Unscoped().Where("id = ?", deletion.UserID).
+			Updates(tombstoneUser(deletion.UserID)).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		deletion.Status = models.AccountDeletionPurged
+		deletion.PurgedAt = &now
+		deletion.LastError = ""
+		deletion.Attempts++
+		return tx.Model(&deletion).Updates(map[string]interface{}{
+			"status":          deletion.Status,
+			"purged_at":       now,
+			"objects_deleted": deletion.ObjectsDeleted,
+			"deleted_rows":    deletion.DeletedRows,
+			"anonymized_rows": deletion.AnonymizedRows,
+			"retained_rows":   deletion.RetainedRows,
+			"last_error":      "",
+			"attempts":        deletion.Attempts,
+			"updated_at":      now,
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil // Canceled or purged in the meantime
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("🗑️ Account purged: user=%s deletion=%s objects=%d deleted=%v retained=%v",
+		deletion.UserID, deletion.ID, deletion.ObjectsDeleted, deletion.DeletedRows, deletion.RetainedRows)
+	if telegramID != nil && NotificationSvc != nil {
+		message := fmt.Sprintf("🗑️ Your Lomi account has been deleted.\n\nYour profile, photos, videos, matches and chats were removed. Records of payments are kept without your personal details, as required for accounting.\n\nDeletion receipt: %s", deletion.ID)
+		if err := NotificationSvc.SendTelegramMessage(*telegramID, message); err != nil {
+			log.Printf("⚠️ Failed to send deletion receipt %s: %v", deletion.ID, err)
+		}
+	}
+	return nil
+}
+
+// purgeAccountFiles deletes the account's objects from every bucket: its
+// upload prefixes, and keys its rows name elsewhere. Returns how many objects
+// were deleted.
+func purgeAccountFiles(ctx context.Context, tx *gorm.DB, userID uuid.UUID) (int, error) {
+	var keys []string
+	if err := tx.Raw(`
+		SELECT url FROM media WHERE user_id = ?
+		UNION SELECT thumbnail_url FROM media WHERE user_id = ?
+		UNION SELECT selfie_url FROM verifications WHERE user_id = ?
+		UNION SELECT id_document_url FROM verifications WHERE user_id = ?
+		UNION SELECT video_url FROM videos WHERE user_id = ?
+		UNION SELECT thumbnail_url FROM videos WHERE user_id = ?
+		UNION SELECT media_url FROM messages WHERE sender_id = ?
+		UNION SELECT jsonb_array_elements_text(screenshot_urls) FROM reports
+			WHERE reporter_id = ? AND jsonb_typeof(screenshot_urls) = 'array'
+	`, userID, userID, userID, userID, userID, userID, userID, userID).Scan(&keys).Error; err != nil {
+		return 0, err
+	}
+
+	if database.S3Client == nil {
+		log.Printf("⚠️ S3 not configured: files of user %s were not deleted", userID)
+		return 0, nil
+	}
+
+	buckets := accountBuckets()
+	prefixes := []string{"users/" + userID.String() + "/", "statements/" + userID.String() + "/", "exports/" + userID.String() + "/"}
+	var objectKeys []string
+	seen := map[string]bool{}
+	for _, raw := range keys {
+		key := objectKey(raw, buckets)
+		if key == "" || seen[key] || strings.HasPrefix(key, prefixes[0]) || strings.HasPrefix(key, prefixes[1]) {
+			continue
+		}
+		seen[key] = true
+		objectKeys = append(objectKeys, key)
+	}
+
+	deleted := 0
+	for _, bucket := range buckets {
+		for _, prefix := range prefixes {
+			n, err := database.DeletePrefix(ctx, bucket, prefix)
+			deleted += n
+			if err != nil {
+				return deleted, err
+			}
+		}
+		// Each key lives in one bucket; deleting a missing key is a no-op
+		if _, err := database.DeleteObjects(ctx, bucket, objectKeys); err != nil {
+			return deleted, err
+		}
+	}
+	return deleted + len(objectKeys), nil
+}
+
+// accountBuckets lists the configured buckets once each
+func accountBuckets() []string {
+	cfg := config.Cfg
+	var buckets []string
+	seen := map[string]bool{}
+	for _, bucket := range []string{cfg.S3BucketPhotos, cfg.S3BucketVideos, cfg.S3BucketGifts, cfg.S3BucketVerify} {
+		if bucket != "" && !seen[bucket] {
+			seen[bucket] = true
+			buckets = append(buckets, bucket)
+		}
+	}
+	return buckets
+}
+
+// objectKey turns a stored media reference into an object key. Most columns
+// hold the key itself; older rows hold a full (path-style) URL.
+func objectKey(raw string, buckets []string) string {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		return strings.TrimPrefix(raw, "/")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	for _, bucket := range buckets {
+		if strings.HasPrefix(key, bucket+"/") {
+			return strings.TrimPrefix(key, bucket+"/")
+		}
+	}
+	return key
+}
+
+// anonymizeAccountRows scrubs the content of rows that stay for someone else:
+// the account's live chat messages and the reports it filed or received.
+// Private chats go with the matches.
+func anonymizeAccountRows(tx *gorm.DB, deletion *models.AccountDeletion) error {
+	userID := deletion.UserID
+
+	result := tx.Exec(`UPDATE messages SET content = '', media_url = '', metadata = '{}', updated_at = NOW() WHERE sender_id = ?`, userID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		deletion.AnonymizedRows["messages"] = result.RowsAffected
+	}
+
+	result = tx.Exec(`UPDATE reports SET description = '', screenshot_urls = '[]', updated_at = NOW() WHERE reporter_id = ? OR reported_user_id = ?`, userID, userID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		deletion.AnonymizedRows["reports"] = result.RowsAffected
+	}
+
+	// Payouts keep amounts, status and the provider's reference for finance, not
+	// where the money went or whose name the account is in
+	result = tx.Exec(`UPDATE payouts SET payment_account = '', payment_account_name = '', updated_at = NOW() WHERE user_id = ?`, userID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		deletion.AnonymizedRows["payouts"] = result.RowsAffected
+	}
+
+	result = tx.Exec(`UPDATE withdrawal_requests SET account_details = '{}', updated_at = NOW() WHERE user_id = ?`, userID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		deletion.AnonymizedRows["withdrawal_requests"] = result.RowsAffected
+	}
+
+	// Follower counts of the accounts on the other side of the follows
+	if err := tx.Exec(`UPDATE users SET followers_count = GREATEST(followers_count - 1, 0) WHERE id IN (SELECT following_id FROM follows WHERE follower_id = ?)`, userID).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec(`UPDATE users SET following_count = GREATEST(following_count - 1, 0) WHERE id IN (SELECT follower_id FROM follows WHERE following_id = ?)`, userID).Error; err != nil {
+		return err
+	}
+
+	// Gifts sent in the account's chats keep their transaction, not the message
+	return tx.Exec(`
+		UPDATE gift_transactions SET message_id = NULL
+		WHERE message_id IN (
+			SELECT m.id FROM messages m JOIN matches x ON x.id = m.match_id
+			WHERE x.user1_id = ? OR x.user2_id = ?
+		)
+	`, userID, userID).Error
+}
+
+// deleteUserReference deletes the rows of one column that point at the
+// account. Rows another table still needs (a restricting foreign key) are
+// kept and counted.
+func deleteUserReference(tx *gorm.DB, ref userReference, userID uuid.UUID) (deleted, kept int64, err error) {
+	return applyToUserReference(tx, ref, userID, "purge", isForeignKeyViolation, func(table, _ string) (string, []interface{}) {
+		return `DELETE FROM ` + table, nil
+	})
+}
+
+// tombstoneUser clears everything that identifies a person from a users row.
+// Balances stay: they are projections of the retained ledger.
+func tombstoneUser(userID uuid.UUID) map[string]interface{} {
+	return map[string]interface{}{
+		"telegram_id":         nil,
+		"telegram_username":   "",
+		"telegram_first_name": "",
+		"telegram_last_name":  "",
+		"email":               nil,
+		"phone":               "",
+		"username":            "deleted_" + strings.ReplaceAll(userID.String(), "-", "")[:22],
+		"nickname":            "",
+		"name":                "Deleted user",
+		"age":                 18,
+		"gender":              models.GenderOther,
+		"city":                "",
+		"bio":                 "",
+		"religion":            nil,
+		"languages":           gorm.Expr("'[]'::jsonb"),
+		"interests":           gorm.Expr("'[]'::jsonb"),
+		"preferences":         gorm.Expr("'{}'::jsonb"),
+		"latitude":            nil,
+		"longitude":           nil,
+		"is_verified":         false,
+		"verification_status": nil,
+		"is_active":           false,
+		"is_online":           false,
+		"password":            "",
+		"profile_pic":         "",
+		"profile_pic_small":   "",
+		"device_token":        "",
+		"auth_token":          "",
+		"device":              "",
+		"ip":                  "",
+		"paypal":              "",
+		"referral_code":       "",
+		"deleted_at":          gorm.Expr("COALESCE(deleted_at, NOW())"),
+		"updated_at":          time.Now(),
+	}
+}
+
+func isForeignKeyViolation(err error) bool {
+	return err != nil && (strings.Contains(err.Error(), "violates foreign key") || strings.Contains(err.Error(), "SQLSTATE 23503"))
+}
@@ -0,0 +1,358 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/ledger"
+	"lomi-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==================== ACCOUNT MERGE ====================
+// Merging moves everything a source account owns to the target account and
+// soft-deletes the source. Rows are moved through every foreign key to
+// users(id), so new tables are covered without changes here. Where the
+// target already has the row (one-per-user settings, wallets, the same
+// channel reward) the source's copy is left on the source and counted as
+// skipped.
+
+var (
+	ErrMergeSameAccount = errors.New("can't merge an account into itself")
+	ErrMergeUserMissing = errors.New("account to merge was not found")
+)
+
+// mergeSkipColumns are user references the generic move leaves alone
+var mergeSkipColumns = map[string]bool{
+	"ledger_accounts.user_id":       true, // Coins move by a journal entry; postings are append-only
+	"refresh_tokens.user_id":        true, // The source's sessions are revoked
+	"user_sessions.user_id":         true,
+	"admin_impersonations.user_id":  true, // Support history stays with the account it was about
+	"account_merges.target_user_id": true,
+	"account_merges.source_user_id": true,
+	"users.merged_into_id":          true,
+	"matches.user1_id":              true, // Moved by moveMatches (user1_id < user2_id)
+	"matches.user2_id":              true,
+}
+
+type userReference struct {
+	Table  string
+	Column string
+}
+
+// MergeAccounts merges source into target. Both users must exist; the source
+// ends up soft-deleted with merged_into_id set and all its sessions revoked.
+func MergeAccounts(ctx context.Context, targetID, sourceID uuid.UUID) (*models.AccountMerge, error) {
+	if targetID == sourceID {
+		return nil, ErrMergeSameAccount
+	}
+
+	merge := &models.AccountMerge{
+		ID:           uuid.New(),
+		TargetUserID: targetID,
+		SourceUserID: sourceID,
+		MovedRows:    models.JSONB{},
+		SkippedRows:  models.JSONB{},
+	}
+	var revoked []models.RefreshToken
+
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Merges are rare: one at a time keeps chains (A->B while B->C) simple
+		if err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('account_merge'))`).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.User{}).Where("id IN ?", []uuid.UUID{targetID, sourceID}).Count(&count).Error; err != nil {
+			return err
+		}
+		if count != 2 {
+			return ErrMergeUserMissing
+		}
+
+		// 1. Coins, through the ledger (before any users row is locked, see ledger.Post)
+		if err := mergeCoins(ctx, tx, merge); err != nil {
+			return err
+		}
+
+		var source, target models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&source, "id = ?", sourceID).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&target, "id = ?", targetID).Error; err != nil {
+			return err
+		}
+
+		// 2. ETB gift balance
+		if source.GiftBalance > 0 {
+			merge.GiftBalanceMoved = source.GiftBalance
+			if err := tx.Model(&models.User{}).Where("id = ?", targetID).
+				UpdateColumn("gift_balance", gorm.Expr("gift_balance + ?", source.GiftBalance)).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.User{}).Where("id = ?", sourceID).
+				UpdateColumn("gift_balance", 0).Error; err != nil {
+				return err
+			}
+		}
+
+		// 3. Rows between the two accounts make no sense on one
+		if err := tx.Exec(`DELETE FROM swipes WHERE (swiper_id = ? AND swiped_id = ?) OR (swiper_id = ? AND swiped_id = ?)`,
+			sourceID, targetID, targetID, sourceID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`DELETE FROM blocks WHERE (blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)`,
+			sourceID, targetID, targetID, sourceID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`DELETE FROM matches WHERE (user1_id = ? AND user2_id = ?) OR (user1_id = ? AND user2_id = ?)`,
+			sourceID, targetID, targetID, sourceID).Error; err != nil {
+			return err
+		}
+
+		// 4. Everything else that points at the source
+		if err := moveMatches(tx, merge); err != nil {
+			return err
+		}
+		refs, err := userReferences(tx)
+		if err != nil {
+			return err
+		}
+		for _, ref := range refs {
+			if mergeSkipColumns[ref.Table+"."+ref.Column] {
+				continue
+			}
+			moved, skipped, err := moveUserReference(tx, ref, sourceID, targetID)
+			if err != nil {
+				return fmt.Errorf("move %s.%s: %w", ref.Table, ref.Column, err)
+			}
+			recordMergeCounts(merge, ref.Table+"."+ref.Column, moved, skipped)
+		}
+
+		// The target's wallet may be the source's old one: resync its projection
+		balance, err := ledger.Balance(ctx, ledger.Gorm(tx), ledger.UserCoins(targetID))
+		if err != nil {
+			return err
+		}
+		if err := tx.Exec(`UPDATE wallets SET balance = ?, updated_at = NOW() WHERE user_id = ?`, balance, targetID).Error; err != nil {
+			return err
+		}
+
+		// 5. Logins stored on users: free them on the source, then fill the target's gaps
+		if err := tx.Model(&models.User{}).Where("id = ?", sourceID).Updates(map[string]interface{}{
+			"telegram_id":    nil,
+			"email":          nil,
+			"phone":          "",
+			"is_active":      false,
+			"merged_into_id": targetID,
+		}).Error; err != nil {
+			return err
+		}
+		identity := map[string]interface{}{}
+		if target.TelegramID == nil && source.TelegramID != nil {
+			identity["telegram_id"] = *source.TelegramID
+			identity["telegram_username"] = source.TelegramUsername
+			identity["telegram_first_name"] = source.TelegramFirstName
+			identity["telegram_last_name"] = source.TelegramLastName
+		}
+		if target.Email == "" && source.Email != "" {
+			identity["email"] = source.Email
+		}
+		if target.Phone == "" && source.Phone != "" {
+			identity["phone"] = source.Phone
+		}
+		if len(identity) > 0 {
+			if err := tx.Model(&models.User{}).Where("id = ?", targetID).Updates(identity).Error; err != nil {
+				return err
+			}
+		}
+
+		// 6. Sessions, then the source itself
+		var families []uuid.UUID
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", sourceID).
+			Distinct().Pluck("family_id", &families).Error; err != nil {
+			return err
+		}
+		if revoked, err = revokeFamilies(tx, families, models.RefreshRevokeMerged); err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.User{}, "id = ?", sourceID).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(merge).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	blacklistAccessTokens(ctx, revoked)
+	log.Printf("🔗 Account merged: source=%s target=%s coins=%d gift_balance=%.2f",
+		sourceID, targetID, merge.CoinsMoved, merge.GiftBalanceMoved)
+	return merge, nil
+}
+
+// mergeCoins moves the source's coin balance to the target. A source in debt
+// passes the debt on: the target's account may go negative.
+func mergeCoins(ctx context.Context, tx *gorm.DB, merge *models.AccountMerge) error {
+	db := ledger.Gorm(tx)
+	source := ledger.UserCoins(merge.SourceUserID)
+	target := ledger.UserCoins(merge.TargetUserID)
+
+	balance, err := ledger.Balance(ctx, db, source)
+	if err != nil || balance == 0 {
+		return err
+	}
+	entry := ledger.Entry{
+		Type:           ledger.EntryAccountMerge,
+		ReferenceType:  "account_merge",
+		ReferenceID:    merge.ID.String(),
+		IdempotencyKey: "account_merge:" + merge.SourceUserID.String(),
+		Description:    "Account merged into " + merge.TargetUserID.String(),
+		Metadata: map[string]interface{}{
+			"source_user_id": merge.SourceUserID.String(),
+			"target_user_id": merge.TargetUserID.String(),
+		},
+	}
+	if balance > 0 {
+		entry.Lines = ledger.Transfer(source, target, balance)
+	} else {
+		entry.Lines = ledger.Transfer(target, source, -balance)
+		entry.AllowOverdraft = true
+	}
+	journal, err := ledger.Post(ctx, db, entry)
+	if err != nil {
+		return err
+	}
+	// A gift or purchase may have changed the balance since it was read
+	if journal.BalanceAfter(source) != 0 {
+		return fmt.Errorf("source coin balance changed during the merge, try again")
+	}
+	merge.JournalEntryID = &journal.ID
+	merge.CoinsMoved = balance
+	return nil
+}
+
+// moveMatches moves the source's matches, keeping user1_id < user2_id. A match
+// with someone the target already matched stays on the source.
+func moveMatches(tx *gorm.DB, merge *models.AccountMerge) error {
+	result := tx.Exec(`
+		UPDATE matches m
+		SET user1_id = LEAST(o.other_id, ?::uuid), user2_id = GREATEST(o.other_id, ?::uuid)
+		FROM (
+			SELECT id, CASE WHEN user1_id = ? THEN user2_id ELSE user1_id END AS other_id
+			FROM matches WHERE user1_id = ? OR user2_id = ?
+		) o
+		WHERE m.id = o.id AND NOT EXISTS (
+			SELECT 1 FROM matches x
+			WHERE x.user1_id = LEAST(o.other_id, ?::uuid) AND x.user2_id = GREATEST(o.other_id, ?::uuid)
+		)
+	`, merge.TargetUserID, merge.TargetUserID,
+		merge.SourceUserID, merge.SourceUserID, merge.SourceUserID,
+		merge.TargetUserID, merge.TargetUserID)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	var skipped int64
+	if err := tx.Model(&models.Match{}).
+		Where("user1_id = ? OR user2_id = ?", merge.SourceUserID, merge.SourceUserID).
+		Count(&skipped).Error; err != nil {
+		return err
+	}
+	recordMergeCounts(merge, "matches", result.RowsAffected, skipped)
+	return nil
+}
+
+// userReferences lists every single-column foreign key to users(id)
+func userReferences(tx *gorm.DB) ([]userReference, error) {
+	var refs []userReference
+	err := tx.Raw(`
+		SELECT c.conrelid::regclass::text AS "table", a.attname AS "column"
+		FROM pg_constraint c
+		JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = c.conkey[1]
+		WHERE c.contype = 'f' AND c.confrelid = 'users'::regclass AND cardinality(c.conkey) = 1
+		ORDER BY 1, 2
+	`).Scan(&refs).Error
+	return refs, err
+}
+
+// moveUserReference points one column from source to target. If a unique
+// constraint refuses the bulk update, rows are moved one by one and the
+// conflicting ones stay on the source.
+func moveUserReference(tx *gorm.DB, ref userReference, sourceID, targetID uuid.UUID) (moved, skipped int64, err error) {
+	return applyToUserReference(tx, ref, sourceID, "merge", isUniqueViolation, func(table, column string) (string, []interface{}) {
+		return `UPDATE ` + table + ` SET ` + column + ` = ?`, []interface{}{targetID}
+	})
+}
+
+// referenceStatement returns the SQL of a statement on a user reference up to
+// its WHERE clause ("UPDATE t SET c = ?", "DELETE FROM t") and its own arguments
+type referenceStatement func(table, column string) (string, []interface{})
+
+// applyToUserReference runs a statement on the rows whose ref column is
+// userID. The bulk statement runs under a savepoint; if it fails with an error
+// refused reports, it is retried row by row and the refused rows are left as
+// they were and counted. Merges and purges both go through here.
+func applyToUserReference(tx *gorm.DB, ref userReference, userID uuid.UUID, savepoint string, refused func(error) bool, statement referenceStatement) (applied, skipped int64, err error) {
+	// Names come from the system catalog, not from input
+	table, column := ref.Table, `"`+ref.Column+`"`
+	query, args := statement(table, column)
+
+	if err := tx.SavePoint(savepoint + "_column").Error; err != nil {
+		return 0, 0, err
+	}
+	result := tx.Exec(query+` WHERE `+column+` = ?`, append(append([]interface{}{}, args...), userID)...)
+	if result.Error == nil {
+		return result.RowsAffected, 0, nil
+	}
+	if !refused(result.Error) {
+		return 0, 0, result.Error
+	}
+	if err := tx.RollbackTo(savepoint + "_column").Error; err != nil {
+		return 0, 0, err
+	}
+
+	var rows []string
+	if err := tx.Raw(`SELECT ctid::text FROM `+table+` WHERE `+column+` = ?`, userID).Scan(&rows).Error; err != nil {
+		return 0, 0, err
+	}
+	for _, ctid := range rows {
+		if err := tx.SavePoint(savepoint + "_row").Error; err != nil {
+			return applied, skipped, err
+		}
+		err := tx.Exec(query+` WHERE ctid = ?::tid`, append(append([]interface{}{}, args...), ctid)...).Error
+		switch {
+		case err == nil:
+			applied++
+		case refused(err):
+			if err := tx.RollbackTo(savepoint + "_row").Error; err != nil {
+				return applied, skipped, err
+			}
+			skipped++
+		default:
+			return applied, skipped, err
+		}
+	}
+	return applied, skipped, nil
+}
+
+func recordMergeCounts(merge *models.AccountMerge, key string, moved, skipped int64) {
+	if moved > 0 {
+		merge.MovedRows[key] = moved
+	}
+	if skipped > 0 {
+		merge.SkippedRows[key] = skipped
+	}
+}
+
+func isUniqueViolation(err error) bool {
+	return err != nil && (strings.Contains(err.Error(), "duplicate key") || strings.Contains(err.Error(), "SQLSTATE 23505"))
+}
@@ -0,0 +1,427 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"lomi-backend/config"
+	"lomi-backend/internal/auth"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==================== ADMIN ACCOUNTS ====================
+// Admins log in against admin_users (bcrypt password, then a TOTP code once
+// they enabled it) and get an admin token. Five failed attempts lock the
+// account for 15 minutes. Roles map to permissions in models.AdminRole.Can.
+
+var (
+	ErrAdminInvalidCredentials = errors.New("invalid email or password")
+	ErrAdminTOTPRequired       = errors.New("a two-factor code is required")
+	ErrAdminLocked             = errors.New("too many failed attempts, try again later")
+	ErrAdminNotLinked          = errors.New("this admin account isn't linked to a user account")
+	ErrAdminNotFound           = errors.New("admin not found")
+	ErrAdminEmailTaken         = errors.New("an admin with this email already exists")
+	ErrAdminTOTPEnabled        = errors.New("two-factor authentication is already enabled")
+	ErrAdminTOTPNotStarted     = errors.New("start two-factor setup first")
+	ErrAdminInvalidTOTP        = errors.New("invalid two-factor code")
+	ErrAdminLastSuperAdmin     = errors.New("there must be at least one active super admin")
+)
+
+const (
+	adminMaxFailedLogins   = 5
+	adminLockout           = 15 * time.Minute
+	adminMinPasswordLength = 12
+	adminBcryptCost        = 12
+	adminTOTPIssuer        = "Lomi Admin"
+)
+
+// dummyAdminHash is compared against when the email is unknown, so a login
+// takes as long whether the account exists or not
+var dummyAdminHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("lomi-admin-timing"), adminBcryptCost)
+	return hash
+})
+
+type AdminLoginResult struct {
+	Admin     *models.AdminUser
+	Token     string
+	ExpiresAt time.Time
+}
+
+// AdminLogin checks an admin's password (and TOTP code) and issues an admin
+// token. Failed attempts are counted even though an error is returned. The
+// second result is the account the email matched, for the audit log.
+func AdminLogin(ctx context.Context, email, password, totpCode string) (*AdminLoginResult, *models.AdminUser, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	now := time.Now()
+
+	var admin models.AdminUser
+	var loginErr error
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&admin, "LOWER(email) = ?", email).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			bcrypt.CompareHashAndPassword(dummyAdminHash(), []byte(password))
+			loginErr = ErrAdminInvalidCredentials
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if admin.LockedUntil != nil && now.Before(*admin.LockedUntil) {
+			loginErr = ErrAdminLocked
+			return nil
+		}
+
+		ok := bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte(password)) == nil && admin.IsActive
+		var step int64
+		if ok && admin.TOTPEnabled {
+			if strings.TrimSpace(totpCode) == "" {
+				loginErr = ErrAdminTOTPRequired
+				return nil
+			}
+			var valid bool
+			step, valid = auth.VerifyTOTP(*admin.TOTPSecret, strings.TrimSpace(totpCode), now)
+			ok = valid && step > admin.TOTPLastStep
+		}
+
+		if !ok {
+			loginErr = ErrAdminInvalidCredentials
+			updates := map[string]interface{}{"failed_logins": admin.FailedLogins + 1}
+			if admin.FailedLogins+1 >= adminMaxFailedLogins {
+				updates["failed_logins"] = 0
+				updates["locked_until"] = now.Add(adminLockout)
+				log.Printf("🔒 Admin account locked after failed logins: %s", admin.ID)
+			}
+			return tx.Model(&admin).Updates(updates).Error
+		}
+
+		if admin.UserID == nil {
+			loginErr = ErrAdminNotLinked
+			return nil
+		}
+
+		updates := map[string]interface{}{
+			"failed_logins": 0,
+			"locked_until":  nil,
+			"last_login_at": now,
+		}
+		if step > 0 {
+			updates["totp_last_step"] = step
+		}
+		return tx.Model(&admin).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var known *models.AdminUser
+	if admin.ID != uuid.Nil {
+		known = &admin
+	}
+	if loginErr != nil {
+		return nil, known, loginErr
+	}
+
+	token, expiresAt, err := auth.CreateAdminToken(admin.ID, *admin.UserID, string(admin.Role))
+	if err != nil {
+		return nil, known, err
+	}
+	return &AdminLoginResult{Admin: &admin, Token: token, ExpiresAt: expiresAt}, known, nil
+}
+
+// GetAdminUser loads an admin account
+func GetAdminUser(ctx context.Context, id uuid.UUID) (*models.AdminUser, error) {
+	var admin models.AdminUser
+	if err := database.DB.WithContext(ctx).First(&admin, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAdminNotFound
+		}
+		return nil, err
+	}
+	return &admin, nil
+}
+
+// SetupAdminTOTP starts two-factor setup: it stores a new secret and returns it
+// with the otpauth URL. The secret only counts once EnableAdminTOTP confirms it.
+func SetupAdminTOTP(ctx context.Context, adminID uuid.UUID) (secret, otpauthURL string, err error) {
+	admin, err := GetAdminUser(ctx, adminID)
+	if err != nil {
+		return "", "", err
+	}
+	if admin.TOTPEnabled {
+		return "", "", ErrAdminTOTPEnabled
+	}
+
+	secret, err = auth.NewTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if err := database.DB.WithContext(ctx).Model(admin).Update("totp_secret", secret).Error; err != nil {
+		return "", "", err
+	}
+	return secret, auth.TOTPURL(adminTOTPIssuer, admin.Email, secret), nil
+}
+
+// EnableAdminTOTP turns on two-factor login after checking a code from the app
+func EnableAdminTOTP(ctx context.Context, adminID uuid.UUID, code string) error {
+	admin, err := GetAdminUser(ctx, adminID)
+	if err != nil {
+		return err
+	}
+	if admin.TOTPEnabled {
+		return ErrAdminTOTPEnabled
+	}
+	if admin.TOTPSecret == nil {
+		return ErrAdminTOTPNotStarted
+	}
+
+	step, ok := auth.VerifyTOTP(*admin.TOTPSecret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return ErrAdminInvalidTOTP
+	}
+	return database.DB.WithContext(ctx).Model(admin).Updates(map[string]interface{}{
+		"totp_enabled":   true,
+		"totp_last_step": step,
+	}).Error
+}
+
+// AdminUserInput is the super admin create/update payload. Nil fields are left unchanged on update.
+type AdminUserInput struct {
+	Email     *string           `json:"email"`
+	Password  *string           `json:"password"`
+	FullName  *string           `json:"full_name"`
+	Role      *models.AdminRole `json:"role"`
+	UserID    *uuid.UUID        `json:"user_id"`
+	IsActive  *bool             `json:"is_active"`
+	ResetTOTP *bool             `json:"reset_totp"` // Turns two-factor off, the admin sets it up again
+}
+
+// ListAdminUsers returns every admin account
+func ListAdminUsers(ctx context.Context) ([]models.AdminUser, error) {
+	var admins []models.AdminUser
+	err := database.DB.WithContext(ctx).Order("created_at ASC").Find(&admins).Error
+	return admins, err
+}
+
+// CreateAdminUser adds an admin account
+func CreateAdminUser(ctx context.Context, input AdminUserInput) (*models.AdminUser, error) {
+	if input.Password == nil {
+		return nil, errors.New("password is required")
+	}
+	admin := models.AdminUser{Role: models.AdminRoleModerator, IsActive: true}
+	if err := applyAdminUserInput(&admin, input); err != nil {
+		return nil, err
+	}
+	if err := validateAdminUser(database.DB.WithContext(ctx), &admin); err != nil {
+		return nil, err
+	}
+
+	if err := database.DB.WithContext(ctx).Create(&admin).Error; err != nil {
+		return nil, fmt.Errorf("failed to create admin: %w", err)
+	}
+	log.Printf("🛡️ Admin created: %s (%s, %s)", admin.Email, admin.Role, admin.ID)
+	return &admin, nil
+}
+
+// UpdateAdminUser changes an admin account. The last active super admin
+// can't be demoted or deactivated.
+func UpdateAdminUser(ctx context.Context, id uuid.UUID, input AdminUserInput) (*models.AdminUser, error) {
+	var admin models.AdminUser
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&admin, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrAdminNotFound
+			}
+			return err
+		}
+
+		wasSuperAdmin := admin.Role == models.AdminRoleSuperAdmin && admin.IsActive
+		if err := applyAdminUserInput(&admin, input); err != nil {
+			return err
+		}
+		if err := validateAdminUser(tx, &admin); err != nil {
+			return err
+		}
+
+		if wasSuperAdmin && (admin.Role != models.AdminRoleSuperAdmin || !admin.IsActive) {
+			var others int64
+			if err := tx.Model(&models.AdminUser{}).
+				Where("role = ? AND is_active AND id <> ?", models.AdminRoleSuperAdmin, admin.ID).
+				Count(&others).Error; err != nil {
+				return err
+			}
+			if others == 0 {
+				return ErrAdminLastSuperAdmin
+			}
+		}
+
+		admin.UpdatedAt = time.Now()
+		return tx.Save(&admin).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("🛡️ Admin updated: %s (%s, active=%v)", admin.Email, admin.Role, admin.IsActive)
+	return &admin, nil
+}
+
+func applyAdminUserInput(a *models.AdminUser, input AdminUserInput) error {
+	if input.Email != nil {
+		a.Email = strings.ToLower(strings.TrimSpace(*input.Email))
+	}
+	if input.Password != nil {
+		if len(*input.Password) < adminMinPasswordLength {
+			return fmt.Errorf("password must be at least %d characters", adminMinPasswordLength)
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(*input.Password), adminBcryptCost)
+		if err != nil {
+			return err
+		}
+		a.PasswordHash = string(hash)
+		a.FailedLogins = 0
+		a.LockedUntil = nil
+	}
+	if input.FullName != nil {
+		a.FullName = strings.TrimSpace(*input.FullName)
+	}
+	if input.Role != nil {
+		a.Role = *input.Role
+	}
+	if input.UserID != nil {
+		a.UserID = input.UserID
+	}
+	if input.IsActive != nil {
+		a.IsActive = *input.IsActive
+	}
+	if input.ResetTOTP != nil && *input.ResetTOTP {
+		a.TOTPEnabled = false
+		a.TOTPSecret = nil
+	}
+	return nil
+}
+
+func validateAdminUser(db *gorm.DB, a *models.AdminUser) error {
+	if _, err := mail.ParseAddress(a.Email); err != nil {
+		return errors.New("a valid email is required")
+	}
+	if a.FullName == "" {
+		return errors.New("full_name is required")
+	}
+	if !a.Role.IsValid() {
+		return errors.New("role must be moderator, finance, admin or super_admin")
+	}
+	if a.UserID == nil {
+		return errors.New("user_id (the admin's user account) is required")
+	}
+
+	var taken int64
+	if err := db.Model(&models.AdminUser{}).
+		Where("LOWER(email) = ? AND id <> ?", a.Email, a.ID).
+		Count(&taken).Error; err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrAdminEmailTaken
+	}
+
+	var user models.User
+	if err := db.Select("id").First(&user, "id = ?", *a.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("user_id doesn't match a user")
+		}
+		return err
+	}
+	return nil
+}
+
+// BootstrapSuperAdmin creates the first super admin from ADMIN_BOOTSTRAP_*
+// when there are no admins yet
+func BootstrapSuperAdmin(cfg *config.Config) {
+	if cfg.AdminBootstrapEmail == "" || cfg.AdminBootstrapPassword == "" {
+		return
+	}
+
+	var count int64
+	if err := database.DB.Model(&models.AdminUser{}).Count(&count).Error; err != nil {
+		log.Printf("⚠️ Could not check admin accounts: %v", err)
+		return
+	}
+	if count > 0 {
+		return
+	}
+
+	userID, err := uuid.Parse(cfg.AdminBootstrapUserID)
+	if err != nil {
+		log.Printf("⚠️ ADMIN_BOOTSTRAP_USER_ID must be the super admin's user ID")
+		return
+	}
+	role := models.AdminRoleSuperAdmin
+	name := "Super Admin"
+	if _, err := CreateAdminUser(context.Background(), AdminUserInput{
+		Email:    &cfg.AdminBootstrapEmail,
+		Password: &cfg.AdminBootstrapPassword,
+		FullName: &name,
+		Role:     &role,
+		UserID:   &userID,
+	}); err != nil {
+		log.Printf("⚠️ Failed to bootstrap super admin: %v", err)
+	}
+}
+
+// ==================== ADMIN AUDIT LOG ====================
+
+// RecordAdminAudit appends an entry to the admin audit log. Failing to write
+// it is logged, not returned: the action already happened.
+func RecordAdminAudit(ctx context.Context, entry *models.AdminAuditLog) {
+	if err := database.DB.WithContext(ctx).Create(entry).Error; err != nil {
+		log.Printf("❌ Failed to write admin audit log (%s %s): %v", entry.Action, entry.EntityID, err)
+	}
+}
+
+// AdminAuditFilter narrows ListAdminAuditLogs. Zero fields don't filter.
+type AdminAuditFilter struct {
+	AdminUserID *uuid.UUID
+	EntityType  string
+	EntityID    string
+	Before      *time.Time // Page backwards from here
+	Limit       int
+}
+
+// ListAdminAuditLogs returns audit entries, newest first
+func ListAdminAuditLogs(ctx context.Context, filter AdminAuditFilter) ([]models.AdminAuditLog, error) {
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+
+	query := database.DB.WithContext(ctx).Model(&models.AdminAuditLog{})
+	if filter.AdminUserID != nil {
+		query = query.Where("admin_user_id = ?", *filter.AdminUserID)
+	}
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.Before != nil {
+		query = query.Where("created_at < ?", *filter.Before)
+	}
+
+	var logs []models.AdminAuditLog
+	err := query.Order("created_at DESC").Limit(filter.Limit).Find(&logs).Error
+	return logs, err
+}
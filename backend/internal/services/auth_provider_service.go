@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==================== LINKED LOGINS ====================
+// A user can log in with any of their auth_providers rows. Linking proves
+// ownership with the provider's own credential (initData, ID token, SMS
+// code); linking a login that belongs to another account merges it.
+
+var (
+	ErrProviderLinkedElsewhere = errors.New("this login belongs to another account")
+	ErrProviderNotFound        = errors.New("login method not found")
+	ErrLastLoginMethod         = errors.New("can't remove the only way to log in to this account")
+)
+
+// ProviderIdentity is a login whose credential was verified
+type ProviderIdentity struct {
+	Provider   string
+	ProviderID string
+	Email      string
+}
+
+// LinkedProvider is one login method of a user
+type LinkedProvider struct {
+	ID         uuid.UUID `json:"id"`
+	Provider   string    `json:"provider"`
+	ProviderID string    `json:"provider_id"`
+	Email      string    `json:"email,omitempty"`
+	LinkedAt   time.Time `json:"linked_at"`
+}
+
+func linkedProvider(p models.AuthProvider) LinkedProvider {
+	return LinkedProvider{
+		ID:         p.ID,
+		Provider:   p.Provider,
+		ProviderID: p.ProviderID,
+		Email:      p.Email,
+		LinkedAt:   p.LinkedAt,
+	}
+}
+
+// FindUserByAuthProvider returns the user a login is linked to
+func FindUserByAuthProvider(tx *gorm.DB, provider, providerID string) (*models.User, error) {
+	var authProvider models.AuthProvider
+	if err := tx.Where("provider = ? AND provider_id = ?", provider, providerID).First(&authProvider).Error; err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := tx.Where("id = ?", authProvider.UserID).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpsertAuthProvider links a login to a user, moving it if another user had it
+func UpsertAuthProvider(tx *gorm.DB, userID uuid.UUID, provider, providerID, email string) error {
+	if provider == "" || providerID == "" {
+		return fmt.Errorf("provider information missing")
+	}
+
+	var existing models.AuthProvider
+	if err := tx.Where("provider = ? AND provider_id = ?", provider, providerID).First(&existing).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			entry := models.AuthProvider{
+				UserID:     userID,
+				Provider:   provider,
+				ProviderID: providerID,
+				Email:      email,
+				LinkedAt:   time.Now(),
+			}
+			return tx.Create(&entry).Error
+		}
+		return err
+	}
+
+	needsUpdate := false
+	if existing.UserID != userID {
+		existing.UserID = userID
+		needsUpdate = true
+	}
+	if email != "" && !strings.EqualFold(existing.Email, email) {
+		existing.Email = email
+		needsUpdate = true
+	}
+	if needsUpdate {
+		existing.LinkedAt = time.Now()
+		return tx.Save(&existing).Error
+	}
+	return nil
+}
+
+// backfillTelegramProvider adds the auth_providers row of users that only
+// have users.telegram_id (accounts from before auth_providers)
+func backfillTelegramProvider(tx *gorm.DB, user *models.User) error {
+	if user.TelegramID == nil {
+		return nil
+	}
+	var count int64
+	if err := tx.Unscoped().Model(&models.AuthProvider{}).
+		Where("provider = ? AND provider_id = ?", models.AuthProviderTelegram, strconv.FormatInt(*user.TelegramID, 10)).
+		Count(&count).Error; err != nil || count > 0 {
+		return err
+	}
+	return UpsertAuthProvider(tx, user.ID, models.AuthProviderTelegram, strconv.FormatInt(*user.TelegramID, 10), "")
+}
+
+// ListAuthProviders returns the login methods of a user
+func ListAuthProviders(ctx context.Context, userID uuid.UUID) ([]LinkedProvider, error) {
+	db := database.DB.WithContext(ctx)
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	if err := backfillTelegramProvider(db, &user); err != nil {
+		return nil, err
+	}
+
+	var rows []models.AuthProvider
+	if err := db.Where("user_id = ?", userID).Order("linked_at").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	providers := make([]LinkedProvider, 0, len(rows))
+	for _, row := range rows {
+		providers = append(providers, linkedProvider(row))
+	}
+	return providers, nil
+}
+
+// providerOwner returns the other user a login belongs to, if any
+func providerOwner(tx *gorm.DB, userID uuid.UUID, identity ProviderIdentity) (*uuid.UUID, error) {
+	var row models.AuthProvider
+	err := tx.Where("provider = ? AND provider_id = ? AND user_id <> ?", identity.Provider, identity.ProviderID, userID).
+		First(&row).Error
+	if err == nil {
+		return &row.UserID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Telegram accounts from before auth_providers are only on users.telegram_id,
+	// and Google sign-in also finds accounts by their verified email
+	query := tx.Select("id").Where("id <> ?", userID)
+	switch {
+	case identity.Provider == models.AuthProviderTelegram:
+		telegramID, err := strconv.ParseInt(identity.ProviderID, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("telegram_id = ?", telegramID)
+	case identity.Provider == models.AuthProviderGoogle && identity.Email != "":
+		query = query.Where("LOWER(email) = LOWER(?)", identity.Email)
+	default:
+		return nil, nil
+	}
+	var owner models.User
+	err = query.First(&owner).Error
+	if err == nil {
+		return &owner.ID, nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return nil, err
+}
+
+// LinkAuthProvider adds a verified login to a user. When the login belongs
+// to another account, that account is merged into this one if merge is set,
+// otherwise ErrProviderLinkedElsewhere is returned.
+func LinkAuthProvider(ctx context.Context, userID uuid.UUID, identity ProviderIdentity, merge bool) (*LinkedProvider, *models.AccountMerge, error) {
+	db := database.DB.WithContext(ctx)
+
+	owner, err := providerOwner(db, userID, identity)
+	if err != nil {
+		return nil, nil, err
+	}
+	var merged *models.AccountMerge
+	if owner != nil {
+		if !merge {
+			return nil, nil, ErrProviderLinkedElsewhere
+		}
+		if merged, err = MergeAccounts(ctx, userID, *owner); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var linked models.AuthProvider
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error; err != nil {
+			return err
+		}
+		// Another account may have taken the login since the check above
+		if owner, err := providerOwner(tx, userID, identity); err != nil {
+			return err
+		} else if owner != nil {
+			return ErrProviderLinkedElsewhere
+		}
+
+		updates := map[string]interface{}{}
+		switch identity.Provider {
+		case models.AuthProviderTelegram:
+			if user.TelegramID == nil {
+				telegramID, err := strconv.ParseInt(identity.ProviderID, 10, 64)
+				if err != nil {
+					return err
+				}
+				updates["telegram_id"] = telegramID
+			}
+		case models.AuthProviderPhone:
+			// One phone number per account: a new one replaces the old one.
+			// Hard delete: (provider, provider_id) is unique across soft-deleted rows too.
+			if err := tx.Unscoped().
+				Where("user_id = ? AND provider = ? AND provider_id <> ?", userID, models.AuthProviderPhone, identity.ProviderID).
+				Delete(&models.AuthProvider{}).Error; err != nil {
+				return err
+			}
+			updates["phone"] = identity.ProviderID
+		case models.AuthProviderGoogle:
+			if user.Email == "" && identity.Email != "" {
+				updates["email"] = identity.Email
+			}
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(&user).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := UpsertAuthProvider(tx, userID, identity.Provider, identity.ProviderID, identity.Email); err != nil {
+			return err
+		}
+		return tx.Where("provider = ? AND provider_id = ?", identity.Provider, identity.ProviderID).First(&linked).Error
+	})
+	if err != nil {
+		return nil, merged, err
+	}
+	result := linkedProvider(linked)
+	return &result, merged, nil
+}
+
+// UnlinkAuthProvider removes a login method, keeping at least one. Google
+// sign-in with the account's verified email still finds the account by email.
+func UnlinkAuthProvider(ctx context.Context, userID, providerRowID uuid.UUID) error {
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The user row lock serializes unlinks, so two can't remove the last two logins
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error; err != nil {
+			return err
+		}
+		if err := backfillTelegramProvider(tx, &user); err != nil {
+			return err
+		}
+
+		var row models.AuthProvider
+		if err := tx.Where("id = ? AND user_id = ?", providerRowID, userID).First(&row).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrProviderNotFound
+			}
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.AuthProvider{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count <= 1 {
+			return ErrLastLoginMethod
+		}
+
+		if err := tx.Unscoped().Delete(&row).Error; err != nil {
+			return err
+		}
+		switch row.Provider {
+		case models.AuthProviderTelegram:
+			if user.TelegramID != nil && strconv.FormatInt(*user.TelegramID, 10) == row.ProviderID {
+				return tx.Model(&user).Update("telegram_id", nil).Error
+			}
+		case models.AuthProviderPhone:
+			if user.Phone == row.ProviderID {
+				return tx.Model(&user).Update("phone", "").Error
+			}
+		}
+		return nil
+	})
+}
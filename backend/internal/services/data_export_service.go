@@ -0,0 +1,463 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"lomi-backend/config"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ==================== DATA EXPORT ====================
+// "Download my data": a request queues an export, and the exporter gathers
+// the user's profile, preferences, media, matches, messages, swipes, gifts,
+// wallet and coin history, reports and login sessions into a ZIP of JSON
+// files in the gifts bucket. Media entries carry presigned links that last as
+// long as the ZIP. The user is notified when it's ready and downloads it
+// through short-lived links until it expires. One export a day per user.
+
+const (
+	dataExportInterval    = 1 * time.Minute
+	dataExportCooldown    = 24 * time.Hour
+	dataExportRetention   = 7 * 24 * time.Hour // Also the longest a presigned link can last
+	dataExportNotifyLink  = 24 * time.Hour
+	dataExportStaleAfter  = 30 * time.Minute // A processing export older than this was interrupted
+	dataExportMaxAttempts = 3
+)
+
+var (
+	ErrExportInProgress = errors.New("an export of your data is already being prepared")
+	ErrExportTooSoon    = errors.New("you can request an export of your data once a day")
+	ErrExportNotReady   = errors.New("export is not ready or has expired")
+)
+
+// dataExportFiles are the JSON files of an export after media.json. Each
+// query returns one jsonb value; @user is the user's ID.
+var dataExportFiles = []struct {
+	Name  string
+	Query string
+}{
+	{"profile.json", `SELECT jsonb_build_object(
+		'account', (SELECT to_jsonb(u) - 'password' - 'auth_token' - 'device_token' - 'preferences' FROM users u WHERE u.id = @user),
+		'login_methods', (SELECT COALESCE(jsonb_agg(jsonb_build_object(
+			'provider', a.provider, 'provider_id', a.provider_id, 'email', a.email, 'linked_at', a.linked_at
+		) ORDER BY a.linked_at), '[]') FROM auth_providers a WHERE a.user_id = @user AND a.deleted_at IS NULL)
+	)`},
+	{"preferences.json", `SELECT jsonb_build_object(
+		'discovery', (SELECT preferences FROM users WHERE id = @user),
+		'privacy', (SELECT to_jsonb(p) - 'id' - 'user_id' FROM privacy_settings p WHERE p.user_id = @user LIMIT 1),
+		'notifications', (SELECT to_jsonb(n) - 'user_id' FROM notification_settings n WHERE n.user_id = @user LIMIT 1),
+		'push_notifications', (SELECT to_jsonb(n) - 'id' - 'user_id' FROM push_notifications n WHERE n.user_id = @user LIMIT 1)
+	)`},
+	{"matches.json", `SELECT COALESCE(jsonb_agg(jsonb_build_object(
+		'id', m.id,
+		'matched_with', jsonb_build_object('id', o.id, 'name', o.name),
+		'initiated_by_me', m.initiated_by = @user,
+		'is_active', m.is_active,
+		'unmatched_at', m.unmatched_at,
+		'created_at', m.created_at
+	) ORDER BY m.created_at), '[]')
+	FROM matches m
+	JOIN users o ON o.id = CASE WHEN m.user1_id = @user THEN m.user2_id ELSE m.user1_id END
+	WHERE m.user1_id = @user OR m.user2_id = @user`},
+	{"messages.json", `SELECT COALESCE(jsonb_agg(jsonb_build_object(
+		'id', x.id,
+		'match_id', x.match_id,
+		'live_stream_id', x.live_stream_id,
+		'sent_by_me', x.sender_id = @user,
+		'type', x.message_type,
+		'content', x.content,
+		'media_url', x.media_url,
+		'created_at', x.created_at,
+		'read_at', x.read_at
+	) ORDER BY x.created_at), '[]')
+	FROM messages x
+	WHERE x.sender_id = @user OR x.match_id IN (SELECT id FROM matches WHERE user1_id = @user OR user2_id = @user)`},
+	{"swipes.json", `SELECT COALESCE(jsonb_agg(jsonb_build_object(
+		'swiped_user_id', s.swiped_id, 'action', s.action, 'created_at', s.created_at
+	) ORDER BY s.created_at), '[]')
+	FROM swipes s WHERE s.swiper_id = @user`},
+	{"gifts.json", `SELECT jsonb_build_object(
+		'sent', (SELECT COALESCE(jsonb_agg(jsonb_build_object(
+			'id', t.id, 'to_user_id', t.receiver_id, 'gift', g.name_en, 'coins', t.coin_amount, 'birr_value', t.birr_value, 'created_at', t.created_at
+		) ORDER BY t.created_at), '[]') FROM gift_transactions t LEFT JOIN gifts g ON g.id = t.gift_id WHERE t.sender_id = @user),
+		'received', (SELECT COALESCE(jsonb_agg(jsonb_build_object(
+			'id', t.id, 'from_user_id', t.sender_id, 'gift', g.name_en, 'coins', t.coin_amount, 'birr_value', t.birr_value, 'created_at', t.created_at
+		) ORDER BY t.created_at), '[]') FROM gift_transactions t LEFT JOIN gifts g ON g.id = t.gift_id WHERE t.receiver_id = @user)
+	)`},
+	{"wallet.json", `SELECT jsonb_build_object(
+		'balances', (SELECT jsonb_build_object('coins', coin_balance, 'gift_balance_birr', gift_balance) FROM users WHERE id = @user),
+		'wallet', (SELECT to_jsonb(w) - 'user_id' FROM wallets w WHERE w.user_id = @user),
+		'wallet_transactions', (SELECT COALESCE(jsonb_agg(to_jsonb(t) - 'user_id' ORDER BY t.created_at), '[]') FROM wallet_transactions t WHERE t.user_id = @user),
+		'coin_transactions', (SELECT COALESCE(jsonb_agg(to_jsonb(t) - 'user_id' ORDER BY t.created_at), '[]') FROM coin_transactions t WHERE t.user_id = @user),
+		'coin_purchases', (SELECT COALESCE(jsonb_agg(to_jsonb(t) - 'user_id' ORDER BY t.created_at), '[]') FROM coin_purchases t WHERE t.user_id = @user),
+		'payouts', (SELECT COALESCE(jsonb_agg(to_jsonb(p) - 'user_id' - 'admin_notes' ORDER BY p.created_at), '[]') FROM payouts p WHERE p.user_id = @user)
+	)`},
+	{"reports.json", `SELECT COALESCE(jsonb_agg(jsonb_build_object(
+		'id', r.id, 'reported_user_id', r.reported_user_id, 'reason', r.reason, 'description', r.description,
+		'screenshot_urls', r.screenshot_urls, 'is_reviewed', r.is_reviewed, 'created_at', r.created_at
+	) ORDER BY r.created_at), '[]')
+	FROM reports r WHERE r.reporter_id = @user`},
+	{"sessions.json", `SELECT COALESCE(jsonb_agg(to_jsonb(s) - 'user_id' ORDER BY s.first_seen_at), '[]')
+	FROM user_sessions s WHERE s.user_id = @user`},
+}
+
+// RequestDataExport queues an export of the user's data. When one is already
+// queued or was made within the last day, that export is returned with
+// ErrExportInProgress or ErrExportTooSoon.
+func RequestDataExport(ctx context.Context, userID uuid.UUID) (*models.DataExport, error) {
+	db := database.DB.WithContext(ctx)
+
+	var last models.DataExport
+	err := db.Where("user_id = ? AND status <> ?", userID, models.DataExportFailed).
+		Order("requested_at DESC").First(&last).Error
+	switch {
+	case err == nil && (last.Status == models.DataExportPending || last.Status == models.DataExportProcessing):
+		return &last, ErrExportInProgress
+	case err == nil && time.Since(last.RequestedAt) < dataExportCooldown:
+		return &last, ErrExportTooSoon
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+
+	export := models.DataExport{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Status:      models.DataExportPending,
+		Files:       models.JSONB{},
+		RequestedAt: time.Now(),
+	}
+	if err := db.Create(&export).Error; err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrExportInProgress
+		}
+		return nil, err
+	}
+	log.Printf("📦 Data export requested: user=%s export=%s", userID, export.ID)
+	return &export, nil
+}
+
+// NextDataExportAt is when a user whose last export is last can ask again
+func NextDataExportAt(last *models.DataExport) time.Time {
+	return last.RequestedAt.Add(dataExportCooldown)
+}
+
+// ListDataExports returns the user's recent exports, newest first
+func ListDataExports(ctx context.Context, userID uuid.UUID) ([]models.DataExport, error) {
+	var exports []models.DataExport
+	err := database.DB.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("requested_at DESC").Limit(10).
+		Find(&exports).Error
+	return exports, err
+}
+
+// DataExportDownloadURL returns a short-lived link to a ready export of the user
+func DataExportDownloadURL(ctx context.Context, userID, exportID uuid.UUID, expiresIn time.Duration) (string, error) {
+	var export models.DataExport
+	if err := database.DB.WithContext(ctx).
+		Where("id = ? AND user_id = ?", exportID, userID).
+		First(&export).Error; err != nil {
+		return "", err
+	}
+	if export.Status != models.DataExportReady || export.ExpiresAt == nil || time.Now().After(*export.ExpiresAt) {
+		return "", ErrExportNotReady
+	}
+	return database.GeneratePresignedDownloadURL(ctx, config.Cfg.S3BucketGifts, export.ObjectKey, expiresIn)
+}
+
+// StartDataExporter builds queued exports and deletes expired ones
+func StartDataExporter() {
+	log.Printf("✅ Data exporter started (every %s)", dataExportInterval)
+
+	ticker := time.NewTicker(dataExportInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx := context.Background()
+		for {
+			export, err := claimDataExport(ctx)
+			if err != nil {
+				log.Printf("❌ Failed to claim a data export: %v", err)
+				break
+			}
+			if export == nil {
+				break
+			}
+			processDataExport(ctx, export)
+		}
+		ExpireDataExports(ctx)
+	}
+}
+
+// claimDataExport marks the oldest queued export processing and returns it.
+// Exports stuck in processing (the server stopped) are picked up again.
+func claimDataExport(ctx context.Context) (*models.DataExport, error) {
+	var export models.DataExport
+	result := database.DB.WithContext(ctx).Raw(`
+		UPDATE data_exports
+		SET status = 'processing', started_at = NOW(), attempts = attempts + 1, updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM data_exports
+			WHERE status = 'pending' OR (status = 'processing' AND started_at < ?)
+			ORDER BY requested_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING *
+	`, time.Now().Add(-dataExportStaleAfter)).Scan(&export)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	return &export, nil
+}
+
+// processDataExport builds, uploads and announces one export. A failure puts
+// it back in the queue until it has been tried dataExportMaxAttempts times.
+func processDataExport(ctx context.Context, export *models.DataExport) {
+	db := database.DB.WithContext(ctx)
+
+	expiresAt := time.Now().Add(dataExportRetention)
+	key := fmt.Sprintf("exports/%s/%s.zip", export.UserID, export.ID)
+	archive, files, err := buildDataExport(ctx, export.UserID, expiresAt)
+	if err == nil {
+		err = database.UploadObject(ctx, config.Cfg.S3BucketGifts, key, "application/zip", archive)
+	}
+	if err != nil {
+		log.Printf("❌ Data export %s failed (attempt %d): %v", export.ID, export.Attempts, err)
+		status := models.DataExportPending
+		if export.Attempts >= dataExportMaxAttempts {
+			status = models.DataExportFailed
+		}
+		db.Model(export).Updates(map[string]interface{}{
+			"status":     status,
+			"last_error": truncate(err.Error(), 2000),
+			"updated_at": time.Now(),
+		})
+		return
+	}
+
+	now := time.Now()
+	export.Status = models.DataExportReady
+	export.ObjectKey = key
+	export.SizeBytes = int64(len(archive))
+	export.Files = files
+	export.CompletedAt = &now
+	export.ExpiresAt = &expiresAt
+	if err := db.Model(export).Updates(map[string]interface{}{
+		"status":       export.Status,
+		"object_key":   key,
+		"size_bytes":   export.SizeBytes,
+		"files":        files,
+		"completed_at": now,
+		"expires_at":   expiresAt,
+		"last_error":   "",
+		"updated_at":   now,
+	}).Error; err != nil {
+		log.Printf("❌ Failed to save data export %s: %v", export.ID, err)
+		return
+	}
+	log.Printf("📦 Data export ready: user=%s export=%s size=%d", export.UserID, export.ID, export.SizeBytes)
+
+	if NotificationSvc == nil {
+		return
+	}
+	url, err := database.GeneratePresignedDownloadURL(ctx, config.Cfg.S3BucketGifts, key, dataExportNotifyLink)
+	if err != nil {
+		log.Printf("⚠️ Failed to sign data export link %s: %v", export.ID, err)
+		return
+	}
+	if err := NotificationSvc.NotifyDataExportReady(*export, url, now.Add(dataExportNotifyLink)); err != nil {
+		log.Printf("⚠️ Failed to notify data export %s: %v", export.ID, err)
+	}
+}
+
+// buildDataExport gathers the user's data into a ZIP. Returns the archive
+// and how many records each file holds.
+func buildDataExport(ctx context.Context, userID uuid.UUID, expiresAt time.Time) ([]byte, models.JSONB, error) {
+	db := database.DB.WithContext(ctx)
+	files := models.JSONB{}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	add := func(name string, content []byte) error {
+		w, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(content)
+		return err
+	}
+
+	media, count, err := exportMedia(ctx, userID, time.Until(expiresAt))
+	if err != nil {
+		return nil, nil, fmt.Errorf("media.json: %w", err)
+	}
+	if err := add("media.json", media); err != nil {
+		return nil, nil, err
+	}
+	files["media.json"] = count
+
+	for _, file := range dataExportFiles {
+		var content string
+		if err := db.Raw(`SELECT jsonb_pretty((`+file.Query+`))`, map[string]interface{}{"user": userID}).
+			Row().Scan(&content); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", file.Name, err)
+		}
+		if err := add(file.Name, []byte(content)); err != nil {
+			return nil, nil, err
+		}
+		files[file.Name] = countExportRecords([]byte(content))
+	}
+
+	readme := fmt.Sprintf("Your Lomi data, exported %s UTC.\n\n"+
+		"profile.json      your account and login methods\n"+
+		"preferences.json  discovery, privacy and notification settings\n"+
+		"media.json        your photos and videos, with download links\n"+
+		"matches.json      your matches\n"+
+		"messages.json     messages in your chats and the live chats you wrote in\n"+
+		"swipes.json       who you liked and passed\n"+
+		"gifts.json        gifts you sent and received\n"+
+		"wallet.json       balances, wallet and coin transactions, purchases and payouts\n"+
+		"reports.json      reports you filed\n"+
+		"sessions.json     devices you logged in on\n\n"+
+		"The download links in media.json work until %s UTC.\n",
+		time.Now().UTC().Format("2006-01-02 15:04"), expiresAt.UTC().Format("2006-01-02 15:04"))
+	if err := add("README.txt", []byte(readme)); err != nil {
+		return nil, nil, err
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), files, nil
+}
+
+// exportMedia lists the user's profile media and videos with presigned links
+func exportMedia(ctx context.Context, userID uuid.UUID, expiresIn time.Duration) ([]byte, int, error) {
+	db := database.DB.WithContext(ctx)
+	cfg := config.Cfg
+
+	var media []models.Media
+	if err := db.Where("user_id = ?", userID).Order("media_type, display_order").Find(&media).Error; err != nil {
+		return nil, 0, err
+	}
+	var videos []struct {
+		ID           uuid.UUID
+		VideoURL     string
+		ThumbnailURL string
+		Description  string
+		CreatedAt    time.Time
+	}
+	if err := db.Raw(`SELECT id, video_url, COALESCE(thumbnail_url, '') AS thumbnail_url, COALESCE(description, '') AS description, created_at
+		FROM videos WHERE user_id = ? ORDER BY created_at`, userID).Scan(&videos).Error; err != nil {
+		return nil, 0, err
+	}
+
+	link := func(bucket, key string) string {
+		if key == "" || strings.Contains(key, "://") {
+			return key
+		}
+		url, err := database.GeneratePresignedDownloadURL(ctx, bucket, key, expiresIn)
+		if err != nil {
+			return ""
+		}
+		return url
+	}
+
+	profile := make([]map[string]interface{}, 0, len(media))
+	for _, m := range media {
+		bucket := cfg.S3BucketPhotos
+		if m.MediaType == models.MediaTypeVideo {
+			bucket = cfg.S3BucketVideos
+		}
+		profile = append(profile, map[string]interface{}{
+			"id":                m.ID,
+			"media_type":        m.MediaType,
+			"url":               link(bucket, m.URL),
+			"thumbnail_url":     link(cfg.S3BucketPhotos, m.ThumbnailURL),
+			"display_order":     m.DisplayOrder,
+			"moderation_status": m.ModerationStatus,
+			"created_at":        m.CreatedAt,
+		})
+	}
+	posted := make([]map[string]interface{}, 0, len(videos))
+	for _, v := range videos {
+		posted = append(posted, map[string]interface{}{
+			"id":            v.ID,
+			"url":           link(cfg.S3BucketVideos, v.VideoURL),
+			"thumbnail_url": link(cfg.S3BucketPhotos, v.ThumbnailURL),
+			"description":   v.Description,
+			"created_at":    v.CreatedAt,
+		})
+	}
+
+	content, err := json.MarshalIndent(map[string]interface{}{
+		"profile_media": profile,
+		"videos":        posted,
+	}, "", "    ")
+	return content, len(profile) + len(posted), err
+}
+
+// countExportRecords counts the records of a file: the entries of a list, or
+// of each list in an object plus its other non-empty values
+func countExportRecords(content []byte) int {
+	var value interface{}
+	if err := json.Unmarshal(content, &value); err != nil {
+		return 0
+	}
+	switch v := value.(type) {
+	case []interface{}:
+		return len(v)
+	case map[string]interface{}:
+		count := 0
+		for _, field := range v {
+			switch f := field.(type) {
+			case []interface{}:
+				count += len(f)
+			case nil:
+			default:
+				count++
+			}
+		}
+		return count
+	}
+	return 0
+}
+
+// ExpireDataExports deletes the ZIPs of exports past their expiry
+func ExpireDataExports(ctx context.Context) {
+	db := database.DB.WithContext(ctx)
+	var expired []models.DataExport
+	if err := db.Where("status = ? AND expires_at <= ?", models.DataExportReady, time.Now()).
+		Limit(100).Find(&expired).Error; err != nil {
+		log.Printf("❌ Failed to list expired data exports: %v", err)
+		return
+	}
+	for _, export := range expired {
+		if export.ObjectKey != "" {
+			if _, err := database.DeleteObjects(ctx, config.Cfg.S3BucketGifts, []string{export.ObjectKey}); err != nil {
+				log.Printf("⚠️ Failed to delete expired data export %s: %v", export.ID, err)
+				continue
+			}
+		}
+		db.Model(&export).Updates(map[string]interface{}{
+			"status":     models.DataExportExpired,
+			"updated_at": time.Now(),
+		})
+	}
+	if len(expired) > 0 {
+		log.Printf("🧹 Expired %d data exports", len(expired))
+	}
+}
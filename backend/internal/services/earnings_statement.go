@@ -0,0 +1,355 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"lomi-backend/config"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/economy"
+	"lomi-backend/internal/models"
+	"lomi-backend/internal/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// ==================== EARNINGS STATEMENTS ====================
+// Monthly statement per creator: gifts received (by type, with distinct
+// senders), the platform fee at the economy config rate in force when the
+// month ended, payouts requested in the month and the earnings balance at
+// both ends of it.
+// The balance is gift value received minus payouts that weren't rejected.
+// Statements for last month are generated on the 1st and archived as CSV and
+// PDF in the gifts bucket; any finished month can be generated on demand.
+
+const statementGenerateInterval = 1 * time.Hour
+
+// StatementGiftLine is one gift type on a statement
+type StatementGiftLine struct {
+	GiftType string  `json:"gift_type"`
+	Count    int     `json:"count"`
+	Senders  int     `json:"senders"`
+	Coins    int64   `json:"coins"`
+	Birr     float64 `json:"birr"`
+}
+
+// StatementPayoutLine is one payout on a statement
+type StatementPayoutLine struct {
+	ID            uuid.UUID           `json:"id"`
+	CreatedAt     time.Time           `json:"created_at"`
+	Status        models.PayoutStatus `json:"status"`
+	PaymentMethod string              `json:"payment_method"`
+	Amount        float64             `json:"amount"`
+	Fee           float64             `json:"fee"`
+	Net           float64             `json:"net"`
+}
+
+// EarningsStatementData is a statement with its lines and rendered files
+type EarningsStatementData struct {
+	Statement   models.EarningsStatement `json:"statement"`
+	CreatorName string                   `json:"creator_name"`
+	Gifts       []StatementGiftLine      `json:"gifts"`
+	Payouts     []StatementPayoutLine    `json:"payouts"`
+	CSV         []byte                   `json:"-"`
+	PDF         []byte                   `json:"-"`
+}
+
+// StatementPeriod is the first day of the business month containing t
+func StatementPeriod(t time.Time) time.Time {
+	loc := reportLocation()
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+}
+
+// StatementKey is where a statement file is archived in the gifts bucket
+func StatementKey(userID uuid.UUID, period time.Time, ext string) string {
+	return fmt.Sprintf("statements/%s/%s.%s", userID, period.Format("2006-01"), ext)
+}
+
+// StartEarningsStatementGenerator generates last month's statements once the month is over
+func StartEarningsStatementGenerator() {
+	log.Printf("✅ Earnings statement generator started (every %s)", statementGenerateInterval)
+
+	ticker := time.NewTicker(statementGenerateInterval)
+	defer ticker.Stop()
+
+	var lastPeriod string
+	for {
+		period := StatementPeriod(time.Now()).AddDate(0, -1, 0)
+		if month := period.Format("2006-01"); month != lastPeriod {
+			if _, err := GenerateMonthlyStatements(context.Background(), period); err != nil {
+				log.Printf("❌ Earnings statements for %s failed: %v", month, err)
+			} else {
+				lastPeriod = month
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// GenerateMonthlyStatements generates the missing statements of a month for
+// every creator who received gifts or requested payouts in it
+func GenerateMonthlyStatements(ctx context.Context, period time.Time) (int, error) {
+	start := StatementPeriod(period)
+	end := start.AddDate(0, 1, 0)
+
+	var userIDs []uuid.UUID
+	if err := database.DB.WithContext(ctx).Raw(`
+		SELECT receiver_id FROM gift_transactions WHERE created_at >= ? AND created_at < ?
+		UNION
+		SELECT user_id FROM payouts WHERE created_at >= ? AND created_at < ?
+		EXCEPT
+		SELECT user_id FROM earnings_statements WHERE period = ?
+	`, start, end, start, end, start).Scan(&userIDs).Error; err != nil {
+		return 0, fmt.Errorf("failed to find creators: %w", err)
+	}
+
+	generated := 0
+	for _, userID := range userIDs {
+		if _, err := GenerateEarningsStatement(ctx, userID, start); err != nil {
+			log.Printf("❌ Earnings statement %s for %s failed: %v", start.Format("2006-01"), userID, err)
+			continue
+		}
+		generated++
+	}
+
+	if len(userIDs) > 0 {
+		log.Printf("🧾 Earnings statements for %s: %d of %d generated", start.Format("2006-01"), generated, len(userIDs))
+	}
+	return generated, nil
+}
+
+// GenerateEarningsStatement builds a creator's statement for a month, archives
+// the CSV and PDF and stores the summary (replacing an earlier one)
+func GenerateEarningsStatement(ctx context.Context, userID uuid.UUID, period time.Time) (*EarningsStatementData, error) {
+	data, err := buildEarningsStatement(ctx, userID, StatementPeriod(period))
+	if err != nil {
+		return nil, err
+	}
+	data.CSV = renderStatementCSV(data)
+	data.PDF = renderStatementPDF(data)
+
+	statement := &data.Statement
+	bucket := config.Cfg.S3BucketGifts
+	csvKey := StatementKey(userID, statement.Period, "csv")
+	pdfKey := StatementKey(userID, statement.Period, "pdf")
+	if err := database.UploadObject(ctx, bucket, csvKey, "text/csv", data.CSV); err != nil {
+		log.Printf("⚠️ Statement CSV not archived: %v", err)
+	} else {
+		statement.CSVKey = csvKey
+	}
+	if err := database.UploadObject(ctx, bucket, pdfKey, "application/pdf", data.PDF); err != nil {
+		log.Printf("⚠️ Statement PDF not archived: %v", err)
+	} else {
+		statement.PDFKey = pdfKey
+	}
+
+	statement.GeneratedAt = time.Now()
+	if err := database.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "period"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"gift_count", "sender_count", "gift_coins", "gross_birr",
+			"platform_fee_percentage", "platform_fee_birr", "net_birr",
+			"payout_count", "payouts_birr", "opening_balance_birr", "closing_balance_birr",
+			"csv_key", "pdf_key", "generated_at",
+		}),
+	}).Create(statement).Error; err != nil {
+		return nil, fmt.Errorf("failed to store statement: %w", err)
+	}
+	return data, nil
+}
+
+func buildEarningsStatement(ctx context.Context, userID uuid.UUID, start time.Time) (*EarningsStatementData, error) {
+	end := start.AddDate(0, 1, 0)
+	db := database.DB.WithContext(ctx)
+
+	var user models.User
+	if err := db.Select("id", "name").First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+
+	data := &EarningsStatementData{CreatorName: user.Name}
+	if err := db.Raw(`
+		SELECT COALESCE(NULLIF(gift_type, ''), 'other') AS gift_type,
+			COUNT(*) AS count,
+			COUNT(DISTINCT sender_id) AS senders,
+			COALESCE(SUM(coin_amount), 0) AS coins,
+			COALESCE(SUM(birr_value), 0) AS birr
+		FROM gift_transactions
+		WHERE receiver_id = ? AND created_at >= ? AND created_at < ?
+		GROUP BY 1
+		ORDER BY birr DESC
+	`, userID, start, end).Scan(&data.Gifts).Error; err != nil {
+		return nil, fmt.Errorf("failed to load gifts: %w", err)
+	}
+
+	pricing, err := economy.At(ctx, end.Add(-time.Nanosecond))
+	if err != nil {
+		return nil, err
+	}
+
+	statement := models.EarningsStatement{
+		UserID:                userID,
+		Period:                start,
+		PlatformFeePercentage: pricing.Params.PlatformFeePercentage,
+	}
+	for _, g := range data.Gifts {
+		statement.GiftCount += g.Count
+		statement.GiftCoins += g.Coins
+		statement.GrossBirr += g.Birr
+	}
+	if err := db.Raw(`
+		SELECT COUNT(DISTINCT sender_id) FROM gift_transactions
+		WHERE receiver_id = ? AND created_at >= ? AND created_at < ?
+	`, userID, start, end).Scan(&statement.SenderCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count senders: %w", err)
+	}
+	statement.PlatformFeeBirr = roundBirr(pricing.PlatformFee(statement.GrossBirr))
+	statement.NetBirr = roundBirr(statement.GrossBirr - statement.PlatformFeeBirr)
+
+	// Rejected payouts gave their funds back, so they don't count
+	var payouts []models.Payout
+	if err := db.Where("user_id = ? AND created_at >= ? AND created_at < ? AND status NOT IN ?",
+		userID, start, end, []models.PayoutStatus{models.PayoutStatusRejected, models.PayoutStatusRefunded}).
+		Order("created_at ASC").
+		Find(&payouts).Error; err != nil {
+		return nil, fmt.Errorf("failed to load payouts: %w", err)
+	}
+	for _, p := range payouts {
+		data.Payouts = append(data.Payouts, StatementPayoutLine{
+			ID:            p.ID,
+			CreatedAt:     p.CreatedAt,
+			Status:        p.Status,
+			PaymentMethod: string(p.PaymentMethod),
+			Amount:        p.GiftBalanceAmount,
+			Fee:           p.PlatformFeeAmount,
+			Net:           p.NetAmount,
+		})
+		statement.PayoutCount++
+		statement.PayoutsBirr += p.GiftBalanceAmount
+	}
+
+	if err := db.Raw(`
+		SELECT
+			COALESCE((SELECT SUM(birr_value) FROM gift_transactions WHERE receiver_id = ? AND created_at < ?), 0)
+			- COALESCE((SELECT SUM(gift_balance_amount) FROM payouts
+				WHERE user_id = ? AND created_at < ? AND status NOT IN ('rejected', 'refunded')), 0)
+	`, userID, start, userID, start).Scan(&statement.OpeningBalanceBirr).Error; err != nil {
+		return nil, fmt.Errorf("failed to compute opening balance: %w", err)
+	}
+	statement.ClosingBalanceBirr = roundBirr(statement.OpeningBalanceBirr + statement.GrossBirr - statement.PayoutsBirr)
+
+	data.Statement = statement
+	return data, nil
+}
+
+func roundBirr(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func birr(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// renderStatementCSV writes the statement as one CSV with a summary, gift and payout section
+func renderStatementCSV(data *EarningsStatementData) []byte {
+	s := data.Statement
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	w.Write([]string{"Lomi earnings statement"})
+	w.Write([]string{"creator", data.CreatorName})
+	w.Write([]string{"creator_id", s.UserID.String()})
+	w.Write([]string{"period", s.Period.Format("2006-01")})
+	w.Write([]string{"currency", "ETB"})
+	w.Write(nil)
+
+	w.Write([]string{"summary", "value"})
+	w.Write([]string{"opening_balance", birr(s.OpeningBalanceBirr)})
+	w.Write([]string{"gifts_received", strconv.Itoa(s.GiftCount)})
+	w.Write([]string{"distinct_senders", strconv.Itoa(s.SenderCount)})
+	w.Write([]string{"gift_coins", strconv.FormatInt(s.GiftCoins, 10)})
+	w.Write([]string{"gross_earnings", birr(s.GrossBirr)})
+	w.Write([]string{fmt.Sprintf("platform_fee_%d_percent", s.PlatformFeePercentage), birr(s.PlatformFeeBirr)})
+	w.Write([]string{"net_earnings", birr(s.NetBirr)})
+	w.Write([]string{"payouts", birr(s.PayoutsBirr)})
+	w.Write([]string{"closing_balance", birr(s.ClosingBalanceBirr)})
+	w.Write(nil)
+
+	w.Write([]string{"gift_type", "count", "senders", "coins", "gross_birr"})
+	for _, g := range data.Gifts {
+		w.Write([]string{g.GiftType, strconv.Itoa(g.Count), strconv.Itoa(g.Senders), strconv.FormatInt(g.Coins, 10), birr(g.Birr)})
+	}
+	w.Write(nil)
+
+	w.Write([]string{"payout_id", "date", "status", "payment_method", "amount_birr", "fee_birr", "net_birr"})
+	for _, p := range data.Payouts {
+		w.Write([]string{p.ID.String(), p.CreatedAt.In(reportLocation()).Format("2006-01-02"), string(p.Status),
+			p.PaymentMethod, birr(p.Amount), birr(p.Fee), birr(p.Net)})
+	}
+
+	w.Flush()
+	return buf.Bytes()
+}
+
+// renderStatementPDF lays out the same statement as a printable PDF
+func renderStatementPDF(data *EarningsStatementData) []byte {
+	s := data.Statement
+	pdf := utils.NewPDF()
+
+	pdf.Line("Lomi - Earnings Statement", 18, true)
+	pdf.Line(s.Period.Format("January 2006"), 12, false)
+	pdf.Space(6)
+	pdf.Line("Creator: "+data.CreatorName, 10, false)
+	pdf.Line("Creator ID: "+s.UserID.String(), 10, false)
+	pdf.Line("Generated: "+time.Now().In(reportLocation()).Format("2006-01-02 15:04"), 10, false)
+	pdf.Space(8)
+
+	summary := []float64{0, 330}
+	pdf.Line("Summary (ETB)", 12, true)
+	pdf.Rule()
+	pdf.Row([]string{"Opening balance", birr(s.OpeningBalanceBirr)}, summary, 10, false)
+	pdf.Row([]string{fmt.Sprintf("Gifts received (%d from %d senders, %d coins)", s.GiftCount, s.SenderCount, s.GiftCoins), birr(s.GrossBirr)}, summary, 10, false)
+	pdf.Row([]string{fmt.Sprintf("Platform fee (%d%%)", s.PlatformFeePercentage), "-" + birr(s.PlatformFeeBirr)}, summary, 10, false)
+	pdf.Row([]string{"Net earnings", birr(s.NetBirr)}, summary, 10, true)
+	pdf.Row([]string{fmt.Sprintf("Payouts (%d)", s.PayoutCount), "-" + birr(s.PayoutsBirr)}, summary, 10, false)
+	pdf.Row([]string{"Closing balance", birr(s.ClosingBalanceBirr)}, summary, 10, true)
+	pdf.Space(10)
+
+	gifts := []float64{0, 160, 230, 300, 380}
+	pdf.Line("Gifts by type", 12, true)
+	pdf.Rule()
+	pdf.Row([]string{"Gift", "Count", "Senders", "Coins", "Gross (ETB)"}, gifts, 9, true)
+	for _, g := range data.Gifts {
+		pdf.Row([]string{g.GiftType, strconv.Itoa(g.Count), strconv.Itoa(g.Senders), strconv.FormatInt(g.Coins, 10), birr(g.Birr)}, gifts, 9, false)
+	}
+	if len(data.Gifts) == 0 {
+		pdf.Line("No gifts this month", 9, false)
+	}
+	pdf.Space(10)
+
+	payouts := []float64{0, 80, 150, 230, 310, 390}
+	pdf.Line("Payouts", 12, true)
+	pdf.Rule()
+	pdf.Row([]string{"Date", "Status", "Method", "Amount", "Fee", "Net (ETB)"}, payouts, 9, true)
+	for _, p := range data.Payouts {
+		pdf.Row([]string{p.CreatedAt.In(reportLocation()).Format("2006-01-02"), string(p.Status), p.PaymentMethod,
+			birr(p.Amount), birr(p.Fee), birr(p.Net)}, payouts, 9, false)
+	}
+	if len(data.Payouts) == 0 {
+		pdf.Line("No payouts this month", 9, false)
+	}
+
+	pdf.Space(16)
+	pdf.Line("Amounts in Ethiopian Birr. Gift value is credited at the gift's ETB value; the platform fee", 8, false)
+	pdf.Line("is deducted when earnings are paid out.", 8, false)
+
+	return pdf.Bytes()
+}
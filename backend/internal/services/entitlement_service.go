@@ -0,0 +1,133 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==================== GIFT ENTITLEMENTS ====================
+// Gifts with HasSpecialEffect grant the receiver a cosmetic (profile frame,
+// crown badge, highlighted swipe card) for SpecialEffectDurationDays.
+// Reads always filter on expires_at, so the sweeper only has to keep
+// is_active tidy for the one-active-per-type unique index.
+
+const (
+	entitlementSweepInterval = 1 * time.Minute
+	maxEntitlementGrantDays  = 10 * 365 // One grant never adds more than this
+)
+
+// GrantGiftEntitlement grants or extends the receiver's entitlement for a gift.
+// It must run inside the gift transaction so the effect and the coins move together.
+// count multiplies the duration (e.g. 3x the same gift = 3x the days).
+func GrantGiftEntitlement(tx *gorm.DB, gift *models.Gift, giftTx *models.GiftTransaction, count int) (*models.UserEntitlement, error) {
+	if !gift.HasSpecialEffect || !gift.EffectType.IsValid() || gift.SpecialEffectDurationDays <= 0 {
+		return nil, nil
+	}
+	if count <= 0 {
+		count = 1
+	}
+	if count > MaxGiftCount {
+		return nil, fmt.Errorf("gift count %d is over the limit of %d", count, MaxGiftCount)
+	}
+
+	now := time.Now()
+	days := min(int64(gift.SpecialEffectDurationDays)*int64(count), maxEntitlementGrantDays)
+	duration := time.Duration(days) * 24 * time.Hour
+
+	var entitlement models.UserEntitlement
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND entitlement_type = ? AND is_active = ?", giftTx.ReceiverID, gift.EffectType, true).
+		First(&entitlement).Error
+
+	switch {
+	case err == nil && entitlement.ExpiresAt.After(now):
+		// Still running: stack the new duration on top
+		entitlement.ExpiresAt = entitlement.ExpiresAt.Add(duration)
+	case err == nil:
+		// Expired but not swept yet: restart it
+		entitlement.StartsAt = now
+		entitlement.ExpiresAt = now.Add(duration)
+	case err == gorm.ErrRecordNotFound:
+		entitlement = models.UserEntitlement{
+			UserID:          giftTx.ReceiverID,
+			EntitlementType: gift.EffectType,
+			StartsAt:        now,
+			ExpiresAt:       now.Add(duration),
+			IsActive:        true,
+		}
+	default:
+		return nil, fmt.Errorf("failed to load entitlement: %w", err)
+	}
+
+	entitlement.SourceGiftID = &gift.ID
+	entitlement.SourceGiftTransactionID = &giftTx.ID
+	entitlement.GrantedByID = &giftTx.SenderID
+	entitlement.UpdatedAt = now
+
+	if err := tx.Save(&entitlement).Error; err != nil {
+		return nil, fmt.Errorf("failed to save entitlement: %w", err)
+	}
+
+	log.Printf("✨ Entitlement %s for %s until %s (gift %s)",
+		entitlement.EntitlementType, entitlement.UserID, entitlement.ExpiresAt.Format(time.RFC3339), gift.Slug)
+	return &entitlement, nil
+}
+
+// PreloadActiveEntitlements is a GORM scope that preloads unexpired entitlements on users
+func PreloadActiveEntitlements(db *gorm.DB) *gorm.DB {
+	return db.Preload("Entitlements", "is_active = ? AND expires_at > ?", true, time.Now())
+}
+
+// GetActiveEntitlements returns unexpired entitlements for the given users, keyed by user
+func GetActiveEntitlements(userIDs []uuid.UUID) (map[uuid.UUID][]models.UserEntitlement, error) {
+	result := make(map[uuid.UUID][]models.UserEntitlement)
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+
+	var entitlements []models.UserEntitlement
+	if err := database.DB.
+		Where("user_id IN ? AND is_active = ? AND expires_at > ?", userIDs, true, time.Now()).
+		Find(&entitlements).Error; err != nil {
+		return nil, err
+	}
+
+	for _, e := range entitlements {
+		result[e.UserID] = append(result[e.UserID], e)
+	}
+	return result, nil
+}
+
+// StartEntitlementSweeper deactivates expired entitlements periodically
+func StartEntitlementSweeper() {
+	log.Printf("✅ Entitlement sweeper started (every %s)", entitlementSweepInterval)
+
+	ticker := time.NewTicker(entitlementSweepInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		SweepExpiredEntitlements()
+	}
+}
+
+// SweepExpiredEntitlements marks every expired entitlement inactive
+func SweepExpiredEntitlements() {
+	result := database.DB.Model(&models.UserEntitlement{}).
+		Where("is_active = ? AND expires_at <= ?", true, time.Now()).
+		Updates(map[string]interface{}{"is_active": false, "updated_at": time.Now()})
+	if result.Error != nil {
+		log.Printf("❌ Entitlement sweep failed: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("🧹 Expired %d entitlements", result.RowsAffected)
+	}
+}
@@ -0,0 +1,9 @@
+package services
+
+// Test helpers for the services_test package, whose tests go through the HTTP
+// handlers (which import this package)
+var (
+	TestDB         = testDB
+	CreateTestUser = createTestUser
+	AccountBalance = accountBalance
+)
@@ -0,0 +1,346 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/economy"
+	"lomi-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ==================== GIFT CATALOG ====================
+// Single source of truth for gifts: the gifts table.
+// Active gifts are cached in Redis as one JSON list; availability windows are
+// checked at read time so seasonal gifts appear/disappear without cache flushes.
+
+const (
+	giftCatalogCacheKey = "gifts:catalog"
+	giftCatalogCacheTTL = 10 * time.Minute
+
+	// MaxGiftCount is the most of one gift that can be sent at once
+	MaxGiftCount = 999
+)
+
+var (
+	ErrGiftNotFound    = errors.New("gift not found")
+	ErrGiftUnavailable = errors.New("gift is not available")
+	ErrGiftSlugTaken   = errors.New("a gift with this slug already exists")
+)
+
+// GiftValidationError is a gift create/update the admin has to fix
+type GiftValidationError struct {
+	Reason string
+}
+
+func (e *GiftValidationError) Error() string { return e.Reason }
+
+func invalidGift(reason string) error {
+	return &GiftValidationError{Reason: reason}
+}
+
+// GiftCatalogService resolves gifts for the shop and the gift-sending endpoints
+type GiftCatalogService struct {
+	db *gorm.DB
+}
+
+var GiftCatalogSvc *GiftCatalogService
+
+func InitGiftCatalogService(db *gorm.DB) {
+	GiftCatalogSvc = &GiftCatalogService{db: db}
+}
+
+// ListAvailable returns gifts that can be sent right now, in display order
+func (s *GiftCatalogService) ListAvailable(ctx context.Context) ([]models.Gift, error) {
+	gifts, err := s.activeGifts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	available := make([]models.Gift, 0, len(gifts))
+	for _, gift := range gifts {
+		if gift.IsAvailableAt(now) {
+			available = append(available, gift)
+		}
+	}
+	return available, nil
+}
+
+// GetByID returns a gift that can be sent right now
+func (s *GiftCatalogService) GetByID(ctx context.Context, id uuid.UUID) (*models.Gift, error) {
+	return s.find(ctx, func(g *models.Gift) bool { return g.ID == id })
+}
+
+// GetBySlug returns a gift by its slug (the legacy gift_type) that can be sent right now
+func (s *GiftCatalogService) GetBySlug(ctx context.Context, slug string) (*models.Gift, error) {
+	return s.find(ctx, func(g *models.Gift) bool { return g.Slug == slug })
+}
+
+func (s *GiftCatalogService) find(ctx context.Context, match func(*models.Gift) bool) (*models.Gift, error) {
+	gifts, err := s.activeGifts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range gifts {
+		if match(&gifts[i]) {
+			if !gifts[i].IsAvailableAt(time.Now()) {
+				return nil, ErrGiftUnavailable
+			}
+			gift := gifts[i]
+			return &gift, nil
+		}
+	}
+	return nil, ErrGiftNotFound
+}
+
+// activeGifts loads all active gifts (any availability window), cache first
+func (s *GiftCatalogService) activeGifts(ctx context.Context) ([]models.Gift, error) {
+	if database.RedisClient != nil {
+		cached, err := database.RedisClient.Get(ctx, giftCatalogCacheKey).Bytes()
+		if err == nil {
+			var gifts []models.Gift
+			if err := json.Unmarshal(cached, &gifts); err == nil {
+				return gifts, nil
+			}
+		}
+	}
+
+	var gifts []models.Gift
+	if err := s.db.WithContext(ctx).
+		Where("is_active = ?", true).
+		Order("display_order ASC, created_at ASC").
+		Find(&gifts).Error; err != nil {
+		return nil, fmt.Errorf("failed to load gift catalog: %w", err)
+	}
+
+	if database.RedisClient != nil {
+		if data, err := json.Marshal(gifts); err == nil {
+			database.RedisClient.Set(ctx, giftCatalogCacheKey, data, giftCatalogCacheTTL)
+		}
+	}
+	return gifts, nil
+}
+
+// InvalidateCache drops the cached catalog so the next read hits the database
+func (s *GiftCatalogService) InvalidateCache(ctx context.Context) {
+	if database.RedisClient == nil {
+		return
+	}
+	if err := database.RedisClient.Del(ctx, giftCatalogCacheKey).Err(); err != nil {
+		log.Printf("⚠️ Failed to invalidate gift catalog cache: %v", err)
+	}
+}
+
+// ==================== ADMIN ====================
+
+// GiftInput is the admin create/update payload. Nil fields are left unchanged on update.
+type GiftInput struct {
+	Slug                      *string    `json:"slug"`
+	NameEn                    *string    `json:"name_en"`
+	NameAm                    *string    `json:"name_am"`
+	DescriptionEn             *string    `json:"description_en"`
+	DescriptionAm             *string    `json:"description_am"`
+	CoinPrice                 *int       `json:"coin_price"`
+	BirrValue                 *float64   `json:"birr_value"`
+	IconURL                   *string    `json:"icon_url"`
+	AnimationURL              *string    `json:"animation_url"`
+	SoundURL                  *string    `json:"sound_url"`
+	HasSpecialEffect          *bool      `json:"has_special_effect"`
+	SpecialEffectDurationDays *int       `json:"special_effect_duration_days"`
+	EffectType                *string    `json:"effect_type"`
+	IsActive                  *bool      `json:"is_active"`
+	IsFeatured                *bool      `json:"is_featured"`
+	DisplayOrder              *int       `json:"display_order"`
+	AvailableFrom             *time.Time `json:"available_from"`
+	AvailableUntil            *time.Time `json:"available_until"`
+	ClearAvailability         bool       `json:"clear_availability"` // Remove both window bounds
+}
+
+// ListAll returns every gift including inactive ones (admin view, uncached)
+func (s *GiftCatalogService) ListAll(ctx context.Context) ([]models.Gift, error) {
+	var gifts []models.Gift
+	err := s.db.WithContext(ctx).Order("display_order ASC, created_at ASC").Find(&gifts).Error
+	return gifts, err
+}
+
+// Create adds a gift to the catalog
+func (s *GiftCatalogService) Create(ctx context.Context, input GiftInput) (*models.Gift, error) {
+	pricing, err := economy.Current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	gift := models.Gift{IsActive: true}
+	applyGiftInput(&gift, input, pricing)
+
+	if gift.Slug == "" || gift.NameEn == "" || gift.NameAm == "" || gift.IconURL == "" || gift.AnimationURL == "" {
+		return nil, invalidGift("slug, name_en, name_am, icon_url and animation_url are required")
+	}
+	if err := validateGift(&gift); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.WithContext(ctx).Create(&gift).Error; err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrGiftSlugTaken
+		}
+		return nil, fmt.Errorf("failed to create gift: %w", err)
+	}
+
+	s.InvalidateCache(ctx)
+	log.Printf("🎁 Gift created: %s (%s, %d LC)", gift.Slug, gift.ID, gift.CoinPrice)
+	return &gift, nil
+}
+
+// Update changes a gift. Price changes only affect gifts sent afterwards.
+func (s *GiftCatalogService) Update(ctx context.Context, id uuid.UUID, input GiftInput) (*models.Gift, error) {
+	var gift models.Gift
+	if err := s.db.WithContext(ctx).First(&gift, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGiftNotFound
+		}
+		return nil, err
+	}
+
+	pricing, err := economy.Current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	applyGiftInput(&gift, input, pricing)
+	if err := validateGift(&gift); err != nil {
+		return nil, err
+	}
+
+	gift.UpdatedAt = time.Now()
+	if err := s.db.WithContext(ctx).Save(&gift).Error; err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrGiftSlugTaken
+		}
+		return nil, fmt.Errorf("failed to update gift: %w", err)
+	}
+
+	s.InvalidateCache(ctx)
+	log.Printf("🎁 Gift updated: %s (%s)", gift.Slug, gift.ID)
+	return &gift, nil
+}
+
+// Deactivate hides a gift from the shop. Gifts are never deleted because
+// gift_transactions reference them.
+func (s *GiftCatalogService) Deactivate(ctx context.Context, id uuid.UUID) error {
+	result := s.db.WithContext(ctx).Model(&models.Gift{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"is_active": false, "updated_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrGiftNotFound
+	}
+
+	s.InvalidateCache(ctx)
+	log.Printf("🎁 Gift deactivated: %s", id)
+	return nil
+}
+
+// applyGiftInput copies the set fields onto gift. A new coin price without a
+// birr value is valued at the economy config's coin rate.
+func applyGiftInput(gift *models.Gift, input GiftInput, pricing *models.EconomyConfig) {
+	if input.Slug != nil {
+		gift.Slug = strings.ToLower(strings.TrimSpace(*input.Slug))
+	}
+	if input.NameEn != nil {
+		gift.NameEn = *input.NameEn
+	}
+	if input.NameAm != nil {
+		gift.NameAm = *input.NameAm
+	}
+	if input.DescriptionEn != nil {
+		gift.DescriptionEn = *input.DescriptionEn
+	}
+	if input.DescriptionAm != nil {
+		gift.DescriptionAm = *input.DescriptionAm
+	}
+	if input.CoinPrice != nil {
+		gift.CoinPrice = *input.CoinPrice
+		if input.BirrValue == nil {
+			gift.BirrValue = pricing.CoinsToBirr(gift.CoinPrice)
+		}
+	}
+	if input.BirrValue != nil {
+		gift.BirrValue = *input.BirrValue
+	}
+	if input.IconURL != nil {
+		gift.IconURL = *input.IconURL
+	}
+	if input.AnimationURL != nil {
+		gift.AnimationURL = *input.AnimationURL
+	}
+	if input.SoundURL != nil {
+		gift.SoundURL = *input.SoundURL
+	}
+	if input.HasSpecialEffect != nil {
+		gift.HasSpecialEffect = *input.HasSpecialEffect
+	}
+	if input.SpecialEffectDurationDays != nil {
+		gift.SpecialEffectDurationDays = *input.SpecialEffectDurationDays
+	}
+	if input.EffectType != nil {
+		gift.EffectType = models.EntitlementType(*input.EffectType)
+	}
+	if input.IsActive != nil {
+		gift.IsActive = *input.IsActive
+	}
+	if input.IsFeatured != nil {
+		gift.IsFeatured = *input.IsFeatured
+	}
+	if input.DisplayOrder != nil {
+		gift.DisplayOrder = *input.DisplayOrder
+	}
+	if input.ClearAvailability {
+		gift.AvailableFrom = nil
+		gift.AvailableUntil = nil
+	}
+	if input.AvailableFrom != nil {
+		gift.AvailableFrom = input.AvailableFrom
+	}
+	if input.AvailableUntil != nil {
+		gift.AvailableUntil = input.AvailableUntil
+	}
+}
+
+func validateGift(gift *models.Gift) error {
+	if gift.Slug == "" {
+		return invalidGift("slug cannot be empty")
+	}
+	if gift.CoinPrice <= 0 {
+		return invalidGift("coin_price must be positive")
+	}
+	if gift.BirrValue <= 0 {
+		return invalidGift("birr_value must be positive")
+	}
+	if gift.AvailableFrom != nil && gift.AvailableUntil != nil && !gift.AvailableFrom.Before(*gift.AvailableUntil) {
+		return invalidGift("available_from must be before available_until")
+	}
+	if gift.SpecialEffectDurationDays < 0 {
+		return invalidGift("special_effect_duration_days cannot be negative")
+	}
+	if gift.HasSpecialEffect {
+		if !gift.EffectType.IsValid() {
+			return invalidGift("effect_type must be profile_frame, crown_badge or highlighted_card")
+		}
+		if gift.SpecialEffectDurationDays == 0 {
+			return invalidGift("special_effect_duration_days is required for gifts with an effect")
+		}
+	} else if gift.EffectType != "" && !gift.EffectType.IsValid() {
+		return invalidGift("invalid effect_type")
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package services_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"lomi-backend/config"
+	"lomi-backend/internal/auth"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/handlers"
+	"lomi-backend/internal/ledger"
+	"lomi-backend/internal/models"
+	"lomi-backend/internal/repositories"
+	"lomi-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Same database requirements as payout_service_test.go. These tests go through
+// the HTTP handlers, so they live in the external test package.
+
+func createTestGift(t *testing.T, db *gorm.DB, coinPrice int) *models.Gift {
+	t.Helper()
+	slug := "test_" + uuid.NewString()[:8]
+	gift := models.Gift{
+		Slug:         slug,
+		NameEn:       "Test " + slug,
+		NameAm:       "Test " + slug,
+		CoinPrice:    coinPrice,
+		BirrValue:    float64(coinPrice) / 10,
+		IconURL:      "https://example.com/" + slug + ".png",
+		AnimationURL: "https://example.com/" + slug + ".json",
+		IsActive:     true,
+	}
+	if err := db.Create(&gift).Error; err != nil {
+		t.Fatalf("create gift: %v", err)
+	}
+	// Registered before the users are created, so it runs after their cleanup
+	// has removed the gift transactions
+	t.Cleanup(func() {
+		if err := db.Delete(&models.Gift{}, "id = ?", gift.ID).Error; err != nil {
+			t.Errorf("delete gift %s: %v", gift.ID, err)
+		}
+	})
+	return &gift
+}
+
+// SendGiftLuxury, the legacy /api/sendGift and wallet withdrawals all spend
+// one balance that can't cover them all. Whatever wins, the balance never goes
+// negative and every coin ends up with the receiver, in a withdrawal hold or
+// still with the sender.
+func TestGiftHandlersConcurrentWithWithdrawals(t *testing.T) {
+	db := services.TestDB(t)
+	ctx := context.Background()
+
+	const (
+		startBalance    = 1000
+		workers         = 10
+		giftPrice       = 30
+		streamGiftCount = 2  // Each /api/sendGift costs giftPrice * streamGiftCount
+		withdrawCoins   = 40 // workers * (30 + 60 + 40) = 1300 > startBalance
+	)
+
+	gift := createTestGift(t, db, giftPrice)
+	sender := services.CreateTestUser(t, db)
+	receiver := services.CreateTestUser(t, db)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		_, err := ledger.Post(ctx, ledger.Gorm(tx), ledger.Entry{
+			Type:  ledger.EntryCredit,
+			Lines: ledger.Transfer(ledger.System(ledger.SystemPlatformCredits), ledger.UserCoins(sender), startBalance),
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("fund sender: %v", err)
+	}
+
+	walletRepo := repositories.NewWalletRepository(database.SqlxDB)
+	if _, err := walletRepo.GetOrCreateWallet(ctx, sender.String()); err != nil {
+		t.Fatalf("create wallet: %v", err)
+	}
+	walletSvc := services.NewWalletService(walletRepo)
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		auth.SetClaims(c, &auth.Claims{UserID: sender})
+		return c.Next()
+	})
+	app.Post("/gifts/send", handlers.SendGiftLuxury)
+	app.Post("/api/sendGift", handlers.NewStreamingHandler(config.Cfg).SendGift)
+
+	post := func(path string, body fiber.Map) (int, []byte, error) {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			return 0, nil, err
+		}
+		defer resp.Body.Close()
+		respBody, err := io.ReadAll(resp.Body)
+		return resp.StatusCode, respBody, err
+	}
+
+	var luxuryGifts, streamGifts, withdrawals atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			status, body, err := post("/gifts/send", fiber.Map{"receiver_id": receiver, "gift_id": gift.ID})
+			switch {
+			case err != nil:
+				t.Errorf("luxury gift: %v", err)
+			case status == fiber.StatusCreated:
+				luxuryGifts.Add(1)
+			case status != fiber.StatusBadRequest || !strings.Contains(string(body), "Insufficient coins"):
+				t.Errorf("luxury gift: %d %s", status, body)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			status, body, err := post("/api/sendGift", fiber.Map{
+				"receiver_id": receiver.String(),
+				"gift_id":     gift.ID.String(),
+				"gift_count":  streamGiftCount,
+			})
+			if err != nil {
+				t.Errorf("stream gift: %v", err)
+				return
+			}
+			var resp struct {
+				Code int `json:"code"`
+			}
+			if status != fiber.StatusOK || json.Unmarshal(body, &resp) != nil {
+				t.Errorf("stream gift: %d %s", status, body)
+				return
+			}
+			switch resp.Code {
+			case 200:
+				streamGifts.Add(1)
+			case 201: // Not enough coins
+			default:
+				t.Errorf("stream gift: %s", body)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := walletSvc.RequestWithdrawal(ctx, sender.String(), &models.WithdrawRequest{
+				Amount:           withdrawCoins,
+				WithdrawalMethod: "telebirr",
+				AccountDetails:   models.JSONB{"phone_number": "0911000000"},
+			})
+			switch {
+			case err == nil:
+				withdrawals.Add(1)
+			case err.Error() != "insufficient balance":
+				t.Errorf("withdrawal: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	gifted := luxuryGifts.Load()*giftPrice + streamGifts.Load()*giftPrice*streamGiftCount
+	spent := gifted + withdrawals.Load()*withdrawCoins
+	if spent > startBalance {
+		t.Fatalf("spent %d coins out of %d", spent, startBalance)
+	}
+
+	senderBalance := services.AccountBalance(t, db, ledger.UserCoins(sender).Code)
+	receiverBalance := services.AccountBalance(t, db, ledger.UserCoins(receiver).Code)
+	if senderBalance < 0 || receiverBalance < 0 {
+		t.Fatalf("negative balance: sender %d, receiver %d", senderBalance, receiverBalance)
+	}
+	if want := startBalance - spent; senderBalance != want {
+		t.Errorf("sender balance = %d, want %d", senderBalance, want)
+	}
+	if receiverBalance != gifted {
+		t.Errorf("receiver balance = %d, want %d", receiverBalance, gifted)
+	}
+
+	var withdrawn int64
+	db.Raw(`SELECT COALESCE(SUM(amount), 0)::BIGINT FROM withdrawal_requests WHERE user_id = ? AND status = ?`,
+		sender, models.WithdrawalStatusPending).Scan(&withdrawn)
+	if want := withdrawals.Load() * withdrawCoins; withdrawn != want {
+		t.Errorf("%d coins in withdrawal requests, want %d", withdrawn, want)
+	}
+	if total := senderBalance + receiverBalance + withdrawn; total != startBalance {
+		t.Errorf("sender + receiver + withdrawn = %d, want %d", total, startBalance)
+	}
+
+	var giftTxs int64
+	db.Model(&models.GiftTransaction{}).Where("sender_id = ?", sender).Count(&giftTxs)
+	if want := luxuryGifts.Load() + streamGifts.Load(); giftTxs != want {
+		t.Errorf("%d gift transactions, %d gifts succeeded", giftTxs, want)
+	}
+
+	for _, id := range []uuid.UUID{sender, receiver} {
+		var coinBalance int64
+		db.Model(&models.User{}).Where("id = ?", id).Select("coin_balance").Scan(&coinBalance)
+		if ledgerBalance := services.AccountBalance(t, db, ledger.UserCoins(id).Code); coinBalance != ledgerBalance {
+			t.Errorf("users.coin_balance of %s = %d, ledger says %d", id, coinBalance, ledgerBalance)
+		}
+	}
+
+	report, err := ledger.Verify(ctx, ledger.Gorm(db))
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if report.TrialBalance[ledger.CurrencyCoins] != 0 {
+		t.Errorf("trial balance = %d, want 0", report.TrialBalance[ledger.CurrencyCoins])
+	}
+	for _, drift := range report.ProjectionDrift {
+		if drift.UserID == sender || drift.UserID == receiver {
+			t.Errorf("projection drift: %+v", drift)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"lomi-backend/internal/auth"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ==================== IMPERSONATION ====================
+// Support admins can act as a user to reproduce an issue. Each impersonation
+// needs a reason and issues one access token (no refresh token) that expires
+// within the hour and carries impersonator_id. Requests made with it are
+// written to admin_audit_logs by the auth middleware, and a few account and
+// money actions refuse it (middleware.NoImpersonation).
+
+const (
+	impersonationDefaultTTL = 15 * time.Minute
+	impersonationMaxTTL     = time.Hour
+)
+
+var (
+	ErrImpersonationReason   = errors.New("a reason is required to impersonate a user")
+	ErrImpersonateAdmin      = errors.New("admin accounts can't be impersonated")
+	ErrImpersonationNotFound = errors.New("impersonation not found or already ended")
+)
+
+// ImpersonationResult is a started impersonation and its token
+type ImpersonationResult struct {
+	Impersonation models.AdminImpersonation
+	AccessToken   string
+}
+
+// StartImpersonation issues an access token for an admin to act as a user.
+// ttl is capped at an hour; zero means 15 minutes.
+func StartImpersonation(ctx context.Context, adminID, userID uuid.UUID, reason string, ttl time.Duration, ip string) (*ImpersonationResult, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrImpersonationReason
+	}
+	if ttl <= 0 {
+		ttl = impersonationDefaultTTL
+	}
+	if ttl > impersonationMaxTTL {
+		ttl = impersonationMaxTTL
+	}
+
+	db := database.DB.WithContext(ctx)
+	var user models.User
+	if err := db.Select("id", "role").First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	// Acting as a staff member's own account would borrow their access
+	var staff int64
+	if err := db.Model(&models.AdminUser{}).Where("user_id = ?", userID).Count(&staff).Error; err != nil {
+		return nil, err
+	}
+	if staff > 0 {
+		return nil, ErrImpersonateAdmin
+	}
+
+	impersonation := models.AdminImpersonation{
+		ID:          uuid.New(),
+		AdminUserID: adminID,
+		UserID:      userID,
+		Reason:      reason,
+		AccessUUID:  uuid.New(),
+		ExpiresAt:   time.Now().Add(ttl),
+		IPAddress:   ip,
+	}
+	token, err := auth.CreateImpersonationToken(user.ID, user.Role, adminID, impersonation.ID, impersonation.AccessUUID, impersonation.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Create(&impersonation).Error; err != nil {
+		return nil, err
+	}
+
+	log.Printf("🕵️ Impersonation started: admin=%s user=%s until=%s reason=%q",
+		adminID, userID, impersonation.ExpiresAt.Format(time.RFC3339), reason)
+	return &ImpersonationResult{Impersonation: impersonation, AccessToken: token}, nil
+}
+
+// EndImpersonation revokes an impersonation token before it expires
+func EndImpersonation(ctx context.Context, id uuid.UUID) error {
+	var impersonation models.AdminImpersonation
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND ended_at IS NULL AND expires_at > ?", id, time.Now()).
+			First(&impersonation).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrImpersonationNotFound
+			}
+			return err
+		}
+		return tx.Model(&impersonation).Update("ended_at", time.Now()).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if database.RedisClient != nil {
+		ttl := time.Until(impersonation.ExpiresAt)
+		if err := database.RedisClient.Set(ctx, auth.RevokedAccessKey(impersonation.AccessUUID), 1, ttl).Err(); err != nil {
+			log.Printf("⚠️ Failed to cache ended impersonation %s: %v", id, err)
+		}
+	}
+	log.Printf("🕵️ Impersonation ended: id=%s user=%s", id, impersonation.UserID)
+	return nil
+}
+
+// ListImpersonations returns recent impersonations, newest first. A nil
+// userID lists every user's.
+func ListImpersonations(ctx context.Context, userID *uuid.UUID, limit int) ([]models.AdminImpersonation, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	query := database.DB.WithContext(ctx).Model(&models.AdminImpersonation{})
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+	var impersonations []models.AdminImpersonation
+	err := query.Order("created_at DESC").Limit(limit).Find(&impersonations).Error
+	return impersonations, err
+}
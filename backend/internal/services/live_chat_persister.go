@@ -0,0 +1,599 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"lomi-backend/config"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==================== LIVE CHAT PERSISTER ====================
+// Live messages are written to Redis streams (live:<id>:history) by the chat hub.
+// This worker drains them into PostgreSQL:
+// - One consumer group per stream, entries are only XACKed after the batch commits
+// - Inserts are idempotent on message_id, so redelivered entries are harmless
+// - When a batch fails its rows are inserted one by one; rows Postgres rejects
+//   (bad stream or gift ID, ...) go to a dead-letter stream and are acknowledged,
+//   so one bad message can't hold back its stream
+// - Streams are trimmed by MINID (retention) and MAXLEN (once fully read and acknowledged)
+
+const (
+	// LiveChatActiveStreamsKey is the set of history stream keys with live traffic
+	LiveChatActiveStreamsKey = "live:active_streams"
+
+	liveChatConsumerGroup  = "persister"
+	liveChatRetention      = 24 * time.Hour  // Same as the history key expiry
+	liveChatClaimIdle      = 1 * time.Minute // Reclaim entries from dead consumers
+	liveChatReadBlock      = 1 * time.Second
+	liveChatStreamsPerRead = 100 // Max stream keys per XREADGROUP call
+
+	// LiveChatDeadLetterKey keeps entries Postgres rejected, with the error
+	LiveChatDeadLetterKey = "live:chat:dead_letter"
+	liveChatDeadLetterMax = 10000
+)
+
+// LiveChatPersisterStats is exposed to the admin dashboard
+type LiveChatPersisterStats struct {
+	Running       bool                  `json:"running"`
+	Consumer      string                `json:"consumer"`
+	ActiveStreams int                   `json:"active_streams"`
+	TotalPending  int64                 `json:"total_pending"`
+	TotalLag      int64                 `json:"total_lag"`
+	Persisted     int64                 `json:"persisted"`
+	Batches       int64                 `json:"batches"`
+	Failures      int64                 `json:"failures"`
+	Dropped       int64                 `json:"dropped"`
+	DeadLettered  int64                 `json:"dead_lettered"`
+	LastFlushAt   *time.Time            `json:"last_flush_at"`
+	LastError     string                `json:"last_error,omitempty"`
+	Streams       []LiveChatStreamStats `json:"streams"`
+}
+
+type LiveChatStreamStats struct {
+	LiveStreamID  string `json:"live_stream_id"`
+	Length        int64  `json:"length"`
+	Pending       int64  `json:"pending"`
+	Lag           int64  `json:"lag"`
+	LastDelivered string `json:"last_delivered_id"`
+}
+
+var liveChatPersister = struct {
+	sync.Mutex
+	running     bool
+	consumer    string
+	persisted   int64
+	batches     int64
+	failures    int64
+	dropped     int64
+	deadLetters int64
+	lastFlushAt *time.Time
+	lastError   string
+	groups      map[string]bool // streams we've already created the group on
+}{groups: make(map[string]bool)}
+
+// liveChatEntry mirrors the fields of the chat hub's WSChatMessage that are persisted
+type liveChatEntry struct {
+	Type         string                 `json:"type"`
+	LiveStreamID string                 `json:"live_stream_id"`
+	Seq          int64                  `json:"seq"`
+	IsPinned     bool                   `json:"is_pinned"`
+	IsSystem     bool                   `json:"is_system"`
+	MessageID    string                 `json:"message_id"`
+	Content      interface{}            `json:"content"`
+	MessageType  string                 `json:"message_type"`
+	MediaURL     string                 `json:"media_url"`
+	GiftID       string                 `json:"gift_id"`
+	SenderID     string                 `json:"sender_id"`
+	Metadata     map[string]interface{} `json:"metadata"`
+}
+
+// LiveChatHistoryKey returns the Redis stream key for a live stream's history
+func LiveChatHistoryKey(liveStreamID string) string {
+	return fmt.Sprintf("live:%s:history", liveStreamID)
+}
+
+func liveStreamIDFromKey(key string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(key, "live:"), ":history")
+}
+
+// StartLiveChatPersister consumes live chat history streams and batch-inserts them into messages
+func StartLiveChatPersister() {
+	if database.RedisClient == nil {
+		log.Printf("❌ Redis client not initialized, cannot start live chat persister")
+		return
+	}
+
+	ctx := context.Background()
+	hostname, _ := os.Hostname()
+	consumer := fmt.Sprintf("%s-%d", hostname, os.Getpid())
+
+	liveChatPersister.Lock()
+	liveChatPersister.running = true
+	liveChatPersister.consumer = consumer
+	liveChatPersister.Unlock()
+
+	log.Printf("✅ Live chat persister started (consumer=%s, batch=%d, maxlen=%d)",
+		consumer, config.Cfg.LiveChatBatchSize, config.Cfg.LiveChatStreamMaxLen)
+
+	// Pick up anything this consumer read but never acknowledged (e.g. crash before commit)
+	recovering := true
+	lastClaim := time.Time{}
+
+	for {
+		keys, err := database.RedisClient.SMembers(ctx, LiveChatActiveStreamsKey).Result()
+		if err != nil {
+			log.Printf("❌ Live chat persister: failed to list streams: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		if len(keys) == 0 {
+			recovering = false
+			time.Sleep(liveChatReadBlock)
+			continue
+		}
+
+		keys = ensureLiveChatGroups(ctx, keys)
+
+		if time.Since(lastClaim) > liveChatClaimIdle {
+			claimStaleLiveChatEntries(ctx, keys, consumer)
+			lastClaim = time.Now()
+		}
+
+		startID := ">"
+		if recovering {
+			startID = "0"
+		}
+
+		read := 0
+		for i := 0; i < len(keys); i += liveChatStreamsPerRead {
+			end := i + liveChatStreamsPerRead
+			if end > len(keys) {
+				end = len(keys)
+			}
+			read += readLiveChatStreams(ctx, keys[i:end], consumer, startID)
+		}
+
+		if recovering && read == 0 {
+			recovering = false
+		}
+	}
+}
+
+func ensureLiveChatGroups(ctx context.Context, keys []string) []string {
+	ready := make([]string, 0, len(keys))
+	for _, key := range keys {
+		liveChatPersister.Lock()
+		known := liveChatPersister.groups[key]
+		liveChatPersister.Unlock()
+		if known {
+			ready = append(ready, key)
+			continue
+		}
+
+		// The stream expired after the stream ended: stop tracking it
+		exists, err := database.RedisClient.Exists(ctx, key).Result()
+		if err == nil && exists == 0 {
+			database.RedisClient.SRem(ctx, LiveChatActiveStreamsKey, key)
+			continue
+		}
+
+		// Start from 0 so nothing added before the group existed is skipped
+		err = database.RedisClient.XGroupCreate(ctx, key, liveChatConsumerGroup, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			log.Printf("❌ Live chat persister: failed to create group on %s: %v", key, err)
+			continue
+		}
+
+		liveChatPersister.Lock()
+		liveChatPersister.groups[key] = true
+		liveChatPersister.Unlock()
+		ready = append(ready, key)
+	}
+	return ready
+}
+
+// claimStaleLiveChatEntries takes over entries left pending by consumers that went away
+func claimStaleLiveChatEntries(ctx context.Context, keys []string, consumer string) {
+	for _, key := range keys {
+		msgs, _, err := database.RedisClient.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   key,
+			Group:    liveChatConsumerGroup,
+			Consumer: consumer,
+			MinIdle:  liveChatClaimIdle,
+			Start:    "0",
+			Count:    int64(config.Cfg.LiveChatBatchSize),
+		}).Result()
+		if err != nil {
+			continue
+		}
+		if len(msgs) > 0 {
+			log.Printf("⚠️ Live chat persister: reclaimed %d stale entries on %s", len(msgs), key)
+			flushLiveChatBatch(ctx, []redis.XStream{{Stream: key, Messages: msgs}})
+		}
+	}
+}
+
+func readLiveChatStreams(ctx context.Context, keys []string, consumer, startID string) int {
+	streams := make([]string, 0, len(keys)*2)
+	streams = append(streams, keys...)
+	for range keys {
+		streams = append(streams, startID)
+	}
+
+	block := liveChatReadBlock
+	if startID != ">" {
+		block = -1 // Pending entries are returned immediately; don't block
+	}
+
+	result, err := database.RedisClient.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    liveChatConsumerGroup,
+		Consumer: consumer,
+		Streams:  streams,
+		Count:    int64(config.Cfg.LiveChatBatchSize),
+		Block:    block,
+	}).Result()
+	if err == redis.Nil {
+		return 0
+	}
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "NOGROUP") {
+			// A stream expired and was recreated; recreate the group next round
+			liveChatPersister.Lock()
+			for _, key := range keys {
+				delete(liveChatPersister.groups, key)
+			}
+			liveChatPersister.Unlock()
+			return 0
+		}
+		log.Printf("❌ Live chat persister: read failed: %v", err)
+		time.Sleep(time.Second)
+		return 0
+	}
+
+	return flushLiveChatBatch(ctx, result)
+}
+
+// liveChatRow is a parsed entry and the stream it came from
+type liveChatRow struct {
+	key   string
+	entry redis.XMessage
+	msg   models.Message
+}
+
+// flushLiveChatBatch inserts one batch in a single transaction and only then
+// acknowledges it. If the batch fails its rows are retried one by one.
+func flushLiveChatBatch(ctx context.Context, result []redis.XStream) int {
+	var rows []liveChatRow
+	ackIDs := make(map[string][]string)
+	total := 0
+	dropped := 0
+
+	for _, stream := range result {
+		for _, entry := range stream.Messages {
+			total++
+
+			msg, ok := liveChatEntryToMessage(entry)
+			if !ok {
+				// Unparseable entries are acknowledged and dropped, otherwise they'd be retried forever
+				ackIDs[stream.Stream] = append(ackIDs[stream.Stream], entry.ID)
+				dropped++
+				continue
+			}
+			rows = append(rows, liveChatRow{key: stream.Stream, entry: entry, msg: msg})
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+
+	persisted, deadLettered := 0, 0
+	if len(rows) > 0 {
+		messages := make([]models.Message, len(rows))
+		for i := range rows {
+			messages[i] = rows[i].msg
+		}
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			return tx.Clauses(clause.OnConflict{DoNothing: true}).
+				CreateInBatches(&messages, 100).Error
+		})
+		if err == nil {
+			persisted = len(rows)
+			for _, row := range rows {
+				ackIDs[row.key] = append(ackIDs[row.key], row.entry.ID)
+			}
+		} else {
+			log.Printf("⚠️ Live chat persister: batch insert of %d messages failed, inserting one by one: %v", len(rows), err)
+			var failed error
+			for i := range rows {
+				row := &rows[i]
+				err := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&row.msg).Error
+				switch {
+				case err == nil:
+					persisted++
+				case isRejectedRow(err) && deadLetterLiveChatEntry(ctx, row, err):
+					deadLettered++
+				default:
+					// Database trouble: the entry stays pending and is retried (claimed) later
+					failed = err
+					continue
+				}
+				ackIDs[row.key] = append(ackIDs[row.key], row.entry.ID)
+			}
+			if failed != nil {
+				log.Printf("❌ Live chat persister: insert failed: %v", failed)
+				liveChatPersister.Lock()
+				liveChatPersister.failures++
+				liveChatPersister.lastError = failed.Error()
+				liveChatPersister.Unlock()
+				time.Sleep(time.Second)
+			}
+		}
+	}
+
+	for key, ids := range ackIDs {
+		if err := database.RedisClient.XAck(ctx, key, liveChatConsumerGroup, ids...).Err(); err != nil {
+			log.Printf("⚠️ Live chat persister: XACK failed on %s: %v", key, err)
+			continue
+		}
+		trimLiveChatStream(ctx, key)
+	}
+
+	now := time.Now()
+	liveChatPersister.Lock()
+	liveChatPersister.persisted += int64(persisted)
+	liveChatPersister.dropped += int64(dropped)
+	liveChatPersister.deadLetters += int64(deadLettered)
+	liveChatPersister.batches++
+	liveChatPersister.lastFlushAt = &now
+	liveChatPersister.Unlock()
+
+	return total
+}
+
+// isRejectedRow reports whether Postgres refused a row for its data (data
+// exception or integrity violation), which retrying won't fix
+func isRejectedRow(err error) bool {
+	return strings.Contains(err.Error(), "SQLSTATE 22") || strings.Contains(err.Error(), "SQLSTATE 23")
+}
+
+// deadLetterLiveChatEntry copies a rejected entry to the dead-letter stream.
+// Returns false when that failed, so the entry stays pending instead of being lost.
+func deadLetterLiveChatEntry(ctx context.Context, row *liveChatRow, cause error) bool {
+	err := database.RedisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: LiveChatDeadLetterKey,
+		MaxLen: liveChatDeadLetterMax,
+		Approx: true,
+		Values: map[string]interface{}{
+			"stream":   row.key,
+			"entry_id": row.entry.ID,
+			"message":  row.entry.Values["message"],
+			"error":    cause.Error(),
+		},
+	}).Err()
+	if err != nil {
+		log.Printf("❌ Live chat persister: failed to dead-letter %s on %s: %v", row.entry.ID, row.key, err)
+		return false
+	}
+	log.Printf("⚠️ Live chat persister: dead-lettered %s on %s: %v", row.entry.ID, row.key, cause)
+	return true
+}
+
+// trimLiveChatStream keeps the stream bounded without dropping entries that
+// aren't in Postgres yet: those still pending, and those the group hasn't read
+// (lag). MINID never goes past the last delivered or the oldest pending entry,
+// even for the retention window; MAXLEN only applies once the group has read
+// and acknowledged everything.
+func trimLiveChatStream(ctx context.Context, key string) {
+	groups, err := database.RedisClient.XInfoGroups(ctx, key).Result()
+	if err != nil {
+		return
+	}
+	var group *redis.XInfoGroup
+	for i := range groups {
+		if groups[i].Name == liveChatConsumerGroup {
+			group = &groups[i]
+		}
+	}
+	if group == nil {
+		return
+	}
+
+	// Lag is nil (read as 0) when Redis can't tell, so also compare with the newest entry
+	lag := group.Lag > 0
+	if newest, err := database.RedisClient.XRevRangeN(ctx, key, "+", "-", 1).Result(); err != nil {
+		return
+	} else if len(newest) > 0 && compareStreamIDs(newest[0].ID, group.LastDeliveredID) > 0 {
+		lag = true
+	}
+
+	minID := fmt.Sprintf("%d-0", time.Now().Add(-liveChatRetention).UnixMilli())
+	if compareStreamIDs(group.LastDeliveredID, minID) < 0 {
+		minID = group.LastDeliveredID
+	}
+	if group.Pending > 0 {
+		pending, err := database.RedisClient.XPending(ctx, key, liveChatConsumerGroup).Result()
+		if err != nil {
+			return
+		}
+		if pending.Count > 0 && compareStreamIDs(pending.Lower, minID) < 0 {
+			minID = pending.Lower
+		}
+	}
+	database.RedisClient.XTrimMinIDApprox(ctx, key, minID, 0)
+
+	if group.Pending > 0 || lag {
+		return
+	}
+	if maxLen := config.Cfg.LiveChatStreamMaxLen; maxLen > 0 {
+		database.RedisClient.XTrimMaxLenApprox(ctx, key, int64(maxLen), 0)
+	}
+}
+
+// compareStreamIDs orders two Redis stream IDs ("<ms>-<seq>")
+func compareStreamIDs(a, b string) int {
+	aMs, aSeq := splitStreamID(a)
+	bMs, bSeq := splitStreamID(b)
+	switch {
+	case aMs != bMs:
+		if aMs < bMs {
+			return -1
+		}
+		return 1
+	case aSeq != bSeq:
+		if aSeq < bSeq {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+func splitStreamID(id string) (uint64, uint64) {
+	msStr, seqStr, _ := strings.Cut(id, "-")
+	ms, _ := strconv.ParseUint(msStr, 10, 64)
+	seq, _ := strconv.ParseUint(seqStr, 10, 64)
+	return ms, seq
+}
+
+func liveChatEntryToMessage(entry redis.XMessage) (models.Message, bool) {
+	raw, ok := entry.Values["message"].(string)
+	if !ok {
+		return models.Message{}, false
+	}
+
+	var e liveChatEntry
+	if err := json.Unmarshal([]byte(raw), &e); err != nil {
+		log.Printf("⚠️ Live chat persister: bad entry %s: %v", entry.ID, err)
+		return models.Message{}, false
+	}
+
+	liveStreamID, err := uuid.Parse(e.LiveStreamID)
+	if err != nil {
+		return models.Message{}, false
+	}
+	senderID, err := uuid.Parse(e.SenderID)
+	if err != nil {
+		return models.Message{}, false
+	}
+
+	msg := models.Message{
+		LiveStreamID: &liveStreamID,
+		SenderID:     senderID,
+		MessageType:  liveChatMessageType(e.MessageType),
+		IsLive:       true,
+		IsSystem:     e.IsSystem || e.Type == "system",
+		Seq:          e.Seq,
+		Pinned:       e.IsPinned,
+		MediaURL:     e.MediaURL,
+	}
+
+	// Message IDs are assigned by the hub; entries without one get a fresh ID
+	if id, err := uuid.Parse(e.MessageID); err == nil {
+		msg.ID = id
+	} else {
+		msg.ID = uuid.New()
+	}
+
+	switch content := e.Content.(type) {
+	case nil:
+	case string:
+		msg.Content = content
+	default:
+		contentJSON, _ := json.Marshal(content)
+		msg.Content = string(contentJSON)
+	}
+
+	if e.GiftID != "" {
+		if giftID, err := uuid.Parse(e.GiftID); err == nil {
+			msg.GiftID = &giftID
+		}
+	}
+
+	if e.Metadata != nil {
+		msg.Metadata = models.JSONMap(e.Metadata)
+	}
+
+	if ms, _ := splitStreamID(entry.ID); ms > 0 {
+		msg.CreatedAt = time.UnixMilli(int64(ms))
+		msg.UpdatedAt = msg.CreatedAt
+	}
+
+	return msg, true
+}
+
+// liveChatMessageType maps client message types onto the message_type enum
+func liveChatMessageType(t string) models.MessageType {
+	switch models.MessageType(t) {
+	case models.MessageTypeText, models.MessageTypePhoto, models.MessageTypeVideo,
+		models.MessageTypeVoice, models.MessageTypeSticker, models.MessageTypeGift:
+		return models.MessageType(t)
+	}
+	// "system" and empty types are stored as text (is_system carries the flag)
+	return models.MessageTypeText
+}
+
+// GetLiveChatPersisterStats returns throughput counters and per-stream consumer lag
+func GetLiveChatPersisterStats() (*LiveChatPersisterStats, error) {
+	ctx := context.Background()
+
+	liveChatPersister.Lock()
+	stats := &LiveChatPersisterStats{
+		Running:      liveChatPersister.running,
+		Consumer:     liveChatPersister.consumer,
+		Persisted:    liveChatPersister.persisted,
+		Batches:      liveChatPersister.batches,
+		Failures:     liveChatPersister.failures,
+		Dropped:      liveChatPersister.dropped,
+		DeadLettered: liveChatPersister.deadLetters,
+		LastFlushAt:  liveChatPersister.lastFlushAt,
+		LastError:    liveChatPersister.lastError,
+		Streams:      []LiveChatStreamStats{},
+	}
+	liveChatPersister.Unlock()
+
+	keys, err := database.RedisClient.SMembers(ctx, LiveChatActiveStreamsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	stats.ActiveStreams = len(keys)
+
+	for _, key := range keys {
+		streamStats := LiveChatStreamStats{LiveStreamID: liveStreamIDFromKey(key)}
+		streamStats.Length, _ = database.RedisClient.XLen(ctx, key).Result()
+
+		groups, err := database.RedisClient.XInfoGroups(ctx, key).Result()
+		if err == nil {
+			for _, group := range groups {
+				if group.Name != liveChatConsumerGroup {
+					continue
+				}
+				streamStats.Pending = group.Pending
+				streamStats.Lag = group.Lag
+				streamStats.LastDelivered = group.LastDeliveredID
+			}
+		} else {
+			// No group yet: everything in the stream is unread
+			streamStats.Lag = streamStats.Length
+		}
+
+		stats.TotalPending += streamStats.Pending
+		stats.TotalLag += streamStats.Lag
+		stats.Streams = append(stats.Streams, streamStats)
+	}
+
+	return stats, nil
+}
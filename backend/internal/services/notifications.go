@@ -41,6 +41,9 @@ const (
 	NotificationTypeNewMessage   NotificationType = "new_message"
 	NotificationTypeGiftReceived NotificationType = "gift_received"
 	NotificationTypeSomeoneLiked NotificationType = "someone_liked"
+
+	NotificationTypePaymentRefunded NotificationType = "payment_refunded"
+	NotificationTypeDataExportReady NotificationType = "data_export_ready"
 )
 
 // SendNotification sends a push notification
@@ -276,6 +279,40 @@ func (ns *NotificationService) NotifySomeoneViewedProfile(viewedUserID uuid.UUID
 	return ns.SendNotification(viewedUserID, NotificationTypeSomeoneLiked, title, body, data)
 }
 
+// NotifyPurchaseRefunded tells a user their coin purchase was refunded or charged back
+func (ns *NotificationService) NotifyPurchaseRefunded(purchase models.CoinTransaction, coinsRemoved int, debt int64) error {
+	title := "Coin purchase refunded"
+	body := fmt.Sprintf("Your purchase of %d coins (%.2f ETB) was refunded and %d coins were removed from your wallet.",
+		purchase.CoinAmount+purchase.BonusCoins, purchase.BirrAmount, coinsRemoved)
+	if debt > 0 {
+		body += fmt.Sprintf(" Your balance is now -%d coins; new coins will settle it first.", debt)
+	}
+	data := map[string]interface{}{
+		"type":           string(NotificationTypePaymentRefunded),
+		"transaction_id": purchase.ID.String(),
+		"coins_removed":  coinsRemoved,
+		"debt":           debt,
+	}
+
+	return ns.SendNotification(purchase.UserID, NotificationTypePaymentRefunded, title, body, data)
+}
+
+// NotifyDataExportReady tells a user the export of their data can be downloaded.
+// The link is in data only: Telegram messages are sent as HTML.
+func (ns *NotificationService) NotifyDataExportReady(export models.DataExport, downloadURL string, linkExpiresAt time.Time) error {
+	title := "Your data export is ready"
+	body := fmt.Sprintf("The copy of your Lomi data you asked for is ready (%.1f MB). Open Settings → Privacy to download it before %s.",
+		float64(export.SizeBytes)/(1024*1024), export.ExpiresAt.UTC().Format("Jan 2, 15:04 UTC"))
+	data := map[string]interface{}{
+		"type":                    string(NotificationTypeDataExportReady),
+		"export_id":               export.ID.String(),
+		"download_url":            downloadURL,
+		"download_url_expires_at": linkExpiresAt.UTC().Format(time.RFC3339),
+	}
+
+	return ns.SendNotification(export.UserID, NotificationTypeDataExportReady, title, body, data)
+}
+
 // SendTelegramMessage sends a simple text message via Telegram Bot API
 func (ns *NotificationService) SendTelegramMessage(telegramID int64, message string) error {
 	if ns.TelegramBotToken == "" {
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"lomi-backend/internal/payments"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==================== PAYMENT RECONCILIATION ====================
+// Webhooks get lost. Every few minutes we ask the provider about purchases
+// that have been pending for a while and settle them through ApplyPaymentEvent,
+// exactly as if the callback had arrived. Purchases nobody confirms within
+// purchaseExpireAfter are failed. Once a day a per-provider report of expected
+// vs. settled birr is written for finance; refunded purchases are counted
+// separately and net to zero coins in the ledger.
+
+const (
+	purchaseReconcileInterval = 5 * time.Minute
+	purchaseStaleAfter        = 15 * time.Minute
+	purchaseExpireAfter       = 24 * time.Hour
+	purchaseReconcileBatch    = 200
+)
+
+// ReconcileResult summarizes one reconciliation pass
+type ReconcileResult struct {
+	Checked      int `json:"checked"`
+	Completed    int `json:"completed"`
+	Failed       int `json:"failed"`
+	Expired      int `json:"expired"`
+	StillPending int `json:"still_pending"`
+	Errors       int `json:"errors"`
+}
+
+// StartPaymentReconciler runs reconciliation passes and the daily report forever
+func StartPaymentReconciler() {
+	log.Printf("✅ Payment reconciler started (every %s)", purchaseReconcileInterval)
+
+	ticker := time.NewTicker(purchaseReconcileInterval)
+	defer ticker.Stop()
+
+	var lastReport string
+	for {
+		ctx := context.Background()
+		ReconcilePendingPurchases(ctx)
+
+		// Yesterday's report, once a day (regenerated after restarts, it's an upsert)
+		yesterday := time.Now().In(reportLocation()).AddDate(0, 0, -1)
+		if day := yesterday.Format("2006-01-02"); day != lastReport {
+			if _, err := GenerateReconciliationReport(ctx, yesterday); err != nil {
+				log.Printf("❌ Reconciliation report for %s failed: %v", day, err)
+			} else {
+				lastReport = day
+			}
+		}
+
+		<-ticker.C
+	}
+}
+
+// ReconcilePendingPurchases checks stale pending purchases with their provider
+func ReconcilePendingPurchases(ctx context.Context) ReconcileResult {
+	var result ReconcileResult
+	now := time.Now()
+
+	var pending []models.CoinTransaction
+	if err := database.DB.WithContext(ctx).
+		Where("transaction_type = ? AND payment_status = ? AND created_at < ?",
+			models.TransactionTypePurchase, models.PaymentStatusPending, now.Add(-purchaseStaleAfter)).
+		Order("created_at ASC").
+		Limit(purchaseReconcileBatch).
+		Find(&pending).Error; err != nil {
+		log.Printf("❌ Failed to load pending purchases: %v", err)
+		result.Errors++
+		return result
+	}
+
+	for i := range pending {
+		coinTx := &pending[i]
+		result.Checked++
+
+		status, err := queryPurchaseStatus(ctx, coinTx)
+		if err != nil {
+			// Can't ask (yet): try again next pass, don't expire on a network blip
+			log.Printf("⚠️ Status query for purchase %s (%s) failed: %v", coinTx.ID, coinTx.PaymentMethod, err)
+			result.Errors++
+			continue
+		}
+
+		if status != nil && status.Status != payments.EventStatusPending {
+			if err := settleFromProvider(ctx, coinTx, status); err != nil {
+				log.Printf("⚠️ Purchase %s needs manual review: %v", coinTx.ID, err)
+				result.Errors++
+				continue
+			}
+			if status.Status == payments.EventStatusSuccess {
+				result.Completed++
+			} else {
+				result.Failed++
+			}
+			continue
+		}
+
+		if coinTx.CreatedAt.Before(now.Add(-purchaseExpireAfter)) {
+			expired, err := ExpirePurchase(ctx, coinTx.ID, "no payment confirmation")
+			if err != nil {
+				log.Printf("❌ Failed to expire purchase %s: %v", coinTx.ID, err)
+				result.Errors++
+				continue
+			}
+			if expired {
+				result.Expired++
+			}
+			continue
+		}
+
+		result.StillPending++
+	}
+
+	if result.Checked > 0 {
+		log.Printf("🧾 Reconciled %d pending purchases: %d completed, %d failed, %d expired, %d still pending, %d errors",
+			result.Checked, result.Completed, result.Failed, result.Expired, result.StillPending, result.Errors)
+	}
+	return result
+}
+
+// queryPurchaseStatus asks the provider; nil status means the provider can't be asked
+func queryPurchaseStatus(ctx context.Context, coinTx *models.CoinTransaction) (*payments.StatusResult, error) {
+	provider, err := payments.Get(coinTx.PaymentMethod)
+	if errors.Is(err, payments.ErrUnknownProvider) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := provider.QueryStatus(ctx, coinTx.ID, coinTx.PaymentReference)
+	if errors.Is(err, payments.ErrNotSupported) {
+		return nil, nil
+	}
+	return status, err
+}
+
+// settleFromProvider applies the provider's answer through the webhook code path
+func settleFromProvider(ctx context.Context, coinTx *models.CoinTransaction, status *payments.StatusResult) error {
+	event := &payments.Event{
+		Provider:         coinTx.PaymentMethod,
+		EventID:          "reconcile:" + coinTx.ID.String(),
+		TransactionID:    coinTx.ID,
+		PaymentReference: status.PaymentReference,
+		Amount:           status.Amount,
+		Currency:         status.Currency,
+		Status:           status.Status,
+	}
+	applied, _, err := ApplyPaymentEvent(ctx, event, nil)
+	if err != nil {
+		return err
+	}
+	if applied != models.WebhookEventProcessed {
+		return nil
+	}
+
+	return database.DB.WithContext(ctx).Model(&models.CoinTransaction{}).
+		Where("id = ?", coinTx.ID).
+		Update("metadata", gorm.Expr("COALESCE(metadata, '{}'::jsonb) || jsonb_build_object('settled_by', 'reconciler', 'reconciled_at', ?::text)",
+			time.Now().Format(time.RFC3339))).Error
+}
+
+// ==================== DAILY REPORT ====================
+
+// reportLocation is the business day used by finance
+func reportLocation() *time.Location {
+	if loc, err := time.LoadLocation("Africa/Addis_Ababa"); err == nil {
+		return loc
+	}
+	return time.FixedZone("EAT", 3*60*60)
+}
+
+// ReconciliationDay is the start of the business day containing t
+func ReconciliationDay(t time.Time) time.Time {
+	loc := reportLocation()
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// GenerateReconciliationReport (re)computes and stores the report for the
+// purchases initiated on day, one row per provider
+func GenerateReconciliationReport(ctx context.Context, day time.Time) ([]models.PaymentReconciliationReport, error) {
+	start := ReconciliationDay(day)
+	end := start.AddDate(0, 0, 1)
+
+	var reports []models.PaymentReconciliationReport
+	err := database.DB.WithContext(ctx).Raw(`
+		SELECT
+			ct.payment_method::text AS provider,
+			COUNT(*) AS initiated_count,
+			COALESCE(SUM(ct.birr_amount), 0) AS expected_birr,
+			COUNT(*) FILTER (WHERE ct.payment_status = 'completed') AS settled_count,
+			COALESCE(SUM(ct.birr_amount) FILTER (WHERE ct.payment_status = 'completed'), 0) AS settled_birr,
+			COUNT(*) FILTER (WHERE ct.payment_status = 'completed' AND ct.metadata->>'settled_by' = 'reconciler') AS reconciled_count,
+			COUNT(*) FILTER (WHERE ct.payment_status = 'failed') AS failed_count,
+			COALESCE(SUM(ct.birr_amount) FILTER (WHERE ct.payment_status = 'failed'), 0) AS failed_birr,
+			COUNT(*) FILTER (WHERE ct.payment_status = 'failed' AND ct.metadata->>'expired_at' IS NOT NULL) AS expired_count,
+			COUNT(*) FILTER (WHERE ct.payment_status = 'pending') AS pending_count,
+			COALESCE(SUM(ct.birr_amount) FILTER (WHERE ct.payment_status = 'pending'), 0) AS pending_birr,
+			COUNT(*) FILTER (WHERE ct.payment_status = 'refunded') AS refunded_count,
+			COALESCE(SUM(ct.birr_amount) FILTER (WHERE ct.payment_status = 'refunded'), 0) AS refunded_birr,
+			COALESCE(SUM(ct.coin_amount) FILTER (WHERE ct.payment_status = 'completed'), 0) AS coins_credited,
+			COALESCE(SUM(posted.coins), 0) AS ledger_coins
+		FROM coin_transactions ct
+		LEFT JOIN (
+			-- Net paid coins the purchase issued from coin sales (promotional
+			-- bonus coins come from system:promotions); a reversal cancels it out
+			SELECT je.reference_id, -SUM(p.amount) AS coins
+			FROM journal_entries je
+			JOIN postings p ON p.journal_entry_id = je.id
+			JOIN ledger_accounts la ON la.id = p.account_id AND la.code = 'system:coin_sales'
+			WHERE je.entry_type IN ('coin_purchase', 'refund', 'chargeback') AND je.reference_type = 'coin_transaction'
+			GROUP BY je.reference_id
+		) posted ON posted.reference_id = ct.id::text
+		WHERE ct.transaction_type = ? AND ct.created_at >= ? AND ct.created_at < ?
+		GROUP BY ct.payment_method
+		ORDER BY ct.payment_method
+	`, models.TransactionTypePurchase, start, end).Scan(&reports).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute reconciliation report: %w", err)
+	}
+
+	now := time.Now()
+	for i := range reports {
+		reports[i].ReportDate = start
+		reports[i].GeneratedAt = now
+		reports[i].HasDiscrepancy = reports[i].CoinsCredited != reports[i].LedgerCoins
+		if reports[i].HasDiscrepancy {
+			log.Printf("🚨 Reconciliation %s %s: %d coins on settled purchases but %d in the ledger",
+				start.Format("2006-01-02"), reports[i].Provider, reports[i].CoinsCredited, reports[i].LedgerCoins)
+		}
+	}
+
+	if len(reports) > 0 {
+		if err := database.DB.WithContext(ctx).Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "report_date"}, {Name: "provider"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"initiated_count", "expected_birr", "settled_count", "settled_birr", "reconciled_count",
+				"failed_count", "failed_birr", "expired_count", "pending_count", "pending_birr",
+				"refunded_count", "refunded_birr",
+				"coins_credited", "ledger_coins", "has_discrepancy", "generated_at",
+			}),
+		}).Create(&reports).Error; err != nil {
+			return nil, fmt.Errorf("failed to store reconciliation report: %w", err)
+		}
+	}
+
+	log.Printf("🧾 Reconciliation report for %s: %d providers", start.Format("2006-01-02"), len(reports))
+	return reports, nil
+}
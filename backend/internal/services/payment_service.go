@@ -0,0 +1,480 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/ledger"
+	"lomi-backend/internal/models"
+	"lomi-backend/internal/payments"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==================== COIN PURCHASES ====================
+// One code path settles a purchase, whether the news comes from a provider
+// webhook or from the reconciler asking the provider.
+
+// PaymentRejection is a correctly signed event we refuse to apply
+type PaymentRejection struct {
+	Reason string
+}
+
+func (r *PaymentRejection) Error() string { return r.Reason }
+
+// CompleteCoinPurchase credits the coins of a paid purchase and marks it completed.
+// Promotional bonus coins are a separate line from system:promotions, so coin
+// sales only counts paid coins. Must run inside a transaction; posting is
+// idempotent per purchase.
+func CompleteCoinPurchase(ctx context.Context, tx *gorm.DB, coinTx *models.CoinTransaction) error {
+	if err := recheckPromotionLimits(ctx, tx, coinTx); err != nil {
+		return err
+	}
+
+	user := ledger.UserCoins(coinTx.UserID)
+	lines := ledger.Transfer(ledger.System(ledger.SystemCoinSales), user, int64(coinTx.CoinAmount))
+	if coinTx.BonusCoins > 0 {
+		lines = append(lines, ledger.Transfer(ledger.System(ledger.SystemPromotions), user, int64(coinTx.BonusCoins))...)
+	}
+
+	entry, err := ledger.Post(ctx, ledger.Gorm(tx), ledger.Entry{
+		Type:           ledger.EntryCoinPurchase,
+		ReferenceType:  "coin_transaction",
+		ReferenceID:    coinTx.ID.String(),
+		IdempotencyKey: "coin_purchase:" + coinTx.ID.String(),
+		Description:    fmt.Sprintf("Purchased %d coins", coinTx.CoinAmount),
+		Metadata: map[string]interface{}{
+			"birr_amount":       coinTx.BirrAmount,
+			"bonus_coins":       coinTx.BonusCoins,
+			"payment_method":    coinTx.PaymentMethod,
+			"payment_reference": coinTx.PaymentReference,
+		},
+		Lines:          lines,
+		EconomyVersion: coinTx.EconomyVersion,
+	})
+	if err != nil {
+		return err
+	}
+
+	coinTx.PaymentStatus = models.PaymentStatusCompleted
+	coinTx.BalanceAfter = int(entry.BalanceAfter(user))
+	coinTx.JournalEntryID = &entry.ID
+	return tx.Save(coinTx).Error
+}
+
+// ApplyPaymentEvent applies a verified provider event to its purchase. When
+// record is set (webhooks) it is marked with the outcome in the same transaction.
+// The purchase row is locked first, so events for the same purchase are applied
+// one at a time and the replay check below is race-free.
+func ApplyPaymentEvent(ctx context.Context, event *payments.Event, record *models.PaymentWebhookEvent) (models.WebhookEventStatus, *models.CoinTransaction, error) {
+	var coinTx models.CoinTransaction
+	var chargeback *PurchaseRefund
+	status := models.WebhookEventProcessed
+
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&coinTx, "id = ?", event.TransactionID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &PaymentRejection{Reason: "transaction not found"}
+			}
+			return err
+		}
+
+		// Replay protection: each provider event is applied once
+		var applied int64
+		if err := tx.Model(&models.PaymentWebhookEvent{}).
+			Where("provider = ? AND event_id = ? AND status = ?", string(event.Provider), event.EventID, models.WebhookEventProcessed).
+			Count(&applied).Error; err != nil {
+			return err
+		}
+		if applied > 0 {
+			status = models.WebhookEventDuplicate
+		} else if err := matchPaymentEvent(&coinTx, event); err != nil {
+			return err
+		} else {
+			switch {
+			case event.Status == payments.EventStatusSuccess && coinTx.PaymentStatus == models.PaymentStatusCompleted:
+				// Paid already (e.g. an earlier event with a different ID)
+				status = models.WebhookEventDuplicate
+			case event.Status == payments.EventStatusSuccess &&
+				(coinTx.PaymentStatus == models.PaymentStatusPending || coinTx.PaymentStatus == models.PaymentStatusFailed):
+				// A late success after a failure still means the user paid
+				coinTx.PaymentReference = event.PaymentReference
+				if err := CompleteCoinPurchase(ctx, tx, &coinTx); err != nil {
+					return err
+				}
+			case event.Status == payments.EventStatusSuccess:
+				return &PaymentRejection{Reason: fmt.Sprintf("transaction is %s", coinTx.PaymentStatus)}
+			case event.Status == payments.EventStatusFailed && coinTx.PaymentStatus == models.PaymentStatusPending:
+				coinTx.PaymentStatus = models.PaymentStatusFailed
+				coinTx.PaymentReference = event.PaymentReference
+				if err := tx.Save(&coinTx).Error; err != nil {
+					return err
+				}
+			case event.Status == payments.EventStatusRefunded && coinTx.PaymentStatus == models.PaymentStatusCompleted:
+				// Chargeback or a refund made from the provider's portal
+				reversal, err := reversePurchase(ctx, tx, &coinTx, ledger.EntryChargeback,
+					"chargeback", "provider:"+string(event.Provider), event.PaymentReference)
+				if err != nil {
+					return err
+				}
+				chargeback = reversal
+			case event.Status == payments.EventStatusRefunded && coinTx.PaymentStatus == models.PaymentStatusRefunded:
+				status = models.WebhookEventDuplicate
+			default:
+				status = models.WebhookEventIgnored
+			}
+		}
+
+		if record == nil {
+			return nil
+		}
+		return tx.Model(record).Updates(map[string]interface{}{
+			"status":              status,
+			"coin_transaction_id": coinTx.ID,
+			"processed_at":        time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	if chargeback != nil {
+		notifyPurchaseRefunded(chargeback)
+	}
+	return status, &coinTx, nil
+}
+
+// matchPaymentEvent checks the event is for this purchase: same provider, currency and amount
+func matchPaymentEvent(coinTx *models.CoinTransaction, event *payments.Event) error {
+	if coinTx.TransactionType != models.TransactionTypePurchase {
+		return &PaymentRejection{Reason: "transaction is not a purchase"}
+	}
+	if coinTx.PaymentMethod != event.Provider {
+		return &PaymentRejection{Reason: fmt.Sprintf("transaction was not paid with %s", event.Provider)}
+	}
+	if event.Currency != payments.Currency {
+		return &PaymentRejection{Reason: fmt.Sprintf("currency mismatch: expected %s, got %s", payments.Currency, event.Currency)}
+	}
+	if expected := payments.ToMinorUnits(coinTx.BirrAmount); event.Amount != expected {
+		return &PaymentRejection{Reason: fmt.Sprintf("amount mismatch: expected %d, got %d santim", expected, event.Amount)}
+	}
+	return nil
+}
+
+// ExpirePurchase fails a purchase that is still pending. Returns false if it
+// was settled in the meantime.
+func ExpirePurchase(ctx context.Context, transactionID uuid.UUID, reason string) (bool, error) {
+	expired := false
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var coinTx models.CoinTransaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&coinTx, "id = ?", transactionID).Error; err != nil {
+			return err
+		}
+		if coinTx.PaymentStatus != models.PaymentStatusPending {
+			return nil
+		}
+
+		if coinTx.Metadata == nil {
+			coinTx.Metadata = models.JSONMap{}
+		}
+		coinTx.Metadata["failure_reason"] = reason
+		coinTx.Metadata["expired_at"] = time.Now().Format(time.RFC3339)
+		coinTx.PaymentStatus = models.PaymentStatusFailed
+		expired = true
+		return tx.Save(&coinTx).Error
+	})
+	return expired, err
+}
+
+// ==================== REFUNDS & CHARGEBACKS ====================
+// A refund reverses the purchase in the ledger: the coins go back to coin
+// sales. Coins that were already spent can't be taken back, so the user's
+// account is overdrawn and stays in debt until new coins cover it.
+
+var (
+	ErrPurchaseNotFound      = errors.New("purchase not found")
+	ErrPurchaseNotRefundable = errors.New("only completed purchases can be refunded")
+	ErrRefundNeedsExternalID = errors.New("provider has no refund API: refund in the merchant portal and pass external_refund_id")
+	ErrProviderRefundFailed  = errors.New("provider refund failed; the coins are already reversed, retry the refund")
+)
+
+// PurchaseRefund is the outcome of reversing a purchase
+type PurchaseRefund struct {
+	Purchase         *models.CoinTransaction `json:"purchase"`
+	Refund           *models.CoinTransaction `json:"refund"`
+	CoinsRemoved     int                     `json:"coins_removed"` // Unspent coins taken back
+	Debt             int64                   `json:"debt"`          // Coins the user owes after the reversal
+	ProviderRefundID string                  `json:"provider_refund_id,omitempty"`
+}
+
+// Provider refund states of an admin refund, in the refund transaction's
+// metadata["provider_refund"]
+const (
+	providerRefundPending   = "pending"   // Coins reversed, provider not asked yet
+	providerRefundFailed    = "failed"    // Provider call failed; retrying the refund asks again
+	providerRefundCompleted = "completed" // Provider refunded the payment
+	providerRefundExternal  = "external"  // Refunded in the merchant portal
+)
+
+// RefundPurchase refunds a completed purchase on behalf of an admin. The coin
+// reversal is committed first with the provider refund pending, then the
+// provider is asked to refund and its answer recorded. The provider call uses
+// the purchase as idempotency key, so retrying a refund whose provider call
+// failed (or whose result wasn't saved) asks again without paying out twice.
+// externalRefundID records a refund already made outside the provider API.
+func RefundPurchase(ctx context.Context, transactionID uuid.UUID, adminID uuid.UUID, reason string, externalRefundID string) (*PurchaseRefund, error) {
+	var (
+		result   *PurchaseRefund
+		provider payments.PaymentProvider
+		retry    bool
+	)
+
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var coinTx models.CoinTransaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&coinTx, "id = ? AND transaction_type = ?", transactionID, models.TransactionTypePurchase).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPurchaseNotFound
+			}
+			return err
+		}
+
+		switch coinTx.PaymentStatus {
+		case models.PaymentStatusCompleted:
+		case models.PaymentStatusRefunded:
+			// Coins already reversed: only an unfinished provider refund is retried
+			unfinished, err := unfinishedRefund(tx, &coinTx)
+			if err != nil {
+				return err
+			}
+			result, retry = unfinished, true
+		default:
+			return ErrPurchaseNotRefundable
+		}
+
+		if externalRefundID != "" {
+			if result == nil {
+				reversal, err := reversePurchase(ctx, tx, &coinTx, ledger.EntryRefund, reason, "admin:"+adminID.String(), externalRefundID)
+				if err != nil {
+					return err
+				}
+				result = reversal
+			}
+			return recordProviderRefund(tx, result, providerRefundExternal, externalRefundID, "")
+		}
+
+		p, err := payments.Get(coinTx.PaymentMethod)
+		if err != nil {
+			return err
+		}
+		if !p.SupportsRefunds() {
+			return ErrRefundNeedsExternalID
+		}
+		provider = p
+		if retry {
+			return nil
+		}
+
+		reversal, err := reversePurchase(ctx, tx, &coinTx, ledger.EntryRefund, reason, "admin:"+adminID.String(), "")
+		if err != nil {
+			return err
+		}
+		result = reversal
+		return recordProviderRefund(tx, result, providerRefundPending, "", "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !retry {
+		notifyPurchaseRefunded(result)
+	}
+	if provider == nil {
+		return result, nil
+	}
+
+	purchase := result.Purchase
+	refund, err := provider.Refund(ctx, payments.RefundRequest{
+		TransactionID:    purchase.ID,
+		PaymentReference: purchase.PaymentReference,
+		Amount:           payments.ToMinorUnits(purchase.BirrAmount),
+		Currency:         payments.Currency,
+		Reason:           reason,
+		IdempotencyKey:   "refund:" + purchase.ID.String(),
+	})
+	if err != nil {
+		if saveErr := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return recordProviderRefund(tx, result, providerRefundFailed, "", err.Error())
+		}); saveErr != nil {
+			log.Printf("❌ Failed to record failed refund of purchase %s: %v", purchase.ID, saveErr)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrProviderRefundFailed, err)
+	}
+
+	if err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return recordProviderRefund(tx, result, providerRefundCompleted, refund.RefundID, "")
+	}); err != nil {
+		log.Printf("❌ Purchase %s was refunded by the provider (%s) but not recorded: %v", purchase.ID, refund.RefundID, err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// unfinishedRefund loads the admin refund of a reversed purchase whose
+// provider refund is still pending or failed. coinTx must be locked.
+func unfinishedRefund(tx *gorm.DB, coinTx *models.CoinTransaction) (*PurchaseRefund, error) {
+	refundID, _ := coinTx.Metadata["refund_transaction_id"].(string)
+	var refund models.CoinTransaction
+	if refundID == "" || tx.First(&refund, "id = ?", refundID).Error != nil {
+		return nil, ErrPurchaseNotRefundable
+	}
+	if state, _ := refund.Metadata["provider_refund"].(string); state != providerRefundPending && state != providerRefundFailed {
+		return nil, ErrPurchaseNotRefundable
+	}
+
+	coinsRemoved, _ := refund.Metadata["coins_removed"].(float64)
+	debt, _ := refund.Metadata["debt"].(float64)
+	return &PurchaseRefund{
+		Purchase:     coinTx,
+		Refund:       &refund,
+		CoinsRemoved: int(coinsRemoved),
+		Debt:         int64(debt),
+	}, nil
+}
+
+// recordProviderRefund saves the provider refund state of an admin refund,
+// and the provider's refund ID once there is one
+func recordProviderRefund(tx *gorm.DB, reversal *PurchaseRefund, state, refundID, errMsg string) error {
+	refund, purchase := reversal.Refund, reversal.Purchase
+	if refund.Metadata == nil {
+		refund.Metadata = models.JSONMap{}
+	}
+	refund.Metadata["provider_refund"] = state
+	delete(refund.Metadata, "provider_refund_error")
+	if errMsg != "" {
+		refund.Metadata["provider_refund_error"] = errMsg
+	}
+
+	if refundID != "" {
+		reversal.ProviderRefundID = refundID
+		refund.PaymentReference = refundID
+		if purchase.Metadata == nil {
+			purchase.Metadata = models.JSONMap{}
+		}
+		purchase.Metadata["refund_reference"] = refundID
+		if err := tx.Model(purchase).Update("metadata", purchase.Metadata).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Model(refund).Updates(map[string]interface{}{
+		"metadata":          refund.Metadata,
+		"payment_reference": refund.PaymentReference,
+	}).Error
+}
+
+// reversePurchase posts the reversing entry for a completed purchase, records
+// the refund transaction and marks the purchase refunded. coinTx must be locked.
+func reversePurchase(ctx context.Context, tx *gorm.DB, coinTx *models.CoinTransaction, entryType ledger.EntryType, reason, source, refundReference string) (*PurchaseRefund, error) {
+	user := ledger.UserCoins(coinTx.UserID)
+	// The bonus coins of a promotion go back with the purchase
+	coins := int64(coinTx.CoinAmount + coinTx.BonusCoins)
+	lines := ledger.Transfer(user, ledger.System(ledger.SystemCoinSales), int64(coinTx.CoinAmount))
+	if coinTx.BonusCoins > 0 {
+		lines = append(lines, ledger.Transfer(user, ledger.System(ledger.SystemPromotions), int64(coinTx.BonusCoins))...)
+	}
+
+	entry, err := ledger.Post(ctx, ledger.Gorm(tx), ledger.Entry{
+		Type:           entryType,
+		ReferenceType:  "coin_transaction",
+		ReferenceID:    coinTx.ID.String(),
+		IdempotencyKey: "reversal:" + coinTx.ID.String(),
+		Description:    fmt.Sprintf("Reversed purchase of %d coins (%s)", coinTx.CoinAmount, entryType),
+		Metadata: map[string]interface{}{
+			"birr_amount":      coinTx.BirrAmount,
+			"payment_method":   coinTx.PaymentMethod,
+			"reason":           reason,
+			"source":           source,
+			"refund_reference": refundReference,
+		},
+		Lines:          lines,
+		AllowOverdraft: true,
+		EconomyVersion: coinTx.EconomyVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Whatever was left of the purchased coins is taken back; the rest is debt
+	balanceAfter := entry.BalanceAfter(user)
+	coinsRemoved := min(coins, max(balanceAfter+coins, 0))
+	debt := max(-balanceAfter, 0)
+
+	refund := &models.CoinTransaction{
+		UserID:           coinTx.UserID,
+		TransactionType:  models.TransactionTypeRefund,
+		CoinAmount:       -int(coins),
+		BirrAmount:       coinTx.BirrAmount,
+		PaymentMethod:    coinTx.PaymentMethod,
+		PaymentReference: refundReference,
+		PaymentStatus:    models.PaymentStatusCompleted,
+		BalanceAfter:     int(balanceAfter),
+		JournalEntryID:   &entry.ID,
+		EconomyVersion:   coinTx.EconomyVersion,
+		Metadata: models.JSONMap{
+			"purchase_id":   coinTx.ID.String(),
+			"kind":          string(entryType),
+			"reason":        reason,
+			"source":        source,
+			"coins_removed": coinsRemoved,
+			"debt":          debt,
+		},
+	}
+	if err := tx.Create(refund).Error; err != nil {
+		return nil, err
+	}
+
+	if coinTx.Metadata == nil {
+		coinTx.Metadata = models.JSONMap{}
+	}
+	coinTx.Metadata["refunded_at"] = time.Now().Format(time.RFC3339)
+	coinTx.Metadata["refund_kind"] = string(entryType)
+	coinTx.Metadata["refund_reason"] = reason
+	coinTx.Metadata["refund_transaction_id"] = refund.ID.String()
+	if refundReference != "" {
+		coinTx.Metadata["refund_reference"] = refundReference
+	}
+	coinTx.PaymentStatus = models.PaymentStatusRefunded
+	if err := tx.Save(coinTx).Error; err != nil {
+		return nil, err
+	}
+
+	return &PurchaseRefund{
+		Purchase:     coinTx,
+		Refund:       refund,
+		CoinsRemoved: int(coinsRemoved),
+		Debt:         debt,
+	}, nil
+}
+
+// notifyPurchaseRefunded tells the user once the reversal is committed
+func notifyPurchaseRefunded(reversal *PurchaseRefund) {
+	if reversal.Debt > 0 {
+		log.Printf("⚠️ User %s is in debt by %d coins after reversing purchase %s",
+			reversal.Purchase.UserID, reversal.Debt, reversal.Purchase.ID)
+	}
+	if NotificationSvc == nil {
+		return
+	}
+	go func(purchase models.CoinTransaction, coinsRemoved int, debt int64) {
+		if err := NotificationSvc.NotifyPurchaseRefunded(purchase, coinsRemoved, debt); err != nil {
+			log.Printf("Failed to send refund notification: %v", err)
+		}
+	}(*reversal.Purchase, reversal.CoinsRemoved, reversal.Debt)
+}
@@ -0,0 +1,366 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"lomi-backend/config"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/ledger"
+	"lomi-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==================== PAYOUTS ====================
+// A payout moves through requested → held → approved → sent → settled.
+// Funds are held in the same transaction that creates the request: coin
+// cashouts move the coins to system:payouts_held, legacy gift-balance payouts
+// take the ETB off users.gift_balance. Rejecting a payout at any point before
+// it settles gives the funds back right away (rejected → refunded).
+// Every change is written to payout_events. The ledger entries of a payout
+// carry the economy version it was priced with.
+
+var (
+	ErrPayoutNotFound          = errors.New("payout not found")
+	ErrPayoutInsufficientFunds = errors.New("insufficient balance")
+	ErrPayoutSelfApproval      = errors.New("you can't approve your own payout")
+	ErrPayoutSameApprover      = errors.New("a second, different admin must approve this payout")
+	ErrNoApprovedPayouts       = errors.New("no approved payouts to export")
+)
+
+// PayoutTransitionError is a status change the state machine doesn't allow
+type PayoutTransitionError struct {
+	From models.PayoutStatus
+	To   models.PayoutStatus
+}
+
+func (e *PayoutTransitionError) Error() string {
+	return fmt.Sprintf("payout can't go from %s to %s", e.From, e.To)
+}
+
+var payoutTransitions = map[models.PayoutStatus][]models.PayoutStatus{
+	models.PayoutStatusRequested: {models.PayoutStatusHeld},
+	models.PayoutStatusHeld:      {models.PayoutStatusApproved, models.PayoutStatusRejected},
+	models.PayoutStatusApproved:  {models.PayoutStatusSent, models.PayoutStatusRejected},
+	models.PayoutStatusSent:      {models.PayoutStatusSettled, models.PayoutStatusRejected},
+	models.PayoutStatusRejected:  {models.PayoutStatusRefunded},
+}
+
+// transitionPayout moves a locked payout to a new status and records the event.
+// The caller saves the payout.
+func transitionPayout(tx *gorm.DB, payout *models.Payout, to models.PayoutStatus, actorID *uuid.UUID, note string) error {
+	allowed := false
+	for _, next := range payoutTransitions[payout.Status] {
+		if next == to {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return &PayoutTransitionError{From: payout.Status, To: to}
+	}
+
+	event := models.PayoutEvent{
+		PayoutID:   payout.ID,
+		FromStatus: payout.Status,
+		ToStatus:   to,
+		ActorID:    actorID,
+		Note:       note,
+	}
+	payout.Status = to
+	return tx.Create(&event).Error
+}
+
+// RequestPayout creates a payout and holds its funds atomically. Coin
+// cashouts set payout.Coins; otherwise GiftBalanceAmount is taken off the
+// gift balance.
+func RequestPayout(ctx context.Context, payout *models.Payout) error {
+	payout.Status = models.PayoutStatusRequested
+	payout.RequiresDualApproval = payout.NetAmount >= float64(config.Cfg.PayoutDualApprovalETB)
+
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(payout).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.PayoutEvent{
+			PayoutID: payout.ID,
+			ToStatus: models.PayoutStatusRequested,
+			ActorID:  &payout.UserID,
+		}).Error; err != nil {
+			return err
+		}
+
+		if payout.Coins > 0 {
+			entry, err := ledger.Post(ctx, ledger.Gorm(tx), ledger.Entry{
+				Type:           ledger.EntryCashout,
+				ReferenceType:  "payout",
+				ReferenceID:    payout.ID.String(),
+				IdempotencyKey: "payout_hold:" + payout.ID.String(),
+				Description:    fmt.Sprintf("Cashout request via %s", payout.PaymentMethod),
+				Lines:          ledger.Transfer(ledger.UserCoins(payout.UserID), ledger.System(ledger.SystemPayoutsHeld), int64(payout.Coins)),
+				EconomyVersion: payout.EconomyVersion,
+			})
+			if errors.Is(err, ledger.ErrInsufficientFunds) {
+				return ErrPayoutInsufficientFunds
+			}
+			if err != nil {
+				return err
+			}
+			payout.HoldJournalEntryID = &entry.ID
+		} else {
+			// Conditional update so two requests racing on one balance can't both pass
+			result := tx.Model(&models.User{}).
+				Where("id = ? AND gift_balance >= ?", payout.UserID, payout.GiftBalanceAmount).
+				UpdateColumn("gift_balance", gorm.Expr("gift_balance - ?", payout.GiftBalanceAmount))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrPayoutInsufficientFunds
+			}
+		}
+
+		if err := transitionPayout(tx, payout, models.PayoutStatusHeld, nil, "funds held"); err != nil {
+			return err
+		}
+		return tx.Save(payout).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("💸 Payout %s requested: %.2f ETB net via %s (dual approval: %v)",
+		payout.ID, payout.NetAmount, payout.PaymentMethod, payout.RequiresDualApproval)
+	return nil
+}
+
+// lockPayout loads a payout FOR UPDATE
+func lockPayout(tx *gorm.DB, payoutID uuid.UUID) (*models.Payout, error) {
+	var payout models.Payout
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payout, "id = ?", payoutID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPayoutNotFound
+		}
+		return nil, err
+	}
+	return &payout, nil
+}
+
+// ApprovePayout records an admin approval. Payouts that need dual approval
+// stay held after the first one; a second, different admin approves them.
+func ApprovePayout(ctx context.Context, payoutID, adminID uuid.UUID, notes string) (*models.Payout, error) {
+	var payout *models.Payout
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if payout, err = lockPayout(tx, payoutID); err != nil {
+			return err
+		}
+		if payout.UserID == adminID {
+			return ErrPayoutSelfApproval
+		}
+		if payout.Status != models.PayoutStatusHeld {
+			return &PayoutTransitionError{From: payout.Status, To: models.PayoutStatusApproved}
+		}
+		if notes != "" {
+			payout.AdminNotes = notes
+		}
+
+		now := time.Now()
+		if payout.RequiresDualApproval && payout.FirstApprovedBy == nil {
+			payout.FirstApprovedBy = &adminID
+			payout.FirstApprovedAt = &now
+			if err := tx.Create(&models.PayoutEvent{
+				PayoutID:   payout.ID,
+				FromStatus: payout.Status,
+				ToStatus:   payout.Status,
+				ActorID:    &adminID,
+				Note:       "first approval",
+			}).Error; err != nil {
+				return err
+			}
+			return tx.Save(payout).Error
+		}
+		if payout.RequiresDualApproval && *payout.FirstApprovedBy == adminID {
+			return ErrPayoutSameApprover
+		}
+
+		payout.ApprovedBy = &adminID
+		payout.ApprovedAt = &now
+		if err := transitionPayout(tx, payout, models.PayoutStatusApproved, &adminID, notes); err != nil {
+			return err
+		}
+		return tx.Save(payout).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payout, nil
+}
+
+// RejectPayout rejects a payout that hasn't settled (including a disbursement
+// that failed after export) and returns the held funds to the user.
+func RejectPayout(ctx context.Context, payoutID, adminID uuid.UUID, reason string) (*models.Payout, error) {
+	var payout *models.Payout
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if payout, err = lockPayout(tx, payoutID); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		payout.RejectedBy = &adminID
+		payout.RejectedAt = &now
+		payout.RejectionReason = reason
+		payout.ProcessedBy = &adminID
+		payout.ProcessedAt = &now
+		if err := transitionPayout(tx, payout, models.PayoutStatusRejected, &adminID, reason); err != nil {
+			return err
+		}
+
+		if err := refundPayout(ctx, tx, payout); err != nil {
+			return err
+		}
+		payout.RefundedAt = &now
+		if err := transitionPayout(tx, payout, models.PayoutStatusRefunded, nil, "funds returned"); err != nil {
+			return err
+		}
+		return tx.Save(payout).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("↩️ Payout %s rejected by %s and refunded: %s", payout.ID, adminID, reason)
+	return payout, nil
+}
+
+// refundPayout gives the held funds of a payout back to the user
+func refundPayout(ctx context.Context, tx *gorm.DB, payout *models.Payout) error {
+	if payout.Coins > 0 {
+		_, err := ledger.Post(ctx, ledger.Gorm(tx), ledger.Entry{
+			Type:           ledger.EntryPayoutRefund,
+			ReferenceType:  "payout",
+			ReferenceID:    payout.ID.String(),
+			IdempotencyKey: "payout_refund:" + payout.ID.String(),
+			Description:    "Cashout rejected, coins returned",
+			Metadata:       map[string]interface{}{"reason": payout.RejectionReason},
+			Lines:          ledger.Transfer(ledger.System(ledger.SystemPayoutsHeld), ledger.UserCoins(payout.UserID), int64(payout.Coins)),
+			EconomyVersion: payout.EconomyVersion,
+		})
+		return err
+	}
+
+	return tx.Model(&models.User{}).Where("id = ?", payout.UserID).
+		UpdateColumn("gift_balance", gorm.Expr("gift_balance + ?", payout.GiftBalanceAmount)).Error
+}
+
+// SettlePayout marks a sent payout as paid with the provider's reference. The
+// held coins of a coin cashout move to system:payouts_paid.
+func SettlePayout(ctx context.Context, payoutID, adminID uuid.UUID, paymentReference string) (*models.Payout, error) {
+	var payout *models.Payout
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if payout, err = lockPayout(tx, payoutID); err != nil {
+			return err
+		}
+		if err := transitionPayout(tx, payout, models.PayoutStatusSettled, &adminID, paymentReference); err != nil {
+			return err
+		}
+
+		if payout.Coins > 0 {
+			if _, err := ledger.Post(ctx, ledger.Gorm(tx), ledger.Entry{
+				Type:           ledger.EntryPayoutSettled,
+				ReferenceType:  "payout",
+				ReferenceID:    payout.ID.String(),
+				IdempotencyKey: "payout_settled:" + payout.ID.String(),
+				Description:    fmt.Sprintf("Cashout paid via %s", payout.PaymentMethod),
+				Metadata:       map[string]interface{}{"payment_reference": paymentReference},
+				Lines:          ledger.Transfer(ledger.System(ledger.SystemPayoutsHeld), ledger.System(ledger.SystemPayoutsPaid), int64(payout.Coins)),
+				EconomyVersion: payout.EconomyVersion,
+			}); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		payout.PaymentReference = paymentReference
+		payout.SettledAt = &now
+		payout.ProcessedBy = &adminID
+		payout.ProcessedAt = &now
+		return tx.Save(payout).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payout, nil
+}
+
+// ExportPayoutBatch puts every approved payout of a payment method into a new
+// disbursement batch and marks them sent. The batch is what finance uploads
+// for bulk disbursement; GetPayoutBatch returns it again for re-download.
+func ExportPayoutBatch(ctx context.Context, method models.PaymentMethod, adminID uuid.UUID) (*models.PayoutBatch, []models.Payout, error) {
+	var batch *models.PayoutBatch
+	var payouts []models.Payout
+
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND payment_method = ?", models.PayoutStatusApproved, method).
+			Order("approved_at ASC").
+			Find(&payouts).Error; err != nil {
+			return err
+		}
+		if len(payouts) == 0 {
+			return ErrNoApprovedPayouts
+		}
+
+		batch = &models.PayoutBatch{PaymentMethod: method, PayoutCount: len(payouts), CreatedBy: adminID}
+		for _, p := range payouts {
+			batch.TotalNetAmount += p.NetAmount
+		}
+		if err := tx.Create(batch).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for i := range payouts {
+			payout := &payouts[i]
+			if err := transitionPayout(tx, payout, models.PayoutStatusSent, &adminID, "batch "+batch.ID.String()); err != nil {
+				return err
+			}
+			payout.BatchID = &batch.ID
+			payout.SentAt = &now
+			if err := tx.Save(payout).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf("📤 Payout batch %s: %d %s payouts, %.2f ETB", batch.ID, batch.PayoutCount, method, batch.TotalNetAmount)
+	return batch, payouts, nil
+}
+
+// GetPayoutBatch loads an exported batch with its payouts
+func GetPayoutBatch(ctx context.Context, batchID uuid.UUID) (*models.PayoutBatch, []models.Payout, error) {
+	var batch models.PayoutBatch
+	if err := database.DB.WithContext(ctx).First(&batch, "id = ?", batchID).Error; err != nil {
+		return nil, nil, err
+	}
+	var payouts []models.Payout
+	if err := database.DB.WithContext(ctx).
+		Where("batch_id = ?", batchID).
+		Order("approved_at ASC").
+		Find(&payouts).Error; err != nil {
+		return nil, nil, err
+	}
+	return &batch, payouts, nil
+}
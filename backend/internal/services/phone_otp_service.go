@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+
+	"lomi-backend/internal/database"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ==================== PHONE OTP ====================
+// One-time codes sent by SMS. Codes, attempts and throttles live in Redis:
+// phone login is unavailable without it.
+
+const (
+	otpDigits      = 6
+	otpTTL         = 5 * time.Minute
+	otpMaxAttempts = 5                // Wrong codes before the code is dropped
+	otpResendAfter = 60 * time.Second // Between two codes to the same number
+	otpPhoneHourly = 5                // Codes per number per hour
+	otpIPHourly    = 20               // Codes per IP per hour
+)
+
+// OTP purposes: a login code can't be used to link a phone and vice versa
+const (
+	OTPPurposeLogin = "login"
+	OTPPurposeLink  = "link"
+)
+
+var (
+	ErrInvalidPhone       = errors.New("invalid phone number")
+	ErrOTPUnavailable     = errors.New("phone verification is not available")
+	ErrOTPExpired         = errors.New("code expired or not requested, request a new one")
+	ErrOTPInvalid         = errors.New("invalid code")
+	ErrOTPTooManyAttempts = errors.New("too many wrong codes, request a new one")
+)
+
+// OTPThrottledError is returned when a number or IP asked for too many codes
+type OTPThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *OTPThrottledError) Error() string {
+	return fmt.Sprintf("too many codes requested, try again in %ds", int(e.RetryAfter.Seconds()))
+}
+
+// OTPChallenge is a code that was sent
+type OTPChallenge struct {
+	Phone     string        `json:"phone"`
+	ExpiresIn time.Duration `json:"-"`
+	ResendIn  time.Duration `json:"-"`
+}
+
+var e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// NormalizePhone returns a number in E.164. Local Ethiopian numbers
+// (09..., 07...) get the +251 prefix.
+func NormalizePhone(raw string) (string, error) {
+	phone := strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "").Replace(strings.TrimSpace(raw))
+	switch {
+	case strings.HasPrefix(phone, "00"):
+		phone = "+" + phone[2:]
+	case strings.HasPrefix(phone, "0") && len(phone) == 10:
+		phone = "+251" + phone[1:]
+	case strings.HasPrefix(phone, "251"):
+		phone = "+" + phone
+	}
+	if !e164Pattern.MatchString(phone) {
+		return "", ErrInvalidPhone
+	}
+	return phone, nil
+}
+
+// otpScope keys a code by purpose; link codes also by the user linking
+func otpScope(purpose, subject string) string {
+	if subject == "" {
+		return purpose
+	}
+	return purpose + ":" + subject
+}
+
+func otpCodeKey(scope, phone string) string {
+	return "otp:code:" + scope + ":" + phone
+}
+
+func hashOTP(scope, phone, code string) string {
+	sum := sha256.Sum256([]byte(scope + "|" + phone + "|" + code))
+	return hex.EncodeToString(sum[:])
+}
+
+// RequestPhoneOTP sends a code to phone (already normalized). subject is the
+// user ID for link codes, empty for login.
+func RequestPhoneOTP(ctx context.Context, phone, purpose, subject, ip string) (*OTPChallenge, error) {
+	if database.RedisClient == nil {
+		return nil, ErrOTPUnavailable
+	}
+	rdb := database.RedisClient
+
+	// Resend cooldown per number, then hourly budgets per number and per IP
+	cooldownKey := "otp:cooldown:" + phone
+	ok, err := rdb.SetNX(ctx, cooldownKey, 1, otpResendAfter).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		ttl, _ := rdb.TTL(ctx, cooldownKey).Result()
+		return nil, &OTPThrottledError{RetryAfter: positiveOr(ttl, otpResendAfter)}
+	}
+	if err := otpThrottle(ctx, rdb, "otp:rate:phone:"+phone, otpPhoneHourly); err != nil {
+		return nil, err
+	}
+	if ip != "" {
+		if err := otpThrottle(ctx, rdb, "otp:rate:ip:"+ip, otpIPHourly); err != nil {
+			return nil, err
+		}
+	}
+
+	code, err := newOTPCode()
+	if err != nil {
+		return nil, err
+	}
+	scope := otpScope(purpose, subject)
+	key := otpCodeKey(scope, phone)
+	pipe := rdb.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.HSet(ctx, key, "hash", hashOTP(scope, phone, code), "attempts", 0)
+	pipe.Expire(ctx, key, otpTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	message := fmt.Sprintf("Your Lomi code is %s. It expires in %d minutes. Don't share it with anyone.", code, int(otpTTL.Minutes()))
+	if err := SendSMS(ctx, phone, message); err != nil {
+		rdb.Del(ctx, key, cooldownKey)
+		if errors.Is(err, ErrSMSUnavailable) {
+			return nil, ErrOTPUnavailable
+		}
+		return nil, fmt.Errorf("send sms: %w", err)
+	}
+
+	return &OTPChallenge{Phone: phone, ExpiresIn: otpTTL, ResendIn: otpResendAfter}, nil
+}
+
+// otpAttemptScript returns the code hash and the attempt count after counting
+// this attempt, or nil when there is no code. One script, so a code expiring
+// between the read and the increment can't be recreated without its TTL.
+var otpAttemptScript = redis.NewScript(`
+local stored = redis.call('HGET', KEYS[1], 'hash')
+if not stored then
+	return false
+end
+return {stored, redis.call('HINCRBY', KEYS[1], 'attempts', 1)}
+`)
+
+// VerifyPhoneOTP checks a code. A code works once; after otpMaxAttempts
+// wrong codes it is dropped.
+func VerifyPhoneOTP(ctx context.Context, phone, purpose, subject, code string) error {
+	if database.RedisClient == nil {
+		return ErrOTPUnavailable
+	}
+	rdb := database.RedisClient
+	scope := otpScope(purpose, subject)
+	key := otpCodeKey(scope, phone)
+
+	// Count the attempt before comparing so parallel guesses can't skip it
+	result, err := otpAttemptScript.Run(ctx, rdb, []string{key}).Slice()
+	if err == redis.Nil {
+		return ErrOTPExpired
+	}
+	if err != nil {
+		return err
+	}
+	stored, _ := result[0].(string)
+	attempts, _ := result[1].(int64)
+	if attempts > otpMaxAttempts {
+		rdb.Del(ctx, key)
+		return ErrOTPTooManyAttempts
+	}
+
+	code = strings.TrimSpace(code)
+	if subtle.ConstantTimeCompare([]byte(hashOTP(scope, phone, code)), []byte(stored)) != 1 {
+		if attempts == otpMaxAttempts {
+			rdb.Del(ctx, key)
+			return ErrOTPTooManyAttempts
+		}
+		return ErrOTPInvalid
+	}
+
+	// Single use: only the request that deletes the code wins
+	deleted, err := rdb.Del(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrOTPExpired
+	}
+	return nil
+}
+
+// otpThrottle counts one request in an hourly window
+func otpThrottle(ctx context.Context, rdb *redis.Client, key string, limit int64) error {
+	count, err := rdb.Incr(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if count == 1 {
+		rdb.Expire(ctx, key, time.Hour)
+	}
+	if count > limit {
+		ttl, _ := rdb.TTL(ctx, key).Result()
+		return &OTPThrottledError{RetryAfter: positiveOr(ttl, time.Hour)}
+	}
+	return nil
+}
+
+func newOTPCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", otpDigits, n.Int64()), nil
+}
+
+func positiveOr(d, fallback time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return fallback
+}
@@ -6,6 +6,8 @@ import (
 
 	"lomi-backend/internal/models"
 	"lomi-backend/internal/repositories"
+
+	"github.com/google/uuid"
 )
 
 type ProfileService struct {
@@ -220,9 +222,10 @@ func (s *ProfileService) ApplyReferralCode(ctx context.Context, userID string, r
 // ACCOUNT MANAGEMENT
 // ============================================
 
-// DeleteUserAccount deletes a user account
-func (s *ProfileService) DeleteUserAccount(ctx context.Context, userID string) error {
-	return s.profileRepo.DeleteUserAccount(ctx, userID)
+// DeleteUserAccount schedules the account for deletion after the grace
+// period (see RequestAccountDeletion)
+func (s *ProfileService) DeleteUserAccount(ctx context.Context, userID uuid.UUID, reason string) (*models.AccountDeletion, error) {
+	return RequestAccountDeletion(ctx, userID, reason)
 }
 
 // RequestVerification requests verification badge
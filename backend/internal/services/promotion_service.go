@@ -0,0 +1,607 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==================== PROMOTIONS ====================
+// A coin purchase can get a first-purchase bonus plus either a promo code or
+// the best running happy hour. Discounts add up (capped at 90%) and lower the
+// ETB price; bonus coins are credited on top of the paid coins from
+// system:promotions. Redemptions are created with the pending purchase, under
+// a lock on the promotion, so usage limits hold under concurrent purchases.
+// A pending purchase only reserves its redemptions for a short while, so
+// abandoned checkouts can't use up a promotion; a purchase paid after its
+// reservation lapsed is checked against the limits again when it completes.
+
+const (
+	maxPromotionDiscountPercentage = 90
+	promotionReservationTTL        = 15 * time.Minute
+)
+
+// countedRedemption is the condition (on coin_transactions ct) for a redemption
+// to count against limits: paid purchases, and pending ones still reserved
+const countedRedemption = `(ct.payment_status IN ? OR (ct.payment_status = ? AND ct.created_at > ?))`
+
+func countedRedemptionArgs() []interface{} {
+	return []interface{}{
+		[]models.PaymentStatus{models.PaymentStatusCompleted, models.PaymentStatusRefunded},
+		models.PaymentStatusPending,
+		time.Now().Add(-promotionReservationTTL),
+	}
+}
+
+var (
+	ErrPromotionNotFound      = errors.New("promotion not found")
+	ErrPromoCodeInvalid       = errors.New("promo code is invalid or expired")
+	ErrPromoCodeNotApplicable = errors.New("promo code doesn't apply to this purchase")
+	ErrPromoCodeUsedUp        = errors.New("promo code has reached its usage limit")
+	ErrPromotionChanged       = errors.New("a promotion on this purchase is no longer available, please try again")
+)
+
+// AppliedPromotion is one promotion on a quote
+type AppliedPromotion struct {
+	PromotionID  uuid.UUID            `json:"promotion_id"`
+	Kind         models.PromotionKind `json:"kind"`
+	Name         string               `json:"name"`
+	Code         string               `json:"code,omitempty"`
+	DiscountBirr float64              `json:"discount_birr"`
+	BonusCoins   int                  `json:"bonus_coins"`
+}
+
+// PurchaseQuote is the price and coins of a purchase after promotions
+type PurchaseQuote struct {
+	PackID        string             `json:"pack_id,omitempty"`
+	Coins         int                `json:"coins"`
+	BonusCoins    int                `json:"bonus_coins"`
+	BasePriceBirr float64            `json:"base_price_birr"`
+	PriceBirr     float64            `json:"price_birr"`
+	Promotions    []AppliedPromotion `json:"promotions,omitempty"`
+}
+
+// promotionCandidates are the promotions a user could get right now
+type promotionCandidates struct {
+	firstPurchase []models.Promotion // Empty once the user has bought coins
+	happyHours    []models.Promotion
+	code          *models.Promotion
+}
+
+// loadPromotionCandidates loads the running promotions the user is still
+// within the limits of. A promo code that is unknown, not running or used up
+// is an error.
+func loadPromotionCandidates(ctx context.Context, db *gorm.DB, userID uuid.UUID, promoCode string) (*promotionCandidates, error) {
+	now := time.Now()
+	candidates := &promotionCandidates{}
+
+	var running []models.Promotion
+	if err := db.WithContext(ctx).
+		Where("kind IN ? AND is_active = ?", []models.PromotionKind{models.PromotionKindFirstPurchase, models.PromotionKindHappyHour}, true).
+		Find(&running).Error; err != nil {
+		return nil, fmt.Errorf("failed to load promotions: %w", err)
+	}
+
+	firstPurchase, err := isFirstPurchase(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range running {
+		if !p.IsRunningAt(now) {
+			continue
+		}
+		ok, err := promotionWithinLimits(ctx, db, &p, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		switch p.Kind {
+		case models.PromotionKindFirstPurchase:
+			if firstPurchase {
+				candidates.firstPurchase = append(candidates.firstPurchase, p)
+			}
+		case models.PromotionKindHappyHour:
+			candidates.happyHours = append(candidates.happyHours, p)
+		}
+	}
+
+	if promoCode = strings.ToUpper(strings.TrimSpace(promoCode)); promoCode != "" {
+		var code models.Promotion
+		result := db.WithContext(ctx).
+			Where("kind = ? AND code = ?", models.PromotionKindPromoCode, promoCode).
+			Limit(1).Find(&code)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 || !code.IsRunningAt(now) {
+			return nil, ErrPromoCodeInvalid
+		}
+		ok, err := promotionWithinLimits(ctx, db, &code, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrPromoCodeUsedUp
+		}
+		candidates.code = &code
+	}
+	return candidates, nil
+}
+
+// isFirstPurchase reports whether the user has never paid for coins and has no
+// first-purchase bonus reserved on a pending purchase
+func isFirstPurchase(ctx context.Context, db *gorm.DB, userID uuid.UUID) (bool, error) {
+	var count int64
+	args := []interface{}{userID, models.TransactionTypePurchase,
+		[]models.PaymentStatus{models.PaymentStatusCompleted, models.PaymentStatusRefunded},
+		userID, models.PromotionKindFirstPurchase}
+	err := db.WithContext(ctx).Raw(`
+		SELECT
+			(SELECT COUNT(*) FROM coin_transactions
+				WHERE user_id = ? AND transaction_type = ? AND payment_status IN ?)
+			+ (SELECT COUNT(*) FROM promotion_redemptions r
+				JOIN promotions p ON p.id = r.promotion_id
+				JOIN coin_transactions ct ON ct.id = r.coin_transaction_id
+				WHERE r.user_id = ? AND p.kind = ? AND `+countedRedemption+`)
+	`, append(args, countedRedemptionArgs()...)...).Scan(&count).Error
+	return count == 0, err
+}
+
+// promotionWithinLimits checks the global and per-user limits. Only redemptions
+// on paid purchases and on pending purchases still within their reservation count.
+func promotionWithinLimits(ctx context.Context, db *gorm.DB, p *models.Promotion, userID uuid.UUID) (bool, error) {
+	if p.MaxRedemptions == nil && p.MaxPerUser == nil {
+		return true, nil
+	}
+	var usage struct {
+		Total  int
+		ByUser int
+	}
+	if err := db.WithContext(ctx).Raw(`
+		SELECT COUNT(*) AS total, COUNT(*) FILTER (WHERE r.user_id = ?) AS by_user
+		FROM promotion_redemptions r
+		JOIN coin_transactions ct ON ct.id = r.coin_transaction_id
+		WHERE r.promotion_id = ? AND `+countedRedemption+`
+	`, append([]interface{}{userID, p.ID}, countedRedemptionArgs()...)...).Scan(&usage).Error; err != nil {
+		return false, fmt.Errorf("failed to count promotion usage: %w", err)
+	}
+	if p.MaxRedemptions != nil && usage.Total >= *p.MaxRedemptions {
+		return false, nil
+	}
+	if p.MaxPerUser != nil && usage.ByUser >= *p.MaxPerUser {
+		return false, nil
+	}
+	return true, nil
+}
+
+// quote prices a purchase with the candidates. strictCode makes a promo code
+// that doesn't cover the pack an error instead of being skipped.
+func (pc *promotionCandidates) quote(packID string, coins int, priceBirr float64, strictCode bool) (*PurchaseQuote, error) {
+	var applied []*models.Promotion
+
+	if best := bestPromotion(pc.firstPurchase, packID, coins, priceBirr); best != nil {
+		applied = append(applied, best)
+	}
+	switch {
+	case pc.code != nil && pc.code.AppliesToPack(packID):
+		applied = append(applied, pc.code)
+	case pc.code != nil && strictCode:
+		return nil, ErrPromoCodeNotApplicable
+	default:
+		if best := bestPromotion(pc.happyHours, packID, coins, priceBirr); best != nil {
+			applied = append(applied, best)
+		}
+	}
+
+	quote := &PurchaseQuote{
+		PackID:        packID,
+		Coins:         coins,
+		BasePriceBirr: priceBirr,
+		PriceBirr:     priceBirr,
+	}
+	discountLeft := maxPromotionDiscountPercentage
+	for _, p := range applied {
+		percentage := min(p.DiscountPercentage, discountLeft)
+		discountLeft -= percentage
+
+		a := AppliedPromotion{
+			PromotionID:  p.ID,
+			Kind:         p.Kind,
+			Name:         p.Name,
+			DiscountBirr: roundBirr(priceBirr * float64(percentage) / 100),
+			BonusCoins:   promotionBonus(p, coins),
+		}
+		if p.Code != nil {
+			a.Code = *p.Code
+		}
+		quote.PriceBirr = roundBirr(quote.PriceBirr - a.DiscountBirr)
+		quote.BonusCoins += a.BonusCoins
+		quote.Promotions = append(quote.Promotions, a)
+	}
+	return quote, nil
+}
+
+// bestPromotion picks the promotion worth the most to the user for a purchase
+func bestPromotion(promotions []models.Promotion, packID string, coins int, priceBirr float64) *models.Promotion {
+	var best *models.Promotion
+	bestValue := 0.0
+	for i := range promotions {
+		p := &promotions[i]
+		if !p.AppliesToPack(packID) {
+			continue
+		}
+		// Compare in coins: the discount is worth what it would buy at the pack's price
+		value := float64(promotionBonus(p, coins)) + float64(coins)*float64(p.DiscountPercentage)/100
+		if best == nil || value > bestValue {
+			best, bestValue = p, value
+		}
+	}
+	return best
+}
+
+func promotionBonus(p *models.Promotion, coins int) int {
+	return coins*p.BonusPercentage/100 + p.BonusCoins
+}
+
+// QuotePurchase prices one purchase for a user: a pack, or a custom amount
+// with an empty packID
+func QuotePurchase(ctx context.Context, userID uuid.UUID, packID string, coins int, priceBirr float64, promoCode string) (*PurchaseQuote, error) {
+	candidates, err := loadPromotionCandidates(ctx, database.DB, userID, promoCode)
+	if err != nil {
+		return nil, err
+	}
+	return candidates.quote(packID, coins, priceBirr, true)
+}
+
+// QuoteCoinPacks prices every pack for a user. A promo code is only applied
+// to the packs it covers.
+func QuoteCoinPacks(ctx context.Context, userID uuid.UUID, packs []models.CoinPack, promoCode string) ([]PurchaseQuote, error) {
+	candidates, err := loadPromotionCandidates(ctx, database.DB, userID, promoCode)
+	if err != nil {
+		return nil, err
+	}
+	quotes := make([]PurchaseQuote, 0, len(packs))
+	for _, pack := range packs {
+		quote, err := candidates.quote(pack.ID, pack.Coins, pack.ETBPrice, false)
+		if err != nil {
+			return nil, err
+		}
+		quotes = append(quotes, *quote)
+	}
+	return quotes, nil
+}
+
+// CreateCoinPurchase creates a pending purchase priced by quote and records
+// its promotion redemptions in the same transaction. The promotions are
+// locked and their limits checked again, so two purchases can't both take
+// the last redemption.
+func CreateCoinPurchase(ctx context.Context, coinTx *models.CoinTransaction, quote *PurchaseQuote) error {
+	coinTx.CoinAmount = quote.Coins
+	coinTx.BonusCoins = quote.BonusCoins
+	coinTx.BirrAmount = quote.PriceBirr
+	if len(quote.Promotions) > 0 {
+		if coinTx.Metadata == nil {
+			coinTx.Metadata = models.JSONMap{}
+		}
+		coinTx.Metadata["base_price_birr"] = quote.BasePriceBirr
+		coinTx.Metadata["promotions"] = quote.Promotions
+	}
+
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		applied := make([]AppliedPromotion, len(quote.Promotions))
+		copy(applied, quote.Promotions)
+		sort.Slice(applied, func(i, j int) bool { return applied[i].PromotionID.String() < applied[j].PromotionID.String() })
+
+		now := time.Now()
+		for _, a := range applied {
+			var p models.Promotion
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&p, "id = ?", a.PromotionID).Error; err != nil {
+				return err
+			}
+			ok, err := promotionWithinLimits(ctx, tx, &p, coinTx.UserID)
+			if err != nil {
+				return err
+			}
+			if ok && p.Kind == models.PromotionKindFirstPurchase {
+				ok, err = isFirstPurchase(ctx, tx, coinTx.UserID)
+				if err != nil {
+					return err
+				}
+			}
+			if !ok || !p.IsRunningAt(now) {
+				return ErrPromotionChanged
+			}
+		}
+
+		if err := tx.Create(coinTx).Error; err != nil {
+			return err
+		}
+		for _, a := range quote.Promotions {
+			if err := tx.Create(&models.PromotionRedemption{
+				PromotionID:       a.PromotionID,
+				UserID:            coinTx.UserID,
+				CoinTransactionID: coinTx.ID,
+				DiscountBirr:      a.DiscountBirr,
+				BonusCoins:        a.BonusCoins,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// recheckPromotionLimits runs before a purchase whose reservation lapsed (it
+// failed, or stayed pending too long) is completed. Its redemptions stopped
+// counting, so each promotion is locked and checked again; the bonus coins of
+// those now over their limits are withheld and the redemption is dropped. The
+// discount was already paid at the lower price and stays.
+func recheckPromotionLimits(ctx context.Context, tx *gorm.DB, coinTx *models.CoinTransaction) error {
+	if coinTx.PaymentStatus == models.PaymentStatusPending && time.Since(coinTx.CreatedAt) < promotionReservationTTL {
+		return nil
+	}
+
+	var redemptions []models.PromotionRedemption
+	if err := tx.Where("coin_transaction_id = ?", coinTx.ID).
+		Order("promotion_id").Find(&redemptions).Error; err != nil {
+		return err
+	}
+
+	var withheld []string
+	for _, r := range redemptions {
+		var p models.Promotion
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&p, "id = ?", r.PromotionID).Error; err != nil {
+			return err
+		}
+		ok, err := promotionWithinLimits(ctx, tx, &p, coinTx.UserID)
+		if err != nil {
+			return err
+		}
+		if ok && p.Kind == models.PromotionKindFirstPurchase {
+			if ok, err = isFirstPurchase(ctx, tx, coinTx.UserID); err != nil {
+				return err
+			}
+		}
+		if ok {
+			continue
+		}
+
+		if err := tx.Delete(&r).Error; err != nil {
+			return err
+		}
+		coinTx.BonusCoins = max(coinTx.BonusCoins-r.BonusCoins, 0)
+		withheld = append(withheld, p.ID.String())
+		log.Printf("🏷️ Promotion %s is used up, withholding %d bonus coins on late purchase %s", p.ID, r.BonusCoins, coinTx.ID)
+	}
+
+	if len(withheld) > 0 {
+		if coinTx.Metadata == nil {
+			coinTx.Metadata = models.JSONMap{}
+		}
+		coinTx.Metadata["withheld_promotions"] = withheld
+	}
+	return nil
+}
+
+// ==================== ADMIN ====================
+
+// PromotionInput is the admin create/update payload. Nil fields are left unchanged on update.
+type PromotionInput struct {
+	Kind               *string    `json:"kind"`
+	Name               *string    `json:"name"`
+	Code               *string    `json:"code"`
+	DiscountPercentage *int       `json:"discount_percentage"`
+	BonusPercentage    *int       `json:"bonus_percentage"`
+	BonusCoins         *int       `json:"bonus_coins"`
+	PackIDs            *[]string  `json:"pack_ids"`
+	StartsAt           *time.Time `json:"starts_at"`
+	EndsAt             *time.Time `json:"ends_at"`
+	MaxRedemptions     *int       `json:"max_redemptions"`
+	MaxPerUser         *int       `json:"max_per_user"`
+	IsActive           *bool      `json:"is_active"`
+	ClearWindow        bool       `json:"clear_window"` // Remove both window bounds
+	ClearLimits        bool       `json:"clear_limits"` // Remove both usage limits
+}
+
+// PromotionUsage is a promotion with its redemptions on purchases that didn't fail
+type PromotionUsage struct {
+	models.Promotion
+	Redemptions  int     `json:"redemptions"`
+	DiscountBirr float64 `json:"discount_birr"`
+	BonusCoins   int     `json:"bonus_coins"`
+}
+
+// ListPromotions returns every promotion with its usage, newest first
+func ListPromotions(ctx context.Context) ([]PromotionUsage, error) {
+	var promotions []models.Promotion
+	if err := database.DB.WithContext(ctx).Order("created_at DESC").Find(&promotions).Error; err != nil {
+		return nil, err
+	}
+
+	var usage []struct {
+		PromotionID  uuid.UUID
+		Redemptions  int
+		DiscountBirr float64
+		BonusCoins   int
+	}
+	if err := database.DB.WithContext(ctx).Raw(`
+		SELECT r.promotion_id, COUNT(*) AS redemptions,
+			COALESCE(SUM(r.discount_birr), 0) AS discount_birr,
+			COALESCE(SUM(r.bonus_coins), 0) AS bonus_coins
+		FROM promotion_redemptions r
+		JOIN coin_transactions ct ON ct.id = r.coin_transaction_id
+		WHERE ct.payment_status <> ?
+		GROUP BY r.promotion_id
+	`, models.PaymentStatusFailed).Scan(&usage).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]PromotionUsage, len(promotions))
+	for i, p := range promotions {
+		result[i].Promotion = p
+		for _, u := range usage {
+			if u.PromotionID == p.ID {
+				result[i].Redemptions = u.Redemptions
+				result[i].DiscountBirr = u.DiscountBirr
+				result[i].BonusCoins = u.BonusCoins
+			}
+		}
+	}
+	return result, nil
+}
+
+// CreatePromotion adds a promotion
+func CreatePromotion(ctx context.Context, input PromotionInput, adminID uuid.UUID) (*models.Promotion, error) {
+	promotion := models.Promotion{IsActive: true, CreatedBy: &adminID, PackIDs: models.JSONStringArray{}}
+	applyPromotionInput(&promotion, input)
+	if err := validatePromotion(&promotion); err != nil {
+		return nil, err
+	}
+
+	if err := database.DB.WithContext(ctx).Create(&promotion).Error; err != nil {
+		return nil, fmt.Errorf("failed to create promotion: %w", err)
+	}
+	log.Printf("🏷️ Promotion created: %s (%s, %s)", promotion.Name, promotion.Kind, promotion.ID)
+	return &promotion, nil
+}
+
+// UpdatePromotion changes a promotion. Purchases already created keep the
+// price and bonus they were quoted.
+func UpdatePromotion(ctx context.Context, id uuid.UUID, input PromotionInput) (*models.Promotion, error) {
+	var promotion models.Promotion
+	if err := database.DB.WithContext(ctx).First(&promotion, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPromotionNotFound
+		}
+		return nil, err
+	}
+
+	kind := promotion.Kind
+	applyPromotionInput(&promotion, input)
+	if promotion.Kind != kind {
+		return nil, errors.New("kind can't be changed")
+	}
+	if err := validatePromotion(&promotion); err != nil {
+		return nil, err
+	}
+
+	promotion.UpdatedAt = time.Now()
+	if err := database.DB.WithContext(ctx).Save(&promotion).Error; err != nil {
+		return nil, fmt.Errorf("failed to update promotion: %w", err)
+	}
+	log.Printf("🏷️ Promotion updated: %s (%s)", promotion.Name, promotion.ID)
+	return &promotion, nil
+}
+
+// DeactivatePromotion ends a promotion. Promotions are never deleted because
+// redemptions reference them.
+func DeactivatePromotion(ctx context.Context, id uuid.UUID) error {
+	result := database.DB.WithContext(ctx).Model(&models.Promotion{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"is_active": false, "updated_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPromotionNotFound
+	}
+	log.Printf("🏷️ Promotion deactivated: %s", id)
+	return nil
+}
+
+func applyPromotionInput(p *models.Promotion, input PromotionInput) {
+	if input.Kind != nil {
+		p.Kind = models.PromotionKind(*input.Kind)
+	}
+	if input.Name != nil {
+		p.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Code != nil {
+		code := strings.ToUpper(strings.TrimSpace(*input.Code))
+		p.Code = &code
+	}
+	if input.DiscountPercentage != nil {
+		p.DiscountPercentage = *input.DiscountPercentage
+	}
+	if input.BonusPercentage != nil {
+		p.BonusPercentage = *input.BonusPercentage
+	}
+	if input.BonusCoins != nil {
+		p.BonusCoins = *input.BonusCoins
+	}
+	if input.PackIDs != nil {
+		p.PackIDs = models.JSONStringArray(*input.PackIDs)
+	}
+	if input.ClearWindow {
+		p.StartsAt = nil
+		p.EndsAt = nil
+	}
+	if input.StartsAt != nil {
+		p.StartsAt = input.StartsAt
+	}
+	if input.EndsAt != nil {
+		p.EndsAt = input.EndsAt
+	}
+	if input.ClearLimits {
+		p.MaxRedemptions = nil
+		p.MaxPerUser = nil
+	}
+	if input.MaxRedemptions != nil {
+		p.MaxRedemptions = input.MaxRedemptions
+	}
+	if input.MaxPerUser != nil {
+		p.MaxPerUser = input.MaxPerUser
+	}
+	if input.IsActive != nil {
+		p.IsActive = *input.IsActive
+	}
+}
+
+func validatePromotion(p *models.Promotion) error {
+	if !p.Kind.IsValid() {
+		return errors.New("kind must be first_purchase, happy_hour or promo_code")
+	}
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	if p.Kind == models.PromotionKindPromoCode {
+		if p.Code == nil || *p.Code == "" {
+			return errors.New("code is required for promo codes")
+		}
+	} else {
+		p.Code = nil
+	}
+	if p.Kind == models.PromotionKindHappyHour && (p.StartsAt == nil || p.EndsAt == nil) {
+		return errors.New("happy hours need starts_at and ends_at")
+	}
+	if p.StartsAt != nil && p.EndsAt != nil && !p.StartsAt.Before(*p.EndsAt) {
+		return errors.New("starts_at must be before ends_at")
+	}
+	if p.DiscountPercentage < 0 || p.DiscountPercentage > maxPromotionDiscountPercentage {
+		return fmt.Errorf("discount_percentage must be between 0 and %d", maxPromotionDiscountPercentage)
+	}
+	if p.BonusPercentage < 0 || p.BonusCoins < 0 {
+		return errors.New("bonus_percentage and bonus_coins can't be negative")
+	}
+	if p.DiscountPercentage == 0 && p.BonusPercentage == 0 && p.BonusCoins == 0 {
+		return errors.New("a promotion needs a discount or bonus coins")
+	}
+	if (p.MaxRedemptions != nil && *p.MaxRedemptions <= 0) || (p.MaxPerUser != nil && *p.MaxPerUser <= 0) {
+		return errors.New("usage limits must be greater than 0")
+	}
+	return nil
+}
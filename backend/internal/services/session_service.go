@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/geoip"
+	"lomi-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ==================== SESSIONS ====================
+// Every login (refresh token family) has a user_sessions row: the device, app
+// version, IP and its GeoIP location, first and last seen. Last seen moves
+// when the session refreshes its tokens. A login from a device or country the
+// account hasn't used before is announced on Telegram, so a stolen login can
+// be spotted and removed from the device list.
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// describeDevice fills in the device name and platform from the user agent
+// when the app didn't send them
+func describeDevice(device *DeviceInfo) {
+	ua := strings.ToLower(device.UserAgent)
+	if device.Platform == "" {
+		switch {
+		case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ios"):
+			device.Platform = "ios"
+		case strings.Contains(ua, "android"), strings.Contains(ua, "okhttp"):
+			device.Platform = "android"
+		case strings.Contains(ua, "windows"):
+			device.Platform = "windows"
+		case strings.Contains(ua, "macintosh"), strings.Contains(ua, "mac os"):
+			device.Platform = "macos"
+		case strings.Contains(ua, "linux"):
+			device.Platform = "linux"
+		case ua == "":
+			device.Platform = "unknown"
+		default:
+			device.Platform = "web"
+		}
+	}
+	if device.DeviceName != "" {
+		return
+	}
+
+	client := ""
+	switch {
+	case strings.Contains(ua, "telegram"):
+		client = "Telegram"
+	case strings.Contains(ua, "edg/"):
+		client = "Edge"
+	case strings.Contains(ua, "firefox/"):
+		client = "Firefox"
+	case strings.Contains(ua, "chrome/"):
+		client = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		client = "Safari"
+	case strings.Contains(ua, "dart/"), strings.Contains(ua, "okhttp"), strings.Contains(ua, "cfnetwork"):
+		client = "Lomi app"
+	}
+	platform := map[string]string{
+		"ios": "iOS", "android": "Android", "windows": "Windows", "macos": "macOS", "linux": "Linux",
+	}[device.Platform]
+	switch {
+	case client != "" && platform != "":
+		device.DeviceName = client + " on " + platform
+	case client != "":
+		device.DeviceName = client
+	case platform != "":
+		device.DeviceName = platform
+	default:
+		device.DeviceName = "Unknown device"
+	}
+}
+
+// newSession describes a login that starts now
+func newSession(userID, familyID uuid.UUID, device DeviceInfo, expiresAt time.Time) *models.UserSession {
+	describeDevice(&device)
+	loc := geoip.Lookup(device.IP)
+	now := time.Now()
+	session := &models.UserSession{
+		ID:          familyID,
+		UserID:      userID,
+		DeviceName:  truncate(device.DeviceName, 100),
+		Platform:    truncate(device.Platform, 20),
+		AppVersion:  truncate(device.AppVersion, 50),
+		UserAgent:   device.UserAgent,
+		IPAddress:   device.IP,
+		CountryCode: loc.CountryCode,
+		Region:      loc.Region,
+		City:        loc.City,
+		FirstSeenAt: now,
+		LastSeenAt:  now,
+		ExpiresAt:   expiresAt,
+	}
+	if device.DeviceID != "" {
+		session.DeviceID = &device.DeviceID
+	}
+	return session
+}
+
+// touchSession records a refresh of a session: last seen, the IP it came
+// from and the app version
+func touchSession(tx *gorm.DB, userID, familyID uuid.UUID, device DeviceInfo, expiresAt time.Time) error {
+	loc := geoip.Lookup(device.IP)
+	updates := map[string]interface{}{
+		"last_seen_at": time.Now(),
+		"expires_at":   expiresAt,
+		"ip_address":   device.IP,
+		"country_code": loc.CountryCode,
+		"region":       loc.Region,
+		"city":         loc.City,
+	}
+	if device.AppVersion != "" {
+		updates["app_version"] = truncate(device.AppVersion, 50)
+	}
+	result := tx.Model(&models.UserSession{}).Where("id = ? AND user_id = ?", familyID, userID).Updates(updates)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	// Login from before user_sessions existed
+	return tx.Create(newSession(userID, familyID, device, expiresAt)).Error
+}
+
+// loginAlert is a login from a device or country the account hasn't used before
+type loginAlert struct {
+	Session    models.UserSession
+	NewDevice  bool
+	NewCountry bool
+}
+
+// checkNewLogin compares a login with the account's earlier sessions. The
+// first login of an account is not announced.
+func checkNewLogin(tx *gorm.DB, session *models.UserSession) (*loginAlert, error) {
+	var earlier int64
+	if err := tx.Model(&models.UserSession{}).Where("user_id = ?", session.UserID).Count(&earlier).Error; err != nil || earlier == 0 {
+		return nil, err
+	}
+
+	alert := &loginAlert{Session: *session}
+	known := tx.Model(&models.UserSession{}).Where("user_id = ?", session.UserID)
+	if session.DeviceID != nil {
+		known = known.Where("device_id = ?", *session.DeviceID)
+	} else {
+		known = known.Where("device_id IS NULL AND user_agent = ?", session.UserAgent)
+	}
+	var count int64
+	if err := known.Count(&count).Error; err != nil {
+		return nil, err
+	}
+	alert.NewDevice = count == 0
+
+	if session.CountryCode != "" {
+		if err := tx.Model(&models.UserSession{}).
+			Where("user_id = ? AND country_code = ?", session.UserID, session.CountryCode).
+			Count(&count).Error; err != nil {
+			return nil, err
+		}
+		alert.NewCountry = count == 0
+	}
+
+	if !alert.NewDevice && !alert.NewCountry {
+		return nil, nil
+	}
+	return alert, nil
+}
+
+// sendLoginAlert tells the user about a new login on Telegram. Accounts
+// without Telegram aren't notified.
+func sendLoginAlert(alert *loginAlert) {
+	if NotificationSvc == nil {
+		return
+	}
+	var user models.User
+	if err := database.DB.Select("id", "telegram_id").First(&user, "id = ?", alert.Session.UserID).Error; err != nil || user.TelegramID == nil {
+		return
+	}
+
+	session := alert.Session
+	location := "Unknown location"
+	if session.CountryCode != "" {
+		parts := []string{}
+		for _, part := range []string{session.City, session.Region, session.CountryCode} {
+			if part != "" {
+				parts = append(parts, part)
+			}
+		}
+		location = strings.Join(parts, ", ")
+	}
+	title := "🔐 New login to your Lomi account"
+	if !alert.NewDevice {
+		title = "🔐 Login to your Lomi account from a new country"
+	}
+	message := fmt.Sprintf("%s\n\nDevice: %s\nLocation: %s\nIP: %s\nTime: %s UTC\n\nIf this wasn't you, open Lomi → Settings → Devices, remove this device and log out of your other sessions.",
+		title, session.DeviceName, location, session.IPAddress, session.FirstSeenAt.UTC().Format("2006-01-02 15:04"))
+
+	if err := NotificationSvc.SendTelegramMessage(*user.TelegramID, message); err != nil {
+		log.Printf("⚠️ Failed to send login alert to user %s: %v", user.ID, err)
+	}
+}
+
+// ListSessions returns the user's active logins, most recently used first
+func ListSessions(ctx context.Context, userID uuid.UUID) ([]models.UserSession, error) {
+	var sessions []models.UserSession
+	err := database.DB.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// RevokeSession logs out one of the user's logins from another device
+func RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	var count int64
+	if err := database.DB.WithContext(ctx).Model(&models.UserSession{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrSessionNotFound
+	}
+	return revokeSession(ctx, userID, sessionID, models.RefreshRevokeRemote)
+}
+
+// truncate cuts s to at most max bytes without splitting a character
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
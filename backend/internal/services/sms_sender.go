@@ -0,0 +1,134 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"lomi-backend/config"
+)
+
+// ==================== SMS ====================
+
+// SMSSender delivers a text message to a phone number (E.164)
+type SMSSender interface {
+	Send(ctx context.Context, to, message string) error
+}
+
+// ErrSMSUnavailable means no SMS sender is configured
+var ErrSMSUnavailable = errors.New("sms is not available")
+
+var smsSender SMSSender
+
+// InitSMS picks the sender from SMS_PROVIDER: "http" posts to SMS_GATEWAY_URL,
+// "log" (default) is the local fake. The fake is never used in production:
+// phone login stays off there until a gateway is configured.
+func InitSMS(cfg *config.Config) error {
+	switch cfg.SMSProvider {
+	case "http":
+		if cfg.SMSGatewayURL == "" {
+			return errors.New("SMS_GATEWAY_URL is required for the http sms provider")
+		}
+		smsSender = &httpSMSSender{
+			url:      cfg.SMSGatewayURL,
+			token:    cfg.SMSGatewayToken,
+			senderID: cfg.SMSSenderID,
+			client:   &http.Client{Timeout: 10 * time.Second},
+		}
+	case "", "log":
+		if cfg.AppEnv == "production" {
+			smsSender = nil
+			log.Printf("⚠️ No SMS gateway configured: phone login is disabled")
+			return nil
+		}
+		smsSender = &LogSMSSender{Path: cfg.SMSFakeFile}
+		log.Printf("⚠️ SMS goes to the log (fake sender): no messages are delivered")
+	default:
+		return fmt.Errorf("unknown SMS_PROVIDER %q", cfg.SMSProvider)
+	}
+	return nil
+}
+
+// SendSMS sends through the configured sender
+func SendSMS(ctx context.Context, to, message string) error {
+	if smsSender == nil {
+		return ErrSMSUnavailable
+	}
+	return smsSender.Send(ctx, to, message)
+}
+
+// LogSMSSender is the local fake: messages are logged and, when Path is set,
+// appended to that file as JSON lines so tests can read the codes back.
+type LogSMSSender struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (s *LogSMSSender) Send(ctx context.Context, to, message string) error {
+	log.Printf("📱 [fake sms] to=%s: %s", to, message)
+	if s.Path == "" {
+		return nil
+	}
+
+	line, err := json.Marshal(map[string]interface{}{
+		"to":      to,
+		"message": message,
+		"sent_at": time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// httpSMSSender posts {to, message, sender} as JSON to an SMS gateway
+type httpSMSSender struct {
+	url      string
+	token    string
+	senderID string
+	client   *http.Client
+}
+
+func (s *httpSMSSender) Send(ctx context.Context, to, message string) error {
+	payload, _ := json.Marshal(map[string]string{
+		"to":      to,
+		"message": message,
+		"sender":  s.senderID,
+	})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("sms gateway returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"lomi-backend/config"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/utils"
+)
+
+// ==================== TELEGRAM INIT DATA ====================
+// Mini App initData is validated by utils.ValidateTelegramInitData and then
+// accepted once: its verified hash (or Ed25519 signature) is kept in Redis
+// until the initData would have expired anyway, so a captured initData can't
+// be replayed, however its query string is reordered or re-encoded. When Redis is
+// unavailable replays aren't detected rather than blocking logins.
+
+var telegramValidation utils.TelegramValidation
+
+// InitTelegramAuth loads the initData validation settings
+func InitTelegramAuth(cfg *config.Config) error {
+	maxAge, err := time.ParseDuration(cfg.TelegramInitDataMaxAge)
+	if err != nil || maxAge <= 0 {
+		return fmt.Errorf("invalid TELEGRAM_INIT_DATA_MAX_AGE %q", cfg.TelegramInitDataMaxAge)
+	}
+	var botID int64
+	if cfg.TelegramBotID != "" {
+		if botID, err = strconv.ParseInt(cfg.TelegramBotID, 10, 64); err != nil {
+			return fmt.Errorf("invalid TELEGRAM_BOT_ID %q", cfg.TelegramBotID)
+		}
+	}
+
+	telegramValidation = utils.TelegramValidation{
+		BotToken:        cfg.TelegramBotToken,
+		BotID:           botID,
+		TestEnvironment: cfg.TelegramTestEnv,
+		MaxAge:          maxAge,
+	}
+	if cfg.TelegramBotToken == "" && botID != 0 {
+		log.Printf("⚠️ No Telegram bot token: initData is checked by Telegram's signature only")
+	}
+	return nil
+}
+
+func telegramReplayKey(data *utils.TelegramInitData) string {
+	return "tg:initdata:" + hex.EncodeToString(data.Proof)
+}
+
+// VerifyTelegramInitData validates initData and accepts it once. Errors are
+// *utils.TelegramInitDataError.
+func VerifyTelegramInitData(ctx context.Context, initData string) (*utils.TelegramInitData, error) {
+	data, err := utils.ValidateTelegramInitData(initData, telegramValidation)
+	if err != nil {
+		return nil, err
+	}
+
+	if database.RedisClient == nil {
+		return data, nil
+	}
+	// Keep it until it expires, plus the allowed clock skew
+	ttl := time.Until(data.AuthDate.Add(telegramValidation.MaxAge)) + time.Minute
+	first, err := database.RedisClient.SetNX(ctx, telegramReplayKey(data), 1, ttl).Result()
+	if err != nil {
+		log.Printf("⚠️ Telegram replay check skipped: %v", err)
+		return data, nil
+	}
+	if !first {
+		log.Printf("🚨 Telegram initData replayed: telegram_id=%d", data.User.ID)
+		return nil, &utils.TelegramInitDataError{
+			Code:    utils.TelegramErrReplayed,
+			Message: "initData was already used, reopen the Mini App",
+		}
+	}
+	return data, nil
+}
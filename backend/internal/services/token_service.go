@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"lomi-backend/internal/auth"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==================== AUTH TOKENS ====================
+// Every login starts a refresh token family. Refresh tokens are single use:
+// refreshing marks the presented token used and issues the next token of the
+// family. Presenting a used token again means it was stolen (or the client
+// is replaying it), so the whole family is revoked. Revoking a family also
+// revokes the access tokens issued with it: refresh_tokens records it, and
+// their access_uuid is cached in Redis until they expire so most lookups
+// stop there (see auth.IsAccessTokenRevoked). Signing and parsing live in internal/auth.
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used, all sessions of this login were revoked")
+)
+
+// DeviceInfo identifies where a token was issued
+type DeviceInfo struct {
+	DeviceID   string
+	DeviceName string // Optional, derived from the user agent when empty
+	Platform   string // Optional, derived from the user agent when empty
+	AppVersion string
+	UserAgent  string
+	IP         string
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueInFamily signs a token pair and stores its refresh token. The family
+// ID is the session ID (sid) of both tokens.
+func issueInFamily(tx *gorm.DB, user *models.User, familyID uuid.UUID, device DeviceInfo) (*auth.TokenDetails, *models.RefreshToken, error) {
+	tokens, err := auth.CreateToken(user.ID, user.Role, familyID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	row := &models.RefreshToken{
+		ID:              tokens.RefreshUUID,
+		UserID:          user.ID,
+		FamilyID:        familyID,
+		TokenHash:       hashToken(tokens.RefreshToken),
+		AccessUUID:      tokens.AccessUUID,
+		AccessExpiresAt: tokens.AtExpires,
+		UserAgent:       device.UserAgent,
+		IPAddress:       device.IP,
+		ExpiresAt:       tokens.RtExpires,
+	}
+	if device.DeviceID != "" {
+		row.DeviceID = &device.DeviceID
+	}
+	if err := tx.Create(row).Error; err != nil {
+		return nil, nil, err
+	}
+	return tokens, row, nil
+}
+
+// IssueTokens starts a new login for a user. A previous login from the same
+// device is revoked. A login from a new device or country is announced.
+func IssueTokens(ctx context.Context, userID uuid.UUID, device DeviceInfo) (*auth.TokenDetails, error) {
+	var tokens *auth.TokenDetails
+	var revoked []models.RefreshToken
+	var alert *loginAlert
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Select("id", "role").First(&user, "id = ?", userID).Error; err != nil {
+			return err
+		}
+
+		if device.DeviceID != "" {
+			var families []uuid.UUID
+			if err := tx.Model(&models.RefreshToken{}).
+				Where("user_id = ? AND device_id = ? AND revoked_at IS NULL", userID, device.DeviceID).
+				Distinct().Pluck("family_id", &families).Error; err != nil {
+				return err
+			}
+			var err error
+			if revoked, err = revokeFamilies(tx, families, models.RefreshRevokeNewLogin); err != nil {
+				return err
+			}
+		}
+
+		familyID := uuid.New()
+		var err error
+		tokens, _, err = issueInFamily(tx, &user, familyID, device)
+		if err != nil {
+			return err
+		}
+		session := newSession(userID, familyID, device, tokens.RtExpires)
+		if alert, err = checkNewLogin(tx, session); err != nil {
+			return err
+		}
+		return tx.Create(session).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	blacklistAccessTokens(ctx, revoked)
+	if alert != nil {
+		go sendLoginAlert(alert)
+	}
+	return tokens, nil
+}
+
+// RefreshTokens uses up a refresh token and returns the next pair of its family
+func RefreshTokens(ctx context.Context, refreshToken string, device DeviceInfo) (*auth.TokenDetails, error) {
+	claims, err := auth.ParseRefreshToken(refreshToken)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	refreshUUID, _ := claims.TokenID()
+	userID := claims.UserID
+
+	var tokens *auth.TokenDetails
+	var revoked []models.RefreshToken
+	reused := false
+	err = database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ? AND family_id = ?", refreshUUID, userID, claims.SessionID).
+			First(&current).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+		if current.TokenHash != hashToken(refreshToken) || current.RevokedAt != nil || !time.Now().Before(current.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		if current.UsedAt != nil {
+			// Reuse: revoke the family and keep the revocation (commit)
+			reused = true
+			revoked, err = revokeFamilies(tx, []uuid.UUID{current.FamilyID}, models.RefreshRevokeReuse)
+			return err
+		}
+
+		var user models.User
+		if err := tx.Select("id", "role").First(&user, "id = ?", userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				revoked, err = revokeFamilies(tx, []uuid.UUID{current.FamilyID}, models.RefreshRevokeUserRemoved)
+				return err
+			}
+			return err
+		}
+
+		if device.DeviceID == "" && current.DeviceID != nil {
+			device.DeviceID = *current.DeviceID
+		}
+		var next *models.RefreshToken
+		tokens, next, err = issueInFamily(tx, &user, current.FamilyID, device)
+		if err != nil {
+			return err
+		}
+		if err := touchSession(tx, userID, current.FamilyID, device, next.ExpiresAt); err != nil {
+			return err
+		}
+		now := time.Now()
+		return tx.Model(&current).Updates(map[string]interface{}{
+			"used_at":     now,
+			"replaced_by": next.ID,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	blacklistAccessTokens(ctx, revoked)
+	if reused {
+		log.Printf("🚨 Refresh token reuse detected: user_id=%s refresh_uuid=%s, family revoked", userID, refreshUUID)
+		return nil, ErrRefreshTokenReused
+	}
+	if tokens == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	return tokens, nil
+}
+
+// Logout revokes one login (session) of a user
+func Logout(ctx context.Context, userID, sessionID uuid.UUID) error {
+	return revokeSession(ctx, userID, sessionID, models.RefreshRevokeLogout)
+}
+
+func revokeSession(ctx context.Context, userID, sessionID uuid.UUID, reason string) error {
+	var revoked []models.RefreshToken
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var families []uuid.UUID
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND family_id = ?", userID, sessionID).
+			Distinct().Pluck("family_id", &families).Error; err != nil {
+			return err
+		}
+		var err error
+		revoked, err = revokeFamilies(tx, families, reason)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	blacklistAccessTokens(ctx, revoked)
+	return nil
+}
+
+// LogoutAllDevices revokes every login of a user. Returns how many logins were revoked.
+func LogoutAllDevices(ctx context.Context, userID uuid.UUID) (int, error) {
+	var families []uuid.UUID
+	var revoked []models.RefreshToken
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Distinct().Pluck("family_id", &families).Error; err != nil {
+			return err
+		}
+		var err error
+		revoked, err = revokeFamilies(tx, families, models.RefreshRevokeLogoutAll)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	blacklistAccessTokens(ctx, revoked)
+	return len(families), nil
+}
+
+// revokeFamilies revokes every token and the session of the families and
+// returns the revoked rows whose access token hasn't expired yet
+func revokeFamilies(tx *gorm.DB, families []uuid.UUID, reason string) ([]models.RefreshToken, error) {
+	if len(families) == 0 {
+		return nil, nil
+	}
+	now := time.Now()
+	revocation := map[string]interface{}{
+		"revoked_at":    now,
+		"revoke_reason": reason,
+	}
+	if err := tx.Model(&models.RefreshToken{}).
+		Where("family_id IN ? AND revoked_at IS NULL", families).
+		Updates(revocation).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&models.UserSession{}).
+		Where("id IN ? AND revoked_at IS NULL", families).
+		Updates(revocation).Error; err != nil {
+		return nil, err
+	}
+
+	var live []models.RefreshToken
+	err := tx.Select("id", "access_uuid", "access_expires_at").
+		Where("family_id IN ? AND access_expires_at > ?", families, now).
+		Find(&live).Error
+	return live, err
+}
+
+// blacklistAccessTokens caches revoked access tokens in Redis until they
+// expire. A failed write only costs lookups: auth.IsAccessTokenRevoked reads
+// refresh_tokens when Redis has no key.
+func blacklistAccessTokens(ctx context.Context, revoked []models.RefreshToken) {
+	if database.RedisClient == nil || len(revoked) == 0 {
+		return
+	}
+	pipe := database.RedisClient.Pipeline()
+	for _, row := range revoked {
+		ttl := time.Until(row.AccessExpiresAt)
+		if ttl <= 0 {
+			continue
+		}
+		pipe.Set(ctx, auth.RevokedAccessKey(row.AccessUUID), 1, ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("⚠️ Failed to cache revoked access tokens: %v", err)
+	}
+}
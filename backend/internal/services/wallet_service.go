@@ -4,10 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"time"
 
+	"lomi-backend/internal/economy"
+	"lomi-backend/internal/ledger"
 	"lomi-backend/internal/models"
 	"lomi-backend/internal/repositories"
+
+	"github.com/google/uuid"
 )
 
 // WalletService handles wallet business logic
@@ -46,94 +51,6 @@ func (s *WalletService) GetWalletBalance(ctx context.Context, userID string) (*m
 // COIN PURCHASE OPERATIONS
 // ============================================
 
-// PurchaseCoins handles coin purchase
-func (s *WalletService) PurchaseCoins(ctx context.Context, userID string, req *models.PurchaseCoinsRequest) (*models.WalletTransaction, error) {
-	// Validate request
-	if req.Coins <= 0 {
-		return nil, errors.New("coins must be greater than 0")
-	}
-	if req.Amount <= 0 {
-		return nil, errors.New("amount must be greater than 0")
-	}
-
-	// Get or create wallet
-	wallet, err := s.walletRepo.GetOrCreateWallet(ctx, userID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get wallet: %w", err)
-	}
-
-	// Start transaction
-	tx, err := s.walletRepo.BeginTx(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback()
-
-	// Create coin purchase record
-	purchase := &models.CoinPurchase{
-		UserID:           userID,
-		PackageID:        req.PackageID,
-		CoinsPurchased:   req.Coins,
-		AmountPaid:       req.Amount,
-		Currency:         "USD",
-		PaymentMethod:    req.PaymentMethod,
-		PaymentReference: &req.PaymentReference,
-		Status:           models.PurchaseStatusCompleted,
-	}
-
-	err = s.walletRepo.CreateCoinPurchase(ctx, tx, purchase)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create purchase record: %w", err)
-	}
-
-	// Calculate new balance
-	coinsAsBalance := float64(req.Coins) // 1 coin = 1 unit in balance
-	newBalance := wallet.Balance + coinsAsBalance
-
-	// Update wallet balance
-	err = s.walletRepo.UpdateWalletBalance(ctx, tx, wallet.ID, newBalance)
-	if err != nil {
-		return nil, fmt.Errorf("failed to update balance: %w", err)
-	}
-
-	// Increment total earned
-	err = s.walletRepo.IncrementTotalEarned(ctx, tx, wallet.ID, coinsAsBalance)
-	if err != nil {
-		return nil, fmt.Errorf("failed to increment total earned: %w", err)
-	}
-
-	// Create transaction record
-	transaction := &models.WalletTransaction{
-		WalletID:        wallet.ID,
-		UserID:          userID,
-		TransactionType: models.TransactionTypeCredit,
-		Amount:          coinsAsBalance,
-		BalanceBefore:   wallet.Balance,
-		BalanceAfter:    newBalance,
-		Description:     fmt.Sprintf("Purchased %d coins", req.Coins),
-		ReferenceID:     &req.PaymentReference,
-		ReferenceType:   stringPtr("coin_purchase"),
-		Status:          models.TransactionStatusCompleted,
-		Metadata: models.JSONB{
-			"coins":          req.Coins,
-			"amount_paid":    req.Amount,
-			"payment_method": req.PaymentMethod,
-		},
-	}
-
-	err = s.walletRepo.CreateTransaction(ctx, tx, transaction)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create transaction: %w", err)
-	}
-
-	// Commit transaction
-	if err = tx.Commit(); err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
-	return transaction, nil
-}
-
 // GetCoinPackages gets all available coin packages
 func (s *WalletService) GetCoinPackages(ctx context.Context) ([]models.CoinPackage, error) {
 	return s.walletRepo.GetActiveCoinPackages(ctx)
@@ -146,8 +63,13 @@ func (s *WalletService) GetCoinPackages(ctx context.Context) ([]models.CoinPacka
 // RequestWithdrawal creates a withdrawal request
 func (s *WalletService) RequestWithdrawal(ctx context.Context, userID string, req *models.WithdrawRequest) (*models.WithdrawalRequest, error) {
 	// Validate request
-	if req.Amount <= 0 {
-		return nil, errors.New("withdrawal amount must be greater than 0")
+	coins, err := wholeCoins(req.Amount)
+	if err != nil {
+		return nil, err
+	}
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
 	}
 
 	// Get wallet
@@ -156,15 +78,12 @@ func (s *WalletService) RequestWithdrawal(ctx context.Context, userID string, re
 		return nil, fmt.Errorf("failed to get wallet: %w", err)
 	}
 
-	// Check if user has sufficient balance
-	if wallet.Balance < req.Amount {
-		return nil, errors.New("insufficient balance")
+	pricing, err := economy.Current(ctx)
+	if err != nil {
+		return nil, err
 	}
-
-	// Minimum withdrawal amount check (e.g., $10)
-	const minWithdrawal = 10.0
-	if req.Amount < minWithdrawal {
-		return nil, fmt.Errorf("minimum withdrawal amount is %.2f", minWithdrawal)
+	if req.Amount < pricing.Params.MinWithdrawal {
+		return nil, fmt.Errorf("minimum withdrawal amount is %.2f", pricing.Params.MinWithdrawal)
 	}
 
 	// Start transaction
@@ -174,12 +93,19 @@ func (s *WalletService) RequestWithdrawal(ctx context.Context, userID string, re
 	}
 	defer tx.Rollback()
 
-	// Deduct amount from wallet (hold it)
-	newBalance := wallet.Balance - req.Amount
-	err = s.walletRepo.UpdateWalletBalance(ctx, tx, wallet.ID, newBalance)
+	// Hold the amount until the withdrawal is processed. The balance check is
+	// part of the ledger update, so parallel withdrawals can't overdraw.
+	entry, err := ledger.Post(ctx, tx, ledger.Entry{
+		Type:           ledger.EntryWithdrawal,
+		Description:    fmt.Sprintf("Withdrawal request - %s", req.WithdrawalMethod),
+		Metadata:       map[string]interface{}{"withdrawal_method": req.WithdrawalMethod},
+		Lines:          ledger.Transfer(ledger.UserCoins(userUUID), ledger.System(ledger.SystemPayoutsHeld), coins),
+		EconomyVersion: &pricing.Version,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to update balance: %w", err)
+		return nil, walletLedgerError(err)
 	}
+	newBalance := float64(entry.BalanceAfter(ledger.UserCoins(userUUID)))
 
 	// Create withdrawal transaction
 	transaction := &models.WalletTransaction{
@@ -187,10 +113,11 @@ func (s *WalletService) RequestWithdrawal(ctx context.Context, userID string, re
 		UserID:          userID,
 		TransactionType: models.TransactionTypeWithdrawal,
 		Amount:          req.Amount,
-		BalanceBefore:   wallet.Balance,
+		BalanceBefore:   newBalance + req.Amount,
 		BalanceAfter:    newBalance,
 		Description:     fmt.Sprintf("Withdrawal request - %s", req.WithdrawalMethod),
 		Status:          models.TransactionStatusPending,
+		JournalEntryID:  stringPtr(entry.ID.String()),
 		Metadata: models.JSONB{
 			"withdrawal_method": req.WithdrawalMethod,
 			"account_details":   req.AccountDetails,
@@ -258,8 +185,13 @@ func (s *WalletService) GetTransactionHistory(ctx context.Context, userID string
 
 // DebitWallet debits amount from user's wallet (for purchases, gifts, etc.)
 func (s *WalletService) DebitWallet(ctx context.Context, userID string, amount float64, transactionType, description string, metadata models.JSONB) (*models.WalletTransaction, error) {
-	if amount <= 0 {
-		return nil, errors.New("amount must be greater than 0")
+	coins, err := wholeCoins(amount)
+	if err != nil {
+		return nil, err
+	}
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
 	}
 
 	// Get wallet
@@ -268,11 +200,6 @@ func (s *WalletService) DebitWallet(ctx context.Context, userID string, amount f
 		return nil, fmt.Errorf("failed to get wallet: %w", err)
 	}
 
-	// Check balance
-	if wallet.Balance < amount {
-		return nil, errors.New("insufficient balance")
-	}
-
 	// Start transaction
 	tx, err := s.walletRepo.BeginTx(ctx)
 	if err != nil {
@@ -280,14 +207,17 @@ func (s *WalletService) DebitWallet(ctx context.Context, userID string, amount f
 	}
 	defer tx.Rollback()
 
-	// Calculate new balance
-	newBalance := wallet.Balance - amount
-
-	// Update wallet balance
-	err = s.walletRepo.UpdateWalletBalance(ctx, tx, wallet.ID, newBalance)
+	// Fails with insufficient balance instead of going negative
+	entry, err := ledger.Post(ctx, tx, ledger.Entry{
+		Type:        ledger.EntryDebit,
+		Description: description,
+		Metadata:    metadata,
+		Lines:       ledger.Transfer(ledger.UserCoins(userUUID), ledger.System(ledger.SystemSpend), coins),
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to update balance: %w", err)
+		return nil, walletLedgerError(err)
 	}
+	newBalance := float64(entry.BalanceAfter(ledger.UserCoins(userUUID)))
 
 	// Increment total spent
 	err = s.walletRepo.IncrementTotalSpent(ctx, tx, wallet.ID, amount)
@@ -301,10 +231,11 @@ func (s *WalletService) DebitWallet(ctx context.Context, userID string, amount f
 		UserID:          userID,
 		TransactionType: transactionType,
 		Amount:          amount,
-		BalanceBefore:   wallet.Balance,
+		BalanceBefore:   newBalance + amount,
 		BalanceAfter:    newBalance,
 		Description:     description,
 		Status:          models.TransactionStatusCompleted,
+		JournalEntryID:  stringPtr(entry.ID.String()),
 		Metadata:        metadata,
 	}
 
@@ -323,8 +254,13 @@ func (s *WalletService) DebitWallet(ctx context.Context, userID string, amount f
 
 // CreditWallet credits amount to user's wallet (for gifts received, earnings, etc.)
 func (s *WalletService) CreditWallet(ctx context.Context, userID string, amount float64, transactionType, description string, metadata models.JSONB) (*models.WalletTransaction, error) {
-	if amount <= 0 {
-		return nil, errors.New("amount must be greater than 0")
+	coins, err := wholeCoins(amount)
+	if err != nil {
+		return nil, err
+	}
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
 	}
 
 	// Get or create wallet
@@ -340,14 +276,16 @@ func (s *WalletService) CreditWallet(ctx context.Context, userID string, amount
 	}
 	defer tx.Rollback()
 
-	// Calculate new balance
-	newBalance := wallet.Balance + amount
-
-	// Update wallet balance
-	err = s.walletRepo.UpdateWalletBalance(ctx, tx, wallet.ID, newBalance)
+	entry, err := ledger.Post(ctx, tx, ledger.Entry{
+		Type:        ledger.EntryCredit,
+		Description: description,
+		Metadata:    metadata,
+		Lines:       ledger.Transfer(ledger.System(ledger.SystemPlatformCredits), ledger.UserCoins(userUUID), coins),
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to update balance: %w", err)
+		return nil, walletLedgerError(err)
 	}
+	newBalance := float64(entry.BalanceAfter(ledger.UserCoins(userUUID)))
 
 	// Increment total earned
 	err = s.walletRepo.IncrementTotalEarned(ctx, tx, wallet.ID, amount)
@@ -361,10 +299,11 @@ func (s *WalletService) CreditWallet(ctx context.Context, userID string, amount
 		UserID:          userID,
 		TransactionType: transactionType,
 		Amount:          amount,
-		BalanceBefore:   wallet.Balance,
+		BalanceBefore:   newBalance - amount,
 		BalanceAfter:    newBalance,
 		Description:     description,
 		Status:          models.TransactionStatusCompleted,
+		JournalEntryID:  stringPtr(entry.ID.String()),
 		Metadata:        metadata,
 	}
 
@@ -429,6 +368,25 @@ func (s *WalletService) DeletePayoutMethod(ctx context.Context, methodID, userID
 // HELPER FUNCTIONS
 // ============================================
 
+// wholeCoins converts a wallet amount to ledger minor units (1 coin = 1 unit)
+func wholeCoins(amount float64) (int64, error) {
+	if amount <= 0 {
+		return 0, errors.New("amount must be greater than 0")
+	}
+	if amount != math.Trunc(amount) {
+		return 0, errors.New("amount must be a whole number of coins")
+	}
+	return int64(amount), nil
+}
+
+// walletLedgerError maps ledger errors to the messages the wallet API returns
+func walletLedgerError(err error) error {
+	if errors.Is(err, ledger.ErrInsufficientFunds) {
+		return errors.New("insufficient balance")
+	}
+	return fmt.Errorf("failed to post ledger entry: %w", err)
+}
+
 func stringPtr(s string) *string {
 	return &s
 }
@@ -1,51 +0,0 @@
-package utils
-
-import (
-	"time"
-
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
-)
-
-type TokenDetails struct {
-	AccessToken  string
-	RefreshToken string
-	AccessUUID   string
-	RefreshUUID  string
-	AtExpires    int64
-	RtExpires    int64
-}
-
-func CreateToken(userID uuid.UUID, secret string) (*TokenDetails, error) {
-	td := &TokenDetails{}
-	td.AtExpires = time.Now().Add(time.Hour * 24).Unix()     // 24 hours
-	td.RtExpires = time.Now().Add(time.Hour * 24 * 7).Unix() // 7 days
-	td.AccessUUID = uuid.New().String()
-	td.RefreshUUID = uuid.New().String()
-
-	// Creating Access Token
-	atClaims := jwt.MapClaims{}
-	atClaims["authorized"] = true
-	atClaims["access_uuid"] = td.AccessUUID
-	atClaims["user_id"] = userID.String()
-	atClaims["exp"] = td.AtExpires
-	at := jwt.NewWithClaims(jwt.SigningMethodHS256, atClaims)
-	var err error
-	td.AccessToken, err = at.SignedString([]byte(secret))
-	if err != nil {
-		return nil, err
-	}
-
-	// Creating Refresh Token
-	rtClaims := jwt.MapClaims{}
-	rtClaims["refresh_uuid"] = td.RefreshUUID
-	rtClaims["user_id"] = userID.String()
-	rtClaims["exp"] = td.RtExpires
-	rt := jwt.NewWithClaims(jwt.SigningMethodHS256, rtClaims)
-	td.RefreshToken, err = rt.SignedString([]byte(secret))
-	if err != nil {
-		return nil, err
-	}
-
-	return td, nil
-}
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// ==================== PDF ====================
+// A minimal text-only PDF writer for statements and reports: A4 pages,
+// Helvetica / Helvetica-Bold, lines flowing top to bottom with automatic page
+// breaks. Characters outside Latin-1 are printed as '?'.
+
+const (
+	pdfPageWidth  = 595.0 // A4 in points
+	pdfPageHeight = 842.0
+	pdfMargin     = 50.0
+)
+
+// PDF is a document being written
+type PDF struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+// NewPDF starts a document with one empty page
+func NewPDF() *PDF {
+	p := &PDF{}
+	p.newPage()
+	return p
+}
+
+func (p *PDF) newPage() {
+	p.pages = append(p.pages, &bytes.Buffer{})
+	p.y = pdfPageHeight - pdfMargin
+}
+
+// advance moves down by height, starting a new page if it doesn't fit
+func (p *PDF) advance(height float64) {
+	if p.y-height < pdfMargin {
+		p.newPage()
+	}
+	p.y -= height
+}
+
+func (p *PDF) text(x, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(p.pages[len(p.pages)-1], "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, p.y, pdfEscape(s))
+}
+
+// Line writes one line of text at the left margin
+func (p *PDF) Line(s string, size float64, bold bool) {
+	p.advance(size * 1.5)
+	p.text(pdfMargin, size, bold, s)
+}
+
+// Row writes cells on one line; columns are x offsets from the left margin
+func (p *PDF) Row(cells []string, columns []float64, size float64, bold bool) {
+	p.advance(size * 1.5)
+	for i, cell := range cells {
+		if i < len(columns) {
+			p.text(pdfMargin+columns[i], size, bold, cell)
+		}
+	}
+}
+
+// Space leaves an empty gap
+func (p *PDF) Space(height float64) {
+	p.advance(height)
+}
+
+// Rule draws a horizontal line across the page
+func (p *PDF) Rule() {
+	p.advance(6)
+	fmt.Fprintf(p.pages[len(p.pages)-1], "0.5 w %.2f %.2f m %.2f %.2f l S\n",
+		pdfMargin, p.y+3, pdfPageWidth-pdfMargin, p.y+3)
+}
+
+// Bytes renders the document
+func (p *PDF) Bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+
+	// 1: catalog, 2: page tree, 3-4: fonts, then a page and its content per page
+	kids := make([]string, len(p.pages))
+	for i := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range p.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// pdfEscape encodes a string as a Latin-1 PDF literal string body
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r == '\n' || r == '\r' || r == '\t':
+			b.WriteByte(' ')
+		case r < 32 || r > 255:
+			b.WriteByte('?')
+		default:
+			b.WriteByte(byte(r))
+		}
+	}
+	return b.String()
+}
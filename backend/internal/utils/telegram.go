@@ -1,8 +1,10 @@
 package utils
 
 import (
+	"crypto/ed25519"
 	"crypto/hmac"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -24,81 +26,202 @@ type TelegramUser struct {
 	PhotoURL     string `json:"photo_url"`
 }
 
-// ValidateTelegramInitData validates the initData string from Telegram WebApp
-// Following official Telegram Mini Apps SDK pattern
-// expiresIn: maximum age of initData in seconds (e.g., 3600 for 1 hour)
-func ValidateTelegramInitData(initData string, botToken string, expiresIn ...time.Duration) (*TelegramUser, error) {
-	// Parse query string
+// ==================== TELEGRAM INIT DATA ====================
+// Mini App initData is validated one of two ways
+// (https://core.telegram.org/bots/webapps#validating-data-received-via-the-mini-app):
+// - hash: HMAC-SHA256 keyed with the bot token, when we have the token
+// - signature: Ed25519 signed by Telegram, verifiable with only the bot ID
+// Validation errors carry a stable code for clients.
+
+// Telegram's Ed25519 public keys for third-party initData validation
+const (
+	telegramPublicKey     = "e7bf03a2fa4602af4580703d88dda5bb59f32ed8b02a56c187fe7d34caed242d"
+	telegramTestPublicKey = "40055058a4ee38156a06562e52eece92a771bcd8346a8c4615cb7376eddf72ec"
+)
+
+// Error codes of rejected initData
+const (
+	TelegramErrNotConfigured    = "telegram_not_configured"
+	TelegramErrMissing          = "init_data_missing"
+	TelegramErrMalformed        = "init_data_malformed"
+	TelegramErrExpired          = "init_data_expired"
+	TelegramErrAuthDateInvalid  = "auth_date_invalid"
+	TelegramErrSignatureMissing = "signature_missing"
+	TelegramErrSignatureInvalid = "signature_invalid"
+	TelegramErrUserMissing      = "user_missing"
+	TelegramErrReplayed         = "init_data_replayed"
+)
+
+// telegramClockSkew is how far in the future auth_date may be
+const telegramClockSkew = time.Minute
+
+// TelegramInitDataError is a rejected initData
+type TelegramInitDataError struct {
+	Code    string
+	Message string
+}
+
+func (e *TelegramInitDataError) Error() string {
+	return e.Message
+}
+
+func telegramError(code, format string, args ...interface{}) error {
+	return &TelegramInitDataError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// TelegramValidation configures initData validation. With a bot token the
+// hash is checked; without one the Ed25519 signature is checked against BotID.
+type TelegramValidation struct {
+	BotToken        string
+	BotID           int64 // Defaults to the ID in BotToken
+	TestEnvironment bool  // Use Telegram's test environment key
+	MaxAge          time.Duration
+}
+
+// TelegramInitData is validated initData
+type TelegramInitData struct {
+	User     TelegramUser
+	AuthDate time.Time
+	QueryID  string
+	// Proof is the verified hash or signature. It identifies the initData
+	// however its query string is ordered or encoded.
+	Proof []byte
+}
+
+// ValidateTelegramInitData checks the hash or signature and the age of Mini
+// App initData and returns its user
+func ValidateTelegramInitData(initData string, opts TelegramValidation) (*TelegramInitData, error) {
+	if opts.BotID == 0 && opts.BotToken != "" {
+		if id, _, ok := strings.Cut(opts.BotToken, ":"); ok {
+			opts.BotID, _ = strconv.ParseInt(id, 10, 64)
+		}
+	}
+	if opts.BotToken == "" && opts.BotID == 0 {
+		return nil, telegramError(TelegramErrNotConfigured, "Telegram bot is not configured")
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = time.Hour
+	}
+	if strings.TrimSpace(initData) == "" {
+		return nil, telegramError(TelegramErrMissing, "initData is missing")
+	}
+
 	values, err := url.ParseQuery(initData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse initData: %w", err)
+		return nil, telegramError(TelegramErrMalformed, "initData is not a valid query string")
 	}
 
-	// Check expiration (if auth_date is present)
-	if authDateStr := values.Get("auth_date"); authDateStr != "" {
-		authDate, err := strconv.ParseInt(authDateStr, 10, 64)
-		if err == nil {
-			authTime := time.Unix(authDate, 0)
-			maxAge := time.Hour // Default: 1 hour
-			if len(expiresIn) > 0 {
-				maxAge = expiresIn[0]
-			}
+	authDateUnix, err := strconv.ParseInt(values.Get("auth_date"), 10, 64)
+	if err != nil {
+		return nil, telegramError(TelegramErrMalformed, "auth_date is missing or invalid")
+	}
+	authDate := time.Unix(authDateUnix, 0)
+	if time.Until(authDate) > telegramClockSkew {
+		return nil, telegramError(TelegramErrAuthDateInvalid, "auth_date is in the future")
+	}
+	if time.Since(authDate) > opts.MaxAge {
+		return nil, telegramError(TelegramErrExpired, "initData expired (older than %v)", opts.MaxAge)
+	}
 
-			if time.Since(authTime) > maxAge {
-				return nil, fmt.Errorf("initData expired (older than %v)", maxAge)
-			}
+	hash := values.Get("hash")
+	signature := values.Get("signature")
+	var proof []byte
+	switch {
+	case opts.BotToken != "":
+		if hash == "" {
+			return nil, telegramError(TelegramErrSignatureMissing, "hash is missing")
+		}
+		if proof = validTelegramHash(values, hash, opts.BotToken); proof == nil {
+			return nil, telegramError(TelegramErrSignatureInvalid, "hash verification failed")
+		}
+	default:
+		if signature == "" {
+			return nil, telegramError(TelegramErrSignatureMissing, "signature is missing")
+		}
+		if proof = validTelegramSignature(values, signature, opts.BotID, opts.TestEnvironment); proof == nil {
+			return nil, telegramError(TelegramErrSignatureInvalid, "signature verification failed")
 		}
 	}
 
-	// Extract hash
-	hash := values.Get("hash")
-	if hash == "" {
-		return nil, fmt.Errorf("hash is missing")
+	userJSON := values.Get("user")
+	if userJSON == "" {
+		return nil, telegramError(TelegramErrUserMissing, "user data is missing")
+	}
+	var user TelegramUser
+	if err := json.Unmarshal([]byte(userJSON), &user); err != nil {
+		return nil, telegramError(TelegramErrMalformed, "user data is invalid")
+	}
+	if user.ID == 0 {
+		return nil, telegramError(TelegramErrUserMissing, "user data is missing")
 	}
-	values.Del("hash")
 
-	// Sort keys alphabetically
-	var keys []string
+	return &TelegramInitData{
+		User:     user,
+		AuthDate: authDate,
+		QueryID:  values.Get("query_id"),
+		Proof:    proof,
+	}, nil
+}
+
+// telegramDataCheckString is the sorted "key=value" lines of every field
+// except the excluded ones
+func telegramDataCheckString(values url.Values, exclude ...string) string {
+	keys := make([]string, 0, len(values))
 	for k := range values {
-		keys = append(keys, k)
+		skip := false
+		for _, e := range exclude {
+			if k == e {
+				skip = true
+			}
+		}
+		if !skip {
+			keys = append(keys, k)
+		}
 	}
 	sort.Strings(keys)
 
-	// Create data check string (format: key=value\nkey=value)
-	var dataCheckArr []string
+	lines := make([]string, 0, len(keys))
 	for _, k := range keys {
-		dataCheckArr = append(dataCheckArr, fmt.Sprintf("%s=%s", k, values.Get(k)))
+		lines = append(lines, k+"="+values.Get(k))
 	}
-	dataCheckString := strings.Join(dataCheckArr, "\n")
+	return strings.Join(lines, "\n")
+}
 
-	// Compute HMAC-SHA256
-	// Secret key = HMAC-SHA256("WebAppData", botToken)
+// validTelegramHash checks hash = HMAC-SHA256(data check string) keyed with
+// HMAC-SHA256("WebAppData", bot token) and returns it, or nil when it doesn't
+// match. The signature field is covered too.
+func validTelegramHash(values url.Values, hash, botToken string) []byte {
 	secretKey := hmac.New(sha256.New, []byte("WebAppData"))
 	secretKey.Write([]byte(botToken))
-	secret := secretKey.Sum(nil)
-
-	// Compute hash
-	h := hmac.New(sha256.New, secret)
-	h.Write([]byte(dataCheckString))
-	computedHash := hex.EncodeToString(h.Sum(nil))
 
-	// Compare hashes
-	if computedHash != hash {
-		return nil, fmt.Errorf("invalid hash: signature verification failed")
+	h := hmac.New(sha256.New, secretKey.Sum(nil))
+	h.Write([]byte(telegramDataCheckString(values, "hash")))
+	expected, err := hex.DecodeString(hash)
+	if err != nil || !hmac.Equal(h.Sum(nil), expected) {
+		return nil
 	}
+	return expected
+}
 
-	// Parse user data
-	userJSON := values.Get("user")
-	if userJSON == "" {
-		return nil, fmt.Errorf("user data missing")
+// validTelegramSignature checks the Ed25519 signature of
+// "<bot_id>:WebAppData\n" + the data check string without hash and signature
+// and returns it, or nil when it doesn't verify
+func validTelegramSignature(values url.Values, signature string, botID int64, testEnvironment bool) []byte {
+	sig, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(signature, "="))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return nil
 	}
-
-	var user TelegramUser
-	if err := json.Unmarshal([]byte(userJSON), &user); err != nil {
-		return nil, fmt.Errorf("failed to parse user data: %w", err)
+	keyHex := telegramPublicKey
+	if testEnvironment {
+		keyHex = telegramTestPublicKey
 	}
+	key, _ := hex.DecodeString(keyHex)
 
-	return &user, nil
+	message := fmt.Sprintf("%d:WebAppData\n%s", botID, telegramDataCheckString(values, "hash", "signature"))
+	if !ed25519.Verify(ed25519.PublicKey(key), []byte(message), sig) {
+		return nil
+	}
+	return sig
 }
 
 // TelegramIDValue safely dereferences an optional Telegram ID pointer.
@@ -0,0 +1,109 @@
+#!/bin/bash
+
+# Payment Sandbox End-to-End Test
+# Buys coins through the sandbox gateway (PAYMENT_SANDBOX=true) and checks the
+# signed callback credits them exactly once.
+#
+# Usage:
+#   TOKEN=<jwt> ./test-payments-sandbox.sh
+
+set -e
+
+BASE_URL="${BASE_URL:-http://localhost:8080}"
+API_URL="$BASE_URL/api/v1"
+PAYMENT_METHOD="${PAYMENT_METHOD:-telebirr}"
+COINS="${COINS:-250}"
+
+# Colors
+GREEN='\033[0;32m'
+RED='\033[0;31m'
+NC='\033[0m' # No Color
+
+PASSED=0
+FAILED=0
+
+if [ -z "$TOKEN" ]; then
+    echo "❌ TOKEN is required"
+    exit 1
+fi
+
+check() {
+    local name=$1
+    local expected=$2
+    local actual=$3
+
+    if [ "$expected" = "$actual" ]; then
+        echo -e "${GREEN}✅ $name${NC} ($actual)"
+        ((PASSED++)) || true
+    else
+        echo -e "${RED}❌ $name: expected $expected, got $actual${NC}"
+        ((FAILED++)) || true
+    fi
+}
+
+balance() {
+    curl -s "$API_URL/coins/balance" -H "Authorization: Bearer $TOKEN" | jq -r '.coin_balance'
+}
+
+settle() {
+    # $1 = checkout URL, $2 = action, $3 = optional event_id to replay
+    curl -s -X POST "$1" -H "Accept: application/json" \
+        --data-urlencode "action=$2" --data-urlencode "event_id=$3"
+}
+
+echo "🧪 Payment Sandbox Test ($PAYMENT_METHOD)"
+echo "================================"
+
+BEFORE=$(balance)
+echo "Balance before: $BEFORE"
+
+# 1. Paid checkout credits the coins
+PURCHASE=$(curl -s -X POST "$API_URL/coins/purchase" \
+    -H "Authorization: Bearer $TOKEN" -H "Content-Type: application/json" \
+    -d "{\"coin_amount\":$COINS,\"payment_method\":\"$PAYMENT_METHOD\"}")
+PAYMENT_URL=$(echo "$PURCHASE" | jq -r '.payment_url')
+if [[ "$PAYMENT_URL" != *"/payments/sandbox/checkout"* ]]; then
+    echo -e "${RED}❌ Not a sandbox checkout: $PURCHASE${NC}"
+    echo "   Is PAYMENT_SANDBOX=true?"
+    exit 1
+fi
+
+check "Checkout page loads" 200 "$(curl -s -o /dev/null -w '%{http_code}' "$PAYMENT_URL")"
+
+RESULT=$(settle "$PAYMENT_URL" pay)
+EVENT_ID=$(echo "$RESULT" | jq -r '.callback.event_id')
+check "Callback accepted" 200 "$(echo "$RESULT" | jq -r '.callback.status_code')"
+check "Coins credited" $((BEFORE + COINS)) "$(balance)"
+
+# 2. Replaying the same provider event changes nothing
+RESULT=$(settle "$PAYMENT_URL" pay "$EVENT_ID")
+check "Replay acknowledged" 200 "$(echo "$RESULT" | jq -r '.callback.status_code')"
+check "Replay credits nothing" $((BEFORE + COINS)) "$(balance)"
+
+# 3. A fresh success event for the same purchase changes nothing either
+settle "$PAYMENT_URL" pay >/dev/null
+check "Second event credits nothing" $((BEFORE + COINS)) "$(balance)"
+
+# 4. Declined checkout credits nothing
+PURCHASE=$(curl -s -X POST "$API_URL/coins/purchase" \
+    -H "Authorization: Bearer $TOKEN" -H "Content-Type: application/json" \
+    -d "{\"coin_amount\":$COINS,\"payment_method\":\"$PAYMENT_METHOD\"}")
+DECLINED_URL=$(echo "$PURCHASE" | jq -r '.payment_url')
+settle "$DECLINED_URL" fail >/dev/null
+check "Declined payment credits nothing" $((BEFORE + COINS)) "$(balance)"
+
+# 5. Tampered checkout link and unsigned callbacks are refused
+TAMPERED_URL=$(echo "$DECLINED_URL" | sed -E 's/amount=[0-9]+/amount=1/')
+check "Tampered checkout refused" 400 "$(curl -s -o /dev/null -w '%{http_code}' "$TAMPERED_URL")"
+
+TRANSACTION_ID=$(echo "$PURCHASE" | jq -r '.transaction_id')
+check "Unsigned callback refused" 401 "$(curl -s -o /dev/null -w '%{http_code}' -X POST \
+    "$API_URL/payments/webhooks/$PAYMENT_METHOD" -H "Content-Type: application/json" \
+    -d "{\"event_id\":\"forged\",\"transaction_id\":\"$TRANSACTION_ID\",\"amount\":\"25.00\",\"currency\":\"ETB\",\"status\":\"success\"}")"
+check "Forged callback credits nothing" $((BEFORE + COINS)) "$(balance)"
+
+echo ""
+echo "================================"
+echo -e "Passed: ${GREEN}$PASSED${NC}  Failed: ${RED}$FAILED${NC}"
+
+[ "$FAILED" -eq 0 ]
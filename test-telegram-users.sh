@@ -15,29 +15,17 @@ if [ -f ".env.production" ]; then
     set +a
 fi
 
-# Step 1: Authenticate to get token
+# Step 1: Admin token (the user list is a debug tool: APP_ENV=development and
+# an admin token with the debug permission)
 echo "Step 1: Authenticating..."
-read -p "Enter Telegram initData (or press Enter to use existing token): " INIT_DATA
-
-if [ -n "$INIT_DATA" ]; then
-    echo "Authenticating with initData..."
-    AUTH_RESPONSE=$(curl -s -X POST http://localhost/api/v1/auth/telegram \
-        -H "Authorization: tma $INIT_DATA")
-    
-    TOKEN=$(echo $AUTH_RESPONSE | jq -r '.access_token' 2>/dev/null || echo "")
-    if [ -z "$TOKEN" ] || [ "$TOKEN" = "null" ]; then
-        echo "❌ Authentication failed"
-        echo "Response: $AUTH_RESPONSE"
-        exit 1
-    fi
-    echo "✅ Authenticated"
-else
-    read -p "Enter JWT token: " TOKEN
-    if [ -z "$TOKEN" ]; then
-        echo "❌ Need either initData or JWT token"
-        exit 1
-    fi
+if [ -z "$ADMIN_TOKEN" ]; then
+    read -p "Enter admin token (debug permission): " ADMIN_TOKEN
+fi
+if [ -z "$ADMIN_TOKEN" ]; then
+    echo "❌ Need an admin token"
+    exit 1
 fi
+TOKEN=$ADMIN_TOKEN
 
 echo "Token: ${TOKEN:0:30}..."
 echo ""
@@ -46,7 +34,7 @@ echo ""
 echo "Step 2: Fetching all Telegram users..."
 echo ""
 
-USERS_RESPONSE=$(curl -s -X GET "http://localhost/api/v1/users?telegram_only=true" \
+USERS_RESPONSE=$(curl -s -X GET "http://localhost/api/v1/debug/users?telegram_only=true" \
     -H "Authorization: Bearer $TOKEN")
 
 echo "Response:"
@@ -0,0 +1,152 @@
+#!/bin/bash
+
+# Wallet Concurrency Test
+# Hammers gift sends and withdrawals for the same user in parallel and checks
+# that no balance goes negative and that every coin is accounted for.
+#
+# Usage:
+#   SENDER_TOKEN=<jwt> RECEIVER_ID=<uuid> ./test-wallet-concurrency.sh
+#
+# The sender needs some coins (e.g. a few thousand LC). Run against a dev
+# database only: it really moves coins.
+#
+# The same race without the HTTP layer (ledger.Post against the payout hold)
+# is a Go test: TEST_DATABASE_URL=... go test ./internal/services -run Payout
+
+set -e
+
+BASE_URL="${BASE_URL:-http://localhost:8080}"
+API_URL="$BASE_URL/api/v1"
+PSQL="${PSQL:-docker exec -i lomi_postgres psql -U lomi -d lomi_db -tA}"
+GIFT_TYPE="${GIFT_TYPE:-rose}"
+GIFTS="${GIFTS:-50}"
+WITHDRAWALS="${WITHDRAWALS:-20}"
+WITHDRAW_AMOUNT="${WITHDRAW_AMOUNT:-10}"
+PARALLEL="${PARALLEL:-20}"
+
+# Colors
+GREEN='\033[0;32m'
+RED='\033[0;31m'
+YELLOW='\033[1;33m'
+NC='\033[0m' # No Color
+
+PASSED=0
+FAILED=0
+
+if [ -z "$SENDER_TOKEN" ] || [ -z "$RECEIVER_ID" ]; then
+    echo "❌ SENDER_TOKEN and RECEIVER_ID are required"
+    exit 1
+fi
+for bin in curl jq xargs; do
+    command -v $bin >/dev/null || { echo "❌ $bin is required"; exit 1; }
+done
+
+sql() {
+    echo "$1" | $PSQL
+}
+
+check() {
+    local name=$1
+    local expected=$2
+    local actual=$3
+
+    if [ "$expected" = "$actual" ]; then
+        echo -e "${GREEN}✅ $name${NC} ($actual)"
+        ((PASSED++)) || true
+    else
+        echo -e "${RED}❌ $name: expected $expected, got $actual${NC}"
+        ((FAILED++)) || true
+    fi
+}
+
+SENDER_ID=$(curl -s "$API_URL/users/me" -H "Authorization: Bearer $SENDER_TOKEN" | jq -r '.id // .ID // empty')
+if [ -z "$SENDER_ID" ]; then
+    echo "❌ Could not resolve sender from token"
+    exit 1
+fi
+
+PRICE=$(sql "SELECT coin_price FROM gifts WHERE slug = '$GIFT_TYPE'")
+if [ -z "$PRICE" ]; then
+    echo "❌ Unknown gift: $GIFT_TYPE"
+    exit 1
+fi
+
+# Make sure the sender has a wallet row (withdrawals need one)
+curl -s "$API_URL/wallet/v2/balance" -H "Authorization: Bearer $SENDER_TOKEN" >/dev/null
+
+ledger_balance() {
+    local balance
+    balance=$(sql "SELECT balance FROM ledger_accounts WHERE code = '$1'")
+    echo "${balance:-0}"
+}
+
+SENDER_BEFORE=$(ledger_balance "user:$SENDER_ID:LC")
+RECEIVER_BEFORE=$(ledger_balance "user:$RECEIVER_ID:LC")
+
+echo "🧪 Wallet Concurrency Test"
+echo "================================"
+echo "Sender:   $SENDER_ID ($SENDER_BEFORE LC)"
+echo "Receiver: $RECEIVER_ID ($RECEIVER_BEFORE LC)"
+echo "Load:     $GIFTS x $GIFT_TYPE ($PRICE LC) + $WITHDRAWALS x $WITHDRAW_AMOUNT LC withdrawals, $PARALLEL in parallel"
+echo ""
+
+RESULTS=$(mktemp -d)
+trap 'rm -rf "$RESULTS"' EXIT
+
+send_gift() {
+    curl -s -o /dev/null -w "%{http_code}\n" -X POST "$API_URL/gifts/send" \
+        -H "Authorization: Bearer $SENDER_TOKEN" \
+        -H "Content-Type: application/json" \
+        -d "{\"receiver_id\":\"$RECEIVER_ID\",\"gift_type\":\"$GIFT_TYPE\"}"
+}
+
+withdraw() {
+    curl -s -o /dev/null -w "%{http_code}\n" -X POST "$API_URL/wallet/withdraw" \
+        -H "Authorization: Bearer $SENDER_TOKEN" \
+        -H "Content-Type: application/json" \
+        -d "{\"amount\":$WITHDRAW_AMOUNT,\"withdrawal_method\":\"telebirr\",\"account_details\":{\"phone\":\"+251900000000\"}}"
+}
+
+export -f send_gift withdraw
+export API_URL SENDER_TOKEN RECEIVER_ID GIFT_TYPE WITHDRAW_AMOUNT
+
+echo -e "${YELLOW}Firing requests...${NC}"
+seq "$GIFTS" | xargs -P "$PARALLEL" -I{} bash -c send_gift > "$RESULTS/gifts" &
+seq "$WITHDRAWALS" | xargs -P "$PARALLEL" -I{} bash -c withdraw > "$RESULTS/withdrawals" &
+wait
+
+GIFTS_OK=$(grep -c '^201$' "$RESULTS/gifts" || true)
+GIFTS_REJECTED=$(grep -c '^400$' "$RESULTS/gifts" || true)
+WITHDRAWALS_OK=$(grep -c '^200$' "$RESULTS/withdrawals" || true)
+WITHDRAWALS_REJECTED=$(grep -c '^400$' "$RESULTS/withdrawals" || true)
+ERRORS=$(grep -vc '^\(200\|201\|400\)$' "$RESULTS/gifts" "$RESULTS/withdrawals" | awk -F: '{s+=$2} END {print s+0}')
+
+echo "Gifts:       $GIFTS_OK sent, $GIFTS_REJECTED rejected"
+echo "Withdrawals: $WITHDRAWALS_OK accepted, $WITHDRAWALS_REJECTED rejected"
+echo ""
+
+SENDER_AFTER=$(ledger_balance "user:$SENDER_ID:LC")
+RECEIVER_AFTER=$(ledger_balance "user:$RECEIVER_ID:LC")
+
+echo "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━"
+echo "Checks"
+echo "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━"
+check "No server errors" 0 "$ERRORS"
+check "Sender balance = before - gifts - withdrawals" \
+    $((SENDER_BEFORE - GIFTS_OK * PRICE - WITHDRAWALS_OK * WITHDRAW_AMOUNT)) "$SENDER_AFTER"
+check "Receiver balance = before + gifts" $((RECEIVER_BEFORE + GIFTS_OK * PRICE)) "$RECEIVER_AFTER"
+# Accounts left in debt by a refunded purchase are the only negative user balances allowed
+check "No negative ledger accounts" 0 "$(sql "SELECT COUNT(*) FROM ledger_accounts WHERE NOT allow_negative AND NOT in_debt AND balance < 0")"
+check "No negative coin balances" 0 "$(sql "SELECT COUNT(*) FROM users u WHERE u.gift_balance < 0 OR (u.coin_balance < 0 AND NOT EXISTS (SELECT 1 FROM ledger_accounts la WHERE la.user_id = u.id AND la.in_debt))")"
+check "No negative wallets" 0 "$(sql "SELECT COUNT(*) FROM wallets w WHERE w.balance < 0 AND NOT EXISTS (SELECT 1 FROM ledger_accounts la WHERE la.user_id = w.user_id AND la.in_debt)")"
+check "Ledger sums to zero" 0 "$(sql "SELECT COALESCE(SUM(balance), 0) FROM ledger_accounts")"
+check "Account balances match postings" 0 "$(sql "SELECT COUNT(*) FROM (SELECT a.id FROM ledger_accounts a LEFT JOIN postings p ON p.account_id = a.id GROUP BY a.id, a.balance HAVING a.balance <> COALESCE(SUM(p.amount), 0)) m")"
+check "Sender projections match ledger" "$SENDER_AFTER|$SENDER_AFTER" \
+    "$(sql "SELECT u.coin_balance || '|' || COALESCE(w.balance::BIGINT, u.coin_balance) FROM users u LEFT JOIN wallets w ON w.user_id = u.id WHERE u.id = '$SENDER_ID'")"
+check "Receiver projection matches ledger" "$RECEIVER_AFTER" "$(sql "SELECT coin_balance FROM users WHERE id = '$RECEIVER_ID'")"
+
+echo ""
+echo "================================"
+echo -e "Passed: ${GREEN}$PASSED${NC}  Failed: ${RED}$FAILED${NC}"
+
+[ "$FAILED" -eq 0 ]